@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	// The timezone database is embedded, the image may not have one
+	_ "time/tzdata"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/filesystem"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/memory"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/postgres"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/postgres/repositories"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/config"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/layout"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/upload"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+// Exit codes, 2 is also used by the flag package on invalid flags
+const (
+	exitOK       = 0
+	exitRejected = 1
+	exitUsage    = 2
+)
+
+// ingest loads users data files straight from disk, the same way uploads are loaded, each
+// file kept as an upload job so it is listed with the uploads and can be rolled back.
+//
+// Usage: ingest [flags] <file or glob>...
+//
+// It exits with a non-zero code when a file fails or any line is rejected
+func main() {
+	os.Exit(run())
+}
+
+// run loads the files and returns the exit code, so the deferred calls run before exiting
+func run() int {
+	// Config
+	if err := config.LoadEnvs(); err != nil {
+		log.Fatalf("Failed to load environment variables. Details: %s", err.Error())
+	}
+
+	dryRun := flag.Bool("dry-run", false, "validate the files and preview their writes without writing anything")
+	workers := flag.Int("workers", config.Env.IngestWorkers, "number of concurrent writers per file")
+	layoutName := flag.String("layout", layout.DefaultName, "record layout of fixed-width files")
+	format := flag.String("format", string(user.FormatAuto), "file format: auto, fixed-width, csv, ndjson or json")
+	encoding := flag.String("encoding", string(user.EncodingUTF8), "file encoding: utf-8, iso-8859-1 or windows-1252")
+	mode := flag.String("mode", string(user.ModeFull), "load mode: full, delta for files whose records carry an operation or snapshot")
+	startDate := flag.String("start-date", "", "first day of the orders of snapshot files, like 2021-03-01")
+	endDate := flag.String("end-date", "", "last day of the orders of snapshot files, included")
+	uploadedBy := flag.String("uploaded-by", "ingest", "who the jobs of the files are recorded as sent by")
+	force := flag.Bool("force", false, "load the files even when they were already imported")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <file or glob>...\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	paths, err := expandPaths(flag.Args())
+	if err != nil {
+		log.Println(err)
+		flag.Usage()
+		return exitUsage
+	}
+
+	// Each file of a snapshot would remove the orders of the others
+	if user.Mode(strings.ToLower(*mode)) == user.ModeSnapshot && len(paths) > 1 {
+		log.Println(errors.ErrUploadSnapshotFiles)
+		return exitUsage
+	}
+
+	// Postgres
+	db, err := postgres.New(config.PostgresDSN())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer postgres.Close(db)
+
+	// Layouts
+	layouts := []*entities.RecordLayout{layout.Default()}
+	if config.Env.LayoutsDir != "" {
+		loaded, err := filesystem.LoadLayouts(config.Env.LayoutsDir)
+		if err != nil {
+			log.Fatalf("Failed to load record layouts. Details: %s", err.Error())
+		}
+		layouts = append(layouts, loaded...)
+	}
+
+	policies, err := config.ConflictPolicies()
+	if err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+
+	location, err := config.BusinessLocation()
+	if err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+
+	// Services
+	uow := postgres.NewUnitOfWork(db)
+	us := services.NewUserService(
+		repositories.NewUserRepository(db),
+		memory.NewLayoutRepository(layouts...),
+		uow,
+		nil,
+		user.LoadConfig{
+			ChunkSize:        config.Env.IngestChunkSize,
+			BulkThreshold:    config.Env.IngestBulkThreshold,
+			MaxLineSize:      config.Env.IngestMaxLineSize,
+			DryRunMaxRecords: config.Env.IngestDryRunMaxRecords,
+			Workers:          *workers,
+			ConflictPolicies: policies,
+			Location:         location,
+		},
+	)
+
+	// Files are imported by the calling goroutine, the workers of the service stay idle
+	ups := services.NewUploadService(repositories.NewIngestionBatchRepository(db), us, uow, nil, 1, 0, os.TempDir())
+	defer ups.Shutdown(context.Background())
+
+	opts := upload.SubmitOptions{
+		LoadOptions: user.LoadOptions{
+			Layout:        *layoutName,
+			Format:        user.Format(*format),
+			Encoding:      user.Encoding(strings.ToLower(*encoding)),
+			Mode:          user.Mode(strings.ToLower(*mode)),
+			DryRun:        *dryRun,
+			SnapshotStart: *startDate,
+			SnapshotEnd:   *endDate,
+		},
+		UploadedBy: *uploadedBy,
+		Force:      *force,
+	}
+	if err := us.ValidateLoadOptions(opts.LoadOptions); err != nil {
+		log.Println(err)
+		return exitUsage
+	}
+
+	// An interrupted load rolls back what was not committed yet
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	failed := false
+	for _, path := range paths {
+		job, result, err := loadFile(ctx, ups, path, opts)
+		printSummary(os.Stdout, path, job, result, err)
+
+		if err != nil || result.RejectedLines > 0 {
+			failed = true
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if failed {
+		return exitRejected
+	}
+
+	return exitOK
+}
+
+// expandPaths resolves the globs in args, keeping plain paths as they are so a
+// missing file is reported when it is opened
+func expandPaths(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("at least one file or glob is required")
+	}
+
+	paths := make([]string, 0, len(args))
+	for _, arg := range args {
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", arg, err)
+		}
+
+		if len(matches) == 0 {
+			paths = append(paths, arg)
+			continue
+		}
+		paths = append(paths, matches...)
+	}
+
+	return paths, nil
+}
+
+// loadFile imports the file as a job, returning it, or the job that imported the file when
+// it already was
+func loadFile(
+	ctx context.Context,
+	ups upload.Service,
+	path string,
+	opts upload.SubmitOptions,
+) (*entities.UploadJob, *user.UserFileResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, new(user.UserFileResult), err
+	}
+	defer file.Close()
+
+	job, result, err := ups.Import(ctx, path, file, opts)
+	if err == errors.ErrUploadAlreadyImported {
+		return nil, &user.UserFileResult{DryRun: opts.DryRun}, fmt.Errorf(
+			"file was already imported or is being imported by job %s, run with -force to load it again",
+			job.ID,
+		)
+	}
+	if result == nil {
+		result = &user.UserFileResult{DryRun: opts.DryRun}
+	}
+
+	return job, result, err
+}
+
+// printSummary writes the job, line counts, writes, order totals, rejections and conflicts of a file
+func printSummary(w io.Writer, path string, job *entities.UploadJob, result *user.UserFileResult, err error) {
+	mode := ""
+	if result.DryRun {
+		mode = " (dry run)"
+	}
+
+	fmt.Fprintf(w, "%s%s\n", path, mode)
+	if job != nil {
+		fmt.Fprintf(w, "  job: %s\n", job.ID)
+	}
+	if err != nil {
+		fmt.Fprintf(w, "  error: %s\n", err.Error())
+	}
+
+	fmt.Fprintf(
+		w,
+		"  format: %s, lines: %d processed, %d accepted, %d rejected, %d skipped\n",
+		result.Format,
+		result.ProcessedLines,
+		result.AcceptedLines,
+		result.RejectedLines,
+		result.SkippedLines,
+	)
+
+	tables := []struct {
+		name   string
+		counts entities.WriteCounts
+	}{
+		{name: "users", counts: result.Writes.Users},
+		{name: "products", counts: result.Writes.Products},
+		{name: "orders", counts: result.Writes.Orders},
+		{name: "order products", counts: result.Writes.OrderProducts},
+	}
+	for _, table := range tables {
+		deleted := ""
+		if table.counts.Deleted > 0 {
+			deleted = fmt.Sprintf(", %d deleted", table.counts.Deleted)
+		}
+
+		fmt.Fprintf(
+			w,
+			"  %s: %d inserted, %d updated, %d unchanged%s\n",
+			table.name,
+			table.counts.Inserted,
+			table.counts.Updated,
+			table.counts.Unchanged,
+			deleted,
+		)
+	}
+
+	for _, total := range result.OrderTotals {
+		removed := ""
+		if total.Removed {
+			removed = ", removed"
+		}
+
+		fmt.Fprintf(w, "  order %d total: %s -> %s%s\n", total.OrderID, total.Previous, total.Current, removed)
+	}
+
+	for _, rejection := range result.Rejections {
+		fmt.Fprintf(w, "  rejected line %d, %s %q: %s\n", rejection.Line, rejection.Field, rejection.Value, rejection.Reason)
+	}
+
+	for _, conflict := range result.Conflicts {
+		fmt.Fprintf(
+			w,
+			"  conflict at line %d, %s %s -> %s, %s: %s\n",
+			conflict.Line,
+			conflict.Field,
+			conflict.Existing,
+			conflict.Incoming,
+			conflict.Policy,
+			conflict.Reason,
+		)
+	}
+}
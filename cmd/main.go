@@ -2,19 +2,25 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	// The timezone database is embedded, the image may not have one
+	_ "time/tzdata"
 
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/controllers"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/filesystem"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/memory"
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/postgres"
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/postgres/repositories"
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/config"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/layout"
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
 )
 
 func main() {
@@ -24,32 +30,104 @@ func main() {
 	}
 
 	// Postgres
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		config.Env.PostgresUser,
-		config.Env.PostgresPassword,
-		config.Env.PostgresHost,
-		config.Env.PostgresPort,
-		config.Env.PostgresDb,
-	)
-	db, err := postgres.New(dsn)
+	db, err := postgres.New(config.PostgresDSN())
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer postgres.Close(db)
 
+	// Layouts
+	layouts := []*entities.RecordLayout{layout.Default()}
+	if config.Env.LayoutsDir != "" {
+		loaded, err := filesystem.LoadLayouts(config.Env.LayoutsDir)
+		if err != nil {
+			log.Fatalf("Failed to load record layouts. Details: %s", err.Error())
+		}
+		layouts = append(layouts, loaded...)
+	}
+
+	// Conflict policies
+	policies, err := config.ConflictPolicies()
+	if err != nil {
+		log.Fatalf("Failed to load conflict policies. Details: %s", err.Error())
+	}
+
+	// Business timezone
+	location, err := config.BusinessLocation()
+	if err != nil {
+		log.Fatalf("Failed to load business timezone. Details: %s", err.Error())
+	}
+
 	// Repositories
-	pr := repositories.NewProductRepository(db)
 	or := repositories.NewOrderRepository(db)
 	ur := repositories.NewUserRepository(db)
 	opr := repositories.NewOrderProductRepository(db)
+	ibr := repositories.NewIngestionBatchRepository(db)
+	cr := repositories.NewConflictRepository(db)
+	lr := memory.NewLayoutRepository(layouts...)
+	uow := postgres.NewUnitOfWork(db)
+	pb := memory.NewProgressBroadcaster()
 
 	// Services
-	us := services.NewUserService(ur, or, pr, opr)
+	us := services.NewUserService(ur, lr, uow, pb, user.LoadConfig{
+		ChunkSize:        config.Env.IngestChunkSize,
+		BulkThreshold:    config.Env.IngestBulkThreshold,
+		MaxLineSize:      config.Env.IngestMaxLineSize,
+		DryRunMaxRecords: config.Env.IngestDryRunMaxRecords,
+		Workers:          config.Env.IngestWorkers,
+		ConflictPolicies: policies,
+		Location:         location,
+	})
 	ors := services.NewOrderService(or, opr, ur)
+	cs := services.NewConflictService(cr)
+	ups := services.NewUploadService(
+		ibr,
+		us,
+		uow,
+		pb,
+		config.Env.UploadWorkers,
+		config.Env.UploadQueueSize,
+		config.Env.UploadSpoolDir,
+	)
+
+	// The jobs a stopped server left unfinished are failed before any job of this one is queued
+	failed, err := ups.FailInterruptedJobs()
+	if err != nil {
+		log.Fatalf("Failed to fail interrupted upload jobs. Details: %s", err.Error())
+	}
+	if failed > 0 {
+		log.Printf("Failed %d upload jobs interrupted by the last shutdown\n", failed)
+	}
+
+	// Resumable uploads
+	rur, err := filesystem.NewResumableUploadRepository(config.Env.ResumableUploadDir)
+	if err != nil {
+		log.Fatalf("Failed to start resumable uploads. Details: %s", err.Error())
+	}
+	rus := services.NewResumableUploadService(
+		rur,
+		ups,
+		config.Env.ResumableUploadMaxSize,
+		config.Env.ResumableUploadExpiry,
+	)
+
+	// Inbox
+	stopInbox := func(ctx context.Context) error { return nil }
+	if config.Env.InboxDir != "" {
+		inbox, err := filesystem.NewInboxWatcher(ups, config.Env.InboxDir, config.Env.InboxPollInterval)
+		if err != nil {
+			log.Fatalf("Failed to start inbox watcher. Details: %s", err.Error())
+		}
+		stopInbox = inbox.Shutdown
+		log.Printf("Watching inbox: %s\n", config.Env.InboxDir)
+	}
 
 	// Controllers
-	uc := controllers.NewUserController(us)
-	oc := controllers.NewOrderController(ors)
+	uc := controllers.NewUserController(us, ups, config.Env.UploadMaxBodySize)
+	oc := controllers.NewOrderController(ors, location)
+	upc := controllers.NewUploadController(ups)
+	cc := controllers.NewConflictController(cs)
+	ruc := controllers.NewResumableUploadController(rus, us)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /healthcheck", func(w http.ResponseWriter, r *http.Request) {
@@ -60,29 +138,56 @@ func main() {
 	mux.HandleFunc("POST /user/upload", uc.PostUsersData)
 	mux.HandleFunc("GET /order/{id}", oc.GetByID)
 	mux.HandleFunc("GET /orders", oc.Get)
+	mux.HandleFunc("GET /uploads", upc.GetJobs)
+	mux.HandleFunc("GET /uploads/{jobId}", upc.GetJob)
+	mux.HandleFunc("GET /uploads/{jobId}/events", upc.Events)
+	mux.HandleFunc("DELETE /uploads/{jobId}", upc.Rollback)
+	mux.HandleFunc("GET /uploads/{jobId}/rejected", upc.GetRejectedLines)
+	mux.HandleFunc("POST /uploads/{jobId}/rejected/reprocess", upc.ReprocessRejectedLines)
+	mux.HandleFunc("POST /resumable-uploads", ruc.Create)
+	mux.HandleFunc("GET /resumable-uploads/{id}", ruc.Get)
+	mux.HandleFunc("PATCH /resumable-uploads/{id}", ruc.PatchChunk)
+	mux.HandleFunc("POST /resumable-uploads/{id}/finalize", ruc.Finish)
+	mux.HandleFunc("DELETE /resumable-uploads/{id}", ruc.Cancel)
+	mux.HandleFunc("GET /conflicts", cc.GetUnresolved)
+	mux.HandleFunc("POST /conflicts/{id}/resolve", cc.Resolve)
 
 	port := config.Env.Port
 	server := &http.Server{
 		Addr:    ":" + port,
 		Handler: mux,
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	server.RegisterOnShutdown(upc.Shutdown)
 
 	go func() {
 		log.Printf("Running on port: %s\n", port)
-		if err := server.ListenAndServe(); err != nil {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to listen and serve HTTP server. Details: %s", err.Error())
 		}
 	}()
-	<-ctx.Done()
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// Requests still in flight, like a long upload, are left behind once the timeout is
+	// reached, but the jobs already queued are drained all the same
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Failed to shutdown server. Details: %s", err.Error())
+		log.Printf("Failed to shutdown server gracefully. Details: %s\n", err.Error())
+	}
+
+	// Upload jobs already accepted are drained before the database connection is closed
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), time.Minute*5)
+	defer drainCancel()
+
+	if err := ups.Shutdown(drainCtx); err != nil {
+		log.Printf("Failed to drain upload jobs. Details: %s\n", err.Error())
+	}
+
+	if err := stopInbox(drainCtx); err != nil {
+		log.Printf("Failed to stop inbox watcher. Details: %s\n", err.Error())
 	}
 }
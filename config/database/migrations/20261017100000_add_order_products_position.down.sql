@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS order_products_natural_key_idx;
+
+ALTER TABLE order_products DROP COLUMN IF EXISTS position;
@@ -0,0 +1,15 @@
+ALTER TABLE order_products ADD COLUMN IF NOT EXISTS position INTEGER;
+
+-- Lines imported before the natural key existed are numbered by insertion order,
+-- so importing the same file again matches them instead of duplicating them
+UPDATE order_products op
+SET position = numbered.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY order_id, product_id, value ORDER BY id) AS position
+    FROM order_products
+) numbered
+WHERE op.id = numbered.id;
+
+ALTER TABLE order_products ALTER COLUMN position SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS order_products_natural_key_idx ON order_products (order_id, product_id, value, position);
@@ -0,0 +1,15 @@
+-- Unlogged staging table for bulk loads: rows are COPY'd here and merged into the
+-- final tables by the same transaction, which owns the rows through its transaction id
+CREATE UNLOGGED TABLE IF NOT EXISTS order_lines_staging (
+    load_id BIGINT NOT NULL DEFAULT txid_current(),
+    line INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    user_name VARCHAR(45) NOT NULL,
+    order_id INTEGER NOT NULL,
+    product_id INTEGER NOT NULL,
+    value NUMERIC(10, 2) NOT NULL,
+    position INTEGER NOT NULL,
+    date TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS order_lines_staging_load_id_idx ON order_lines_staging (load_id);
@@ -0,0 +1,31 @@
+-- Every uploaded file is kept as an ingestion batch, the history of what was imported.
+-- Rejections, conflicts, writes and the files of archives are kept as they were reported
+CREATE TABLE IF NOT EXISTS ingestion_batches (
+    id VARCHAR(32) PRIMARY KEY,
+    file_name TEXT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    size BIGINT NOT NULL,
+    uploaded_by TEXT NOT NULL DEFAULT '',
+    forced BOOLEAN NOT NULL DEFAULT FALSE,
+    layout VARCHAR(64) NOT NULL DEFAULT '',
+    format VARCHAR(16) NOT NULL DEFAULT '',
+    compression VARCHAR(16) NOT NULL DEFAULT '',
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    state VARCHAR(16) NOT NULL,
+    processed_lines INTEGER NOT NULL DEFAULT 0,
+    accepted_lines INTEGER NOT NULL DEFAULT 0,
+    rejected_lines INTEGER NOT NULL DEFAULT 0,
+    skipped_lines INTEGER NOT NULL DEFAULT 0,
+    rejections JSONB NOT NULL DEFAULT '[]',
+    conflicts JSONB NOT NULL DEFAULT '[]',
+    writes JSONB NOT NULL DEFAULT '{}',
+    entries JSONB NOT NULL DEFAULT '[]',
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS ingestion_batches_created_at_idx ON ingestion_batches (created_at DESC);
+CREATE INDEX IF NOT EXISTS ingestion_batches_imported_sha256_idx ON ingestion_batches (sha256)
+    WHERE state = 'done' AND NOT dry_run;
@@ -0,0 +1,18 @@
+-- Lines that disagree with what was stored before them, with both versions of the value.
+-- The key is the order or user id the conflict is about, batch_id the upload that raised it
+CREATE TABLE IF NOT EXISTS conflicts (
+    id SERIAL PRIMARY KEY,
+    type VARCHAR(16) NOT NULL,
+    key TEXT NOT NULL,
+    existing TEXT NOT NULL,
+    incoming TEXT NOT NULL,
+    policy VARCHAR(16) NOT NULL,
+    reason TEXT NOT NULL,
+    batch_id VARCHAR(32) NOT NULL DEFAULT '',
+    file_name TEXT NOT NULL DEFAULT '',
+    line INTEGER NOT NULL,
+    detected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    resolved_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS conflicts_unresolved_idx ON conflicts (type, id) WHERE resolved_at IS NULL;
@@ -0,0 +1 @@
+ALTER TABLE ingestion_batches DROP COLUMN IF EXISTS encoding;
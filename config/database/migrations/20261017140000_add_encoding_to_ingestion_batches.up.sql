@@ -0,0 +1,2 @@
+-- The character encoding the file was declared in, as its layout and format
+ALTER TABLE ingestion_batches ADD COLUMN IF NOT EXISTS encoding VARCHAR(16) NOT NULL DEFAULT '';
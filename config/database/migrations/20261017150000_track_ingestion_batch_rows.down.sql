@@ -0,0 +1,13 @@
+ALTER TABLE ingestion_batches DROP COLUMN IF EXISTS rolled_back_at;
+
+DROP TRIGGER IF EXISTS orders_record_change ON orders;
+DROP TRIGGER IF EXISTS users_record_change ON users;
+DROP FUNCTION IF EXISTS record_ingestion_batch_change();
+DROP TABLE IF EXISTS ingestion_batch_changes;
+
+DROP INDEX IF EXISTS order_products_product_id_idx;
+DROP INDEX IF EXISTS orders_user_id_idx;
+ALTER TABLE order_products DROP COLUMN IF EXISTS batch_id;
+ALTER TABLE orders DROP COLUMN IF EXISTS batch_id;
+ALTER TABLE products DROP COLUMN IF EXISTS batch_id;
+ALTER TABLE users DROP COLUMN IF EXISTS batch_id;
@@ -0,0 +1,47 @@
+-- Rows are tagged with the ingestion batch that inserted them, which the loading transaction
+-- sets in app.batch_id. Loads that are not batches, and rows older than this migration, have none
+ALTER TABLE users ADD COLUMN IF NOT EXISTS batch_id VARCHAR(32) DEFAULT NULLIF(current_setting('app.batch_id', true), '');
+ALTER TABLE products ADD COLUMN IF NOT EXISTS batch_id VARCHAR(32) DEFAULT NULLIF(current_setting('app.batch_id', true), '');
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS batch_id VARCHAR(32) DEFAULT NULLIF(current_setting('app.batch_id', true), '');
+ALTER TABLE order_products ADD COLUMN IF NOT EXISTS batch_id VARCHAR(32) DEFAULT NULLIF(current_setting('app.batch_id', true), '');
+
+CREATE INDEX IF NOT EXISTS users_batch_id_idx ON users (batch_id) WHERE batch_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS products_batch_id_idx ON products (batch_id) WHERE batch_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS orders_batch_id_idx ON orders (batch_id) WHERE batch_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS order_products_batch_id_idx ON order_products (batch_id) WHERE batch_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS orders_user_id_idx ON orders (user_id);
+CREATE INDEX IF NOT EXISTS order_products_product_id_idx ON order_products (product_id);
+
+-- Every change to a stored user or order keeps the values it overwrote, with the batch that
+-- changed it, so rolling a batch back restores them
+CREATE TABLE IF NOT EXISTS ingestion_batch_changes (
+    id BIGSERIAL PRIMARY KEY,
+    batch_id VARCHAR(32),
+    table_name VARCHAR(32) NOT NULL,
+    row_id INTEGER NOT NULL,
+    previous JSONB NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS ingestion_batch_changes_batch_id_idx ON ingestion_batch_changes (batch_id);
+CREATE INDEX IF NOT EXISTS ingestion_batch_changes_row_idx ON ingestion_batch_changes (table_name, row_id);
+
+CREATE OR REPLACE FUNCTION record_ingestion_batch_change() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO ingestion_batch_changes (batch_id, table_name, row_id, previous)
+    VALUES (NULLIF(current_setting('app.batch_id', true), ''), TG_TABLE_NAME, OLD.id, to_jsonb(OLD) - 'batch_id');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_record_change ON users;
+CREATE TRIGGER users_record_change AFTER UPDATE ON users FOR EACH ROW
+    WHEN (OLD.name IS DISTINCT FROM NEW.name)
+    EXECUTE FUNCTION record_ingestion_batch_change();
+
+DROP TRIGGER IF EXISTS orders_record_change ON orders;
+CREATE TRIGGER orders_record_change AFTER UPDATE ON orders FOR EACH ROW
+    WHEN ((OLD.user_id, OLD.date) IS DISTINCT FROM (NEW.user_id, NEW.date))
+    EXECUTE FUNCTION record_ingestion_batch_change();
+
+ALTER TABLE ingestion_batches ADD COLUMN IF NOT EXISTS rolled_back_at TIMESTAMPTZ;
@@ -0,0 +1,2 @@
+ALTER TABLE order_lines_staging DROP COLUMN IF EXISTS content;
+DROP TABLE IF EXISTS rejected_lines;
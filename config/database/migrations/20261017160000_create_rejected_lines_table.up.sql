@@ -0,0 +1,14 @@
+-- Rejected lines of the uploads kept as they were read, with the reasons they were rejected,
+-- until they are reprocessed successfully or their upload is rolled back
+CREATE TABLE IF NOT EXISTS rejected_lines (
+    batch_id VARCHAR(32) NOT NULL,
+    file_name TEXT NOT NULL,
+    line INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    rejections JSONB NOT NULL,
+    rejected_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (batch_id, file_name, line)
+);
+
+-- Staged lines keep their content, so the lines a conflict rejects can be quarantined
+ALTER TABLE order_lines_staging ADD COLUMN IF NOT EXISTS content TEXT NOT NULL DEFAULT '';
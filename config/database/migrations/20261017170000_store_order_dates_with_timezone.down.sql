@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS orders_record_change ON orders;
+
+ALTER TABLE order_lines_staging ALTER COLUMN date TYPE TIMESTAMP USING date AT TIME ZONE 'America/Sao_Paulo';
+ALTER TABLE orders ALTER COLUMN date TYPE TIMESTAMP USING date AT TIME ZONE 'America/Sao_Paulo';
+
+CREATE TRIGGER orders_record_change AFTER UPDATE ON orders FOR EACH ROW
+    WHEN ((OLD.user_id, OLD.date) IS DISTINCT FROM (NEW.user_id, NEW.date))
+    EXECUTE FUNCTION record_ingestion_batch_change();
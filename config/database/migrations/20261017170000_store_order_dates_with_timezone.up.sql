@@ -0,0 +1,12 @@
+-- Order dates were stored without a timezone, as the day they were bought on at midnight, so
+-- they are taken as midnight in the business timezone. Deployments with another BUSINESS_TIMEZONE
+-- must change it here before migrating. The column type can not change under the trigger
+-- comparing it, which is created again
+DROP TRIGGER IF EXISTS orders_record_change ON orders;
+
+ALTER TABLE orders ALTER COLUMN date TYPE TIMESTAMPTZ USING date AT TIME ZONE 'America/Sao_Paulo';
+ALTER TABLE order_lines_staging ALTER COLUMN date TYPE TIMESTAMPTZ USING date AT TIME ZONE 'America/Sao_Paulo';
+
+CREATE TRIGGER orders_record_change AFTER UPDATE ON orders FOR EACH ROW
+    WHEN ((OLD.user_id, OLD.date) IS DISTINCT FROM (NEW.user_id, NEW.date))
+    EXECUTE FUNCTION record_ingestion_batch_change();
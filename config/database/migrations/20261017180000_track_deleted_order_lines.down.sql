@@ -0,0 +1,15 @@
+ALTER TABLE ingestion_batches DROP COLUMN IF EXISTS mode;
+
+DROP TRIGGER IF EXISTS order_products_record_delete ON order_products;
+DROP TRIGGER IF EXISTS orders_record_delete ON orders;
+
+CREATE OR REPLACE FUNCTION record_ingestion_batch_change() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO ingestion_batch_changes (batch_id, table_name, row_id, previous)
+    VALUES (NULLIF(current_setting('app.batch_id', true), ''), TG_TABLE_NAME, OLD.id, to_jsonb(OLD) - 'batch_id');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DELETE FROM ingestion_batch_changes WHERE operation = 'DELETE';
+ALTER TABLE ingestion_batch_changes DROP COLUMN IF EXISTS operation;
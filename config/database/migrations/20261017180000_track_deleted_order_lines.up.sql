@@ -0,0 +1,27 @@
+-- Delta files delete order lines and the orders left without any, so the deleted rows are kept
+-- in ingestion_batch_changes as well, whole, for rolling the batch back to insert them again
+ALTER TABLE ingestion_batch_changes ADD COLUMN IF NOT EXISTS operation VARCHAR(8) NOT NULL DEFAULT 'UPDATE';
+
+CREATE OR REPLACE FUNCTION record_ingestion_batch_change() RETURNS TRIGGER AS $$
+DECLARE
+    previous JSONB := to_jsonb(OLD);
+BEGIN
+    IF TG_OP = 'UPDATE' THEN
+        previous := previous - 'batch_id';
+    END IF;
+
+    INSERT INTO ingestion_batch_changes (batch_id, table_name, row_id, previous, operation)
+    VALUES (NULLIF(current_setting('app.batch_id', true), ''), TG_TABLE_NAME, OLD.id, previous, TG_OP);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS orders_record_delete ON orders;
+CREATE TRIGGER orders_record_delete AFTER DELETE ON orders FOR EACH ROW
+    EXECUTE FUNCTION record_ingestion_batch_change();
+
+DROP TRIGGER IF EXISTS order_products_record_delete ON order_products;
+CREATE TRIGGER order_products_record_delete AFTER DELETE ON order_products FOR EACH ROW
+    EXECUTE FUNCTION record_ingestion_batch_change();
+
+ALTER TABLE ingestion_batches ADD COLUMN IF NOT EXISTS mode VARCHAR(16) NOT NULL DEFAULT 'full';
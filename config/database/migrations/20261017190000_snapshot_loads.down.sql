@@ -0,0 +1,2 @@
+ALTER TABLE ingestion_batches DROP COLUMN IF EXISTS snapshot_end;
+ALTER TABLE ingestion_batches DROP COLUMN IF EXISTS snapshot_start;
@@ -0,0 +1,3 @@
+-- The range of order dates a snapshot file holds, as it was sent
+ALTER TABLE ingestion_batches ADD COLUMN IF NOT EXISTS snapshot_start VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE ingestion_batches ADD COLUMN IF NOT EXISTS snapshot_end VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS ingestion_batches_importing_sha256_key;
+DROP INDEX IF EXISTS ingestion_batches_imported_sha256_idx;
+CREATE INDEX IF NOT EXISTS ingestion_batches_imported_sha256_idx ON ingestion_batches (sha256)
+    WHERE state = 'done' AND NOT dry_run;
@@ -0,0 +1,8 @@
+-- A file is refused while another upload is loading it, not only once it was loaded, and the
+-- check is enforced by a unique index so two uploads of the same file can not both pass it.
+-- Forced uploads load a file again on purpose and are left out of it
+DROP INDEX IF EXISTS ingestion_batches_imported_sha256_idx;
+CREATE INDEX IF NOT EXISTS ingestion_batches_imported_sha256_idx ON ingestion_batches (sha256)
+    WHERE state IN ('queued', 'running', 'done') AND NOT dry_run;
+CREATE UNIQUE INDEX IF NOT EXISTS ingestion_batches_importing_sha256_key ON ingestion_batches (sha256)
+    WHERE state IN ('queued', 'running', 'done') AND NOT dry_run AND NOT forced;
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/conflict"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+)
+
+type conflictController struct {
+	service conflict.Service
+}
+
+func NewConflictController(service conflict.Service) *conflictController {
+	return &conflictController{
+		service: service,
+	}
+}
+
+// GetUnresolved lists the conflicts not resolved yet from the oldest to the newest, only the
+// ones of the type query value when it is sent, paginated by limit and offset
+func (c *conflictController) GetUnresolved(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	conflictType := entities.ConflictType(r.URL.Query().Get("type"))
+
+	conflicts, err := c.service.GetUnresolvedConflicts(conflictType, limit, offset)
+	if err != nil {
+		if err == errors.ErrUnknownConflictType {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	conflictsRes := make([]*conflict.Response, 0, len(conflicts))
+	for _, lineConflict := range conflicts {
+		conflictsRes = append(conflictsRes, conflict.FromConflictToResponse(lineConflict))
+	}
+
+	res, err := json.Marshal(conflictsRes)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(res)
+}
+
+// Resolve marks a conflict as resolved, taking it out of the unresolved list
+func (c *conflictController) Resolve(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if err := c.service.ResolveConflict(uint(id)); err != nil {
+		if err == errors.ErrConflictNotFound {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -12,11 +12,14 @@ import (
 
 type orderController struct {
 	service order.Service
+	// location is the business timezone the days of the intervals begin and end in
+	location *time.Location
 }
 
-func NewOrderController(service order.Service) *orderController {
+func NewOrderController(service order.Service, location *time.Location) *orderController {
 	return &orderController{
-		service: service,
+		service:  service,
+		location: location,
 	}
 }
 
@@ -65,26 +68,14 @@ func (c *orderController) Get(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if startDateStr != "" {
-		startDate, err := time.Parse(time.DateOnly, startDateStr)
+		interval, err := order.ParseInterval(startDateStr, endDateStr, time.Now(), c.location)
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("Invalid startDate format. Expected 2006-01-02"))
+			w.Write([]byte(err.Error()))
 			return
 		}
 
-		endDate := time.Now()
-		if endDateStr != "" {
-			parsedEndDate, err := time.Parse(time.DateOnly, endDateStr)
-			if err != nil {
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte("Invalid endDate format. Expected 2006-01-02"))
-				return
-			}
-
-			endDate = parsedEndDate
-		}
-
-		purchases, err := c.service.GetOrdersProductsByInterval(startDate, endDate)
+		purchases, err := c.service.GetOrdersProductsByInterval(interval.Start, interval.End)
 		if err != nil {
 			if err == errors.ErrInvalidDateInterval {
 				w.WriteHeader(http.StatusBadRequest)
@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/resumable"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+// Headers of the resumable upload protocol, named after the ones of tus
+const (
+	uploadLengthHeader = "Upload-Length"
+	uploadOffsetHeader = "Upload-Offset"
+	chunkContentType   = "application/offset+octet-stream"
+)
+
+type resumableUploadController struct {
+	service     resumable.Service
+	userService user.Service
+}
+
+func NewResumableUploadController(service resumable.Service, userService user.Service) *resumableUploadController {
+	return &resumableUploadController{
+		service:     service,
+		userService: userService,
+	}
+}
+
+// Create starts the upload of the file named by fileName, with the size sent in Upload-Length.
+// The load options are sent in the query string, as the form fields of a regular upload
+func (c *resumableUploadController) Create(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+
+	fileName := values.Get("fileName")
+	if fileName == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(errors.ErrResumableMissingFileName.Error()))
+		return
+	}
+
+	length, err := parseUploadHeader(r, uploadLengthHeader)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	opts, err := parseSubmitOptions(c.userService, values)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	resumableUpload, err := c.service.Create(fileName, length, opts)
+	if err != nil {
+		if stderrors.Is(err, errors.ErrUploadTooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	res := resumable.FromResumableUploadToResponse(resumableUpload)
+	w.Header().Set("Location", res.URL)
+	writeResumableUpload(w, http.StatusCreated, res)
+}
+
+// Get reports how many bytes of the upload were received, in Upload-Offset and in the body,
+// so a client can resume from there. HEAD requests only get the headers
+func (c *resumableUploadController) Get(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	resumableUpload, err := c.service.Get(id)
+	if err != nil {
+		if err == errors.ErrResumableUploadNotFound {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	writeResumableUpload(w, http.StatusOK, resumable.FromResumableUploadToResponse(resumableUpload))
+}
+
+// PatchChunk appends the body to the upload. Upload-Offset must be the offset of the upload,
+// the new one is sent back in the same header
+func (c *resumableUploadController) PatchChunk(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != chunkContentType {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		w.Write([]byte(fmt.Sprintf("Invalid Content-Type. Expected %s", chunkContentType)))
+		return
+	}
+
+	offset, err := parseUploadHeader(r, uploadOffsetHeader)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	resumableUpload, err := c.service.WriteChunk(id, offset, r.Body)
+	if resumableUpload != nil {
+		w.Header().Set(uploadOffsetHeader, strconv.FormatInt(resumableUpload.Offset, 10))
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	if err != nil {
+		switch {
+		case err == errors.ErrResumableUploadNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		case err == errors.ErrResumableOffsetMismatch, err == errors.ErrResumableUploadBusy:
+			w.WriteHeader(http.StatusConflict)
+		case err == errors.ErrResumableChunkTooLong:
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Finish queues the complete file as an upload job, answering as a regular upload does
+func (c *resumableUploadController) Finish(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	force, err := parseBoolValue(r.URL.Query(), "force")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	job, err := c.service.Finish(id, force)
+	if err != nil {
+		switch {
+		case err == errors.ErrUploadAlreadyImported:
+			files := []*user.UploadedFileResponse{newUploadedFileResponse(job.FileName, job, err)}
+			writeUploadResponse(w, http.StatusConflict, err.Error(), files)
+			return
+		case err == errors.ErrResumableUploadNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		case stderrors.Is(err, errors.ErrResumableUploadIncomplete), err == errors.ErrResumableUploadBusy:
+			w.WriteHeader(http.StatusConflict)
+		case err == errors.ErrUploadQueueFull || err == errors.ErrUploadQueueClosed:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	files := []*user.UploadedFileResponse{newUploadedFileResponse(job.FileName, job, nil)}
+	if job.DryRun {
+		writeUploadResponse(w, http.StatusAccepted, "file accepted for a dry run, nothing will be written!", files)
+		return
+	}
+
+	writeUploadResponse(w, http.StatusAccepted, "file accepted for processing!", files)
+}
+
+// Cancel gives up the upload, removing the bytes received
+func (c *resumableUploadController) Cancel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if err := c.service.Cancel(id); err != nil {
+		switch err {
+		case errors.ErrResumableUploadNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		case errors.ErrResumableUploadBusy:
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseUploadHeader reads a size header of the protocol, which must be a non-negative number
+func parseUploadHeader(r *http.Request, header string) (int64, error) {
+	value, err := strconv.ParseInt(r.Header.Get(header), 10, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("Invalid %s header. Expected a non-negative number", header)
+	}
+
+	return value, nil
+}
+
+// writeResumableUpload answers with the state of the upload, also sent in the protocol headers
+func writeResumableUpload(w http.ResponseWriter, status int, upload *resumable.Response) {
+	res, err := json.Marshal(upload)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.Header().Set(uploadLengthHeader, strconv.FormatInt(upload.Length, 10))
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(res)
+}
@@ -0,0 +1,312 @@
+package controllers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/progress"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/upload"
+)
+
+// keepAliveInterval is how often a comment is sent to an event stream without events, so
+// proxies do not close it while a job waits in the queue
+const keepAliveInterval = 15 * time.Second
+
+type uploadController struct {
+	service upload.Service
+
+	// done is closed on shutdown, ending the event streams still open
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewUploadController(service upload.Service) *uploadController {
+	return &uploadController{
+		service: service,
+		done:    make(chan struct{}),
+	}
+}
+
+// Shutdown ends the event streams, which would otherwise keep the server from shutting down
+func (c *uploadController) Shutdown() {
+	c.stopOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+func (c *uploadController) GetJob(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("jobId")
+
+	job, err := c.service.GetJob(jobID)
+	if err != nil {
+		if err == errors.ErrUploadJobNotFound {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	res, err := json.Marshal(upload.FromJobToResponse(job))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(res)
+}
+
+// GetJobs lists the upload history from the newest to the oldest, paginated by limit and offset
+func (c *uploadController) GetJobs(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePage(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	jobs, err := c.service.GetJobs(limit, offset)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	jobsRes := make([]*upload.JobSummaryResponse, 0, len(jobs))
+	for _, job := range jobs {
+		jobsRes = append(jobsRes, upload.FromJobToSummaryResponse(job))
+	}
+
+	res, err := json.Marshal(jobsRes)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(res)
+}
+
+// Events streams the progress of the job as Server-Sent Events while its files are loaded: a
+// progress event for each file now and then, and a done event with the job once it finishes,
+// which ends the stream. A job already finished only gets the done event
+func (c *uploadController) Events(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("jobId")
+
+	events, cancel, err := c.service.Follow(jobID)
+	if err != nil {
+		if err == errors.ErrUploadJobNotFound {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keeps reverse proxies from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		log.Printf("Failed to stream events of upload job [%s]. Details: %s\n", jobID, err.Error())
+		return
+	}
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-c.done:
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				c.writeDoneEvent(w, jobID)
+				rc.Flush()
+				return
+			}
+
+			if err := writeEvent(w, "progress", progress.FromIngestionProgressToResponse(event)); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeDoneEvent sends the finished job, or an error event when it can not be read
+func (c *uploadController) writeDoneEvent(w http.ResponseWriter, jobID string) {
+	job, err := c.service.GetJob(jobID)
+	if err != nil {
+		fmt.Fprintf(w, "event: error\ndata: %s\n\n", err.Error())
+		return
+	}
+
+	writeEvent(w, "done", upload.FromJobToSummaryResponse(job))
+}
+
+// writeEvent sends a Server-Sent Event with the value encoded as JSON in its data
+func writeEvent(w http.ResponseWriter, name string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+	return err
+}
+
+// Rollback undoes what the job imported. Jobs depending on its rows are rolled back as
+// well only when cascade=true is sent, otherwise the rollback is refused
+func (c *uploadController) Rollback(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("jobId")
+
+	cascade, err := parseBoolValue(r.URL.Query(), "cascade")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	jobs, summary, err := c.service.Rollback(jobID, cascade)
+	if err != nil {
+		switch {
+		case stderrors.Is(err, errors.ErrUploadJobNotFound):
+			w.WriteHeader(http.StatusNotFound)
+		case stderrors.Is(err, errors.ErrUploadHasDependents),
+			stderrors.Is(err, errors.ErrUploadUntrackedLoad),
+			stderrors.Is(err, errors.ErrUploadJobNotFinished),
+			stderrors.Is(err, errors.ErrUploadDryRunRollback),
+			stderrors.Is(err, errors.ErrUploadRolledBack):
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	res, err := json.Marshal(upload.FromRollbackToResponse(jobs, summary))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(res)
+}
+
+// GetRejectedLines downloads the quarantined lines of the job, to be fixed and sent again
+func (c *uploadController) GetRejectedLines(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("jobId")
+
+	job, err := c.service.GetJob(jobID)
+	if err != nil {
+		if err == errors.ErrUploadJobNotFound {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	if job.DryRun {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(errors.ErrUploadDryRunRejected.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "rejected-"+job.ID+".txt"))
+	w.WriteHeader(http.StatusOK)
+
+	// The status is already sent, so a failure can only cut the download short
+	if err := c.service.WriteRejectedLines(job, w); err != nil {
+		log.Printf("Failed to write rejected lines of upload job [%s]. Details: %s\n", job.ID, err.Error())
+	}
+}
+
+// ReprocessRejectedLines loads again the quarantined lines of the job, after the data they
+// depend on was fixed
+func (c *uploadController) ReprocessRejectedLines(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("jobId")
+
+	job, result, err := c.service.ReprocessRejectedLines(jobID)
+	if err != nil {
+		switch {
+		case stderrors.Is(err, errors.ErrUploadJobNotFound):
+			w.WriteHeader(http.StatusNotFound)
+		case stderrors.Is(err, errors.ErrUploadDryRunRejected),
+			stderrors.Is(err, errors.ErrUploadRolledBack),
+			stderrors.Is(err, errors.ErrUploadJobNotFinished),
+			stderrors.Is(err, errors.ErrUploadReprocessing):
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	res, err := json.Marshal(upload.FromReprocessToResponse(job, result))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(res)
+}
+
+// parsePage reads the optional limit and offset of a listing from the query string, zero when missing
+func parsePage(r *http.Request) (int, int, error) {
+	page := make(map[string]int)
+	for _, key := range []string{"limit", "offset"} {
+		value := r.URL.Query().Get(key)
+		if value == "" {
+			continue
+		}
+
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, 0, fmt.Errorf("Invalid %s value. Expected a non-negative number", key)
+		}
+		page[key] = parsed
+	}
+
+	return page["limit"], page["offset"], nil
+}
@@ -2,20 +2,37 @@ package controllers
 
 import (
 	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/layout"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/upload"
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
 )
 
+// maxFormValueSize bounds the form fields that are not files, which are read into memory
+const maxFormValueSize = 64 << 10
+
 type userController struct {
-	service user.Service
+	service       user.Service
+	uploadService upload.Service
+	// maxBodySize bounds the upload requests, unbounded when not positive
+	maxBodySize int64
 }
 
-func NewUserController(service user.Service) *userController {
+func NewUserController(service user.Service, uploadService upload.Service, maxBodySize int64) *userController {
 	return &userController{
-		service: service,
+		service:       service,
+		uploadService: uploadService,
+		maxBodySize:   maxBodySize,
 	}
 }
 
@@ -52,26 +69,241 @@ func (c *userController) Get(w http.ResponseWriter, r *http.Request) {
 	w.Write(res)
 }
 
+// PostUsersData streams the users_data parts of the form to the upload service as they
+// arrive, without buffering the form, and queues a job for each of them. The other fields
+// of the form apply to the files sent after them and may also come in the query string.
+// A part failing after files were queued stops the request, which still lists their jobs
 func (c *userController) PostUsersData(w http.ResponseWriter, r *http.Request) {
-	// Max Memory up to 5 MB (10 * 1024 * 1024)
-	if err := r.ParseMultipartForm(5 << 20); err != nil {
+	if c.maxBodySize > 0 {
+		if r.ContentLength > c.maxBodySize {
+			writeBodyTooLarge(w, c.maxBodySize)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, c.maxBodySize)
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(err.Error()))
 		return
 	}
 
-	file, _, err := r.FormFile("users_data")
-	if err != nil {
+	// Form fields override the query string, as they did when the form was parsed at once
+	values := r.URL.Query()
+	files := make([]*user.UploadedFileResponse, 0)
+	accepted := 0
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			status, err := partError(err)
+			writeUploadFailure(w, status, "", err, files)
+			return
+		}
+
+		if part.FormName() != "users_data" {
+			value, err := readFormValue(part)
+			part.Close()
+			if err != nil {
+				status, err := partError(err)
+				writeUploadFailure(w, status, "", err, files)
+				return
+			}
+			values.Set(part.FormName(), value)
+			continue
+		}
+
+		job, status, err := c.submitPart(part, values)
+		part.Close()
+		if err == errors.ErrUploadAlreadyImported {
+			files = append(files, newUploadedFileResponse(part.FileName(), job, err))
+			continue
+		}
+		if err != nil {
+			writeUploadFailure(w, status, part.FileName(), err, files)
+			return
+		}
+
+		files = append(files, newUploadedFileResponse(part.FileName(), job, nil))
+		accepted++
+	}
+
+	if len(files) == 0 {
 		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(errors.ErrUploadMissingFile.Error()))
+		return
+	}
+
+	dryRun, _ := parseBoolValue(values, "dryRun")
+
+	switch {
+	case accepted == 0 && len(files) == 1:
+		writeUploadResponse(w, http.StatusConflict, files[0].Error, files)
+	case accepted == 0:
+		writeUploadResponse(w, http.StatusConflict, "files were already imported, send force=true to load them again", files)
+	case dryRun:
+		writeUploadResponse(w, http.StatusAccepted, "file accepted for a dry run, nothing will be written!", files)
+	default:
+		writeUploadResponse(w, http.StatusAccepted, "file accepted for processing!", files)
+	}
+}
+
+// submitPart queues a users_data part with the options of the form fields read so far,
+// returning the status the request must fail with when it can not be queued
+func (c *userController) submitPart(part *multipart.Part, values url.Values) (*entities.UploadJob, int, error) {
+	opts, err := parseSubmitOptions(c.service, values)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	job, err := c.uploadService.Submit(part.FileName(), part, opts)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case err == errors.ErrUploadAlreadyImported:
+			return job, http.StatusConflict, err
+		case err == errors.ErrUploadQueueFull || err == errors.ErrUploadQueueClosed:
+			return nil, http.StatusServiceUnavailable, err
+		case stderrors.As(err, &maxBytesErr):
+			return nil, http.StatusRequestEntityTooLarge, bodyTooLargeError(maxBytesErr.Limit)
+		}
+
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return job, http.StatusAccepted, nil
+}
+
+// parseSubmitOptions reads how an uploaded file is loaded, falling back to the defaults, and
+// validates the options against the registered layouts, formats, encodings and modes
+func parseSubmitOptions(service user.Service, values url.Values) (upload.SubmitOptions, error) {
+	opts := user.LoadOptions{
+		Layout:   values.Get("layout"),
+		Format:   user.Format(values.Get("format")),
+		Encoding: user.Encoding(strings.ToLower(values.Get("encoding"))),
+		Mode:     user.Mode(strings.ToLower(values.Get("mode"))),
+		// The range of order dates of snapshot files, named as in the order search
+		SnapshotStart: values.Get("startDate"),
+		SnapshotEnd:   values.Get("endDate"),
+	}
+	if opts.Layout == "" {
+		opts.Layout = layout.DefaultName
+	}
+	if opts.Format == "" {
+		opts.Format = user.FormatAuto
+	}
+	if opts.Encoding == "" {
+		opts.Encoding = user.EncodingUTF8
+	}
+	if opts.Mode == "" {
+		opts.Mode = user.ModeFull
+	}
+
+	dryRun, err := parseBoolValue(values, "dryRun")
+	if err != nil {
+		return upload.SubmitOptions{}, err
+	}
+	opts.DryRun = dryRun
+
+	force, err := parseBoolValue(values, "force")
+	if err != nil {
+		return upload.SubmitOptions{}, err
+	}
+
+	if err := service.ValidateLoadOptions(opts); err != nil {
+		return upload.SubmitOptions{}, err
+	}
+
+	return upload.SubmitOptions{
+		LoadOptions: opts,
+		UploadedBy:  values.Get("uploadedBy"),
+		Force:       force,
+	}, nil
+}
+
+// partError returns the status and the error an error reading the form fails the request
+// with, which is 413 when the body went over the limit
+func partError(err error) (int, error) {
+	var maxBytesErr *http.MaxBytesError
+	if stderrors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge, bodyTooLargeError(maxBytesErr.Limit)
+	}
+
+	return http.StatusBadRequest, err
+}
+
+// writeUploadFailure fails the request with the status of the part that failed. The jobs of
+// the files listed before it keep running, so they are returned along with the part that
+// failed under 207 Multi-Status instead, for the uploader to follow or roll them back
+func writeUploadFailure(
+	w http.ResponseWriter,
+	status int,
+	fileName string,
+	err error,
+	files []*user.UploadedFileResponse,
+) {
+	if len(files) == 0 {
+		w.WriteHeader(status)
 		w.Write([]byte(err.Error()))
 		return
 	}
-	defer file.Close()
 
-	processedLines := c.service.LoadUsersDataFile(file)
+	files = append(files, newUploadedFileResponse(fileName, nil, err))
+	writeUploadResponse(w, http.StatusMultiStatus, "upload stopped at a file that was not accepted", files)
+}
+
+func writeBodyTooLarge(w http.ResponseWriter, limit int64) {
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	w.Write([]byte(bodyTooLargeError(limit).Error()))
+}
+
+func bodyTooLargeError(limit int64) error {
+	return fmt.Errorf("%w: the limit is %d bytes", errors.ErrUploadTooLarge, limit)
+}
+
+// readFormValue reads a form field that is not a file, refusing values too long to be one
+func readFormValue(part *multipart.Part) (string, error) {
+	value, err := io.ReadAll(io.LimitReader(part, maxFormValueSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(value) > maxFormValueSize {
+		return "", fmt.Errorf("form field %s is longer than %d bytes", part.FormName(), maxFormValueSize)
+	}
+
+	return string(value), nil
+}
+
+func newUploadedFileResponse(fileName string, job *entities.UploadJob, err error) *user.UploadedFileResponse {
+	res := &user.UploadedFileResponse{
+		FileName: fileName,
+	}
+	if job != nil {
+		res.JobID = job.ID
+		res.StatusURL = "/uploads/" + job.ID
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	return res
+}
+
+// writeUploadResponse points the uploader to the jobs of the files. A single file is also
+// pointed to by the top level fields and the Location header, as before several files
+// could be sent at once
+func writeUploadResponse(w http.ResponseWriter, status int, message string, files []*user.UploadedFileResponse) {
 	userFileRes := &user.UserFileResponse{
-		Message:        "file processed successfully!",
-		ProcessedLines: processedLines,
+		Message: message,
+		Files:   files,
+	}
+	if len(files) == 1 {
+		userFileRes.JobID = files[0].JobID
+		userFileRes.StatusURL = files[0].StatusURL
 	}
 
 	res, err := json.Marshal(userFileRes)
@@ -81,6 +313,24 @@ func (c *userController) PostUsersData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	if len(files) == 1 {
+		w.Header().Set("Location", files[0].StatusURL)
+	}
+	w.WriteHeader(status)
 	w.Write(res)
 }
+
+// parseBoolValue reads an optional boolean from the query string or the form, false when missing
+func parseBoolValue(values url.Values, key string) (bool, error) {
+	value := values.Get(key)
+	if value == "" {
+		return false, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("Invalid %s value. Expected true or false", key)
+	}
+
+	return parsed, nil
+}
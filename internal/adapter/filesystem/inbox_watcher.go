@@ -0,0 +1,272 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/upload"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+// Subfolders of the inbox where the loaded files are moved to, next to their results
+const (
+	InboxProcessedDir = "processed"
+	InboxFailedDir    = "failed"
+	inboxResultSuffix = ".result.json"
+	// InboxUploadedBy is who the jobs of the inbox files are recorded as sent by
+	InboxUploadedBy = "inbox"
+)
+
+// inboxTempSuffixes mark files still being copied by tools that rename them once they are complete
+var inboxTempSuffixes = []string{".tmp", ".part", ".partial", ".crdownload"}
+
+// inboxFile is the state of a file found by a poll
+type inboxFile struct {
+	size    int64
+	modTime time.Time
+}
+
+func (f inboxFile) same(other inboxFile) bool {
+	return f.size == other.size && f.modTime.Equal(other.modTime)
+}
+
+// inboxResultResponse is the sidecar written next to a loaded file
+type inboxResultResponse struct {
+	FileName string `json:"file_name"`
+	// JobID is the job of the file, or the one that imported it when it was already imported
+	JobID string `json:"job_id,omitempty"`
+	*user.UserFileResultResponse
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+type inboxWatcher struct {
+	uploadService upload.Service
+	dir           string
+	interval      time.Duration
+
+	// seen holds the files found by the previous poll, a file is only loaded once it
+	// did not change for a whole interval, so files still being written are left alone
+	seen map[string]inboxFile
+	// unarchived holds the files loaded but left in the inbox because they could not be
+	// moved out of it, which are not loaded again until they change
+	unarchived map[string]inboxFile
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	// ctx is canceled when Shutdown gives up waiting, aborting the file being loaded
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewInboxWatcher creates the inbox subfolders and starts polling dir every interval,
+// importing the new files through the upload service, so they are kept as jobs like the
+// uploads. Loaded files are moved to the processed
+// subfolder, or to the failed one when the load fails, next to a JSON with their result
+func NewInboxWatcher(uploadService upload.Service, dir string, interval time.Duration) (*inboxWatcher, error) {
+	for _, subdir := range []string{InboxProcessedDir, InboxFailedDir} {
+		if err := os.MkdirAll(filepath.Join(dir, subdir), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create inbox folder: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	w := &inboxWatcher{
+		uploadService: uploadService,
+		dir:           dir,
+		interval:      interval,
+		seen:          make(map[string]inboxFile),
+		unarchived:    make(map[string]inboxFile),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+
+	go w.watch()
+
+	return w, nil
+}
+
+// Shutdown stops polling and waits until the file being loaded is done. When the context
+// is done first, the load is canceled and the file is left in the inbox to be loaded again
+func (w *inboxWatcher) Shutdown(ctx context.Context) error {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		w.cancel()
+		return ctx.Err()
+	}
+}
+
+func (w *inboxWatcher) watch() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.poll()
+
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll loads the files that did not change since the previous poll, leaving out the
+// files loaded before that could not be moved out of the inbox
+func (w *inboxWatcher) poll() {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		log.Printf("Failed to read inbox [%s]. Details: %s\n", w.dir, err.Error())
+		return
+	}
+
+	found := make(map[string]inboxFile)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || isInboxIgnored(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// The file was removed or renamed since the directory was read
+			continue
+		}
+
+		file := inboxFile{size: info.Size(), modTime: info.ModTime()}
+		if loaded, ok := w.unarchived[entry.Name()]; ok {
+			if loaded.same(file) {
+				continue
+			}
+			delete(w.unarchived, entry.Name())
+		}
+
+		if previous, ok := w.seen[entry.Name()]; !ok || !previous.same(file) {
+			found[entry.Name()] = file
+			continue
+		}
+
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+
+		w.process(entry.Name(), file)
+	}
+
+	w.seen = found
+}
+
+// process loads a file and moves it out of the inbox with its result
+func (w *inboxWatcher) process(name string, file inboxFile) {
+	startedAt := time.Now()
+	job, result, err := w.load(filepath.Join(w.dir, name))
+
+	if w.ctx.Err() != nil {
+		log.Printf("Inbox file [%s] was interrupted and will be loaded again\n", name)
+		return
+	}
+
+	subdir := InboxProcessedDir
+	res := &inboxResultResponse{
+		FileName:               name,
+		UserFileResultResponse: user.FromUserFileResultToResponse(result),
+		StartedAt:              startedAt,
+		FinishedAt:             time.Now(),
+	}
+	if job != nil {
+		res.JobID = job.ID
+	}
+	if err != nil {
+		subdir = InboxFailedDir
+		res.Error = err.Error()
+	}
+
+	if err := w.archive(name, subdir, res); err != nil {
+		w.unarchived[name] = file
+		log.Printf(
+			"Failed to move loaded inbox file [%s] to %s, it is left in the inbox and not loaded again "+
+				"until it changes, move it by hand. Details: %s\n",
+			name,
+			subdir,
+			err.Error(),
+		)
+	}
+}
+
+// load imports the file, returning its job, or the job that imported it when it already was
+func (w *inboxWatcher) load(path string) (*entities.UploadJob, *user.UserFileResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, new(user.UserFileResult), err
+	}
+	defer file.Close()
+
+	opts := upload.SubmitOptions{UploadedBy: InboxUploadedBy}
+	job, result, err := w.uploadService.Import(w.ctx, filepath.Base(path), file, opts)
+	if result == nil {
+		result = new(user.UserFileResult)
+	}
+
+	return job, result, err
+}
+
+// archive writes the result next to where the file is moved to. A file with the name of
+// one moved before gets the time it was loaded added to its name
+func (w *inboxWatcher) archive(name, subdir string, res *inboxResultResponse) error {
+	target := filepath.Join(w.dir, subdir, name)
+	if _, err := os.Stat(target); err == nil {
+		ext := filepath.Ext(name)
+		stamp := res.StartedAt.Format("20060102T150405.000000000")
+		target = filepath.Join(w.dir, subdir, strings.TrimSuffix(name, ext)+"-"+stamp+ext)
+	}
+
+	content, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(target+inboxResultSuffix, content, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(filepath.Join(w.dir, name), target)
+}
+
+// isInboxIgnored tells whether a file must be left alone: hidden files and the
+// temporary files of tools that rename them once they are completely written
+func isInboxIgnored(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return true
+	}
+
+	for _, suffix := range inboxTempSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
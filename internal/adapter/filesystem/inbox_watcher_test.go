@@ -0,0 +1,229 @@
+package filesystem_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/filesystem"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	mockupload "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/upload"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/upload"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+	"go.uber.org/mock/gomock"
+)
+
+const inboxPollInterval = 10 * time.Millisecond
+
+var inboxImportOptions = upload.SubmitOptions{UploadedBy: filesystem.InboxUploadedBy}
+
+func Test_InboxWatcher(t *testing.T) {
+	tests := []struct {
+		description    string
+		fileName       string
+		job            *entities.UploadJob
+		result         *user.UserFileResult
+		err            error
+		expectedSubdir string
+		expectedError  string
+	}{
+		{
+			description:    "should move a loaded file to processed with its result",
+			fileName:       "users.txt",
+			job:            &entities.UploadJob{ID: "a1b2c3d4"},
+			result:         &user.UserFileResult{Format: user.FormatFixedWidth, ProcessedLines: 2, AcceptedLines: 2},
+			expectedSubdir: filesystem.InboxProcessedDir,
+		},
+		{
+			description:    "should move a file that failed to load to failed with the error",
+			fileName:       "users.csv",
+			job:            &entities.UploadJob{ID: "a1b2c3d4"},
+			result:         &user.UserFileResult{Format: user.FormatCSV, ProcessedLines: 1},
+			err:            fmt.Errorf("csv header misses the user_id column"),
+			expectedSubdir: filesystem.InboxFailedDir,
+			expectedError:  "csv header misses the user_id column",
+		},
+		{
+			description:    "should move a file already imported to failed with the job that imported it",
+			fileName:       "users.txt",
+			job:            &entities.UploadJob{ID: "e5f6a7b8", State: entities.UploadJobDone},
+			err:            errors.ErrUploadAlreadyImported,
+			expectedSubdir: filesystem.InboxFailedDir,
+			expectedError:  errors.ErrUploadAlreadyImported.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			dir := t.TempDir()
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, tt.fileName), []byte("mocked content"), 0o644))
+
+			mups := mockupload.NewMockService(ctrl)
+			mups.
+				EXPECT().
+				Import(gomock.Any(), tt.fileName, gomock.Any(), inboxImportOptions).
+				Return(tt.job, tt.result, tt.err).
+				Times(1)
+
+			watcher, err := filesystem.NewInboxWatcher(mups, dir, inboxPollInterval)
+			assert.NoError(t, err)
+
+			target := filepath.Join(dir, tt.expectedSubdir, tt.fileName)
+			assert.Eventually(t, func() bool {
+				_, err := os.Stat(target)
+				return err == nil
+			}, time.Second, inboxPollInterval)
+			assert.NoError(t, watcher.Shutdown(context.Background()))
+
+			assert.NoFileExists(t, filepath.Join(dir, tt.fileName))
+
+			content, err := os.ReadFile(target + ".result.json")
+			assert.NoError(t, err)
+
+			var sidecar map[string]any
+			assert.NoError(t, json.Unmarshal(content, &sidecar))
+			result := tt.result
+			if result == nil {
+				result = new(user.UserFileResult)
+			}
+
+			assert.Equal(t, tt.fileName, sidecar["file_name"])
+			assert.Equal(t, tt.job.ID, sidecar["job_id"])
+			assert.Equal(t, string(result.Format), sidecar["format"])
+			assert.Equal(t, float64(result.ProcessedLines), sidecar["processed_lines"])
+			if tt.expectedError != "" {
+				assert.Equal(t, tt.expectedError, sidecar["error"])
+			} else {
+				assert.NotContains(t, sidecar, "error")
+			}
+		})
+	}
+}
+
+func Test_InboxWatcher_PartialFiles(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dir := t.TempDir()
+	for _, name := range []string{".hidden.txt", "users.txt.part", "users.tmp"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("mocked content"), 0o644))
+	}
+
+	// The file keeps growing for a few polls and must only be loaded once it is complete
+	path := filepath.Join(dir, "users.txt")
+	expected := strings.Repeat("line\n", 50)
+
+	var mu sync.Mutex
+	loaded := make([]string, 0)
+
+	mups := mockupload.NewMockService(ctrl)
+	mups.
+		EXPECT().
+		Import(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fileName string, file io.ReadSeeker, opts upload.SubmitOptions) (*entities.UploadJob, *user.UserFileResult, error) {
+			content, err := io.ReadAll(file)
+			mu.Lock()
+			loaded = append(loaded, string(content))
+			mu.Unlock()
+			return &entities.UploadJob{ID: "a1b2c3d4"}, new(user.UserFileResult), err
+		}).
+		AnyTimes()
+
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+
+	watcher, err := filesystem.NewInboxWatcher(mups, dir, inboxPollInterval)
+	assert.NoError(t, err)
+
+	for i := 0; i < 50; i++ {
+		_, err := file.WriteString("line\n")
+		assert.NoError(t, err)
+		time.Sleep(inboxPollInterval / 5)
+	}
+	assert.NoError(t, file.Close())
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, filesystem.InboxProcessedDir, "users.txt"))
+		return err == nil
+	}, time.Second, inboxPollInterval)
+
+	// A few more polls to make sure the ignored files are left alone
+	time.Sleep(5 * inboxPollInterval)
+	assert.NoError(t, watcher.Shutdown(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{expected}, loaded)
+
+	for _, name := range []string{".hidden.txt", "users.txt.part", "users.tmp"} {
+		assert.FileExists(t, filepath.Join(dir, name))
+	}
+}
+
+func Test_InboxWatcher_UnarchivedFiles(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	dir := t.TempDir()
+
+	var mu sync.Mutex
+	loads := 0
+
+	mups := mockupload.NewMockService(ctrl)
+	mups.
+		EXPECT().
+		Import(gomock.Any(), "users.txt", gomock.Any(), inboxImportOptions).
+		DoAndReturn(func(ctx context.Context, fileName string, file io.ReadSeeker, opts upload.SubmitOptions) (*entities.UploadJob, *user.UserFileResult, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			loads++
+			return &entities.UploadJob{ID: "a1b2c3d4"}, new(user.UserFileResult), nil
+		}).
+		AnyTimes()
+
+	watcher, err := filesystem.NewInboxWatcher(mups, dir, inboxPollInterval)
+	assert.NoError(t, err)
+
+	// The processed folder is replaced by a broken link, so loaded files can not be moved into it
+	processed := filepath.Join(dir, filesystem.InboxProcessedDir)
+	assert.NoError(t, os.Remove(processed))
+	assert.NoError(t, os.Symlink(filepath.Join(dir, "missing"), processed))
+
+	path := filepath.Join(dir, "users.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("mocked content"), 0o644))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return loads > 0
+	}, time.Second, inboxPollInterval)
+
+	// The file is left in the inbox and not loaded again while it does not change
+	time.Sleep(10 * inboxPollInterval)
+	mu.Lock()
+	assert.Equal(t, 1, loads)
+	mu.Unlock()
+	assert.FileExists(t, path)
+
+	// Once it changes it is a new file, loaded again
+	assert.NoError(t, os.WriteFile(path, []byte("mocked content, fixed"), 0o644))
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return loads == 2
+	}, time.Second, inboxPollInterval)
+
+	assert.NoError(t, watcher.Shutdown(context.Background()))
+}
@@ -0,0 +1,62 @@
+package filesystem
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/layout"
+)
+
+// LoadLayouts reads every .json file of dir as a record layout, so new partner layouts
+// can be registered without changing the code. Layouts are validated and their names
+// must be unique and different from the default layout
+func LoadLayouts(dir string) ([]*entities.RecordLayout, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	layouts := make([]*entities.RecordLayout, 0, len(paths))
+	names := map[string]string{layout.DefaultName: "the default layout"}
+
+	for _, path := range paths {
+		recordLayout, err := loadLayout(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		if owner, ok := names[recordLayout.Name]; ok {
+			return nil, fmt.Errorf("%s: layout %s is already registered by %s", path, recordLayout.Name, owner)
+		}
+		names[recordLayout.Name] = path
+
+		layouts = append(layouts, recordLayout)
+	}
+
+	return layouts, nil
+}
+
+func loadLayout(path string) (*entities.RecordLayout, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+
+	recordLayout := new(entities.RecordLayout)
+	if err := decoder.Decode(recordLayout); err != nil {
+		return nil, err
+	}
+
+	if err := recordLayout.Validate(); err != nil {
+		return nil, err
+	}
+
+	return recordLayout, nil
+}
@@ -0,0 +1,173 @@
+package filesystem_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/filesystem"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+)
+
+const mockLayoutFile = `{
+	"name": "v2",
+	"description": "dates first, values in cents",
+	"fields": [
+		{"name": "order_date", "offset": 0, "width": 10, "type": "date", "format": "DD/MM/YYYY"},
+		{"name": "user_id", "offset": 10, "width": 10, "type": "integer", "padding": "0"},
+		{"name": "user_name", "offset": 20, "width": 30, "type": "string", "align": "left"},
+		{"name": "order_id", "offset": 50, "width": 10, "type": "integer"},
+		{"name": "product_id", "offset": 60, "width": 10, "type": "integer"},
+		{"name": "product_value", "offset": 70, "width": 10, "type": "decimal", "decimals": 2}
+	]
+}`
+
+// withControl adds header and trailer records to the layout file
+func withControl(header, trailer string) string {
+	return mockLayoutFile[:len(mockLayoutFile)-len("\n}")] + `,
+	"header": ` + header + `,
+	"trailer": ` + trailer + `
+}`
+}
+
+const (
+	mockHeader = `{"identifier": "HDR", "fields": [
+		{"name": "file_date", "offset": 3, "width": 8, "type": "date", "format": "YYYYMMDD"},
+		{"name": "sender", "offset": 11, "width": 20, "type": "string", "align": "left"}
+	]}`
+	mockTrailer = `{"identifier": "TRL", "fields": [
+		{"name": "record_count", "offset": 3, "width": 10, "type": "integer"},
+		{"name": "value_sum", "offset": 13, "width": 15, "type": "decimal", "decimals": 2}
+	]}`
+)
+
+func Test_LoadLayouts(t *testing.T) {
+	tests := []struct {
+		description   string
+		files         map[string]string
+		expectedNames []string
+		isErrExpected bool
+	}{
+		{
+			description:   "should load every json layout of the directory",
+			files:         map[string]string{"v2.json": mockLayoutFile, "README.md": "not a layout"},
+			expectedNames: []string{"v2"},
+		},
+		{
+			description:   "should load nothing from an empty directory",
+			files:         map[string]string{},
+			expectedNames: []string{},
+		},
+		{
+			description:   "should return error when a layout is not valid json",
+			files:         map[string]string{"v2.json": `{"name": "v2",`},
+			isErrExpected: true,
+		},
+		{
+			description:   "should return error when a layout has unknown attributes",
+			files:         map[string]string{"v2.json": `{"name": "v2", "fields": [], "version": 2}`},
+			isErrExpected: true,
+		},
+		{
+			description:   "should return error when a layout misses fields",
+			files:         map[string]string{"v2.json": `{"name": "v2", "fields": [{"name": "user_id", "offset": 0, "width": 10, "type": "integer"}]}`},
+			isErrExpected: true,
+		},
+		{
+			description: "should return error when fields overlap",
+			files: map[string]string{"v2.json": `{"name": "v2", "fields": [
+				{"name": "order_date", "offset": 0, "width": 10, "type": "date", "format": "DD/MM/YYYY"},
+				{"name": "user_id", "offset": 5, "width": 10, "type": "integer"},
+				{"name": "user_name", "offset": 20, "width": 30, "type": "string"},
+				{"name": "order_id", "offset": 50, "width": 10, "type": "integer"},
+				{"name": "product_id", "offset": 60, "width": 10, "type": "integer"},
+				{"name": "product_value", "offset": 70, "width": 10, "type": "decimal"}
+			]}`},
+			isErrExpected: true,
+		},
+		{
+			description:   "should return error when a field has the wrong type",
+			files:         map[string]string{"v2.json": `{"name": "v2", "fields": [{"name": "user_id", "offset": 0, "width": 10, "type": "string"}]}`},
+			isErrExpected: true,
+		},
+		{
+			description:   "should load a layout with header and trailer records",
+			files:         map[string]string{"v2.json": withControl(mockHeader, mockTrailer)},
+			expectedNames: []string{"v2"},
+		},
+		{
+			description:   "should return error when the trailer does not count the records",
+			files:         map[string]string{"v2.json": withControl(mockHeader, `{"identifier": "TRL", "fields": [{"name": "value_sum", "offset": 3, "width": 15, "type": "decimal"}]}`)},
+			isErrExpected: true,
+		},
+		{
+			description:   "should return error when a header field overlaps the identifier",
+			files:         map[string]string{"v2.json": withControl(`{"identifier": "HDR", "fields": [{"name": "sender", "offset": 2, "width": 20, "type": "string"}]}`, mockTrailer)},
+			isErrExpected: true,
+		},
+		{
+			description:   "should return error when a header field is unknown",
+			files:         map[string]string{"v2.json": withControl(`{"identifier": "HDR", "fields": [{"name": "user_id", "offset": 3, "width": 10, "type": "integer"}]}`, mockTrailer)},
+			isErrExpected: true,
+		},
+		{
+			description:   "should return error when header and trailer have the same identifier",
+			files:         map[string]string{"v2.json": withControl(mockHeader, strings.Replace(mockTrailer, "TRL", "HDR", 1))},
+			isErrExpected: true,
+		},
+		{
+			description:   "should return error when two files register the same layout",
+			files:         map[string]string{"a.json": mockLayoutFile, "b.json": mockLayoutFile},
+			isErrExpected: true,
+		},
+		{
+			description:   "should return error when a file replaces the default layout",
+			files:         map[string]string{"v1.json": `{"name": "v1"` + mockLayoutFile[len(`{"name": "v2"`):]},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+					panic(err)
+				}
+			}
+
+			layouts, err := filesystem.LoadLayouts(dir)
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			names := make([]string, 0)
+			for _, layout := range layouts {
+				names = append(names, layout.Name)
+			}
+			assert.Equal(t, tt.expectedNames, names)
+		})
+	}
+}
+
+func Test_LoadLayouts_Fields(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "v2.json"), []byte(mockLayoutFile), 0o600); err != nil {
+		panic(err)
+	}
+
+	layouts, err := filesystem.LoadLayouts(dir)
+	assert.NoError(t, err)
+	assert.Len(t, layouts, 1)
+
+	layout := layouts[0]
+	assert.Equal(t, 80, layout.LineLength())
+	assert.Equal(t, entities.FieldDate, layout.Fields[0].Type)
+	assert.Equal(t, "02/01/2006", layout.Fields[0].TimeLayout())
+	assert.Equal(t, entities.AlignLeft, layout.Fields[2].Align)
+	assert.Equal(t, 2, layout.Fields[5].Decimals)
+}
@@ -0,0 +1,372 @@
+package filesystem
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	domainerrors "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+)
+
+// Every resumable upload is a pair of files: its options and the bytes received so far,
+// next to the SHA-256 of those bytes
+const (
+	resumableInfoSuffix = ".json"
+	resumableDataSuffix = ".part"
+	resumableHashSuffix = ".sha256"
+)
+
+// resumableUploadInfo is what is kept of a resumable upload besides its bytes. The offset and
+// the time of the last chunk come from the file with the bytes
+type resumableUploadInfo struct {
+	ID            string    `json:"id"`
+	FileName      string    `json:"file_name"`
+	Length        int64     `json:"length"`
+	UploadedBy    string    `json:"uploaded_by"`
+	Forced        bool      `json:"forced"`
+	Layout        string    `json:"layout"`
+	Format        string    `json:"format"`
+	Encoding      string    `json:"encoding"`
+	Mode          string    `json:"mode"`
+	SnapshotStart string    `json:"snapshot_start"`
+	SnapshotEnd   string    `json:"snapshot_end"`
+	DryRun        bool      `json:"dry_run"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// resumableHashState is the state of the SHA-256 of the first Offset bytes of an upload, so the
+// bytes are fingerprinted as they are received instead of being read again once complete
+type resumableHashState struct {
+	Offset int64  `json:"offset"`
+	State  []byte `json:"state"`
+}
+
+// resumableUploadRepository keeps the resumable uploads on local disk, so they survive restarts
+type resumableUploadRepository struct {
+	dir string
+}
+
+// NewResumableUploadRepository keeps the resumable uploads in dir, creating it when missing
+func NewResumableUploadRepository(dir string) (*resumableUploadRepository, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create resumable upload folder: %w", err)
+	}
+
+	return &resumableUploadRepository{
+		dir: dir,
+	}, nil
+}
+
+// Create writes the bytes file before the options, an upload is only found once both exist
+func (r *resumableUploadRepository) Create(upload *entities.ResumableUpload) error {
+	if !validResumableID(upload.ID) {
+		return fmt.Errorf("invalid resumable upload id %q", upload.ID)
+	}
+
+	data, err := os.OpenFile(r.path(upload.ID, resumableDataSuffix), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	data.Close()
+
+	info, err := json.Marshal(&resumableUploadInfo{
+		ID:            upload.ID,
+		FileName:      upload.FileName,
+		Length:        upload.Length,
+		UploadedBy:    upload.UploadedBy,
+		Forced:        upload.Forced,
+		Layout:        upload.Layout,
+		Format:        upload.Format,
+		Encoding:      upload.Encoding,
+		Mode:          upload.Mode,
+		SnapshotStart: upload.SnapshotStart,
+		SnapshotEnd:   upload.SnapshotEnd,
+		DryRun:        upload.DryRun,
+		CreatedAt:     upload.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The options are renamed in place, so a crash never leaves them half written
+	tmp := r.path(upload.ID, resumableInfoSuffix+".tmp")
+	if err := os.WriteFile(tmp, info, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, r.path(upload.ID, resumableInfoSuffix))
+}
+
+func (r *resumableUploadRepository) Get(id string) (*entities.ResumableUpload, error) {
+	if !validResumableID(id) {
+		return nil, domainerrors.ErrResumableUploadNotFound
+	}
+
+	content, err := os.ReadFile(r.path(id, resumableInfoSuffix))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, domainerrors.ErrResumableUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info := new(resumableUploadInfo)
+	if err := json.Unmarshal(content, info); err != nil {
+		return nil, fmt.Errorf("resumable upload %s: %w", id, err)
+	}
+
+	stat, err := os.Stat(r.path(id, resumableDataSuffix))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, domainerrors.ErrResumableUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.ResumableUpload{
+		ID:            info.ID,
+		FileName:      info.FileName,
+		Length:        info.Length,
+		Offset:        stat.Size(),
+		UploadedBy:    info.UploadedBy,
+		Forced:        info.Forced,
+		Layout:        info.Layout,
+		Format:        info.Format,
+		Encoding:      info.Encoding,
+		Mode:          info.Mode,
+		SnapshotStart: info.SnapshotStart,
+		SnapshotEnd:   info.SnapshotEnd,
+		DryRun:        info.DryRun,
+		CreatedAt:     info.CreatedAt,
+		UpdatedAt:     stat.ModTime(),
+	}, nil
+}
+
+// Append syncs what was written before returning, so the offset reported to the client is
+// never ahead of the bytes that would be found after a crash. The chunk is hashed as it is
+// written, and the hash is only kept when it holds every byte written
+func (r *resumableUploadRepository) Append(id string, chunk io.Reader) (int64, error) {
+	if !validResumableID(id) {
+		return 0, domainerrors.ErrResumableUploadNotFound
+	}
+
+	data, err := os.OpenFile(r.path(id, resumableDataSuffix), os.O_WRONLY|os.O_APPEND, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, domainerrors.ErrResumableUploadNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer data.Close()
+
+	stat, err := data.Stat()
+	if err != nil {
+		return 0, err
+	}
+	offset := stat.Size()
+
+	digest, err := r.hash(id, offset)
+	if err != nil {
+		return offset, err
+	}
+
+	// The data is written before the hash, so a failed write leaves the hash behind the data
+	written, copyErr := io.Copy(io.MultiWriter(data, digest), chunk)
+
+	if err := data.Sync(); err != nil {
+		return 0, err
+	}
+
+	stat, err = data.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	if stat.Size() == offset+written {
+		err = r.saveHash(id, digest, stat.Size())
+	} else {
+		err = os.Remove(r.path(id, resumableHashSuffix))
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return stat.Size(), err
+	}
+
+	return stat.Size(), copyErr
+}
+
+// Checksum returns the SHA-256 of the bytes received, hashing them again only when a crash
+// left the kept hash behind them
+func (r *resumableUploadRepository) Checksum(id string) (string, error) {
+	if !validResumableID(id) {
+		return "", domainerrors.ErrResumableUploadNotFound
+	}
+
+	stat, err := os.Stat(r.path(id, resumableDataSuffix))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", domainerrors.ErrResumableUploadNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	digest, err := r.hash(id, stat.Size())
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// Path returns the file holding the bytes received, so a finished upload can be moved
+// instead of copied
+func (r *resumableUploadRepository) Path(id string) (string, error) {
+	if !validResumableID(id) {
+		return "", domainerrors.ErrResumableUploadNotFound
+	}
+
+	path := r.path(id, resumableDataSuffix)
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return "", domainerrors.ErrResumableUploadNotFound
+	} else if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// Remove removes the options first, so an upload half removed is no longer found. The bytes
+// may already be gone, moved by the job of the upload
+func (r *resumableUploadRepository) Remove(id string) error {
+	if !validResumableID(id) {
+		return domainerrors.ErrResumableUploadNotFound
+	}
+
+	err := os.Remove(r.path(id, resumableInfoSuffix))
+	if errors.Is(err, fs.ErrNotExist) {
+		return domainerrors.ErrResumableUploadNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, suffix := range []string{resumableDataSuffix, resumableHashSuffix} {
+		if err := os.Remove(r.path(id, suffix)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hash returns the hash of the first offset bytes of the upload, from the kept state when it
+// covers them, or reading them again otherwise
+func (r *resumableUploadRepository) hash(id string, offset int64) (hash.Hash, error) {
+	digest := sha256.New()
+
+	content, err := os.ReadFile(r.path(id, resumableHashSuffix))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	state := new(resumableHashState)
+	if err == nil && json.Unmarshal(content, state) == nil && state.Offset == offset {
+		if err := digest.(encoding.BinaryUnmarshaler).UnmarshalBinary(state.State); err == nil {
+			return digest, nil
+		}
+		digest.Reset()
+	}
+
+	data, err := os.Open(r.path(id, resumableDataSuffix))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, domainerrors.ErrResumableUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+
+	if _, err := io.CopyN(digest, data, offset); err != nil {
+		return nil, err
+	}
+
+	return digest, nil
+}
+
+// saveHash keeps the state of the hash of the first offset bytes, renamed in place as the options
+func (r *resumableUploadRepository) saveHash(id string, digest hash.Hash, offset int64) error {
+	state, err := digest.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(&resumableHashState{Offset: offset, State: state})
+	if err != nil {
+		return err
+	}
+
+	tmp := r.path(id, resumableHashSuffix+".tmp")
+	if err := os.WriteFile(tmp, content, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, r.path(id, resumableHashSuffix))
+}
+
+// RemoveIdle also removes the bytes left behind by uploads whose options are missing, like
+// the ones of a crash while an upload was created or removed
+func (r *resumableUploadRepository) RemoveIdle(before time.Time) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(r.dir, "*"+resumableDataSuffix))
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, path := range paths {
+		stat, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		if !stat.ModTime().Before(before) {
+			continue
+		}
+
+		id := strings.TrimSuffix(filepath.Base(path), resumableDataSuffix)
+		for _, suffix := range []string{resumableInfoSuffix, resumableDataSuffix, resumableHashSuffix} {
+			if err := os.Remove(r.path(id, suffix)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return removed, err
+			}
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+func (r *resumableUploadRepository) path(id, suffix string) string {
+	return filepath.Join(r.dir, id+suffix)
+}
+
+// validResumableID tells whether the id is one the service creates, a hex string, so an id
+// coming from a request can never point outside of the folder
+func validResumableID(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
@@ -0,0 +1,141 @@
+package filesystem_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/filesystem"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+)
+
+func newResumableUpload(id string) *entities.ResumableUpload {
+	return &entities.ResumableUpload{
+		ID:         id,
+		FileName:   "users.txt",
+		Length:     10,
+		UploadedBy: "partner",
+		Layout:     "v1",
+		Format:     "auto",
+		Encoding:   "utf-8",
+		CreatedAt:  time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+func Test_ResumableUploadRepository(t *testing.T) {
+	dir := t.TempDir()
+	repository, err := filesystem.NewResumableUploadRepository(dir)
+	assert.NoError(t, err)
+
+	assert.NoError(t, repository.Create(newResumableUpload("a1b2c3")))
+
+	stored, err := repository.Get("a1b2c3")
+	assert.NoError(t, err)
+	assert.Equal(t, "users.txt", stored.FileName)
+	assert.Equal(t, int64(10), stored.Length)
+	assert.Equal(t, int64(0), stored.Offset)
+	assert.Equal(t, "partner", stored.UploadedBy)
+	assert.Equal(t, time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC), stored.CreatedAt)
+
+	offset, err := repository.Append("a1b2c3", strings.NewReader("0123"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), offset)
+
+	// A chunk cut short keeps what was received of it
+	offset, err = repository.Append("a1b2c3", iotest.TimeoutReader(strings.NewReader("4567")))
+	assert.ErrorIs(t, err, iotest.ErrTimeout)
+	assert.Equal(t, int64(8), offset)
+
+	offset, err = repository.Append("a1b2c3", strings.NewReader("89"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), offset)
+
+	stored, err = repository.Get("a1b2c3")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), stored.Offset)
+
+	path, err := repository.Path("a1b2c3")
+	assert.NoError(t, err)
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(content))
+
+	// The bytes were hashed as they were appended, and are hashed again when a crash lost the hash
+	expectedSHA256 := fmt.Sprintf("%x", sha256.Sum256([]byte("0123456789")))
+	checksum, err := repository.Checksum("a1b2c3")
+	assert.NoError(t, err)
+	assert.Equal(t, expectedSHA256, checksum)
+
+	assert.NoError(t, os.Remove(filepath.Join(dir, "a1b2c3.sha256")))
+	checksum, err = repository.Checksum("a1b2c3")
+	assert.NoError(t, err)
+	assert.Equal(t, expectedSHA256, checksum)
+
+	assert.NoError(t, repository.Remove("a1b2c3"))
+	_, err = repository.Get("a1b2c3")
+	assert.ErrorIs(t, err, errors.ErrResumableUploadNotFound)
+	assert.ErrorIs(t, repository.Remove("a1b2c3"), errors.ErrResumableUploadNotFound)
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func Test_ResumableUploadRepository_InvalidID(t *testing.T) {
+	repository, err := filesystem.NewResumableUploadRepository(t.TempDir())
+	assert.NoError(t, err)
+
+	for _, id := range []string{"", "../a1b2c3", "a1b2c3.json"} {
+		_, err := repository.Get(id)
+		assert.ErrorIs(t, err, errors.ErrResumableUploadNotFound)
+
+		_, err = repository.Append(id, strings.NewReader("0123"))
+		assert.ErrorIs(t, err, errors.ErrResumableUploadNotFound)
+
+		_, err = repository.Checksum(id)
+		assert.ErrorIs(t, err, errors.ErrResumableUploadNotFound)
+
+		_, err = repository.Path(id)
+		assert.ErrorIs(t, err, errors.ErrResumableUploadNotFound)
+	}
+
+	assert.Error(t, repository.Create(newResumableUpload("../a1b2c3")))
+}
+
+func Test_ResumableUploadRepository_RemoveIdle(t *testing.T) {
+	dir := t.TempDir()
+	repository, err := filesystem.NewResumableUploadRepository(dir)
+	assert.NoError(t, err)
+
+	for _, id := range []string{"a1", "b2"} {
+		assert.NoError(t, repository.Create(newResumableUpload(id)))
+	}
+
+	// The bytes left behind by an upload whose options are gone are removed as well
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "c3.part"), []byte("0123"), 0o644))
+
+	idle := time.Now().Add(-2 * time.Hour)
+	for _, name := range []string{"a1.part", "c3.part"} {
+		assert.NoError(t, os.Chtimes(filepath.Join(dir, name), idle, idle))
+	}
+
+	removed, err := repository.RemoveIdle(time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	_, err = repository.Get("a1")
+	assert.ErrorIs(t, err, errors.ErrResumableUploadNotFound)
+	_, err = repository.Get("b2")
+	assert.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
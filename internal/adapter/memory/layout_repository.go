@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+)
+
+// layoutRepository keeps the record layouts registered at startup
+type layoutRepository struct {
+	layouts map[string]*entities.RecordLayout
+}
+
+func NewLayoutRepository(layouts ...*entities.RecordLayout) *layoutRepository {
+	r := &layoutRepository{
+		layouts: make(map[string]*entities.RecordLayout),
+	}
+
+	for _, layout := range layouts {
+		r.layouts[layout.Name] = layout
+	}
+
+	return r
+}
+
+func (r *layoutRepository) Get(name string) (*entities.RecordLayout, error) {
+	layout, ok := r.layouts[name]
+	if !ok {
+		return nil, errors.ErrLayoutNotFound
+	}
+
+	return layout, nil
+}
+
+func (r *layoutRepository) GetAll() ([]*entities.RecordLayout, error) {
+	layouts := make([]*entities.RecordLayout, 0, len(r.layouts))
+	for _, layout := range r.layouts {
+		layouts = append(layouts, layout)
+	}
+
+	sort.Slice(layouts, func(i, j int) bool {
+		return layouts[i].Name < layouts[j].Name
+	})
+
+	return layouts, nil
+}
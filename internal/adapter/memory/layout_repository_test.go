@@ -0,0 +1,58 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/memory"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/layout"
+)
+
+func Test_Get_LayoutRepository(t *testing.T) {
+	mockLayout := &entities.RecordLayout{Name: "v2"}
+
+	tests := []struct {
+		description    string
+		name           string
+		expectedLayout *entities.RecordLayout
+		expectedErr    error
+	}{
+		{
+			description:    "should return registered layout",
+			name:           "v2",
+			expectedLayout: mockLayout,
+		},
+		{
+			description: "should return error when layout is not registered",
+			name:        "v3",
+			expectedErr: errors.ErrLayoutNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			layoutRepository := memory.NewLayoutRepository(layout.Default(), mockLayout)
+
+			recordLayout, err := layoutRepository.Get(tt.name)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedLayout, recordLayout)
+		})
+	}
+}
+
+func Test_GetAll_LayoutRepository(t *testing.T) {
+	layoutRepository := memory.NewLayoutRepository(&entities.RecordLayout{Name: "v2"}, layout.Default())
+
+	layouts, err := layoutRepository.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, layouts, 2)
+	assert.Equal(t, "v1", layouts[0].Name)
+	assert.Equal(t, "v2", layouts[1].Name)
+}
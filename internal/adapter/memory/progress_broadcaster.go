@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+)
+
+// progressBroadcaster hands the progress to the subscribers in the same process. Every
+// subscriber holds a single pending progress, replaced by the newer ones it did not read yet
+type progressBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan *entities.IngestionProgress]struct{}
+}
+
+func NewProgressBroadcaster() *progressBroadcaster {
+	return &progressBroadcaster{
+		subscribers: make(map[string]map[chan *entities.IngestionProgress]struct{}),
+	}
+}
+
+func (b *progressBroadcaster) Publish(progress *entities.IngestionProgress) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for events := range b.subscribers[progress.BatchID] {
+		// Publishing is serialized, so once the stale progress is taken out there is room
+		select {
+		case events <- progress:
+		default:
+			select {
+			case <-events:
+			default:
+			}
+			events <- progress
+		}
+	}
+}
+
+func (b *progressBroadcaster) Subscribe(batchID string) (<-chan *entities.IngestionProgress, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := make(chan *entities.IngestionProgress, 1)
+	if b.subscribers[batchID] == nil {
+		b.subscribers[batchID] = make(map[chan *entities.IngestionProgress]struct{})
+	}
+	b.subscribers[batchID][events] = struct{}{}
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		// The batch may have been closed already, which closed the channel
+		if _, ok := b.subscribers[batchID][events]; !ok {
+			return
+		}
+
+		delete(b.subscribers[batchID], events)
+		if len(b.subscribers[batchID]) == 0 {
+			delete(b.subscribers, batchID)
+		}
+		close(events)
+	}
+
+	return events, cancel
+}
+
+// Close closes the channels after the progress still pending, which is read before the close
+func (b *progressBroadcaster) Close(batchID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for events := range b.subscribers[batchID] {
+		close(events)
+	}
+	delete(b.subscribers, batchID)
+}
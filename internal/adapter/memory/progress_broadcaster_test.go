@@ -0,0 +1,68 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/memory"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+)
+
+func Test_PublishAndSubscribe_ProgressBroadcaster(t *testing.T) {
+	progressBroadcaster := memory.NewProgressBroadcaster()
+
+	first, cancelFirst := progressBroadcaster.Subscribe("f1e2d3c4")
+	defer cancelFirst()
+	second, cancelSecond := progressBroadcaster.Subscribe("f1e2d3c4")
+	other, cancelOther := progressBroadcaster.Subscribe("a1b2c3d4")
+	defer cancelOther()
+
+	progressBroadcaster.Publish(&entities.IngestionProgress{BatchID: "f1e2d3c4", ReadLines: 10})
+	assert.Equal(t, 10, (<-first).ReadLines)
+	assert.Equal(t, 10, (<-second).ReadLines)
+
+	// A canceled subscriber stops getting progress, and canceling twice is harmless
+	cancelSecond()
+	cancelSecond()
+	_, ok := <-second
+	assert.False(t, ok)
+
+	// A subscriber that falls behind only gets the latest progress
+	progressBroadcaster.Publish(&entities.IngestionProgress{BatchID: "f1e2d3c4", ReadLines: 20})
+	progressBroadcaster.Publish(&entities.IngestionProgress{BatchID: "f1e2d3c4", ReadLines: 30})
+	assert.Equal(t, 30, (<-first).ReadLines)
+
+	// The progress still pending is read before the channel is closed
+	progressBroadcaster.Publish(&entities.IngestionProgress{BatchID: "f1e2d3c4", ReadLines: 40, Done: true})
+	progressBroadcaster.Close("f1e2d3c4")
+
+	progress, ok := <-first
+	assert.True(t, ok)
+	assert.True(t, progress.Done)
+	_, ok = <-first
+	assert.False(t, ok)
+
+	// The progress of the other batches is not sent to them
+	select {
+	case progress := <-other:
+		t.Fatalf("unexpected progress of batch %s", progress.BatchID)
+	default:
+	}
+}
+
+func Test_Publish_ProgressBroadcaster_NoSubscribers(t *testing.T) {
+	progressBroadcaster := memory.NewProgressBroadcaster()
+
+	// Nothing to send to, so publishing and closing must neither block nor fail
+	progressBroadcaster.Publish(&entities.IngestionProgress{BatchID: "f1e2d3c4", ReadLines: 10})
+	progressBroadcaster.Close("f1e2d3c4")
+
+	events, cancel := progressBroadcaster.Subscribe("f1e2d3c4")
+	defer cancel()
+
+	select {
+	case <-events:
+		t.Fatal("unexpected progress published before subscribing")
+	default:
+	}
+}
@@ -0,0 +1,88 @@
+package postgres_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/memory"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/postgres"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/postgres/repositories"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/layout"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+const benchmarkLines = 10000
+
+// The load benchmarks need a migrated database pointed by POSTGRES_BENCH_DSN.
+// They truncate the ingestion tables on every run, so never point it to a database with data to keep
+func BenchmarkLoadUsersDataFile_PerRow(b *testing.B) {
+	benchmarkLoadUsersDataFile(b, 0)
+}
+
+func BenchmarkLoadUsersDataFile_Bulk(b *testing.B) {
+	benchmarkLoadUsersDataFile(b, 1)
+}
+
+func benchmarkLoadUsersDataFile(b *testing.B, bulkThreshold int) {
+	dsn := os.Getenv("POSTGRES_BENCH_DSN")
+	if dsn == "" {
+		b.Skip("POSTGRES_BENCH_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+
+	userService := services.NewUserService(
+		repositories.NewUserRepository(db),
+		memory.NewLayoutRepository(layout.Default()),
+		postgres.NewUnitOfWork(db),
+		nil,
+		user.LoadConfig{BulkThreshold: bulkThreshold},
+	)
+	file := generateUsersDataFile(benchmarkLines)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		if _, err := db.Exec(`TRUNCATE order_products, orders, products, users`); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+
+		result, err := userService.LoadUsersDataFile(context.Background(), bytes.NewReader(file), user.LoadOptions{})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if result.AcceptedLines != benchmarkLines {
+			b.Fatalf("expected %d accepted lines, got %d", benchmarkLines, result.AcceptedLines)
+		}
+	}
+}
+
+// generateUsersDataFile builds a fixed-width file spreading the lines over a few users, orders and products
+func generateUsersDataFile(lines int) []byte {
+	buf := new(bytes.Buffer)
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(
+			buf,
+			"%010d%45s%010d%010d%12.2f%s\n",
+			i%500+1,
+			fmt.Sprintf("User %d", i%500+1),
+			i%2000+1,
+			i%100+1,
+			float64(i%100000)/100,
+			"20210308",
+		)
+	}
+
+	return buf.Bytes()
+}
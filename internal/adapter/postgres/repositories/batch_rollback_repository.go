@@ -0,0 +1,212 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+)
+
+// The rows a batch inserted carry its ID in batch_id and the values it overwrote, or the rows it
+// deleted, are kept in ingestion_batch_changes by the change triggers, so the batches are rolled
+// back with plain SQL
+const (
+	lockIngestionTablesQuery string = `LOCK TABLE users, products, orders, order_products IN SHARE ROW EXCLUSIVE MODE`
+	// The batch rows are the ones the batches inserted and the ones they changed, since their
+	// first change. Dependents changed a batch row afterwards or reference a row the batches
+	// inserted, except for orders whose user is restored by the rollback. Dependents also took
+	// the place of a row the batches deleted or deleted the order of a line the batches deleted,
+	// which could not be inserted again
+	getBatchDependentsQuery string = `WITH touched AS (
+		SELECT 'users' AS table_name, u.id AS row_id, 0::bigint AS since FROM users u WHERE u.batch_id = ANY($1)
+		UNION ALL
+		SELECT 'orders', o.id, 0 FROM orders o WHERE o.batch_id = ANY($1)
+		UNION ALL
+		SELECT c.table_name, c.row_id, MIN(c.id) FROM ingestion_batch_changes c
+		WHERE c.batch_id = ANY($1)
+		GROUP BY c.table_name, c.row_id
+	), dependents AS (
+		SELECT c.batch_id FROM ingestion_batch_changes c
+		JOIN touched t ON t.table_name = c.table_name AND t.row_id = c.row_id AND c.id > t.since
+		WHERE c.batch_id IS NULL OR c.batch_id <> ALL($1)
+		UNION
+		SELECT o.batch_id FROM orders o
+		JOIN users u ON u.id = o.user_id
+		WHERE u.batch_id = ANY($1) AND (o.batch_id IS NULL OR o.batch_id <> ALL($1))
+			AND NOT EXISTS (
+				SELECT 1 FROM ingestion_batch_changes c
+				WHERE c.table_name = 'orders' AND c.row_id = o.id AND c.batch_id = ANY($1)
+			)
+		UNION
+		SELECT op.batch_id FROM order_products op
+		JOIN orders o ON o.id = op.order_id
+		WHERE o.batch_id = ANY($1) AND (op.batch_id IS NULL OR op.batch_id <> ALL($1))
+		UNION
+		SELECT op.batch_id FROM order_products op
+		JOIN products p ON p.id = op.product_id
+		WHERE p.batch_id = ANY($1) AND (op.batch_id IS NULL OR op.batch_id <> ALL($1))
+		UNION
+		SELECT o.batch_id FROM orders o
+		JOIN ingestion_batch_changes c ON c.table_name = 'orders' AND c.operation = 'DELETE' AND c.row_id = o.id
+		WHERE c.batch_id = ANY($1) AND (o.batch_id IS NULL OR o.batch_id <> ALL($1))
+		UNION
+		SELECT op.batch_id FROM order_products op
+		JOIN ingestion_batch_changes c ON c.table_name = 'order_products' AND c.operation = 'DELETE'
+			AND op.order_id = (c.previous->>'order_id')::integer AND op.product_id = (c.previous->>'product_id')::integer
+			AND op.value = (c.previous->>'value')::numeric AND op.position = (c.previous->>'position')::integer
+		WHERE c.batch_id = ANY($1) AND (op.batch_id IS NULL OR op.batch_id <> ALL($1))
+		UNION
+		SELECT d.batch_id FROM ingestion_batch_changes c
+		JOIN ingestion_batch_changes d ON d.table_name = 'orders' AND d.operation = 'DELETE'
+			AND d.row_id = (c.previous->>'order_id')::integer AND d.id > c.id
+		WHERE c.table_name = 'order_products' AND c.operation = 'DELETE' AND c.batch_id = ANY($1)
+			AND (d.batch_id IS NULL OR d.batch_id <> ALL($1))
+	)
+	SELECT COALESCE(d.batch_id, '') FROM dependents d ORDER BY 1`
+	// The restores are recorded by the change triggers as changes of the first batch and
+	// removed along with the changes of the batches
+	tagRollbackQuery string = `SELECT set_config('app.batch_id', $1, true)`
+	// A row is restored to the values before the first change of the batches. Rows the batches
+	// inserted are left alone, as they are removed
+	restoreUsersQuery string = `UPDATE users u SET name = c.previous->>'name'
+		FROM (
+			SELECT DISTINCT ON (c.row_id) c.row_id, c.previous
+			FROM ingestion_batch_changes c
+			WHERE c.table_name = 'users' AND c.batch_id = ANY($1)
+			ORDER BY c.row_id, c.id
+		) c
+		WHERE u.id = c.row_id AND (u.batch_id IS NULL OR u.batch_id <> ALL($1))`
+	restoreOrdersQuery string = `UPDATE orders o SET user_id = (c.previous->>'user_id')::integer, date = (c.previous->>'date')::timestamptz
+		FROM (
+			SELECT DISTINCT ON (c.row_id) c.row_id, c.previous
+			FROM ingestion_batch_changes c
+			WHERE c.table_name = 'orders' AND c.batch_id = ANY($1)
+			ORDER BY c.row_id, c.id
+		) c
+		WHERE o.id = c.row_id AND (o.batch_id IS NULL OR o.batch_id <> ALL($1))`
+	removeOrderProductsQuery string = `DELETE FROM order_products op WHERE op.batch_id = ANY($1)`
+	removeOrdersQuery        string = `DELETE FROM orders o WHERE o.batch_id = ANY($1)`
+	// The rows the batches deleted are inserted again once the rows they inserted are removed,
+	// orders with the values before the first change of the batches. Deletes of rows the batches
+	// inserted, including the ones the rollback itself records, are left alone
+	reinsertOrdersQuery string = `INSERT INTO orders (id, user_id, date, batch_id)
+		SELECT d.row_id, (c.previous->>'user_id')::integer, (c.previous->>'date')::timestamptz, d.previous->>'batch_id'
+		FROM (
+			SELECT DISTINCT ON (d.row_id) d.row_id, d.previous
+			FROM ingestion_batch_changes d
+			WHERE d.table_name = 'orders' AND d.operation = 'DELETE' AND d.batch_id = ANY($1)
+				AND COALESCE(d.previous->>'batch_id', '') <> ALL($1)
+			ORDER BY d.row_id, d.id
+		) d
+		JOIN (
+			SELECT DISTINCT ON (c.row_id) c.row_id, c.previous
+			FROM ingestion_batch_changes c
+			WHERE c.table_name = 'orders' AND c.batch_id = ANY($1)
+			ORDER BY c.row_id, c.id
+		) c ON c.row_id = d.row_id
+		WHERE NOT EXISTS (SELECT 1 FROM orders o WHERE o.id = d.row_id)`
+	reinsertOrderProductsQuery string = `INSERT INTO order_products (id, order_id, product_id, value, position, batch_id)
+		SELECT DISTINCT ON (c.row_id) c.row_id, (c.previous->>'order_id')::integer, (c.previous->>'product_id')::integer,
+			(c.previous->>'value')::numeric, (c.previous->>'position')::integer, c.previous->>'batch_id'
+		FROM ingestion_batch_changes c
+		WHERE c.table_name = 'order_products' AND c.operation = 'DELETE' AND c.batch_id = ANY($1)
+			AND COALESCE(c.previous->>'batch_id', '') <> ALL($1)
+			AND NOT EXISTS (SELECT 1 FROM order_products op WHERE op.id = c.row_id)
+		ORDER BY c.row_id, c.id`
+	removeProductsQuery     string = `DELETE FROM products p WHERE p.batch_id = ANY($1)`
+	removeUsersQuery        string = `DELETE FROM users u WHERE u.batch_id = ANY($1)`
+	removeBatchChangesQuery string = `DELETE FROM ingestion_batch_changes c WHERE c.batch_id = ANY($1)`
+	removeConflictsQuery    string = `DELETE FROM conflicts c WHERE c.batch_id = ANY($1)`
+	removeQuarantineQuery   string = `DELETE FROM rejected_lines l WHERE l.batch_id = ANY($1)`
+)
+
+type batchRollbackRepository struct {
+	db DBTX
+}
+
+func NewBatchRollbackRepository(db DBTX) *batchRollbackRepository {
+	return &batchRollbackRepository{
+		db: db,
+	}
+}
+
+// Lock locks the ingestion tables against writes until the transaction ends, waiting for
+// the loads writing to them to finish first
+func (r *batchRollbackRepository) Lock() error {
+	_, err := r.db.ExecContext(context.Background(), lockIngestionTablesQuery)
+	return err
+}
+
+func (r *batchRollbackRepository) GetDependents(batchIDs []string) ([]string, error) {
+	rows, err := r.db.QueryContext(context.Background(), getBatchDependentsQuery, pq.StringArray(batchIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dependents := make([]string, 0)
+	for rows.Next() {
+		var batchID string
+		if err := rows.Scan(&batchID); err != nil {
+			return nil, err
+		}
+		dependents = append(dependents, batchID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return dependents, nil
+}
+
+// Rollback restores the rows the batches changed before removing the ones they inserted,
+// so no order still points to a user being removed, and then inserts again the ones they deleted
+func (r *batchRollbackRepository) Rollback(batchIDs []string) (entities.RollbackSummary, error) {
+	summary := entities.RollbackSummary{}
+	if len(batchIDs) == 0 {
+		return summary, nil
+	}
+
+	ids := pq.StringArray(batchIDs)
+
+	if _, err := r.db.ExecContext(context.Background(), tagRollbackQuery, batchIDs[0]); err != nil {
+		return summary, err
+	}
+
+	steps := []struct {
+		query string
+		count *int
+	}{
+		{query: restoreUsersQuery, count: &summary.Users.Restored},
+		{query: restoreOrdersQuery, count: &summary.Orders.Restored},
+		{query: removeOrderProductsQuery, count: &summary.OrderProducts.Removed},
+		{query: removeOrdersQuery, count: &summary.Orders.Removed},
+		{query: reinsertOrdersQuery, count: &summary.Orders.Restored},
+		{query: reinsertOrderProductsQuery, count: &summary.OrderProducts.Restored},
+		{query: removeProductsQuery, count: &summary.Products.Removed},
+		{query: removeUsersQuery, count: &summary.Users.Removed},
+		{query: removeBatchChangesQuery},
+		{query: removeConflictsQuery},
+		{query: removeQuarantineQuery},
+	}
+
+	for _, step := range steps {
+		result, err := r.db.ExecContext(context.Background(), step.query, ids)
+		if err != nil {
+			return summary, err
+		}
+
+		if step.count == nil {
+			continue
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return summary, err
+		}
+		*step.count += int(affected)
+	}
+
+	return summary, nil
+}
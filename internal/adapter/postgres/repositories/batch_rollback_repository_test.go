@@ -0,0 +1,207 @@
+package repositories_test
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/postgres/repositories"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+)
+
+func Test_Lock_BatchRollbackRepository(t *testing.T) {
+	query := regexp.QuoteMeta(`LOCK TABLE users, products, orders, order_products IN SHARE ROW EXCLUSIVE MODE`)
+
+	tests := []struct {
+		description   string
+		setMocks      func(mock sqlmock.Sqlmock)
+		isErrExpected bool
+	}{
+		{
+			description: "should lock the ingestion tables",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+		{
+			description: "should return error",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(query).WillReturnError(sql.ErrConnDone)
+			},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			tt.setMocks(mock)
+
+			batchRollbackRepository := repositories.NewBatchRollbackRepository(db)
+			err = batchRollbackRepository.Lock()
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_GetDependents_BatchRollbackRepository(t *testing.T) {
+	query := regexp.QuoteMeta(`SELECT COALESCE(d.batch_id, '') FROM dependents d ORDER BY 1`)
+	batchIDs := []string{"a1b2c3", "d4e5f6"}
+
+	tests := []struct {
+		description   string
+		expectedRows  *sqlmock.Rows
+		expectedErr   error
+		expected      []string
+		isErrExpected bool
+	}{
+		{
+			description:  "should return the batches depending on the batches",
+			expectedRows: sqlmock.NewRows([]string{"batch_id"}).AddRow("").AddRow("g7h8i9"),
+			expected:     []string{"", "g7h8i9"},
+		},
+		{
+			description:  "should return no batches",
+			expectedRows: sqlmock.NewRows([]string{"batch_id"}),
+			expected:     []string{},
+		},
+		{
+			description:   "should return error",
+			expectedErr:   sql.ErrConnDone,
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			expectation := mock.ExpectQuery(query).WithArgs(pq.StringArray(batchIDs))
+			if tt.expectedErr != nil {
+				expectation.WillReturnError(tt.expectedErr)
+			} else {
+				expectation.WillReturnRows(tt.expectedRows)
+			}
+
+			batchRollbackRepository := repositories.NewBatchRollbackRepository(db)
+			dependents, err := batchRollbackRepository.GetDependents(batchIDs)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				assert.Nil(t, dependents)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, dependents)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_Rollback_BatchRollbackRepository(t *testing.T) {
+	batchIDs := []string{"a1b2c3", "d4e5f6"}
+	ids := pq.StringArray(batchIDs)
+
+	tagQuery := regexp.QuoteMeta(`SELECT set_config('app.batch_id', $1, true)`)
+	restoreUsersQuery := regexp.QuoteMeta(`UPDATE users u SET name`)
+	restoreOrdersQuery := regexp.QuoteMeta(`UPDATE orders o SET user_id`)
+	removeOrderProductsQuery := regexp.QuoteMeta(`DELETE FROM order_products op WHERE op.batch_id = ANY($1)`)
+	removeOrdersQuery := regexp.QuoteMeta(`DELETE FROM orders o WHERE o.batch_id = ANY($1)`)
+	reinsertOrdersQuery := regexp.QuoteMeta(`INSERT INTO orders (id, user_id, date, batch_id)`)
+	reinsertOrderProductsQuery := regexp.QuoteMeta(`INSERT INTO order_products (id, order_id, product_id, value, position, batch_id)`)
+	removeProductsQuery := regexp.QuoteMeta(`DELETE FROM products p WHERE p.batch_id = ANY($1)`)
+	removeUsersQuery := regexp.QuoteMeta(`DELETE FROM users u WHERE u.batch_id = ANY($1)`)
+	removeBatchChangesQuery := regexp.QuoteMeta(`DELETE FROM ingestion_batch_changes c WHERE c.batch_id = ANY($1)`)
+	removeConflictsQuery := regexp.QuoteMeta(`DELETE FROM conflicts c WHERE c.batch_id = ANY($1)`)
+	removeQuarantineQuery := regexp.QuoteMeta(`DELETE FROM rejected_lines l WHERE l.batch_id = ANY($1)`)
+
+	tests := []struct {
+		description   string
+		batchIDs      []string
+		setMocks      func(mock sqlmock.Sqlmock)
+		expected      entities.RollbackSummary
+		isErrExpected bool
+	}{
+		{
+			description: "should restore the changed rows, remove the inserted ones and insert the deleted ones again",
+			batchIDs:    batchIDs,
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(tagQuery).WithArgs("a1b2c3").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(restoreUsersQuery).WithArgs(ids).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(restoreOrdersQuery).WithArgs(ids).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(removeOrderProductsQuery).WithArgs(ids).WillReturnResult(sqlmock.NewResult(0, 5))
+				mock.ExpectExec(removeOrdersQuery).WithArgs(ids).WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectExec(reinsertOrdersQuery).WithArgs(ids).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(reinsertOrderProductsQuery).WithArgs(ids).WillReturnResult(sqlmock.NewResult(0, 4))
+				mock.ExpectExec(removeProductsQuery).WithArgs(ids).WillReturnResult(sqlmock.NewResult(0, 4))
+				mock.ExpectExec(removeUsersQuery).WithArgs(ids).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(removeBatchChangesQuery).WithArgs(ids).WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectExec(removeConflictsQuery).WithArgs(ids).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(removeQuarantineQuery).WithArgs(ids).WillReturnResult(sqlmock.NewResult(0, 6))
+			},
+			expected: entities.RollbackSummary{
+				Users:         entities.RollbackCounts{Removed: 2, Restored: 1},
+				Products:      entities.RollbackCounts{Removed: 4},
+				Orders:        entities.RollbackCounts{Removed: 3, Restored: 3},
+				OrderProducts: entities.RollbackCounts{Removed: 5, Restored: 4},
+			},
+		},
+		{
+			description: "should do nothing without batches",
+			setMocks:    func(mock sqlmock.Sqlmock) {},
+		},
+		{
+			description: "should return error",
+			batchIDs:    batchIDs,
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(tagQuery).WithArgs("a1b2c3").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(restoreUsersQuery).WithArgs(ids).WillReturnError(sql.ErrConnDone)
+			},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			tt.setMocks(mock)
+
+			batchRollbackRepository := repositories.NewBatchRollbackRepository(db)
+			summary, err := batchRollbackRepository.Rollback(tt.batchIDs)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, summary)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+// The staged lines belong to the transaction that copied them, identified by txid_current(),
+// so concurrent bulk loads never merge each other's lines
+const (
+	countStagedLinesQuery string = `SELECT COUNT(*) FROM order_lines_staging s WHERE s.load_id = txid_current()`
+	mergeUsersQuery       string = `WITH upserted AS (
+		INSERT INTO users (id, name)
+		SELECT DISTINCT ON (s.user_id) s.user_id, s.user_name
+		FROM order_lines_staging s
+		WHERE s.load_id = txid_current()
+		ORDER BY s.user_id, CASE WHEN $1 THEN s.line ELSE -s.line END
+		ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name
+		WHERE NOT $1 AND users.name IS DISTINCT FROM EXCLUDED.name
+		RETURNING (xmax = 0) AS inserted
+	)
+	SELECT COUNT(*) FILTER (WHERE inserted), COUNT(*) FILTER (WHERE NOT inserted) FROM upserted`
+	mergeProductsQuery string = `WITH upserted AS (
+		INSERT INTO products (id)
+		SELECT DISTINCT s.product_id
+		FROM order_lines_staging s
+		WHERE s.load_id = txid_current()
+		ON CONFLICT (id) DO NOTHING
+		RETURNING (xmax = 0) AS inserted
+	)
+	SELECT COUNT(*) FILTER (WHERE inserted), COUNT(*) FILTER (WHERE NOT inserted) FROM upserted`
+	mergeOrdersQuery string = `WITH upserted AS (
+		INSERT INTO orders (id, user_id, date)
+		SELECT f.order_id,
+			CASE WHEN $1 THEN f.user_id ELSE l.user_id END,
+			CASE WHEN $2 THEN f.date ELSE l.date END
+		FROM (
+			SELECT DISTINCT ON (s.order_id) s.order_id, s.user_id, s.date
+			FROM order_lines_staging s
+			WHERE s.load_id = txid_current()
+			ORDER BY s.order_id, s.line
+		) f
+		JOIN (
+			SELECT DISTINCT ON (s.order_id) s.order_id, s.user_id, s.date
+			FROM order_lines_staging s
+			WHERE s.load_id = txid_current()
+			ORDER BY s.order_id, s.line DESC
+		) l USING (order_id)
+		ON CONFLICT (id) DO UPDATE SET
+			user_id = CASE WHEN $1 THEN orders.user_id ELSE EXCLUDED.user_id END,
+			date = CASE WHEN $2 THEN orders.date ELSE EXCLUDED.date END
+		WHERE (orders.user_id, orders.date) IS DISTINCT FROM (
+			CASE WHEN $1 THEN orders.user_id ELSE EXCLUDED.user_id END,
+			CASE WHEN $2 THEN orders.date ELSE EXCLUDED.date END
+		)
+		RETURNING (xmax = 0) AS inserted
+	)
+	SELECT COUNT(*) FILTER (WHERE inserted), COUNT(*) FILTER (WHERE NOT inserted) FROM upserted`
+	mergeOrderProductsQuery string = `WITH upserted AS (
+		INSERT INTO order_products (order_id, product_id, value, position)
+		SELECT s.order_id, s.product_id, s.value, s.position
+		FROM order_lines_staging s
+		WHERE s.load_id = txid_current()
+		ON CONFLICT (order_id, product_id, value, position) DO NOTHING
+		RETURNING (xmax = 0) AS inserted
+	)
+	SELECT COUNT(*) FILTER (WHERE inserted), COUNT(*) FILTER (WHERE NOT inserted) FROM upserted`
+	clearStagedLinesQuery   string = `DELETE FROM order_lines_staging s WHERE s.load_id = txid_current()`
+	discardStagedLinesQuery string = `DELETE FROM order_lines_staging s WHERE s.load_id = txid_current() AND s.line = ANY($1)
+		RETURNING s.line, s.content`
+)
+
+// detectConflictsQuery compares each staged line with the value it would find stored when the
+// lines are upserted in order: the previous line of the same key, or the stored row, when the
+// last write wins, and the stored row, or the first line of the key, when the first value is
+// kept, which $1 tells. The arguments are the staged key and value columns, the stored table
+// and column and how the values are printed
+const detectConflictsQuery string = `WITH staged AS (
+		SELECT s.line, s.%[1]s AS key, s.%[2]s AS incoming,
+			LAG(s.%[2]s) OVER w AS previous,
+			FIRST_VALUE(s.%[2]s) OVER w AS first
+		FROM order_lines_staging s
+		WHERE s.load_id = txid_current()
+		WINDOW w AS (PARTITION BY s.%[1]s ORDER BY s.line)
+	), compared AS (
+		SELECT st.line, st.key, st.incoming,
+			CASE WHEN $1 THEN COALESCE(t.%[4]s, st.first) ELSE COALESCE(st.previous, t.%[4]s) END AS existing
+		FROM staged st
+		LEFT JOIN %[3]s t ON t.id = st.key
+	)
+	SELECT c.line, c.key::text, %[5]s, %[6]s
+	FROM compared c
+	WHERE c.existing <> c.incoming
+	ORDER BY c.line`
+
+// conflictDetections holds the detection query of each conflict type
+var conflictDetections = []struct {
+	conflictType entities.ConflictType
+	field        string
+	query        string
+}{
+	{
+		conflictType: entities.ConflictOrderUser,
+		field:        entities.FieldUserID,
+		query:        fmt.Sprintf(detectConflictsQuery, "order_id", "user_id", "orders", "user_id", "c.existing::text", "c.incoming::text"),
+	},
+	{
+		conflictType: entities.ConflictOrderDate,
+		field:        entities.FieldOrderDate,
+		query: fmt.Sprintf(detectConflictsQuery, "order_id", "date", "orders", "date",
+			"to_char(c.existing, 'YYYY-MM-DD')", "to_char(c.incoming, 'YYYY-MM-DD')"),
+	},
+	{
+		conflictType: entities.ConflictUserName,
+		field:        entities.FieldUserName,
+		query:        fmt.Sprintf(detectConflictsQuery, "user_id", "user_name", "users", "name", "c.existing", "c.incoming"),
+	},
+}
+
+var stagingColumns = []string{
+	"line",
+	"user_id",
+	"user_name",
+	"order_id",
+	"product_id",
+	"value",
+	"position",
+	"date",
+	"content",
+}
+
+type bulkRepository struct {
+	db DBTX
+}
+
+func NewBulkRepository(db DBTX) *bulkRepository {
+	return &bulkRepository{
+		db: db,
+	}
+}
+
+// Stage streams the lines into the staging table with COPY
+func (r *bulkRepository) Stage(usersData []*user.UserFileData) error {
+	ctx := context.Background()
+
+	stmt, err := r.db.PrepareContext(ctx, pq.CopyIn("order_lines_staging", stagingColumns...))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, userData := range usersData {
+		if _, err := stmt.ExecContext(
+			ctx,
+			userData.Line,
+			userData.UserID,
+			userData.UserName,
+			userData.OrderID,
+			userData.ProductID,
+			userData.ProductValue,
+			userData.Position,
+			userData.OrderDate,
+			userData.Text,
+		); err != nil {
+			return err
+		}
+	}
+
+	// An exec without arguments flushes the buffered rows
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DetectConflicts returns the staged lines whose user or order disagrees with the stored one
+// or with a previous staged line. Only the key, the values and the policy are set, ordered by line
+func (r *bulkRepository) DetectConflicts(policies entities.ConflictPolicies) ([]*entities.LineConflict, error) {
+	conflicts := make([]*entities.LineConflict, 0)
+
+	for _, detection := range conflictDetections {
+		rows, err := r.db.QueryContext(
+			context.Background(),
+			detection.query,
+			policies.KeepsFirst(detection.conflictType),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			conflict := &entities.LineConflict{
+				Type:   detection.conflictType,
+				Field:  detection.field,
+				Policy: policies.For(detection.conflictType),
+			}
+			if err := rows.Scan(&conflict.Line, &conflict.Value, &conflict.Existing, &conflict.Incoming); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			conflicts = append(conflicts, conflict)
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return conflicts, nil
+}
+
+// Discard removes the given staged lines, returning their content by line
+func (r *bulkRepository) Discard(lines []int) (map[int]string, error) {
+	contents := make(map[int]string, len(lines))
+	if len(lines) == 0 {
+		return contents, nil
+	}
+
+	numbers := make(pq.Int64Array, 0, len(lines))
+	for _, line := range lines {
+		numbers = append(numbers, int64(line))
+	}
+
+	rows, err := r.db.QueryContext(context.Background(), discardStagedLinesQuery, numbers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line int
+		var content string
+		if err := rows.Scan(&line, &content); err != nil {
+			return nil, err
+		}
+		contents[line] = content
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return contents, nil
+}
+
+// Merge upserts the staged lines into the final tables and clears them from the staging table.
+// Like the row by row upserts, every staged line that did not insert or update a row counts as
+// unchanged. A user name, order user or order date whose policy keeps the first value is taken
+// from the stored row or, for new rows, from the first staged line instead of the last one
+func (r *bulkRepository) Merge(policies entities.ConflictPolicies) (entities.WriteSummary, error) {
+	summary := entities.WriteSummary{}
+
+	var staged int
+	if err := r.db.QueryRowContext(context.Background(), countStagedLinesQuery).Scan(&staged); err != nil {
+		return summary, err
+	}
+
+	merges := []struct {
+		query  string
+		args   []any
+		counts *entities.WriteCounts
+	}{
+		{
+			query:  mergeUsersQuery,
+			args:   []any{policies.KeepsFirst(entities.ConflictUserName)},
+			counts: &summary.Users,
+		},
+		{query: mergeProductsQuery, counts: &summary.Products},
+		{
+			query: mergeOrdersQuery,
+			args: []any{
+				policies.KeepsFirst(entities.ConflictOrderUser),
+				policies.KeepsFirst(entities.ConflictOrderDate),
+			},
+			counts: &summary.Orders,
+		},
+		{query: mergeOrderProductsQuery, counts: &summary.OrderProducts},
+	}
+
+	for _, merge := range merges {
+		if err := r.db.QueryRowContext(context.Background(), merge.query, merge.args...).Scan(
+			&merge.counts.Inserted,
+			&merge.counts.Updated,
+		); err != nil {
+			return summary, err
+		}
+		merge.counts.Unchanged = staged - merge.counts.Inserted - merge.counts.Updated
+	}
+
+	if _, err := r.db.ExecContext(context.Background(), clearStagedLinesQuery); err != nil {
+		return summary, err
+	}
+
+	return summary, nil
+}
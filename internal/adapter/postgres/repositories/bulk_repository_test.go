@@ -0,0 +1,352 @@
+package repositories_test
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/postgres/repositories"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+func Test_Stage_BulkRepository(t *testing.T) {
+	mockUsersData := []*user.UserFileData{
+		{Line: 1, Position: 1, UserID: 70, UserName: "Palmer Prosacco", OrderID: 753, ProductID: 3, ProductValue: 183674, OrderDate: time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC), Text: "0000000070"},
+		{Line: 2, Position: 1, UserID: 75, UserName: "Bobbie Batz", OrderID: 798, ProductID: 2, ProductValue: 157857, OrderDate: time.Date(2021, 11, 16, 0, 0, 0, 0, time.UTC)},
+	}
+
+	copyQuery := `COPY "order_lines_staging" ("line", "user_id", "user_name", "order_id", "product_id", "value", "position", "date", "content") FROM STDIN`
+
+	tests := []struct {
+		description   string
+		setMocks      func(mock sqlmock.Sqlmock)
+		isErrExpected bool
+	}{
+		{
+			description: "should copy every line and flush",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				prepare := mock.ExpectPrepare(regexp.QuoteMeta(copyQuery))
+				for _, d := range mockUsersData {
+					prepare.ExpectExec().
+						WithArgs(d.Line, d.UserID, d.UserName, d.OrderID, d.ProductID, d.ProductValue, d.Position, d.OrderDate, d.Text).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+				prepare.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			isErrExpected: false,
+		},
+		{
+			description: "should return error when copy can not start",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectPrepare(regexp.QuoteMeta(copyQuery)).WillReturnError(sql.ErrConnDone)
+			},
+			isErrExpected: true,
+		},
+		{
+			description: "should return error when a line can not be copied",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				d := mockUsersData[0]
+				mock.ExpectPrepare(regexp.QuoteMeta(copyQuery)).
+					ExpectExec().
+					WithArgs(d.Line, d.UserID, d.UserName, d.OrderID, d.ProductID, d.ProductValue, d.Position, d.OrderDate, d.Text).
+					WillReturnError(sql.ErrConnDone)
+			},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			tt.setMocks(mock)
+
+			bulkRepository := repositories.NewBulkRepository(db)
+			err = bulkRepository.Stage(mockUsersData)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_Merge_BulkRepository(t *testing.T) {
+	countQuery := `SELECT COUNT(*) FROM order_lines_staging s WHERE s.load_id = txid_current()`
+	clearQuery := `DELETE FROM order_lines_staging s WHERE s.load_id = txid_current()`
+	mergeQueries := []string{
+		`INSERT INTO users (id, name)`,
+		`INSERT INTO products (id)`,
+		`INSERT INTO orders (id, user_id, date)`,
+		`INSERT INTO order_products (order_id, product_id, value, position)`,
+	}
+
+	tests := []struct {
+		description     string
+		policies        entities.ConflictPolicies
+		setMocks        func(mock sqlmock.Sqlmock)
+		expectedSummary entities.WriteSummary
+		isErrExpected   bool
+	}{
+		{
+			description: "should merge every table and count unchanged lines",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(countQuery)).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+				mock.ExpectQuery(regexp.QuoteMeta(mergeQueries[0])).
+					WithArgs(false).
+					WillReturnRows(sqlmock.NewRows([]string{"inserted", "updated"}).AddRow(1, 1))
+				mock.ExpectQuery(regexp.QuoteMeta(mergeQueries[1])).
+					WillReturnRows(sqlmock.NewRows([]string{"inserted", "updated"}).AddRow(2, 0))
+				mock.ExpectQuery(regexp.QuoteMeta(mergeQueries[2])).
+					WithArgs(false, false).
+					WillReturnRows(sqlmock.NewRows([]string{"inserted", "updated"}).AddRow(0, 0))
+				mock.ExpectQuery(regexp.QuoteMeta(mergeQueries[3])).
+					WillReturnRows(sqlmock.NewRows([]string{"inserted", "updated"}).AddRow(3, 0))
+				mock.ExpectExec(regexp.QuoteMeta(clearQuery)).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+			},
+			expectedSummary: entities.WriteSummary{
+				Users:         entities.WriteCounts{Inserted: 1, Updated: 1, Unchanged: 1},
+				Products:      entities.WriteCounts{Inserted: 2, Unchanged: 1},
+				Orders:        entities.WriteCounts{Unchanged: 3},
+				OrderProducts: entities.WriteCounts{Inserted: 3},
+			},
+			isErrExpected: false,
+		},
+		{
+			description: "should keep the first value of the conflict types whose policy is not last-write-wins",
+			policies: entities.ConflictPolicies{
+				entities.ConflictUserName:  entities.ConflictKeepFirst,
+				entities.ConflictOrderDate: entities.ConflictReject,
+			},
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(countQuery)).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mock.ExpectQuery(regexp.QuoteMeta(mergeQueries[0])).
+					WithArgs(true).
+					WillReturnRows(sqlmock.NewRows([]string{"inserted", "updated"}).AddRow(0, 0))
+				mock.ExpectQuery(regexp.QuoteMeta(mergeQueries[1])).
+					WillReturnRows(sqlmock.NewRows([]string{"inserted", "updated"}).AddRow(0, 0))
+				mock.ExpectQuery(regexp.QuoteMeta(mergeQueries[2])).
+					WithArgs(false, true).
+					WillReturnRows(sqlmock.NewRows([]string{"inserted", "updated"}).AddRow(0, 1))
+				mock.ExpectQuery(regexp.QuoteMeta(mergeQueries[3])).
+					WillReturnRows(sqlmock.NewRows([]string{"inserted", "updated"}).AddRow(0, 0))
+				mock.ExpectExec(regexp.QuoteMeta(clearQuery)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedSummary: entities.WriteSummary{
+				Users:         entities.WriteCounts{Unchanged: 1},
+				Products:      entities.WriteCounts{Unchanged: 1},
+				Orders:        entities.WriteCounts{Updated: 1},
+				OrderProducts: entities.WriteCounts{Unchanged: 1},
+			},
+			isErrExpected: false,
+		},
+		{
+			description: "should return error when a merge fails",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(countQuery)).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+				mock.ExpectQuery(regexp.QuoteMeta(mergeQueries[0])).
+					WillReturnError(sql.ErrConnDone)
+			},
+			isErrExpected: true,
+		},
+		{
+			description: "should return error when staged lines can not be cleared",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(countQuery)).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				for _, query := range mergeQueries {
+					mock.ExpectQuery(regexp.QuoteMeta(query)).
+						WillReturnRows(sqlmock.NewRows([]string{"inserted", "updated"}).AddRow(0, 0))
+				}
+				mock.ExpectExec(regexp.QuoteMeta(clearQuery)).
+					WillReturnError(sql.ErrConnDone)
+			},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			tt.setMocks(mock)
+
+			bulkRepository := repositories.NewBulkRepository(db)
+			summary, err := bulkRepository.Merge(tt.policies)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedSummary, summary)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_DetectConflicts_BulkRepository(t *testing.T) {
+	orderUserQuery := `PARTITION BY s.order_id ORDER BY s.line`
+	userNameQuery := `PARTITION BY s.user_id ORDER BY s.line`
+	columns := []string{"line", "key", "existing", "incoming"}
+
+	tests := []struct {
+		description       string
+		policies          entities.ConflictPolicies
+		setMocks          func(mock sqlmock.Sqlmock)
+		expectedConflicts []*entities.LineConflict
+		isErrExpected     bool
+	}{
+		{
+			description: "should return the conflicts of every type with their policy",
+			policies:    entities.ConflictPolicies{entities.ConflictOrderUser: entities.ConflictReject},
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(orderUserQuery)).
+					WithArgs(true).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(2, "753", "70", "75"))
+				mock.ExpectQuery(regexp.QuoteMeta(orderUserQuery)).
+					WithArgs(false).
+					WillReturnRows(sqlmock.NewRows(columns))
+				mock.ExpectQuery(regexp.QuoteMeta(userNameQuery)).
+					WithArgs(false).
+					WillReturnRows(sqlmock.NewRows(columns).AddRow(3, "70", "Palmer Prosacco", "Palmer P."))
+			},
+			expectedConflicts: []*entities.LineConflict{
+				{
+					Line:     2,
+					Type:     entities.ConflictOrderUser,
+					Field:    entities.FieldUserID,
+					Value:    "753",
+					Existing: "70",
+					Incoming: "75",
+					Policy:   entities.ConflictReject,
+				},
+				{
+					Line:     3,
+					Type:     entities.ConflictUserName,
+					Field:    entities.FieldUserName,
+					Value:    "70",
+					Existing: "Palmer Prosacco",
+					Incoming: "Palmer P.",
+					Policy:   entities.ConflictLastWriteWins,
+				},
+			},
+			isErrExpected: false,
+		},
+		{
+			description: "should return error",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(orderUserQuery)).WillReturnError(sql.ErrConnDone)
+			},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			tt.setMocks(mock)
+
+			bulkRepository := repositories.NewBulkRepository(db)
+			conflicts, err := bulkRepository.DetectConflicts(tt.policies)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedConflicts, conflicts)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_Discard_BulkRepository(t *testing.T) {
+	query := regexp.QuoteMeta(`DELETE FROM order_lines_staging s WHERE s.load_id = txid_current() AND s.line = ANY($1)
+		RETURNING s.line, s.content`)
+
+	tests := []struct {
+		description   string
+		lines         []int
+		setMocks      func(mock sqlmock.Sqlmock)
+		expected      map[int]string
+		isErrExpected bool
+	}{
+		{
+			description: "should remove the lines and return their content",
+			lines:       []int{2, 5},
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(pq.Int64Array{2, 5}).
+					WillReturnRows(sqlmock.NewRows([]string{"line", "content"}).AddRow(2, "0000000070").AddRow(5, "0000000075"))
+			},
+			expected: map[int]string{2: "0000000070", 5: "0000000075"},
+		},
+		{
+			description: "should do nothing without lines",
+			setMocks:    func(mock sqlmock.Sqlmock) {},
+			expected:    map[int]string{},
+		},
+		{
+			description: "should return error",
+			lines:       []int{2},
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnError(sql.ErrConnDone)
+			},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			tt.setMocks(mock)
+
+			bulkRepository := repositories.NewBulkRepository(db)
+			contents, err := bulkRepository.Discard(tt.lines)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, contents)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
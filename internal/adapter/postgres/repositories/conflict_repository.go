@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+)
+
+const (
+	saveConflictQuery string = `INSERT INTO conflicts (type, key, existing, incoming, policy, reason, batch_id, file_name, line)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, detected_at`
+	getUnresolvedConflictsQuery string = `SELECT c.id, c.type, c.key, c.existing, c.incoming, c.policy, c.reason,
+		c.batch_id, c.file_name, c.line, c.detected_at
+		FROM conflicts c
+		WHERE c.resolved_at IS NULL AND ($1 = '' OR c.type = $1)
+		ORDER BY c.id LIMIT $2 OFFSET $3`
+	resolveConflictQuery string = `UPDATE conflicts SET resolved_at = NOW() WHERE id = $1 AND resolved_at IS NULL`
+)
+
+type conflictRepository struct {
+	db DBTX
+}
+
+func NewConflictRepository(db DBTX) *conflictRepository {
+	return &conflictRepository{
+		db: db,
+	}
+}
+
+func (r *conflictRepository) Save(conflict *entities.LineConflict) error {
+	return r.db.QueryRowContext(
+		context.Background(),
+		saveConflictQuery,
+		string(conflict.Type),
+		conflict.Value,
+		conflict.Existing,
+		conflict.Incoming,
+		string(conflict.Policy),
+		conflict.Reason,
+		conflict.BatchID,
+		conflict.FileName,
+		conflict.Line,
+	).Scan(&conflict.ID, &conflict.DetectedAt)
+}
+
+func (r *conflictRepository) GetUnresolved(
+	conflictType entities.ConflictType,
+	limit, offset int,
+) ([]*entities.LineConflict, error) {
+	rows, err := r.db.QueryContext(context.Background(), getUnresolvedConflictsQuery, string(conflictType), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conflicts := make([]*entities.LineConflict, 0)
+	for rows.Next() {
+		conflict := new(entities.LineConflict)
+
+		var conflictType, policy string
+		if err := rows.Scan(
+			&conflict.ID,
+			&conflictType,
+			&conflict.Value,
+			&conflict.Existing,
+			&conflict.Incoming,
+			&policy,
+			&conflict.Reason,
+			&conflict.BatchID,
+			&conflict.FileName,
+			&conflict.Line,
+			&conflict.DetectedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		conflict.Type = entities.ConflictType(conflictType)
+		conflict.Policy = entities.ConflictPolicy(policy)
+		conflicts = append(conflicts, conflict)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return conflicts, nil
+}
+
+func (r *conflictRepository) Resolve(id uint) error {
+	result, err := r.db.ExecContext(context.Background(), resolveConflictQuery, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return errors.ErrConflictNotFound
+	}
+
+	return nil
+}
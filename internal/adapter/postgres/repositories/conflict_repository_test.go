@@ -0,0 +1,203 @@
+package repositories_test
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/postgres/repositories"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+)
+
+func Test_Save_ConflictRepository(t *testing.T) {
+	detectedAt := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta(`INSERT INTO conflicts`)
+
+	tests := []struct {
+		description   string
+		setMocks      func(mock sqlmock.Sqlmock)
+		isErrExpected bool
+	}{
+		{
+			description: "should store the conflict and set its id",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs("order_user", "753", "70", "75", "reject", "order 753 already belongs to user 70", "a1b2c3", "data_1.txt", 2).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "detected_at"}).AddRow(1, detectedAt))
+			},
+		},
+		{
+			description: "should return error",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnError(sql.ErrConnDone)
+			},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			tt.setMocks(mock)
+
+			conflict := &entities.LineConflict{
+				BatchID:  "a1b2c3",
+				FileName: "data_1.txt",
+				Line:     2,
+				Type:     entities.ConflictOrderUser,
+				Value:    "753",
+				Existing: "70",
+				Incoming: "75",
+				Policy:   entities.ConflictReject,
+				Reason:   "order 753 already belongs to user 70",
+			}
+
+			conflictRepository := repositories.NewConflictRepository(db)
+			err = conflictRepository.Save(conflict)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, uint(1), conflict.ID)
+			assert.Equal(t, detectedAt, conflict.DetectedAt)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_GetUnresolved_ConflictRepository(t *testing.T) {
+	detectedAt := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta(`WHERE c.resolved_at IS NULL AND ($1 = '' OR c.type = $1) ORDER BY c.id LIMIT $2 OFFSET $3`)
+	columns := []string{"id", "type", "key", "existing", "incoming", "policy", "reason", "batch_id", "file_name", "line", "detected_at"}
+
+	tests := []struct {
+		description       string
+		setMocks          func(mock sqlmock.Sqlmock)
+		expectedConflicts []*entities.LineConflict
+		isErrExpected     bool
+	}{
+		{
+			description: "should return the unresolved conflicts of the type",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs("order_date", 50, 0).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(4, "order_date", "753", "2021-03-08", "2021-03-09", "keep-first", "order 753 already has the date 2021-03-08", "", "users.txt", 7, detectedAt))
+			},
+			expectedConflicts: []*entities.LineConflict{
+				{
+					ID:         4,
+					FileName:   "users.txt",
+					Line:       7,
+					Type:       entities.ConflictOrderDate,
+					Value:      "753",
+					Existing:   "2021-03-08",
+					Incoming:   "2021-03-09",
+					Policy:     entities.ConflictKeepFirst,
+					Reason:     "order 753 already has the date 2021-03-08",
+					DetectedAt: detectedAt,
+				},
+			},
+		},
+		{
+			description: "should return error",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WithArgs("order_date", 50, 0).WillReturnError(sql.ErrConnDone)
+			},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			tt.setMocks(mock)
+
+			conflictRepository := repositories.NewConflictRepository(db)
+			conflicts, err := conflictRepository.GetUnresolved(entities.ConflictOrderDate, 50, 0)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedConflicts, conflicts)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_Resolve_ConflictRepository(t *testing.T) {
+	query := regexp.QuoteMeta(`UPDATE conflicts SET resolved_at = NOW() WHERE id = $1 AND resolved_at IS NULL`)
+
+	tests := []struct {
+		description   string
+		setMocks      func(mock sqlmock.Sqlmock)
+		expectedErr   error
+		isErrExpected bool
+	}{
+		{
+			description: "should resolve the conflict",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(query).WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			description: "should return not found when the conflict is missing or already resolved",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(query).WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			expectedErr:   errors.ErrConflictNotFound,
+			isErrExpected: true,
+		},
+		{
+			description: "should return error",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(query).WithArgs(4).WillReturnError(sql.ErrConnDone)
+			},
+			expectedErr:   sql.ErrConnDone,
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			tt.setMocks(mock)
+
+			conflictRepository := repositories.NewConflictRepository(db)
+			err = conflictRepository.Resolve(4)
+
+			if tt.isErrExpected {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+)
+
+// DBTX is satisfied by both *sql.DB and *sql.Tx, so the same repository
+// can run on a bare connection or inside a transaction
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// upsert runs an upsert query that returns whether the row was inserted, by checking `xmax = 0`.
+// A query that returns no row left the stored row unchanged
+func upsert(db DBTX, query string, args ...any) (entities.UpsertOutcome, error) {
+	var inserted bool
+	if err := db.QueryRowContext(context.Background(), query, args...).Scan(&inserted); err != nil {
+		if err == sql.ErrNoRows {
+			return entities.UpsertUnchanged, nil
+		}
+
+		return entities.UpsertUnchanged, err
+	}
+
+	if inserted {
+		return entities.UpsertInserted, nil
+	}
+
+	return entities.UpsertUpdated, nil
+}
+
+// deleteRows runs a delete query, returning how many rows it removed
+func deleteRows(db DBTX, query string, args ...any) (int, error) {
+	result, err := db.ExecContext(context.Background(), query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(affected), nil
+}
@@ -0,0 +1,268 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	stderrors "errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+)
+
+const (
+	ingestionBatchColumns string = `b.id, b.file_name, b.sha256, b.size, b.uploaded_by, b.forced, b.layout, b.format,
+		b.encoding, b.mode, b.snapshot_start, b.snapshot_end, b.compression, b.dry_run, b.state, b.processed_lines, b.accepted_lines, b.rejected_lines, b.skipped_lines,
+		b.rejections, b.conflicts, b.writes, b.entries, b.error, b.created_at, b.started_at, b.finished_at, b.rolled_back_at`
+	getIngestionBatchQuery         string = `SELECT ` + ingestionBatchColumns + ` FROM ingestion_batches b WHERE b.id = $1`
+	getAllIngestionBatchesQuery    string = `SELECT ` + ingestionBatchColumns + ` FROM ingestion_batches b ORDER BY b.created_at DESC LIMIT $1 OFFSET $2`
+	getImportedIngestionBatchQuery string = `SELECT ` + ingestionBatchColumns + ` FROM ingestion_batches b
+		WHERE b.sha256 = $1 AND b.state IN ('queued', 'running', 'done') AND NOT b.dry_run
+		ORDER BY b.created_at DESC LIMIT 1`
+	saveIngestionBatchQuery string = `INSERT INTO ingestion_batches (id, file_name, sha256, size, uploaded_by, forced, layout, format,
+		encoding, mode, snapshot_start, snapshot_end, compression, dry_run, state, processed_lines, accepted_lines,
+		rejected_lines, skipped_lines, rejections, conflicts, writes, entries, error, created_at, started_at, finished_at,
+		rolled_back_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24,
+		$25, $26, $27, $28)
+		ON CONFLICT (id) DO UPDATE SET format = EXCLUDED.format, compression = EXCLUDED.compression,
+		state = EXCLUDED.state, processed_lines = EXCLUDED.processed_lines, accepted_lines = EXCLUDED.accepted_lines,
+		rejected_lines = EXCLUDED.rejected_lines, skipped_lines = EXCLUDED.skipped_lines,
+		rejections = EXCLUDED.rejections, conflicts = EXCLUDED.conflicts, writes = EXCLUDED.writes,
+		entries = EXCLUDED.entries, error = EXCLUDED.error, started_at = EXCLUDED.started_at,
+		finished_at = EXCLUDED.finished_at, rolled_back_at = EXCLUDED.rolled_back_at`
+	failUnfinishedIngestionBatchesQuery string = `UPDATE ingestion_batches SET state = 'failed', error = $1, finished_at = $2
+		WHERE state IN ('queued', 'running')`
+)
+
+const (
+	// uniqueViolation is the code of the error Postgres refuses a row breaking a unique index with
+	uniqueViolation pq.ErrorCode = "23505"
+	// importingSHA256Key is the unique index keeping a single upload, unless forced, loading a file
+	importingSHA256Key string = "ingestion_batches_importing_sha256_key"
+)
+
+// ingestionBatchRepository keeps the upload jobs in the ingestion_batches table, so the
+// history of what was imported outlives the process
+type ingestionBatchRepository struct {
+	db DBTX
+}
+
+func NewIngestionBatchRepository(db DBTX) *ingestionBatchRepository {
+	return &ingestionBatchRepository{
+		db: db,
+	}
+}
+
+func (r *ingestionBatchRepository) Get(id string) (*entities.UploadJob, error) {
+	row := r.db.QueryRowContext(context.Background(), getIngestionBatchQuery, id)
+
+	job, err := scanIngestionBatch(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrUploadJobNotFound
+		}
+
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (r *ingestionBatchRepository) GetAll(limit, offset int) ([]*entities.UploadJob, error) {
+	rows, err := r.db.QueryContext(context.Background(), getAllIngestionBatchesQuery, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]*entities.UploadJob, 0)
+	for rows.Next() {
+		job, err := scanIngestionBatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (r *ingestionBatchRepository) GetImportedBySHA256(sha256 string) (*entities.UploadJob, error) {
+	row := r.db.QueryRowContext(context.Background(), getImportedIngestionBatchQuery, sha256)
+
+	job, err := scanIngestionBatch(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrUploadJobNotFound
+		}
+
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// Save inserts the job or updates what changes while it is processed
+func (r *ingestionBatchRepository) Save(job *entities.UploadJob) error {
+	rejections, err := json.Marshal(emptyIfNil(job.Rejections))
+	if err != nil {
+		return err
+	}
+
+	conflicts, err := json.Marshal(emptyIfNil(job.Conflicts))
+	if err != nil {
+		return err
+	}
+
+	writes, err := json.Marshal(job.Writes)
+	if err != nil {
+		return err
+	}
+
+	entries, err := json.Marshal(emptyIfNil(job.Entries))
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(
+		context.Background(),
+		saveIngestionBatchQuery,
+		job.ID,
+		job.FileName,
+		job.SHA256,
+		job.Size,
+		job.UploadedBy,
+		job.Forced,
+		job.Layout,
+		job.Format,
+		job.Encoding,
+		job.Mode,
+		job.SnapshotStart,
+		job.SnapshotEnd,
+		job.Compression,
+		job.DryRun,
+		string(job.State),
+		job.ProcessedLines,
+		job.AcceptedLines,
+		job.RejectedLines,
+		job.SkippedLines,
+		rejections,
+		conflicts,
+		writes,
+		entries,
+		job.Error,
+		job.CreatedAt,
+		nullTime(job.StartedAt),
+		nullTime(job.FinishedAt),
+		nullTime(job.RolledBackAt),
+	)
+
+	var pqErr *pq.Error
+	if stderrors.As(err, &pqErr) && pqErr.Code == uniqueViolation && pqErr.Constraint == importingSHA256Key {
+		return errors.ErrUploadAlreadyImported
+	}
+
+	return err
+}
+
+func (r *ingestionBatchRepository) FailUnfinished(reason string, finishedAt time.Time) (int, error) {
+	result, err := r.db.ExecContext(context.Background(), failUnfinishedIngestionBatchesQuery, reason, finishedAt)
+	if err != nil {
+		return 0, err
+	}
+
+	failed, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(failed), nil
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanIngestionBatch(row scanner) (*entities.UploadJob, error) {
+	job := new(entities.UploadJob)
+
+	var state string
+	var rejections, conflicts, writes, entries []byte
+	var startedAt, finishedAt, rolledBackAt sql.NullTime
+
+	if err := row.Scan(
+		&job.ID,
+		&job.FileName,
+		&job.SHA256,
+		&job.Size,
+		&job.UploadedBy,
+		&job.Forced,
+		&job.Layout,
+		&job.Format,
+		&job.Encoding,
+		&job.Mode,
+		&job.SnapshotStart,
+		&job.SnapshotEnd,
+		&job.Compression,
+		&job.DryRun,
+		&state,
+		&job.ProcessedLines,
+		&job.AcceptedLines,
+		&job.RejectedLines,
+		&job.SkippedLines,
+		&rejections,
+		&conflicts,
+		&writes,
+		&entries,
+		&job.Error,
+		&job.CreatedAt,
+		&startedAt,
+		&finishedAt,
+		&rolledBackAt,
+	); err != nil {
+		return nil, err
+	}
+
+	job.State = entities.UploadJobState(state)
+	job.StartedAt = startedAt.Time
+	job.FinishedAt = finishedAt.Time
+	job.RolledBackAt = rolledBackAt.Time
+
+	for _, column := range []struct {
+		value []byte
+		dest  any
+	}{
+		{value: rejections, dest: &job.Rejections},
+		{value: conflicts, dest: &job.Conflicts},
+		{value: writes, dest: &job.Writes},
+		{value: entries, dest: &job.Entries},
+	} {
+		if err := json.Unmarshal(column.value, column.dest); err != nil {
+			return nil, err
+		}
+	}
+
+	return job, nil
+}
+
+// emptyIfNil keeps nil slices from being stored as JSON null
+func emptyIfNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+
+	return values
+}
+
+// nullTime stores the zero time as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
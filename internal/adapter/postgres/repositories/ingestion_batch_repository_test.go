@@ -0,0 +1,314 @@
+package repositories_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/postgres/repositories"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+)
+
+var ingestionBatchColumns = []string{
+	"id", "file_name", "sha256", "size", "uploaded_by", "forced", "layout", "format",
+	"encoding", "mode", "snapshot_start", "snapshot_end", "compression", "dry_run", "state",
+	"processed_lines", "accepted_lines", "rejected_lines", "skipped_lines",
+	"rejections", "conflicts", "writes", "entries", "error", "created_at", "started_at", "finished_at", "rolled_back_at",
+}
+
+func mockIngestionBatchRow(job *entities.UploadJob) []driver.Value {
+	return []driver.Value{
+		job.ID, job.FileName, job.SHA256, job.Size, job.UploadedBy, job.Forced, job.Layout, job.Format,
+		job.Encoding, job.Mode, job.SnapshotStart, job.SnapshotEnd, job.Compression, job.DryRun, string(job.State),
+		job.ProcessedLines, job.AcceptedLines, job.RejectedLines, job.SkippedLines,
+		[]byte(`[{"Entry":"","Line":2,"Field":"user_id","Value":"AB","Reason":"must be an integer"}]`),
+		[]byte(`[]`),
+		[]byte(`{"Users":{"Inserted":1,"Updated":0,"Unchanged":0}}`),
+		[]byte(`[]`),
+		job.Error, job.CreatedAt, job.StartedAt, nil, nil,
+	}
+}
+
+func Test_Get_IngestionBatchRepository(t *testing.T) {
+	createdAt := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	mockJob := &entities.UploadJob{
+		ID:             "a1b2c3",
+		FileName:       "data_1.txt",
+		SHA256:         "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		Size:           95,
+		Layout:         "v1",
+		Format:         "fixed-width",
+		State:          entities.UploadJobRunning,
+		ProcessedLines: 2,
+		AcceptedLines:  1,
+		RejectedLines:  1,
+		CreatedAt:      createdAt,
+		StartedAt:      createdAt.Add(time.Second),
+	}
+
+	query := regexp.QuoteMeta(`FROM ingestion_batches b WHERE b.id = $1`)
+
+	tests := []struct {
+		description   string
+		expectedRows  *sqlmock.Rows
+		expectedErr   error
+		isErrExpected bool
+	}{
+		{
+			description:  "should return the job with its reports",
+			expectedRows: sqlmock.NewRows(ingestionBatchColumns).AddRow(mockIngestionBatchRow(mockJob)...),
+		},
+		{
+			description:   "should return not found when there is no row",
+			expectedRows:  sqlmock.NewRows(ingestionBatchColumns),
+			expectedErr:   errors.ErrUploadJobNotFound,
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			mock.ExpectQuery(query).WithArgs(mockJob.ID).WillReturnRows(tt.expectedRows)
+
+			ingestionBatchRepository := repositories.NewIngestionBatchRepository(db)
+			job, err := ingestionBatchRepository.Get(mockJob.ID)
+
+			if tt.isErrExpected {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, mockJob.SHA256, job.SHA256)
+			assert.Equal(t, mockJob.State, job.State)
+			assert.Equal(t, mockJob.StartedAt, job.StartedAt)
+			assert.True(t, job.FinishedAt.IsZero())
+			assert.Equal(t, []*entities.LineRejection{
+				{Line: 2, Field: "user_id", Value: "AB", Reason: "must be an integer"},
+			}, job.Rejections)
+			assert.Equal(t, 1, job.Writes.Users.Inserted)
+			assert.Empty(t, job.Entries)
+		})
+	}
+}
+
+func Test_GetAll_IngestionBatchRepository(t *testing.T) {
+	mockJob := &entities.UploadJob{
+		ID:        "a1b2c3",
+		FileName:  "data_1.txt",
+		State:     entities.UploadJobDone,
+		CreatedAt: time.Now(),
+	}
+
+	query := regexp.QuoteMeta(`FROM ingestion_batches b ORDER BY b.created_at DESC LIMIT $1 OFFSET $2`)
+
+	tests := []struct {
+		description   string
+		setMocks      func(mock sqlmock.Sqlmock)
+		expectedLen   int
+		isErrExpected bool
+	}{
+		{
+			description: "should return a page of jobs",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs(50, 0).
+					WillReturnRows(sqlmock.NewRows(ingestionBatchColumns).AddRow(mockIngestionBatchRow(mockJob)...))
+			},
+			expectedLen: 1,
+		},
+		{
+			description: "should return error",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WithArgs(50, 0).WillReturnError(sql.ErrConnDone)
+			},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			tt.setMocks(mock)
+
+			ingestionBatchRepository := repositories.NewIngestionBatchRepository(db)
+			jobs, err := ingestionBatchRepository.GetAll(50, 0)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Len(t, jobs, tt.expectedLen)
+		})
+	}
+}
+
+func Test_GetImportedBySHA256_IngestionBatchRepository(t *testing.T) {
+	mockSHA256 := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	query := regexp.QuoteMeta(`WHERE b.sha256 = $1 AND b.state IN ('queued', 'running', 'done') AND NOT b.dry_run
+		ORDER BY b.created_at DESC LIMIT 1`)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(query).WithArgs(mockSHA256).WillReturnRows(sqlmock.NewRows(ingestionBatchColumns))
+
+	ingestionBatchRepository := repositories.NewIngestionBatchRepository(db)
+	job, err := ingestionBatchRepository.GetImportedBySHA256(mockSHA256)
+
+	assert.Nil(t, job)
+	assert.ErrorIs(t, err, errors.ErrUploadJobNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_Save_IngestionBatchRepository(t *testing.T) {
+	mockJob := &entities.UploadJob{
+		ID:        "a1b2c3",
+		FileName:  "data_1.txt",
+		SHA256:    "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		Size:      95,
+		State:     entities.UploadJobQueued,
+		CreatedAt: time.Now(),
+	}
+
+	query := regexp.QuoteMeta(`INSERT INTO ingestion_batches`)
+
+	tests := []struct {
+		description   string
+		setMocks      func(mock sqlmock.Sqlmock)
+		isErrExpected bool
+		expectedErr   error
+	}{
+		{
+			description: "should store empty reports and NULL times for a queued job",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(query).
+					WithArgs(
+						mockJob.ID, mockJob.FileName, mockJob.SHA256, mockJob.Size, "", false, "", "",
+						"", "", "", "", "", false, "queued", 0, 0, 0, 0,
+						[]byte(`[]`), []byte(`[]`), sqlmock.AnyArg(), []byte(`[]`), "", mockJob.CreatedAt,
+						sql.NullTime{}, sql.NullTime{}, sql.NullTime{},
+					).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			description: "should refuse a job loading a file another job is loading",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(query).WillReturnError(&pq.Error{
+					Code:       "23505",
+					Constraint: "ingestion_batches_importing_sha256_key",
+				})
+			},
+			isErrExpected: true,
+			expectedErr:   errors.ErrUploadAlreadyImported,
+		},
+		{
+			description: "should return error",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(query).WillReturnError(sql.ErrConnDone)
+			},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			tt.setMocks(mock)
+
+			ingestionBatchRepository := repositories.NewIngestionBatchRepository(db)
+			err = ingestionBatchRepository.Save(mockJob)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				if tt.expectedErr != nil {
+					assert.ErrorIs(t, err, tt.expectedErr)
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_FailUnfinished_IngestionBatchRepository(t *testing.T) {
+	mockFinishedAt := time.Now()
+	query := regexp.QuoteMeta(`UPDATE ingestion_batches SET state = 'failed', error = $1, finished_at = $2
+		WHERE state IN ('queued', 'running')`)
+
+	tests := []struct {
+		description   string
+		result        driver.Result
+		expected      int
+		isErrExpected bool
+	}{
+		{
+			description: "should return how many jobs were failed",
+			result:      sqlmock.NewResult(0, 2),
+			expected:    2,
+		},
+		{
+			description:   "should return error",
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			expectation := mock.ExpectExec(query).WithArgs("interrupted", mockFinishedAt)
+			if tt.isErrExpected {
+				expectation.WillReturnError(sql.ErrConnDone)
+			} else {
+				expectation.WillReturnResult(tt.result)
+			}
+
+			ingestionBatchRepository := repositories.NewIngestionBatchRepository(db)
+			failed, err := ingestionBatchRepository.FailUnfinished("interrupted", mockFinishedAt)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, failed)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
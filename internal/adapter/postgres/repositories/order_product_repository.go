@@ -2,38 +2,42 @@ package repositories
 
 import (
 	"context"
-	"database/sql"
 
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
 )
 
 const (
-	createOrderProductsQuery       string = `INSERT INTO order_products (order_id, product_id, value) VALUES ($1, $2, $3)`
-	getOrderProductsByOrderIdQuery string = `SELECT * FROM order_products op WHERE op.order_id = $1`
+	upsertOrderProductsQuery string = `INSERT INTO order_products (order_id, product_id, value, position) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (order_id, product_id, value, position) DO NOTHING
+		RETURNING (xmax = 0) AS inserted`
+	getOrderProductsByOrderIdQuery    string = `SELECT op.id, op.order_id, op.product_id, op.value, op.position FROM order_products op WHERE op.order_id = $1`
+	deleteOrderProductByIdQuery       string = `DELETE FROM order_products op WHERE op.id = $1`
+	deleteOrderProductsByOrderIdQuery string = `DELETE FROM order_products op WHERE op.order_id = $1`
+	deleteOrderProductsByProductQuery string = `DELETE FROM order_products op WHERE op.order_id = $1 AND op.product_id = $2`
+	deleteOrderProductsByValueQuery   string = `DELETE FROM order_products op WHERE op.order_id = $1 AND op.product_id = $2 AND op.value = $3`
 )
 
 type orderProductRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewOrderProductRepository(db *sql.DB) *orderProductRepository {
+func NewOrderProductRepository(db DBTX) *orderProductRepository {
 	return &orderProductRepository{
 		db: db,
 	}
 }
 
-func (r *orderProductRepository) Add(orderProduct *entities.OrderProduct) error {
-	if _, err := r.db.ExecContext(
-		context.Background(),
-		createOrderProductsQuery,
+// Upsert inserts the order product when no line with the same natural key
+// (order, product, value and position) exists yet
+func (r *orderProductRepository) Upsert(orderProduct *entities.OrderProduct) (entities.UpsertOutcome, error) {
+	return upsert(
+		r.db,
+		upsertOrderProductsQuery,
 		orderProduct.OrderID,
 		orderProduct.ProductID,
 		orderProduct.Value,
-	); err != nil {
-		return err
-	}
-
-	return nil
+		orderProduct.Position,
+	)
 }
 
 func (r *orderProductRepository) GetByOrderID(orderId uint) ([]*entities.OrderProduct, error) {
@@ -51,6 +55,7 @@ func (r *orderProductRepository) GetByOrderID(orderId uint) ([]*entities.OrderPr
 			&orderProduct.OrderID,
 			&orderProduct.ProductID,
 			&orderProduct.Value,
+			&orderProduct.Position,
 		); err != nil {
 			return nil, err
 		}
@@ -63,3 +68,19 @@ func (r *orderProductRepository) GetByOrderID(orderId uint) ([]*entities.OrderPr
 
 	return orders, nil
 }
+
+func (r *orderProductRepository) DeleteByID(id uint) (int, error) {
+	return deleteRows(r.db, deleteOrderProductByIdQuery, id)
+}
+
+func (r *orderProductRepository) DeleteByOrderID(orderId uint) (int, error) {
+	return deleteRows(r.db, deleteOrderProductsByOrderIdQuery, orderId)
+}
+
+func (r *orderProductRepository) DeleteByProduct(orderId, productId uint) (int, error) {
+	return deleteRows(r.db, deleteOrderProductsByProductQuery, orderId, productId)
+}
+
+func (r *orderProductRepository) DeleteByValue(orderId, productId uint, value entities.Money) (int, error) {
+	return deleteRows(r.db, deleteOrderProductsByValueQuery, orderId, productId, value)
+}
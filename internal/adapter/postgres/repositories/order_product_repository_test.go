@@ -2,6 +2,7 @@ package repositories_test
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"regexp"
 	"testing"
 
@@ -11,27 +12,50 @@ import (
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
 )
 
-func Test_Add_OrderProductRepository(t *testing.T) {
+func Test_Upsert_OrderProductRepository(t *testing.T) {
 	mockOrderProduct := &entities.OrderProduct{
 		ID:        10,
 		OrderID:   137,
 		ProductID: 120,
-		Value:     99.99,
+		Value:     9999,
+		Position:  1,
 	}
 
+	upsertQuery := `INSERT INTO order_products (order_id, product_id, value, position) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (order_id, product_id, value, position) DO NOTHING
+		RETURNING (xmax = 0) AS inserted`
+
 	tests := []struct {
-		description   string
-		expectedQuery string
-		isErrExpected bool
+		description     string
+		expectedQuery   string
+		expectedRows    *sqlmock.Rows
+		expectedOutcome entities.UpsertOutcome
+		isErrExpected   bool
 	}{
 		{
-			description:   "should return no error and add order product",
-			expectedQuery: `INSERT INTO order_products (order_id, product_id, value) VALUES ($1, $2, $3)`,
-			isErrExpected: false,
+			description:     "should return inserted when the row is new",
+			expectedQuery:   upsertQuery,
+			expectedRows:    sqlmock.NewRows([]string{"inserted"}).AddRow(true),
+			expectedOutcome: entities.UpsertInserted,
+			isErrExpected:   false,
+		},
+		{
+			description:     "should return updated when the row changed",
+			expectedQuery:   upsertQuery,
+			expectedRows:    sqlmock.NewRows([]string{"inserted"}).AddRow(false),
+			expectedOutcome: entities.UpsertUpdated,
+			isErrExpected:   false,
+		},
+		{
+			description:     "should return unchanged when no row is returned",
+			expectedQuery:   upsertQuery,
+			expectedRows:    sqlmock.NewRows([]string{"inserted"}),
+			expectedOutcome: entities.UpsertUnchanged,
+			isErrExpected:   false,
 		},
 		{
 			description:   "should return error",
-			expectedQuery: `INSERT INTO order_products (order_id, product_id, value) VALUES ($1, $2, $3)`,
+			expectedQuery: upsertQuery,
 			isErrExpected: true,
 		},
 	}
@@ -47,26 +71,31 @@ func Test_Add_OrderProductRepository(t *testing.T) {
 			query := regexp.QuoteMeta(tt.expectedQuery)
 
 			if tt.isErrExpected {
-				mock.ExpectExec(query).WithArgs(
+				mock.ExpectQuery(query).WithArgs(
 					mockOrderProduct.OrderID,
 					mockOrderProduct.ProductID,
 					mockOrderProduct.Value,
+					mockOrderProduct.Position,
 				).WillReturnError(sql.ErrConnDone)
 			} else {
-				mock.ExpectExec(query).WithArgs(
+				mock.ExpectQuery(query).WithArgs(
 					mockOrderProduct.OrderID,
 					mockOrderProduct.ProductID,
-					mockOrderProduct.Value,
-				).WillReturnResult(sqlmock.NewResult(1, 1))
+					"99.99",
+					mockOrderProduct.Position,
+				).WillReturnRows(tt.expectedRows)
 			}
 
 			orderProductRepository := repositories.NewOrderProductRepository(db)
-			if err := orderProductRepository.Add(mockOrderProduct); err != nil && tt.isErrExpected {
+			outcome, err := orderProductRepository.Upsert(mockOrderProduct)
+
+			if tt.isErrExpected {
 				assert.Error(t, err)
 				return
 			}
 
 			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedOutcome, outcome)
 		})
 	}
 }
@@ -74,8 +103,8 @@ func Test_Add_OrderProductRepository(t *testing.T) {
 func Test_GetByOrderID_OrderProductRepository(t *testing.T) {
 	mockOrderID := 10
 	mockOrderProducts := []*entities.OrderProduct{
-		{ID: 1, OrderID: uint(mockOrderID), ProductID: 100, Value: 99.90},
-		{ID: 2, OrderID: uint(mockOrderID), ProductID: 200, Value: 19.90},
+		{ID: 1, OrderID: uint(mockOrderID), ProductID: 100, Value: 9990, Position: 1},
+		{ID: 2, OrderID: uint(mockOrderID), ProductID: 200, Value: 1990, Position: 1},
 	}
 
 	tests := []struct {
@@ -86,61 +115,68 @@ func Test_GetByOrderID_OrderProductRepository(t *testing.T) {
 	}{
 		{
 			description:   "should return no error and return order products",
-			expectedQuery: `SELECT * FROM order_products op WHERE op.order_id = $1`,
+			expectedQuery: `SELECT op.id, op.order_id, op.product_id, op.value, op.position FROM order_products op WHERE op.order_id = $1`,
 			expectedRows: sqlmock.NewRows([]string{
 				"id",
 				"order_id",
 				"product_id",
 				"value",
+				"position",
 			}).AddRow(
 				mockOrderProducts[0].ID,
 				mockOrderProducts[0].OrderID,
 				mockOrderProducts[0].ProductID,
 				mockOrderProducts[0].Value,
+				mockOrderProducts[0].Position,
 			).AddRow(
 				mockOrderProducts[1].ID,
 				mockOrderProducts[1].OrderID,
 				mockOrderProducts[1].ProductID,
 				mockOrderProducts[1].Value,
+				mockOrderProducts[1].Position,
 			),
 			isErrExpected: false,
 		},
 		{
 			description:   "should return no error and return empty order products",
-			expectedQuery: `SELECT * FROM order_products op WHERE op.order_id = $1`,
+			expectedQuery: `SELECT op.id, op.order_id, op.product_id, op.value, op.position FROM order_products op WHERE op.order_id = $1`,
 			expectedRows: sqlmock.NewRows([]string{
 				"id",
 				"order_id",
 				"product_id",
 				"value",
+				"position",
 			}),
 			isErrExpected: false,
 		},
 		{
 			description:   "should return error on query",
-			expectedQuery: `SELECT * FROM order_products op WHEREoporder_id = $1`,
+			expectedQuery: `SELECT op.id, op.order_id, op.product_id, op.value, op.position FROM order_products op WHEREoporder_id = $1`,
 			expectedRows: sqlmock.NewRows([]string{
 				"id",
 				"order_id",
 				"product_id",
 				"value",
+				"position",
 			}),
 			isErrExpected: true,
 		},
 		{
 			description:   "should return error on scan rows",
-			expectedQuery: `SELECT * FROM order_products op WHERE op.order_id = $1`,
+			expectedQuery: `SELECT op.id, op.order_id, op.product_id, op.value, op.position FROM order_products op WHERE op.order_id = $1`,
 			expectedRows: sqlmock.NewRows([]string{
 				"id",
 				"order_id",
 				"product_id",
 				"value",
+				"position",
 				"mocked",
 			}).AddRow(
 				mockOrderProducts[0].ID,
 				mockOrderProducts[0].OrderID,
 				mockOrderProducts[0].ProductID,
 				mockOrderProducts[0].Value,
+				mockOrderProducts[0].Position,
 				[]byte{},
 			),
 			isErrExpected: true,
@@ -173,7 +209,212 @@ func Test_GetByOrderID_OrderProductRepository(t *testing.T) {
 				assert.Equal(t, mockOrderProducts[i].OrderID, orderProducts[i].OrderID)
 				assert.Equal(t, mockOrderProducts[i].ProductID, orderProducts[i].ProductID)
 				assert.Equal(t, mockOrderProducts[i].Value, orderProducts[i].Value)
+				assert.Equal(t, mockOrderProducts[i].Position, orderProducts[i].Position)
+			}
+		})
+	}
+}
+
+func Test_DeleteByOrderID_OrderProductRepository(t *testing.T) {
+	query := regexp.QuoteMeta(`DELETE FROM order_products op WHERE op.order_id = $1`)
+
+	tests := []struct {
+		description   string
+		result        driver.Result
+		expected      int
+		isErrExpected bool
+	}{
+		{
+			description: "should return how many lines were removed",
+			result:      sqlmock.NewResult(0, 3),
+			expected:    3,
+		},
+		{
+			description:   "should return error",
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			expectation := mock.ExpectExec(query).WithArgs(uint(137))
+			if tt.isErrExpected {
+				expectation.WillReturnError(sql.ErrConnDone)
+			} else {
+				expectation.WillReturnResult(tt.result)
+			}
+
+			orderProductRepository := repositories.NewOrderProductRepository(db)
+			deleted, err := orderProductRepository.DeleteByOrderID(137)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, deleted)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_DeleteByProduct_OrderProductRepository(t *testing.T) {
+	query := regexp.QuoteMeta(`DELETE FROM order_products op WHERE op.order_id = $1 AND op.product_id = $2`)
+
+	tests := []struct {
+		description   string
+		result        driver.Result
+		expected      int
+		isErrExpected bool
+	}{
+		{
+			description: "should return how many lines were removed",
+			result:      sqlmock.NewResult(0, 2),
+			expected:    2,
+		},
+		{
+			description: "should return zero when the order has no line of the product",
+			result:      sqlmock.NewResult(0, 0),
+		},
+		{
+			description:   "should return error",
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			expectation := mock.ExpectExec(query).WithArgs(uint(137), uint(120))
+			if tt.isErrExpected {
+				expectation.WillReturnError(sql.ErrConnDone)
+			} else {
+				expectation.WillReturnResult(tt.result)
+			}
+
+			orderProductRepository := repositories.NewOrderProductRepository(db)
+			deleted, err := orderProductRepository.DeleteByProduct(137, 120)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
 			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, deleted)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_DeleteByValue_OrderProductRepository(t *testing.T) {
+	query := regexp.QuoteMeta(`DELETE FROM order_products op WHERE op.order_id = $1 AND op.product_id = $2 AND op.value = $3`)
+
+	tests := []struct {
+		description   string
+		result        driver.Result
+		expected      int
+		isErrExpected bool
+	}{
+		{
+			description: "should return how many lines were removed",
+			result:      sqlmock.NewResult(0, 1),
+			expected:    1,
+		},
+		{
+			description:   "should return error",
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			expectation := mock.ExpectExec(query).WithArgs(uint(137), uint(120), "99.99")
+			if tt.isErrExpected {
+				expectation.WillReturnError(sql.ErrConnDone)
+			} else {
+				expectation.WillReturnResult(tt.result)
+			}
+
+			orderProductRepository := repositories.NewOrderProductRepository(db)
+			deleted, err := orderProductRepository.DeleteByValue(137, 120, 9999)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, deleted)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_DeleteByID_OrderProductRepository(t *testing.T) {
+	query := regexp.QuoteMeta(`DELETE FROM order_products op WHERE op.id = $1`)
+
+	tests := []struct {
+		description   string
+		result        driver.Result
+		expected      int
+		isErrExpected bool
+	}{
+		{
+			description: "should return how many lines were removed",
+			result:      sqlmock.NewResult(0, 1),
+			expected:    1,
+		},
+		{
+			description:   "should return error",
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			expectation := mock.ExpectExec(query).WithArgs(uint(42))
+			if tt.isErrExpected {
+				expectation.WillReturnError(sql.ErrConnDone)
+			} else {
+				expectation.WillReturnResult(tt.result)
+			}
+
+			orderProductRepository := repositories.NewOrderProductRepository(db)
+			deleted, err := orderProductRepository.DeleteByID(42)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, deleted)
+			assert.NoError(t, mock.ExpectationsWereMet())
 		})
 	}
 }
@@ -10,17 +10,22 @@ import (
 )
 
 const (
-	getOrderQuery            string = `SELECT * FROM orders o WHERE o.id = $1 ORDER BY o.id DESC`
-	getOrdersByIntervalQuery string = `SELECT * FROM orders o WHERE o.date >= $1 AND o.date <= $2 ORDER BY o.date DESC`
-	createOrderQuery         string = `INSERT INTO orders (id, user_id, date) VALUES ($1, $2, $3)`
-	getAllOrdersQuery        string = `SELECT * FROM orders o ORDER BY o.id DESC`
+	getOrderQuery            string = `SELECT o.id, o.user_id, o.date FROM orders o WHERE o.id = $1 ORDER BY o.id DESC`
+	getOrdersByIntervalQuery string = `SELECT o.id, o.user_id, o.date FROM orders o WHERE o.date >= $1 AND o.date < $2 ORDER BY o.date DESC`
+	upsertOrderQuery         string = `INSERT INTO orders (id, user_id, date) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET user_id = EXCLUDED.user_id, date = EXCLUDED.date
+		WHERE (orders.user_id, orders.date) IS DISTINCT FROM (EXCLUDED.user_id, EXCLUDED.date)
+		RETURNING (xmax = 0) AS inserted`
+	getAllOrdersQuery     string = `SELECT o.id, o.user_id, o.date FROM orders o ORDER BY o.id DESC`
+	deleteEmptyOrderQuery string = `DELETE FROM orders o WHERE o.id = $1
+		AND NOT EXISTS (SELECT 1 FROM order_products op WHERE op.order_id = o.id)`
 )
 
 type orderRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewOrderRepository(db *sql.DB) *orderRepository {
+func NewOrderRepository(db DBTX) *orderRepository {
 	return &orderRepository{
 		db: db,
 	}
@@ -71,18 +76,15 @@ func (r *orderRepository) GetByInterval(startDate, endDate time.Time) ([]*entiti
 	return orders, nil
 }
 
-func (r *orderRepository) Add(order *entities.Order) error {
-	if _, err := r.db.ExecContext(
-		context.Background(),
-		createOrderQuery,
+// Upsert inserts the order or updates its user and date when they changed
+func (r *orderRepository) Upsert(order *entities.Order) (entities.UpsertOutcome, error) {
+	return upsert(
+		r.db,
+		upsertOrderQuery,
 		order.ID,
 		order.UserID,
 		order.Date,
-	); err != nil {
-		return err
-	}
-
-	return nil
+	)
 }
 
 func (r *orderRepository) GetAll() ([]*entities.Order, error) {
@@ -111,3 +113,12 @@ func (r *orderRepository) GetAll() ([]*entities.Order, error) {
 
 	return orders, nil
 }
+
+func (r *orderRepository) DeleteIfEmpty(id uint) (bool, error) {
+	deleted, err := deleteRows(r.db, deleteEmptyOrderQuery, id)
+	if err != nil {
+		return false, err
+	}
+
+	return deleted > 0, nil
+}
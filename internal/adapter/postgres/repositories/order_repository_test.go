@@ -2,6 +2,7 @@ package repositories_test
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"regexp"
 	"testing"
 	"time"
@@ -27,7 +28,7 @@ func Test_Get_OrderRepository(t *testing.T) {
 	}{
 		{
 			description:   "should return no error",
-			expectedQuery: `SELECT * FROM orders o WHERE o.id = $1 ORDER BY o.id DESC`,
+			expectedQuery: `SELECT o.id, o.user_id, o.date FROM orders o WHERE o.id = $1 ORDER BY o.id DESC`,
 			expectedRows: sqlmock.NewRows([]string{
 				"id",
 				"user_id",
@@ -41,7 +42,7 @@ func Test_Get_OrderRepository(t *testing.T) {
 		},
 		{
 			description:   "should return error",
-			expectedQuery: `SELECT * FROM orders o WHEREoid IS NULL`,
+			expectedQuery: `SELECT o.id, o.user_id, o.date FROM orders o WHEREoid IS NULL`,
 			expectedRows: sqlmock.NewRows([]string{
 				"id",
 				"user_id",
@@ -55,7 +56,7 @@ func Test_Get_OrderRepository(t *testing.T) {
 		},
 		{
 			description:   "should return error on scan rows",
-			expectedQuery: `SELECT * FROM orders o WHERE o.id = $1 ORDER BY o.id DESC`,
+			expectedQuery: `SELECT o.id, o.user_id, o.date FROM orders o WHERE o.id = $1 ORDER BY o.id DESC`,
 			expectedRows: sqlmock.NewRows([]string{
 				"id",
 				"user_id",
@@ -71,7 +72,7 @@ func Test_Get_OrderRepository(t *testing.T) {
 		},
 		{
 			description:   "should return error on no rows",
-			expectedQuery: `SELECT * FROM orders o WHERE o.id = $1 ORDER BY o.id DESC`,
+			expectedQuery: `SELECT o.id, o.user_id, o.date FROM orders o WHERE o.id = $1 ORDER BY o.id DESC`,
 			expectedRows: sqlmock.NewRows([]string{
 				"ID",
 				"user_id",
@@ -127,7 +128,7 @@ func Test_GetByInterval_OrderRepository(t *testing.T) {
 	}{
 		{
 			description:   "should return no error",
-			expectedQuery: `SELECT * FROM orders o WHERE o.date >= $1 AND o.date <= $2 ORDER BY o.date DESC`,
+			expectedQuery: `SELECT o.id, o.user_id, o.date FROM orders o WHERE o.date >= $1 AND o.date < $2 ORDER BY o.date DESC`,
 			expectedRows: sqlmock.NewRows([]string{
 				"id",
 				"user_id",
@@ -149,7 +150,7 @@ func Test_GetByInterval_OrderRepository(t *testing.T) {
 		},
 		{
 			description:   "should return no error and return empty slice if no orders",
-			expectedQuery: `SELECT * FROM orders o WHERE o.date >= $1 AND o.date <= $2 ORDER BY o.date DESC`,
+			expectedQuery: `SELECT o.id, o.user_id, o.date FROM orders o WHERE o.date >= $1 AND o.date < $2 ORDER BY o.date DESC`,
 			expectedRows: sqlmock.NewRows([]string{
 				"id",
 				"user_id",
@@ -159,7 +160,7 @@ func Test_GetByInterval_OrderRepository(t *testing.T) {
 		},
 		{
 			description:   "should return error on query",
-			expectedQuery: `SELECT * FROM orders o WHEREo.date > $1 AND o.date <= $2 ORDER BY o.date DESC`,
+			expectedQuery: `SELECT o.id, o.user_id, o.date FROM orders o WHEREo.date > $1 AND o.date < $2 ORDER BY o.date DESC`,
 			expectedRows: sqlmock.NewRows([]string{
 				"id",
 				"user_id",
@@ -169,7 +170,7 @@ func Test_GetByInterval_OrderRepository(t *testing.T) {
 		},
 		{
 			description:   "should return error on scan rows",
-			expectedQuery: `SELECT * FROM orders o WHERE o.date >= $1 AND o.date <= $2 ORDER BY o.date DESC`,
+			expectedQuery: `SELECT o.id, o.user_id, o.date FROM orders o WHERE o.date >= $1 AND o.date < $2 ORDER BY o.date DESC`,
 			expectedRows: sqlmock.NewRows([]string{
 				"id",
 				"user_id",
@@ -215,26 +216,49 @@ func Test_GetByInterval_OrderRepository(t *testing.T) {
 	}
 }
 
-func Test_Add_OrderRepository(t *testing.T) {
+func Test_Upsert_OrderRepository(t *testing.T) {
 	mockOrder := &entities.Order{
 		ID:     2,
 		UserID: 20,
 		Date:   time.Now(),
 	}
 
+	upsertQuery := `INSERT INTO orders (id, user_id, date) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET user_id = EXCLUDED.user_id, date = EXCLUDED.date
+		WHERE (orders.user_id, orders.date) IS DISTINCT FROM (EXCLUDED.user_id, EXCLUDED.date)
+		RETURNING (xmax = 0) AS inserted`
+
 	tests := []struct {
-		description   string
-		expectedQuery string
-		isErrExpected bool
+		description     string
+		expectedQuery   string
+		expectedRows    *sqlmock.Rows
+		expectedOutcome entities.UpsertOutcome
+		isErrExpected   bool
 	}{
 		{
-			description:   "should add order successfully",
-			expectedQuery: `INSERT INTO orders (id, user_id, date) VALUES ($1, $2, $3)`,
-			isErrExpected: false,
+			description:     "should return inserted when the row is new",
+			expectedQuery:   upsertQuery,
+			expectedRows:    sqlmock.NewRows([]string{"inserted"}).AddRow(true),
+			expectedOutcome: entities.UpsertInserted,
+			isErrExpected:   false,
+		},
+		{
+			description:     "should return updated when the row changed",
+			expectedQuery:   upsertQuery,
+			expectedRows:    sqlmock.NewRows([]string{"inserted"}).AddRow(false),
+			expectedOutcome: entities.UpsertUpdated,
+			isErrExpected:   false,
 		},
 		{
-			description:   "should return error on database operation",
-			expectedQuery: `INSERT INTO orders (id, user_id, date) VALUES ($1, $2, $3)`,
+			description:     "should return unchanged when no row is returned",
+			expectedQuery:   upsertQuery,
+			expectedRows:    sqlmock.NewRows([]string{"inserted"}),
+			expectedOutcome: entities.UpsertUnchanged,
+			isErrExpected:   false,
+		},
+		{
+			description:   "should return error",
+			expectedQuery: upsertQuery,
 			isErrExpected: true,
 		},
 	}
@@ -250,13 +274,13 @@ func Test_Add_OrderRepository(t *testing.T) {
 			query := regexp.QuoteMeta(tt.expectedQuery)
 
 			if tt.isErrExpected {
-				mock.ExpectExec(query).WithArgs(mockOrder.ID, mockOrder.UserID, mockOrder.Date).WillReturnError(sql.ErrConnDone)
+				mock.ExpectQuery(query).WithArgs(mockOrder.ID, mockOrder.UserID, mockOrder.Date).WillReturnError(sql.ErrConnDone)
 			} else {
-				mock.ExpectExec(query).WithArgs(mockOrder.ID, mockOrder.UserID, mockOrder.Date).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(query).WithArgs(mockOrder.ID, mockOrder.UserID, mockOrder.Date).WillReturnRows(tt.expectedRows)
 			}
 
 			orderRepository := repositories.NewOrderRepository(db)
-			err = orderRepository.Add(mockOrder)
+			outcome, err := orderRepository.Upsert(mockOrder)
 
 			if tt.isErrExpected {
 				assert.Error(t, err)
@@ -264,6 +288,7 @@ func Test_Add_OrderRepository(t *testing.T) {
 			}
 
 			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedOutcome, outcome)
 		})
 	}
 }
@@ -286,7 +311,7 @@ func Test_GetAll_OrderRepository(t *testing.T) {
 	}{
 		{
 			description:   "should return no error",
-			expectedQuery: `SELECT * FROM orders o ORDER BY o.id DESC`,
+			expectedQuery: `SELECT o.id, o.user_id, o.date FROM orders o ORDER BY o.id DESC`,
 			expectedRows: sqlmock.NewRows([]string{
 				"id",
 				"user_id",
@@ -308,7 +333,7 @@ func Test_GetAll_OrderRepository(t *testing.T) {
 		},
 		{
 			description:   "should return no error and return empty slice if no orders",
-			expectedQuery: `SELECT * FROM orders o ORDER BY o.id DESC`,
+			expectedQuery: `SELECT o.id, o.user_id, o.date FROM orders o ORDER BY o.id DESC`,
 			expectedRows: sqlmock.NewRows([]string{
 				"id",
 				"user_id",
@@ -318,7 +343,7 @@ func Test_GetAll_OrderRepository(t *testing.T) {
 		},
 		{
 			description:   "should return error on query",
-			expectedQuery: `SELECT * FROM orders o ORDER BYoid DESC`,
+			expectedQuery: `SELECT o.id, o.user_id, o.date FROM orders o ORDER BYoid DESC`,
 			expectedRows: sqlmock.NewRows([]string{
 				"id",
 				"user_id",
@@ -328,7 +353,7 @@ func Test_GetAll_OrderRepository(t *testing.T) {
 		},
 		{
 			description:   "should return error on scan rows",
-			expectedQuery: `SELECT * FROM orders o ORDER BY o.id DESC`,
+			expectedQuery: `SELECT o.id, o.user_id, o.date FROM orders o ORDER BY o.id DESC`,
 			expectedRows: sqlmock.NewRows([]string{
 				"id",
 				"user_id",
@@ -373,3 +398,58 @@ func Test_GetAll_OrderRepository(t *testing.T) {
 		})
 	}
 }
+
+func Test_DeleteIfEmpty_OrderRepository(t *testing.T) {
+	query := regexp.QuoteMeta(`DELETE FROM orders o WHERE o.id = $1
+		AND NOT EXISTS (SELECT 1 FROM order_products op WHERE op.order_id = o.id)`)
+
+	tests := []struct {
+		description   string
+		result        driver.Result
+		expected      bool
+		isErrExpected bool
+	}{
+		{
+			description: "should tell the order was removed",
+			result:      sqlmock.NewResult(0, 1),
+			expected:    true,
+		},
+		{
+			description: "should tell the order was kept when it still has lines",
+			result:      sqlmock.NewResult(0, 0),
+		},
+		{
+			description:   "should return error",
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			expectation := mock.ExpectExec(query).WithArgs(uint(137))
+			if tt.isErrExpected {
+				expectation.WillReturnError(sql.ErrConnDone)
+			} else {
+				expectation.WillReturnResult(tt.result)
+			}
+
+			orderRepository := repositories.NewOrderRepository(db)
+			deleted, err := orderRepository.DeleteIfEmpty(137)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, deleted)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
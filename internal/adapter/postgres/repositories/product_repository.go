@@ -5,29 +5,47 @@ import (
 	"database/sql"
 
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
 )
 
 const (
-	createProductQuery string = `INSERT INTO products (id) VALUES ($1)`
+	getProductQuery    string = `SELECT p.id FROM products p WHERE p.id = $1`
+	upsertProductQuery string = `INSERT INTO products (id) VALUES ($1)
+		ON CONFLICT (id) DO NOTHING
+		RETURNING (xmax = 0) AS inserted`
 )
 
 type productRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewProductRepository(db *sql.DB) *productRepository {
+func NewProductRepository(db DBTX) *productRepository {
 	return &productRepository{
 		db: db,
 	}
 }
 
-func (r *productRepository) Add(product *entities.Product) error {
-	if _, err := r.db.ExecContext(
-		context.Background(),
-		createProductQuery,
-		product.ID,
+func (r *productRepository) Get(id uint) (*entities.Product, error) {
+	product := new(entities.Product)
+	row := r.db.QueryRowContext(context.Background(), getProductQuery, id)
+	if err := row.Scan(
+		&product.ID,
 	); err != nil {
-		return err
+		if err == sql.ErrNoRows {
+			return nil, errors.ErrProductNotFound
+		}
+
+		return nil, err
 	}
-	return nil
+
+	return product, nil
+}
+
+// Upsert inserts the product when it does not exist yet
+func (r *productRepository) Upsert(product *entities.Product) (entities.UpsertOutcome, error) {
+	return upsert(
+		r.db,
+		upsertProductQuery,
+		product.ID,
+	)
 }
@@ -9,26 +9,107 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/postgres/repositories"
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
 )
 
-func Test_Add_ProductRepository(t *testing.T) {
+func Test_Get_ProductRepository(t *testing.T) {
 	mockProduct := &entities.Product{
 		ID: 150,
 	}
 
+	getQuery := `SELECT p.id FROM products p WHERE p.id = $1`
+
 	tests := []struct {
 		description   string
-		expectedQuery string
+		expectedRows  *sqlmock.Rows
+		expectedErr   error
 		isErrExpected bool
 	}{
 		{
-			description:   "should return no error when add product",
-			expectedQuery: `INSERT INTO products (id) VALUES ($1)`,
+			description:   "should return no error",
+			expectedRows:  sqlmock.NewRows([]string{"ID"}).AddRow(mockProduct.ID),
 			isErrExpected: false,
 		},
+		{
+			description:   "should return not found when there is no row",
+			expectedRows:  sqlmock.NewRows([]string{"ID"}),
+			expectedErr:   errors.ErrProductNotFound,
+			isErrExpected: true,
+		},
+		{
+			description:   "should return error on scan rows",
+			expectedRows:  sqlmock.NewRows([]string{"ID", "Mocked"}).AddRow(mockProduct.ID, []byte{}),
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			mock.ExpectQuery(regexp.QuoteMeta(getQuery)).WithArgs(mockProduct.ID).WillReturnRows(tt.expectedRows)
+
+			productRepository := repositories.NewProductRepository(db)
+			p, err := productRepository.Get(mockProduct.ID)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				if tt.expectedErr != nil {
+					assert.Equal(t, tt.expectedErr, err)
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, mockProduct, p)
+		})
+	}
+}
+
+func Test_Upsert_ProductRepository(t *testing.T) {
+	mockProduct := &entities.Product{
+		ID: 150,
+	}
+
+	upsertQuery := `INSERT INTO products (id) VALUES ($1)
+		ON CONFLICT (id) DO NOTHING
+		RETURNING (xmax = 0) AS inserted`
+
+	tests := []struct {
+		description     string
+		expectedQuery   string
+		expectedRows    *sqlmock.Rows
+		expectedOutcome entities.UpsertOutcome
+		isErrExpected   bool
+	}{
+		{
+			description:     "should return inserted when the row is new",
+			expectedQuery:   upsertQuery,
+			expectedRows:    sqlmock.NewRows([]string{"inserted"}).AddRow(true),
+			expectedOutcome: entities.UpsertInserted,
+			isErrExpected:   false,
+		},
+		{
+			description:     "should return updated when the row changed",
+			expectedQuery:   upsertQuery,
+			expectedRows:    sqlmock.NewRows([]string{"inserted"}).AddRow(false),
+			expectedOutcome: entities.UpsertUpdated,
+			isErrExpected:   false,
+		},
+		{
+			description:     "should return unchanged when no row is returned",
+			expectedQuery:   upsertQuery,
+			expectedRows:    sqlmock.NewRows([]string{"inserted"}),
+			expectedOutcome: entities.UpsertUnchanged,
+			isErrExpected:   false,
+		},
 		{
 			description:   "should return error",
-			expectedQuery: `INSERT INTO products (id) VALUES ($1)`,
+			expectedQuery: upsertQuery,
 			isErrExpected: true,
 		},
 	}
@@ -44,18 +125,21 @@ func Test_Add_ProductRepository(t *testing.T) {
 			query := regexp.QuoteMeta(tt.expectedQuery)
 
 			if tt.isErrExpected {
-				mock.ExpectExec(query).WithArgs(mockProduct.ID).WillReturnError(sql.ErrConnDone)
+				mock.ExpectQuery(query).WithArgs(mockProduct.ID).WillReturnError(sql.ErrConnDone)
 			} else {
-				mock.ExpectExec(query).WithArgs(mockProduct.ID).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(query).WithArgs(mockProduct.ID).WillReturnRows(tt.expectedRows)
 			}
 
 			productRepository := repositories.NewProductRepository(db)
-			if err := productRepository.Add(mockProduct); err != nil && tt.isErrExpected {
+			outcome, err := productRepository.Upsert(mockProduct)
+
+			if tt.isErrExpected {
 				assert.Error(t, err)
 				return
 			}
 
 			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedOutcome, outcome)
 		})
 	}
 }
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/lib/pq"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+)
+
+const (
+	saveRejectedLineQuery string = `INSERT INTO rejected_lines (batch_id, file_name, line, content, rejections)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (batch_id, file_name, line) DO UPDATE SET content = EXCLUDED.content,
+		rejections = EXCLUDED.rejections, rejected_at = NOW()`
+	getRejectedLinesQuery string = `SELECT l.batch_id, l.file_name, l.line, l.content, l.rejections, l.rejected_at
+		FROM rejected_lines l
+		WHERE l.batch_id = $1 AND l.file_name = $2 AND l.line > $3
+		ORDER BY l.line LIMIT $4`
+	removeRejectedLinesQuery string = `DELETE FROM rejected_lines l WHERE l.batch_id = $1 AND l.file_name = $2 AND l.line = ANY($3)`
+)
+
+// rejectedLineRepository keeps the quarantined lines in the rejected_lines table
+type rejectedLineRepository struct {
+	db DBTX
+}
+
+func NewRejectedLineRepository(db DBTX) *rejectedLineRepository {
+	return &rejectedLineRepository{
+		db: db,
+	}
+}
+
+func (r *rejectedLineRepository) Save(lines []*entities.RejectedLine) error {
+	for _, line := range lines {
+		rejections, err := json.Marshal(emptyIfNil(line.Rejections))
+		if err != nil {
+			return err
+		}
+
+		if _, err := r.db.ExecContext(
+			context.Background(),
+			saveRejectedLineQuery,
+			line.BatchID,
+			line.FileName,
+			line.Line,
+			line.Content,
+			rejections,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *rejectedLineRepository) Get(batchID, fileName string, afterLine, limit int) ([]*entities.RejectedLine, error) {
+	rows, err := r.db.QueryContext(context.Background(), getRejectedLinesQuery, batchID, fileName, afterLine, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := make([]*entities.RejectedLine, 0)
+	for rows.Next() {
+		line := new(entities.RejectedLine)
+
+		var rejections []byte
+		if err := rows.Scan(
+			&line.BatchID,
+			&line.FileName,
+			&line.Line,
+			&line.Content,
+			&rejections,
+			&line.RejectedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(rejections, &line.Rejections); err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+func (r *rejectedLineRepository) Remove(batchID, fileName string, lines []int) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	numbers := make(pq.Int64Array, 0, len(lines))
+	for _, line := range lines {
+		numbers = append(numbers, int64(line))
+	}
+
+	_, err := r.db.ExecContext(context.Background(), removeRejectedLinesQuery, batchID, fileName, numbers)
+	return err
+}
@@ -0,0 +1,214 @@
+package repositories_test
+
+import (
+	"database/sql"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/postgres/repositories"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+)
+
+func Test_Save_RejectedLineRepository(t *testing.T) {
+	query := regexp.QuoteMeta(`INSERT INTO rejected_lines`)
+	content := "0000000070                              Palmer Prosacco00000007530000000003     1836.7420210308"
+
+	tests := []struct {
+		description   string
+		setMocks      func(mock sqlmock.Sqlmock)
+		isErrExpected bool
+	}{
+		{
+			description: "should store every line with its rejections",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(query).
+					WithArgs("a1b2c3", "data_1.txt", 2, content, []byte(`[{"Entry":"","Line":2,"Field":"order","Value":"753","Reason":"order 753 already belongs to user 75"}]`)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(query).
+					WithArgs("a1b2c3", "data_1.txt", 5, "", []byte(`[]`)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			description: "should return error",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(query).WillReturnError(sql.ErrConnDone)
+			},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			tt.setMocks(mock)
+
+			lines := []*entities.RejectedLine{
+				{
+					BatchID:  "a1b2c3",
+					FileName: "data_1.txt",
+					Line:     2,
+					Content:  content,
+					Rejections: []*entities.LineRejection{
+						{Line: 2, Field: "order", Value: "753", Reason: "order 753 already belongs to user 75"},
+					},
+				},
+				{BatchID: "a1b2c3", FileName: "data_1.txt", Line: 5},
+			}
+
+			rejectedLineRepository := repositories.NewRejectedLineRepository(db)
+			err = rejectedLineRepository.Save(lines)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_Get_RejectedLineRepository(t *testing.T) {
+	rejectedAt := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	query := regexp.QuoteMeta(`SELECT l.batch_id, l.file_name, l.line, l.content, l.rejections, l.rejected_at
+		FROM rejected_lines l
+		WHERE l.batch_id = $1 AND l.file_name = $2 AND l.line > $3
+		ORDER BY l.line LIMIT $4`)
+	columns := []string{"batch_id", "file_name", "line", "content", "rejections", "rejected_at"}
+
+	tests := []struct {
+		description   string
+		setMocks      func(mock sqlmock.Sqlmock)
+		expected      []*entities.RejectedLine
+		isErrExpected bool
+	}{
+		{
+			description: "should return the lines after the given one",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs("a1b2c3", "data_1.txt", 1, 500).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("a1b2c3", "data_1.txt", 2, "line 2", []byte(`[{"Line":2,"Field":"user_id","Value":"x","Reason":"must be a positive integer"}]`), rejectedAt))
+			},
+			expected: []*entities.RejectedLine{
+				{
+					BatchID:  "a1b2c3",
+					FileName: "data_1.txt",
+					Line:     2,
+					Content:  "line 2",
+					Rejections: []*entities.LineRejection{
+						{Line: 2, Field: "user_id", Value: "x", Reason: "must be a positive integer"},
+					},
+					RejectedAt: rejectedAt,
+				},
+			},
+		},
+		{
+			description: "should return no lines",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).
+					WithArgs("a1b2c3", "data_1.txt", 1, 500).
+					WillReturnRows(sqlmock.NewRows(columns))
+			},
+			expected: []*entities.RejectedLine{},
+		},
+		{
+			description: "should return error",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(query).WillReturnError(sql.ErrConnDone)
+			},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			tt.setMocks(mock)
+
+			rejectedLineRepository := repositories.NewRejectedLineRepository(db)
+			lines, err := rejectedLineRepository.Get("a1b2c3", "data_1.txt", 1, 500)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, lines)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_Remove_RejectedLineRepository(t *testing.T) {
+	query := regexp.QuoteMeta(`DELETE FROM rejected_lines l WHERE l.batch_id = $1 AND l.file_name = $2 AND l.line = ANY($3)`)
+
+	tests := []struct {
+		description   string
+		lines         []int
+		setMocks      func(mock sqlmock.Sqlmock)
+		isErrExpected bool
+	}{
+		{
+			description: "should remove the given lines",
+			lines:       []int{2, 5},
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(query).
+					WithArgs("a1b2c3", "data_1.txt", pq.Int64Array{2, 5}).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+		},
+		{
+			description: "should do nothing without lines",
+			setMocks:    func(mock sqlmock.Sqlmock) {},
+		},
+		{
+			description: "should return error",
+			lines:       []int{2},
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(query).WillReturnError(sql.ErrConnDone)
+			},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			tt.setMocks(mock)
+
+			rejectedLineRepository := repositories.NewRejectedLineRepository(db)
+			err = rejectedLineRepository.Remove("a1b2c3", "data_1.txt", tt.lines)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
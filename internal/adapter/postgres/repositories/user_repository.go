@@ -9,15 +9,18 @@ import (
 )
 
 const (
-	getUserQuery    string = `SELECT * FROM users u WHERE u.id = $1 ORDER BY u.id DESC`
-	createUserQuery string = `INSERT INTO users (id, name) VALUES ($1, $2)`
+	getUserQuery    string = `SELECT u.id, u.name FROM users u WHERE u.id = $1 ORDER BY u.id DESC`
+	upsertUserQuery string = `INSERT INTO users (id, name) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name
+		WHERE users.name IS DISTINCT FROM EXCLUDED.name
+		RETURNING (xmax = 0) AS inserted`
 )
 
 type userRepository struct {
-	db *sql.DB
+	db DBTX
 }
 
-func NewUserRepository(db *sql.DB) *userRepository {
+func NewUserRepository(db DBTX) *userRepository {
 	return &userRepository{
 		db: db,
 	}
@@ -44,14 +47,12 @@ func (r *userRepository) Get(id uint) (*entities.User, error) {
 	return user, nil
 }
 
-func (r *userRepository) Add(user *entities.User) error {
-	if _, err := r.db.ExecContext(
-		context.Background(),
-		createUserQuery,
+// Upsert inserts the user or updates its name when it changed
+func (r *userRepository) Upsert(user *entities.User) (entities.UpsertOutcome, error) {
+	return upsert(
+		r.db,
+		upsertUserQuery,
 		user.ID,
 		user.Name,
-	); err != nil {
-		return err
-	}
-	return nil
+	)
 }
@@ -25,7 +25,7 @@ func Test_Get_UserRepository(t *testing.T) {
 	}{
 		{
 			description:   "should return no error",
-			expectedQuery: `SELECT * FROM users u WHERE u.id = $1 ORDER BY u.id DESC`,
+			expectedQuery: `SELECT u.id, u.name FROM users u WHERE u.id = $1 ORDER BY u.id DESC`,
 			expectedRows: sqlmock.NewRows([]string{
 				"ID",
 				"Name",
@@ -37,7 +37,7 @@ func Test_Get_UserRepository(t *testing.T) {
 		},
 		{
 			description:   "should return error",
-			expectedQuery: `SELECT * FROM users u WHEREuid IS NULL`,
+			expectedQuery: `SELECT u.id, u.name FROM users u WHEREuid IS NULL`,
 			expectedRows: sqlmock.NewRows([]string{
 				"ID",
 				"Name",
@@ -49,7 +49,7 @@ func Test_Get_UserRepository(t *testing.T) {
 		},
 		{
 			description:   "should return error on scan rows",
-			expectedQuery: `SELECT * FROM users u WHERE u.id = $1 ORDER BY u.id DESC`,
+			expectedQuery: `SELECT u.id, u.name FROM users u WHERE u.id = $1 ORDER BY u.id DESC`,
 			expectedRows: sqlmock.NewRows([]string{
 				"ID",
 				"Name",
@@ -63,7 +63,7 @@ func Test_Get_UserRepository(t *testing.T) {
 		},
 		{
 			description:   "should return error on scan rows",
-			expectedQuery: `SELECT * FROM users u WHERE u.id = $1 ORDER BY u.id DESC`,
+			expectedQuery: `SELECT u.id, u.name FROM users u WHERE u.id = $1 ORDER BY u.id DESC`,
 			expectedRows: sqlmock.NewRows([]string{
 				"ID",
 				"Name",
@@ -100,25 +100,48 @@ func Test_Get_UserRepository(t *testing.T) {
 	}
 }
 
-func Test_Add_UserRepository(t *testing.T) {
+func Test_Upsert_UserRepository(t *testing.T) {
 	mockUser := &entities.User{
 		ID:   10,
 		Name: "Tulio Guaraldo",
 	}
 
+	upsertQuery := `INSERT INTO users (id, name) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name
+		WHERE users.name IS DISTINCT FROM EXCLUDED.name
+		RETURNING (xmax = 0) AS inserted`
+
 	tests := []struct {
-		description   string
-		expectedQuery string
-		isErrExpected bool
+		description     string
+		expectedQuery   string
+		expectedRows    *sqlmock.Rows
+		expectedOutcome entities.UpsertOutcome
+		isErrExpected   bool
 	}{
 		{
-			description:   "should return no error when add user",
-			expectedQuery: `INSERT INTO users (id, name) VALUES ($1, $2)`,
-			isErrExpected: false,
+			description:     "should return inserted when the row is new",
+			expectedQuery:   upsertQuery,
+			expectedRows:    sqlmock.NewRows([]string{"inserted"}).AddRow(true),
+			expectedOutcome: entities.UpsertInserted,
+			isErrExpected:   false,
+		},
+		{
+			description:     "should return updated when the row changed",
+			expectedQuery:   upsertQuery,
+			expectedRows:    sqlmock.NewRows([]string{"inserted"}).AddRow(false),
+			expectedOutcome: entities.UpsertUpdated,
+			isErrExpected:   false,
+		},
+		{
+			description:     "should return unchanged when no row is returned",
+			expectedQuery:   upsertQuery,
+			expectedRows:    sqlmock.NewRows([]string{"inserted"}),
+			expectedOutcome: entities.UpsertUnchanged,
+			isErrExpected:   false,
 		},
 		{
 			description:   "should return error",
-			expectedQuery: `INSERT INTO users (id, name) VALUES ($1, $2)`,
+			expectedQuery: upsertQuery,
 			isErrExpected: true,
 		},
 	}
@@ -134,13 +157,13 @@ func Test_Add_UserRepository(t *testing.T) {
 			query := regexp.QuoteMeta(tt.expectedQuery)
 
 			if tt.isErrExpected {
-				mock.ExpectExec(query).WithArgs(mockUser.ID, mockUser.Name).WillReturnError(sql.ErrConnDone)
+				mock.ExpectQuery(query).WithArgs(mockUser.ID, mockUser.Name).WillReturnError(sql.ErrConnDone)
 			} else {
-				mock.ExpectExec(query).WithArgs(mockUser.ID, mockUser.Name).WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectQuery(query).WithArgs(mockUser.ID, mockUser.Name).WillReturnRows(tt.expectedRows)
 			}
 
 			userRepository := repositories.NewUserRepository(db)
-			err = userRepository.Add(mockUser)
+			outcome, err := userRepository.Upsert(mockUser)
 
 			if tt.isErrExpected {
 				assert.Error(t, err)
@@ -148,6 +171,7 @@ func Test_Add_UserRepository(t *testing.T) {
 			}
 
 			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedOutcome, outcome)
 		})
 	}
 }
@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+	"log"
+
+	"github.com/lib/pq"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/postgres/repositories"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/transaction"
+)
+
+// setBatchQuery tags the rows the transaction inserts and changes with its ingestion batch,
+// through the app.batch_id setting read by the batch_id column defaults and the change triggers
+const setBatchQuery string = `SELECT set_config('app.batch_id', $1, true)`
+
+// deadlockDetected is the code of the error Postgres aborts one of the deadlocked transactions with
+const deadlockDetected pq.ErrorCode = "40P01"
+
+type unitOfWork struct {
+	db *sql.DB
+}
+
+func NewUnitOfWork(db *sql.DB) *unitOfWork {
+	return &unitOfWork{
+		db: db,
+	}
+}
+
+// Do opens a transaction, binds the repositories to it and commits when fn succeeds.
+// Any error or panic inside fn rolls the transaction back. A transaction aborted to break
+// a deadlock fails with errors.ErrTransactionDeadlock, so it can be retried
+func (u *unitOfWork) Do(ctx context.Context, fn func(repositories *transaction.Repositories) error) error {
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if batchID := transaction.BatchFrom(ctx); batchID != "" {
+		if _, err := tx.ExecContext(ctx, setBatchQuery, batchID); err != nil {
+			rollback(tx)
+			return err
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			rollback(tx)
+			panic(r)
+		}
+	}()
+
+	if err := fn(newTransactionRepositories(tx)); err != nil {
+		rollback(tx)
+		return translateError(err)
+	}
+
+	return translateError(tx.Commit())
+}
+
+// View opens a read-only transaction, binds the repositories to it and rolls it back once fn returns
+func (u *unitOfWork) View(ctx context.Context, fn func(repositories *transaction.Repositories) error) error {
+	tx, err := u.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer rollback(tx)
+
+	return fn(newTransactionRepositories(tx))
+}
+
+func newTransactionRepositories(tx *sql.Tx) *transaction.Repositories {
+	return &transaction.Repositories{
+		Users:         repositories.NewUserRepository(tx),
+		Products:      repositories.NewProductRepository(tx),
+		Orders:        repositories.NewOrderRepository(tx),
+		OrderProducts: repositories.NewOrderProductRepository(tx),
+		Bulk:          repositories.NewBulkRepository(tx),
+		Conflicts:     repositories.NewConflictRepository(tx),
+		Batches:       repositories.NewIngestionBatchRepository(tx),
+		Rollbacks:     repositories.NewBatchRollbackRepository(tx),
+		Quarantine:    repositories.NewRejectedLineRepository(tx),
+	}
+}
+
+// translateError tells the deadlocks apart from the other errors of a transaction
+func translateError(err error) error {
+	var pqErr *pq.Error
+	if stderrors.As(err, &pqErr) && pqErr.Code == deadlockDetected {
+		return fmt.Errorf("%w: %w", errors.ErrTransactionDeadlock, err)
+	}
+
+	return err
+}
+
+func rollback(tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil {
+		log.Printf("Failed to rollback Postgres transaction. Details: %s\n", err.Error())
+	}
+}
@@ -0,0 +1,222 @@
+package postgres_test
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/adapter/postgres"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/transaction"
+)
+
+func Test_Do_UnitOfWork(t *testing.T) {
+	mockUser := &entities.User{
+		ID:   10,
+		Name: "Tulio Guaraldo",
+	}
+
+	upsertUserQuery := `INSERT INTO users (id, name) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name
+		WHERE users.name IS DISTINCT FROM EXCLUDED.name
+		RETURNING (xmax = 0) AS inserted`
+
+	setBatchQuery := `SELECT set_config('app.batch_id', $1, true)`
+
+	tests := []struct {
+		description   string
+		batchID       string
+		setMocks      func(mock sqlmock.Sqlmock)
+		isErrExpected bool
+		expectedErr   error
+	}{
+		{
+			description: "should commit when every operation succeeds",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(upsertUserQuery)).
+					WithArgs(mockUser.ID, mockUser.Name).
+					WillReturnRows(sqlmock.NewRows([]string{"inserted"}).AddRow(true))
+				mock.ExpectCommit()
+			},
+			isErrExpected: false,
+		},
+		{
+			description: "should rollback when an operation fails",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(upsertUserQuery)).
+					WithArgs(mockUser.ID, mockUser.Name).
+					WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			isErrExpected: true,
+		},
+		{
+			description: "should tell a transaction aborted by a deadlock apart",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(upsertUserQuery)).
+					WithArgs(mockUser.ID, mockUser.Name).
+					WillReturnError(&pq.Error{Code: "40P01", Message: "deadlock detected"})
+				mock.ExpectRollback()
+			},
+			isErrExpected: true,
+			expectedErr:   errors.ErrTransactionDeadlock,
+		},
+		{
+			description: "should tell a deadlock found on commit apart",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(upsertUserQuery)).
+					WithArgs(mockUser.ID, mockUser.Name).
+					WillReturnRows(sqlmock.NewRows([]string{"inserted"}).AddRow(true))
+				mock.ExpectCommit().WillReturnError(&pq.Error{Code: "40P01", Message: "deadlock detected"})
+			},
+			isErrExpected: true,
+			expectedErr:   errors.ErrTransactionDeadlock,
+		},
+		{
+			description: "should tag the rows written with the batch of the context",
+			batchID:     "a1b2c3",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(setBatchQuery)).
+					WithArgs("a1b2c3").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(regexp.QuoteMeta(upsertUserQuery)).
+					WithArgs(mockUser.ID, mockUser.Name).
+					WillReturnRows(sqlmock.NewRows([]string{"inserted"}).AddRow(true))
+				mock.ExpectCommit()
+			},
+			isErrExpected: false,
+		},
+		{
+			description: "should rollback when the batch can not be set",
+			batchID:     "a1b2c3",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(setBatchQuery)).
+					WithArgs("a1b2c3").
+					WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			isErrExpected: true,
+		},
+		{
+			description: "should return error when the transaction can not begin",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(sql.ErrConnDone)
+			},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			tt.setMocks(mock)
+
+			ctx := context.Background()
+			if tt.batchID != "" {
+				ctx = transaction.WithBatch(ctx, tt.batchID)
+			}
+
+			unitOfWork := postgres.NewUnitOfWork(db)
+			err = unitOfWork.Do(ctx, func(r *transaction.Repositories) error {
+				_, err := r.Users.Upsert(mockUser)
+				return err
+			})
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func Test_View_UnitOfWork(t *testing.T) {
+	mockUser := &entities.User{
+		ID:   10,
+		Name: "Tulio Guaraldo",
+	}
+
+	getUserQuery := `SELECT u.id, u.name FROM users u WHERE u.id = $1 ORDER BY u.id DESC`
+
+	tests := []struct {
+		description   string
+		setMocks      func(mock sqlmock.Sqlmock)
+		isErrExpected bool
+	}{
+		{
+			description: "should rollback even when every operation succeeds",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(getUserQuery)).
+					WithArgs(mockUser.ID).
+					WillReturnRows(sqlmock.NewRows([]string{"ID", "Name"}).AddRow(mockUser.ID, mockUser.Name))
+				mock.ExpectRollback()
+			},
+			isErrExpected: false,
+		},
+		{
+			description: "should rollback when an operation fails",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(getUserQuery)).
+					WithArgs(mockUser.ID).
+					WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			isErrExpected: true,
+		},
+		{
+			description: "should return error when the transaction can not begin",
+			setMocks: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(sql.ErrConnDone)
+			},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				panic(err)
+			}
+			defer db.Close()
+
+			tt.setMocks(mock)
+
+			unitOfWork := postgres.NewUnitOfWork(db)
+			err = unitOfWork.View(context.Background(), func(r *transaction.Repositories) error {
+				_, err := r.Users.Get(mockUser.ID)
+				return err
+			})
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -1,29 +1,133 @@
-package config
-
-import (
-	"os"
-
-	"github.com/joho/godotenv"
-)
-
-// LoadEnvs loads all the variables listed in the environment variables file
-func LoadEnvs() error {
-	if Env == nil {
-		if err := godotenv.Load(); err != nil {
-			return err
-		}
-
-		env := &envVar{
-			PostgresUser:     os.Getenv("POSTGRES_USER"),
-			PostgresPassword: os.Getenv("POSTGRES_PASSWORD"),
-			PostgresDb:       os.Getenv("POSTGRES_DB"),
-			PostgresHost:     os.Getenv("POSTGRES_HOST"),
-			PostgresPort:     os.Getenv("POSTGRES_PORT"),
-			Port:             os.Getenv("PORT"),
-		}
-
-		Env = env
-	}
-
-	return nil
-}
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	domainerrors "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+)
+
+// LoadEnvs loads all the variables listed in the environment variables file. The file is
+// optional when the variables are already set, like in batch jobs run by a scheduler
+func LoadEnvs() error {
+	if Env == nil {
+		if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		env := &envVar{
+			PostgresUser:           os.Getenv("POSTGRES_USER"),
+			PostgresPassword:       os.Getenv("POSTGRES_PASSWORD"),
+			PostgresDb:             os.Getenv("POSTGRES_DB"),
+			PostgresHost:           os.Getenv("POSTGRES_HOST"),
+			PostgresPort:           os.Getenv("POSTGRES_PORT"),
+			Port:                   os.Getenv("PORT"),
+			UploadWorkers:          getEnvInt("UPLOAD_WORKERS", 2),
+			UploadQueueSize:        getEnvInt("UPLOAD_QUEUE_SIZE", 100),
+			UploadSpoolDir:         getEnvString("UPLOAD_SPOOL_DIR", os.TempDir()),
+			UploadMaxBodySize:      int64(getEnvInt("UPLOAD_MAX_BODY_SIZE", 1<<30)),
+			IngestChunkSize:        getEnvInt("INGEST_CHUNK_SIZE", 0),
+			IngestBulkThreshold:    getEnvInt("INGEST_BULK_THRESHOLD", 10000),
+			IngestWorkers:          getEnvInt("INGEST_WORKERS", 4),
+			IngestMaxLineSize:      getEnvInt("INGEST_MAX_LINE_SIZE", 1<<20),
+			IngestDryRunMaxRecords: getEnvInt("INGEST_DRY_RUN_MAX_RECORDS", 1_000_000),
+			LayoutsDir:             os.Getenv("LAYOUTS_DIR"),
+			InboxDir:               os.Getenv("INBOX_DIR"),
+			InboxPollInterval:      getEnvDuration("INBOX_POLL_INTERVAL", 5*time.Second),
+
+			ConflictPolicyOrderUser: getEnvString("CONFLICT_POLICY_ORDER_USER", string(entities.ConflictLastWriteWins)),
+			ConflictPolicyOrderDate: getEnvString("CONFLICT_POLICY_ORDER_DATE", string(entities.ConflictLastWriteWins)),
+			ConflictPolicyUserName:  getEnvString("CONFLICT_POLICY_USER_NAME", string(entities.ConflictLastWriteWins)),
+
+			BusinessTimezone: getEnvString("BUSINESS_TIMEZONE", "America/Sao_Paulo"),
+
+			ResumableUploadDir:     getEnvString("RESUMABLE_UPLOAD_DIR", filepath.Join(os.TempDir(), "resumable-uploads")),
+			ResumableUploadMaxSize: int64(getEnvInt("RESUMABLE_UPLOAD_MAX_SIZE", 16<<30)),
+			ResumableUploadExpiry:  getEnvDuration("RESUMABLE_UPLOAD_EXPIRY", 24*time.Hour),
+		}
+
+		Env = env
+	}
+
+	return nil
+}
+
+// PostgresDSN builds the Postgres connection string from the loaded variables. The sessions
+// use the business timezone, so the dates the database prints are the days of the business
+func PostgresDSN() string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable&timezone=%s",
+		Env.PostgresUser,
+		Env.PostgresPassword,
+		Env.PostgresHost,
+		Env.PostgresPort,
+		Env.PostgresDb,
+		url.QueryEscape(Env.BusinessTimezone),
+	)
+}
+
+// BusinessLocation returns the business timezone, in which the order dates without one are
+// read and the days of the date intervals begin and end, failing on an unknown timezone
+func BusinessLocation() (*time.Location, error) {
+	location, err := time.LoadLocation(Env.BusinessTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("business timezone %q: %w", Env.BusinessTimezone, err)
+	}
+
+	return location, nil
+}
+
+// ConflictPolicies returns the policy of each conflict type from the loaded variables,
+// failing on a value that is not a known policy
+func ConflictPolicies() (entities.ConflictPolicies, error) {
+	policies := entities.ConflictPolicies{
+		entities.ConflictOrderUser: entities.ConflictPolicy(Env.ConflictPolicyOrderUser),
+		entities.ConflictOrderDate: entities.ConflictPolicy(Env.ConflictPolicyOrderDate),
+		entities.ConflictUserName:  entities.ConflictPolicy(Env.ConflictPolicyUserName),
+	}
+
+	for conflictType, policy := range policies {
+		if !policy.IsValid() {
+			return nil, fmt.Errorf("%s policy %q: %w", conflictType, policy, domainerrors.ErrUnknownConflictPolicy)
+		}
+	}
+
+	return policies, nil
+}
+
+// getEnvString returns the variable value or the fallback when it is not set
+func getEnvString(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+// getEnvInt returns the variable value parsed as int or the fallback when it is not set or invalid
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
+
+// getEnvDuration returns the variable value parsed as a duration, like 5s or 1m,
+// or the fallback when it is not set or invalid
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+
+	return value
+}
@@ -1,12 +1,45 @@
-package config
-
-var Env *envVar
-
-type envVar struct {
-	PostgresUser     string
-	PostgresPassword string
-	PostgresDb       string
-	PostgresHost     string
-	PostgresPort     string
-	Port             string
-}
+package config
+
+import "time"
+
+var Env *envVar
+
+type envVar struct {
+	PostgresUser     string
+	PostgresPassword string
+	PostgresDb       string
+	PostgresHost     string
+	PostgresPort     string
+	Port             string
+
+	// Upload jobs
+	UploadWorkers     int
+	UploadQueueSize   int
+	UploadSpoolDir    string
+	UploadMaxBodySize int64
+
+	// Ingestion
+	IngestChunkSize        int
+	IngestBulkThreshold    int
+	IngestWorkers          int
+	IngestMaxLineSize      int
+	IngestDryRunMaxRecords int
+	LayoutsDir             string
+
+	// Conflict policies, by conflict type
+	ConflictPolicyOrderUser string
+	ConflictPolicyOrderDate string
+	ConflictPolicyUserName  string
+
+	// Inbox
+	InboxDir          string
+	InboxPollInterval time.Duration
+
+	// Dates
+	BusinessTimezone string
+
+	// Resumable uploads
+	ResumableUploadDir     string
+	ResumableUploadMaxSize int64
+	ResumableUploadExpiry  time.Duration
+}
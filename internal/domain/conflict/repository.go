@@ -0,0 +1,12 @@
+package conflict
+
+import "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+
+type Repository interface {
+	Save(conflict *entities.LineConflict) error
+	// GetUnresolved returns the conflicts not resolved yet from the oldest to the newest,
+	// only the ones of conflictType when it is not empty
+	GetUnresolved(conflictType entities.ConflictType, limit, offset int) ([]*entities.LineConflict, error)
+	// Resolve marks an unresolved conflict as resolved
+	Resolve(id uint) error
+}
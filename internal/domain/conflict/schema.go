@@ -0,0 +1,37 @@
+package conflict
+
+import (
+	"time"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+)
+
+type Response struct {
+	ID         uint      `json:"id"`
+	Type       string    `json:"type"`
+	Key        string    `json:"key"`
+	Existing   string    `json:"existing"`
+	Incoming   string    `json:"incoming"`
+	Policy     string    `json:"policy"`
+	Reason     string    `json:"reason"`
+	BatchID    string    `json:"batch_id,omitempty"`
+	FileName   string    `json:"file_name,omitempty"`
+	Line       int       `json:"line"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+func FromConflictToResponse(conflict *entities.LineConflict) *Response {
+	return &Response{
+		ID:         conflict.ID,
+		Type:       string(conflict.Type),
+		Key:        conflict.Value,
+		Existing:   conflict.Existing,
+		Incoming:   conflict.Incoming,
+		Policy:     string(conflict.Policy),
+		Reason:     conflict.Reason,
+		BatchID:    conflict.BatchID,
+		FileName:   conflict.FileName,
+		Line:       conflict.Line,
+		DetectedAt: conflict.DetectedAt,
+	}
+}
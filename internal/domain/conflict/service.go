@@ -0,0 +1,8 @@
+package conflict
+
+import "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+
+type Service interface {
+	GetUnresolvedConflicts(conflictType entities.ConflictType, limit, offset int) ([]*entities.LineConflict, error)
+	ResolveConflict(id uint) error
+}
@@ -0,0 +1,20 @@
+package entities
+
+import "time"
+
+// FileHeader is what the header record of a file tells about it. The fields its layout does
+// not describe are left empty
+type FileHeader struct {
+	Date          time.Time
+	Sender        string
+	LayoutVersion string
+}
+
+// FileTrailer is what the trailer record of a file tells about the records before it, so a
+// file cut short or changed on the way is not loaded as if it were whole
+type FileTrailer struct {
+	RecordCount int
+	// ValueSum is the sum of the product values of the records, nil when the layout does not
+	// describe it
+	ValueSum *Money
+}
@@ -0,0 +1,26 @@
+package entities
+
+import "time"
+
+// IngestionProgress is how far the load of a file of an upload got when it was published.
+// The lines written count the ones handed to the database, even when their transaction
+// did not commit yet
+type IngestionProgress struct {
+	BatchID       string
+	FileName      string
+	ReadLines     int
+	WrittenLines  int
+	RejectedLines int
+	SkippedLines  int
+	ReadBytes     int64
+	// TotalBytes is the size of the file, zero when it is not known
+	TotalBytes int64
+	// LinesPerSecond is the rate the lines were read at since the load started
+	LinesPerSecond float64
+	// ETA is the estimated time left to read the file, negative when it can not be estimated
+	ETA time.Duration
+	// Done is set on the last progress of the file
+	Done      bool
+	StartedAt time.Time
+	UpdatedAt time.Time
+}
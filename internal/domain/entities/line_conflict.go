@@ -0,0 +1,89 @@
+package entities
+
+import "time"
+
+// ConflictType tells which stored value a line disagrees with
+type ConflictType string
+
+const (
+	// ConflictOrderUser is an order that already belongs to another user
+	ConflictOrderUser ConflictType = "order_user"
+	// ConflictOrderDate is an order that already has another date
+	ConflictOrderDate ConflictType = "order_date"
+	// ConflictUserName is a user that already has another name
+	ConflictUserName ConflictType = "user_name"
+)
+
+// ConflictTypes lists every conflict type
+var ConflictTypes = []ConflictType{ConflictOrderUser, ConflictOrderDate, ConflictUserName}
+
+// ConflictPolicy tells how a conflicting line is stored
+type ConflictPolicy string
+
+const (
+	// ConflictReject rejects the whole line, nothing of it is stored
+	ConflictReject ConflictPolicy = "reject"
+	// ConflictKeepFirst stores the line but keeps the value stored first
+	ConflictKeepFirst ConflictPolicy = "keep-first"
+	// ConflictLastWriteWins stores the line replacing the stored value
+	ConflictLastWriteWins ConflictPolicy = "last-write-wins"
+)
+
+// ConflictPolicies holds the policy of each conflict type
+type ConflictPolicies map[ConflictType]ConflictPolicy
+
+// For returns the policy of the conflict type, last-write-wins when it has none
+func (p ConflictPolicies) For(conflictType ConflictType) ConflictPolicy {
+	if policy, ok := p[conflictType]; ok && policy != "" {
+		return policy
+	}
+
+	return ConflictLastWriteWins
+}
+
+// KeepsFirst tells whether the value stored first stays, which is the case of
+// every policy but last-write-wins
+func (p ConflictPolicies) KeepsFirst(conflictType ConflictType) bool {
+	return p.For(conflictType) != ConflictLastWriteWins
+}
+
+// IsValid tells whether the policy is one of the known ones
+func (p ConflictPolicy) IsValid() bool {
+	switch p {
+	case ConflictReject, ConflictKeepFirst, ConflictLastWriteWins:
+		return true
+	}
+
+	return false
+}
+
+// IsValid tells whether the type is one of the known ones
+func (t ConflictType) IsValid() bool {
+	for _, conflictType := range ConflictTypes {
+		if t == conflictType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LineConflict describes a line that changes data another line or an earlier load
+// already stored, like an order moving to a different user. Conflicts of loads that
+// write are kept until they are resolved, with the batch and file they came from
+type LineConflict struct {
+	ID         uint
+	BatchID    string
+	FileName   string
+	Entry      string
+	Line       int
+	Type       ConflictType
+	Field      string
+	Value      string
+	Existing   string
+	Incoming   string
+	Policy     ConflictPolicy
+	Reason     string
+	DetectedAt time.Time
+	ResolvedAt time.Time
+}
@@ -0,0 +1,11 @@
+package entities
+
+// LineRejection describes why a line of an ingested file was not stored. Entry is the
+// archive entry the line belongs to, when the file was an archive
+type LineRejection struct {
+	Entry  string
+	Line   int
+	Field  string
+	Value  string
+	Reason string
+}
@@ -0,0 +1,139 @@
+package entities
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxMoneyDigits is the most digits an amount of cents may have, so it always fits an int64
+const maxMoneyDigits = 18
+
+// Money is an amount of money in cents. Amounts are exact, so adding them up never drifts
+// the way floating point numbers do, and they are written with exactly two decimal places
+type Money int64
+
+// ParseMoney parses a decimal number, like 1836.74, 1836.7 or 1.83674e3, rounding it half away
+// from zero to cents, as the NUMERIC columns of the database do
+func ParseMoney(value string) (Money, error) {
+	text := value
+
+	negative := false
+	if text != "" && (text[0] == '+' || text[0] == '-') {
+		negative = text[0] == '-'
+		text = text[1:]
+	}
+
+	exponent := 0
+	if i := strings.IndexAny(text, "eE"); i >= 0 {
+		parsed, err := strconv.Atoi(text[i+1:])
+		if err != nil || parsed < -maxMoneyDigits*2 || parsed > maxMoneyDigits*2 {
+			return 0, fmt.Errorf("invalid amount of money %q", value)
+		}
+		exponent = parsed
+		text = text[:i]
+	}
+
+	integer, fraction, _ := strings.Cut(text, ".")
+	digits := integer + fraction
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid amount of money %q", value)
+	}
+
+	// The cents are the digits shifted by the exponent, minus the fraction, plus two places
+	shift := exponent - len(fraction) + 2
+
+	digits = strings.TrimLeft(digits, "0")
+	// rounding is the first digit cut off, the one telling whether to round up
+	rounding := byte('0')
+	switch keep := len(digits) + shift; {
+	case shift >= 0 && digits != "":
+		digits += strings.Repeat("0", shift)
+	case shift < 0 && keep >= 0:
+		rounding = digits[keep]
+		digits = digits[:keep]
+	case shift < 0:
+		digits = ""
+	}
+
+	if len(digits) > maxMoneyDigits {
+		return 0, fmt.Errorf("amount of money %q out of range", value)
+	}
+
+	cents := int64(0)
+	if digits != "" {
+		cents, _ = strconv.ParseInt(digits, 10, 64)
+	}
+	if rounding >= '5' {
+		cents++
+	}
+	if negative {
+		cents = -cents
+	}
+
+	return Money(cents), nil
+}
+
+// Cents returns the amount in cents
+func (m Money) Cents() int64 {
+	return int64(m)
+}
+
+// String writes the amount with exactly two decimal places, like 1836.74
+func (m Money) String() string {
+	sign := ""
+	cents := uint64(m)
+	if m < 0 {
+		sign = "-"
+		cents = -cents
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// MarshalJSON writes the amount as a number with exactly two decimal places
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON reads the amount from a number or a string holding one
+func (m *Money) UnmarshalJSON(data []byte) error {
+	parsed, err := ParseMoney(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
+
+// Value stores the amount as a decimal text, which NUMERIC columns take as is
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan reads the amount from a NUMERIC column, which the driver hands as text
+func (m *Money) Scan(src any) error {
+	var text string
+	switch value := src.(type) {
+	case []byte:
+		text = string(value)
+	case string:
+		text = value
+	case int64:
+		text = strconv.FormatInt(value, 10)
+	case float64:
+		text = strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Errorf("can not scan %T into an amount of money", src)
+	}
+
+	parsed, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,239 @@
+package entities_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+)
+
+// maxCents keeps the generated amounts inside NUMERIC(10, 2) and far from the int64 limits
+const maxCents = 1_000_000_000_000
+
+var twoDecimals = regexp.MustCompile(`^-?\d+\.\d{2}$`)
+
+// roundedCents is the oracle: mantissa / 10^scale in cents, rounded half away from zero
+func roundedCents(mantissa int64, scale int) int64 {
+	value := new(big.Rat).SetFrac(
+		new(big.Int).Mul(big.NewInt(mantissa), big.NewInt(100)),
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil),
+	)
+
+	abs := new(big.Rat).Abs(value)
+	quotient, remainder := new(big.Int).QuoRem(abs.Num(), abs.Denom(), new(big.Int))
+	if new(big.Int).Mul(remainder, big.NewInt(2)).Cmp(abs.Denom()) >= 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+	if value.Sign() < 0 {
+		quotient.Neg(quotient)
+	}
+
+	return quotient.Int64()
+}
+
+// decimalText writes mantissa / 10^scale as a plain decimal number, like -12.345
+func decimalText(mantissa int64, scale int) string {
+	sign := ""
+	if mantissa < 0 {
+		sign = "-"
+		mantissa = -mantissa
+	}
+
+	digits := fmt.Sprintf("%0*d", scale+1, mantissa)
+	if scale == 0 {
+		return sign + digits
+	}
+
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+func Test_ParseMoney_RoundTrip(t *testing.T) {
+	property := func(cents int64) bool {
+		money := entities.Money(cents % maxCents)
+
+		parsed, err := entities.ParseMoney(money.String())
+		return err == nil && parsed == money
+	}
+
+	assert.NoError(t, quick.Check(property, nil))
+}
+
+func Test_ParseMoney_RoundsHalfAwayFromZero(t *testing.T) {
+	property := func(mantissa int64, scale uint8) bool {
+		mantissa %= maxCents * 1000
+		digits := int(scale % 8)
+
+		parsed, err := entities.ParseMoney(decimalText(mantissa, digits))
+		return err == nil && parsed.Cents() == roundedCents(mantissa, digits)
+	}
+
+	assert.NoError(t, quick.Check(property, nil))
+}
+
+func Test_ParseMoney_ExponentMatchesDecimal(t *testing.T) {
+	property := func(mantissa int64, scale uint8) bool {
+		mantissa %= maxCents * 1000
+		digits := int(scale % 8)
+
+		parsed, err := entities.ParseMoney(fmt.Sprintf("%de-%d", mantissa, digits))
+		return err == nil && parsed.Cents() == roundedCents(mantissa, digits)
+	}
+
+	assert.NoError(t, quick.Check(property, nil))
+}
+
+func Test_ParseMoney_RoundsOnFirstCutOffDigit(t *testing.T) {
+	property := func(cents int64, cutOff uint8, tail uint16) bool {
+		money := entities.Money(cents % maxCents)
+		digit := int(cutOff % 10)
+
+		// Whatever follows the first digit cut off does not change the rounding
+		text := fmt.Sprintf("%s%d%d", money.String(), digit, tail)
+		parsed, err := entities.ParseMoney(text)
+		if err != nil {
+			return false
+		}
+
+		expected := money
+		switch {
+		case digit >= 5 && money < 0:
+			expected--
+		case digit >= 5:
+			expected++
+		}
+
+		return parsed == expected
+	}
+
+	assert.NoError(t, quick.Check(property, nil))
+}
+
+func Test_MarshalJSON_Money_TwoDecimals(t *testing.T) {
+	property := func(cents int64) bool {
+		data, err := json.Marshal(entities.Money(cents))
+		return err == nil && twoDecimals.Match(data)
+	}
+
+	assert.NoError(t, quick.Check(property, nil))
+}
+
+func Test_Sum_Money_Exact(t *testing.T) {
+	property := func(amounts []int64) bool {
+		expected := int64(0)
+		total := entities.Money(0)
+		for _, amount := range amounts {
+			amount %= maxCents
+			expected += amount
+
+			parsed, err := entities.ParseMoney(entities.Money(amount).String())
+			if err != nil {
+				return false
+			}
+			total += parsed
+		}
+
+		return total.Cents() == expected
+	}
+
+	assert.NoError(t, quick.Check(property, nil))
+
+	// Ten products of 0.10 add up to 1.00, where float64 gives 0.9999999999999999
+	total := entities.Money(0)
+	for range 10 {
+		value, _ := entities.ParseMoney("0.1")
+		total += value
+	}
+	assert.Equal(t, "1.00", total.String())
+}
+
+func Test_ParseMoney(t *testing.T) {
+	tests := []struct {
+		description   string
+		value         string
+		expected      entities.Money
+		isErrExpected bool
+	}{
+		{description: "should parse two decimal places", value: "1836.74", expected: 183674},
+		{description: "should parse one decimal place", value: "1836.7", expected: 183670},
+		{description: "should parse no decimal places", value: "1836", expected: 183600},
+		{description: "should parse a leading dot", value: ".5", expected: 50},
+		{description: "should parse a trailing dot", value: "5.", expected: 500},
+		{description: "should parse a plus sign", value: "+9.99", expected: 999},
+		{description: "should parse a minus sign", value: "-9.99", expected: -999},
+		{description: "should parse an exponent", value: "1.83674e3", expected: 183674},
+		{description: "should parse a negative exponent", value: "183674E-2", expected: 183674},
+		{description: "should round half up", value: "0.005", expected: 1},
+		{description: "should round below half down", value: "0.0049999", expected: 0},
+		{description: "should round half away from zero", value: "-0.005", expected: -1},
+		{description: "should parse zero", value: "-0.00", expected: 0},
+		{description: "should return error on an empty value", value: "", isErrExpected: true},
+		{description: "should return error on a sign alone", value: "-", isErrExpected: true},
+		{description: "should return error on a dot alone", value: ".", isErrExpected: true},
+		{description: "should return error on letters", value: "12a.00", isErrExpected: true},
+		{description: "should return error on two dots", value: "1.2.3", isErrExpected: true},
+		{description: "should return error on a comma", value: "1,50", isErrExpected: true},
+		{description: "should return error on an empty exponent", value: "1e", isErrExpected: true},
+		{description: "should return error on a huge exponent", value: "1e400", isErrExpected: true},
+		{description: "should return error out of range", value: "100000000000000000000", isErrExpected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			outcome, err := entities.ParseMoney(tt.value)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, outcome)
+			}
+		})
+	}
+}
+
+func Test_Scan_Money(t *testing.T) {
+	tests := []struct {
+		description   string
+		src           any
+		expected      entities.Money
+		isErrExpected bool
+	}{
+		{description: "should scan the text of a NUMERIC column", src: []byte("1836.74"), expected: 183674},
+		{description: "should scan a string", src: "9.99", expected: 999},
+		{description: "should scan an integer", src: int64(20), expected: 2000},
+		{description: "should scan a float", src: 9.99, expected: 999},
+		{description: "should return error on a NULL", src: nil, isErrExpected: true},
+		{description: "should return error on an invalid text", src: []byte("abc"), isErrExpected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			var outcome entities.Money
+			err := outcome.Scan(tt.src)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, outcome)
+			}
+		})
+	}
+}
+
+func Test_UnmarshalJSON_Money(t *testing.T) {
+	var outcome struct {
+		Number entities.Money `json:"number"`
+		Text   entities.Money `json:"text"`
+	}
+
+	err := json.Unmarshal([]byte(`{"number": 1836.74, "text": "9.9"}`), &outcome)
+	assert.NoError(t, err)
+	assert.Equal(t, entities.Money(183674), outcome.Number)
+	assert.Equal(t, entities.Money(990), outcome.Text)
+}
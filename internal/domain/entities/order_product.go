@@ -4,5 +4,8 @@ type OrderProduct struct {
 	ID        uint
 	OrderID   uint
 	ProductID uint
-	Value     float64
+	Value     Money
+	// Position tells apart equal lines of the same order, it is the occurrence
+	// number of the line among the lines with the same order, product and value
+	Position int
 }
@@ -0,0 +1,11 @@
+package entities
+
+// OrderTotal is the sum of the values of the lines of an order a delta or snapshot file
+// changed, before and after the file was loaded. Removed tells the file left the order without
+// lines, so it was deleted
+type OrderTotal struct {
+	OrderID  uint
+	Previous Money
+	Current  Money
+	Removed  bool
+}
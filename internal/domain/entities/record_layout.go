@@ -0,0 +1,312 @@
+package entities
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"unicode/utf8"
+)
+
+type FieldType string
+
+const (
+	FieldInteger FieldType = "integer"
+	FieldString  FieldType = "string"
+	FieldDecimal FieldType = "decimal"
+	FieldDate    FieldType = "date"
+)
+
+type FieldAlign string
+
+const (
+	AlignLeft  FieldAlign = "left"
+	AlignRight FieldAlign = "right"
+)
+
+// Names of the fields a record layout must describe
+const (
+	FieldUserID       = "user_id"
+	FieldUserName     = "user_name"
+	FieldOrderID      = "order_id"
+	FieldProductID    = "product_id"
+	FieldProductValue = "product_value"
+	FieldOrderDate    = "order_date"
+)
+
+// recordFieldTypes is the type each record field must be declared with
+var recordFieldTypes = map[string]FieldType{
+	FieldUserID:       FieldInteger,
+	FieldUserName:     FieldString,
+	FieldOrderID:      FieldInteger,
+	FieldProductID:    FieldInteger,
+	FieldProductValue: FieldDecimal,
+	FieldOrderDate:    FieldDate,
+}
+
+// FieldOperation is the field with the operation code of the records of delta files, which
+// layouts may describe besides the record fields
+const FieldOperation = "operation"
+
+// Names of the fields header and trailer records may describe
+const (
+	FieldFileDate      = "file_date"
+	FieldSender        = "sender"
+	FieldLayoutVersion = "layout_version"
+	FieldRecordCount   = "record_count"
+	FieldValueSum      = "value_sum"
+)
+
+// headerFieldTypes and trailerFieldTypes are the types the fields of header and trailer
+// records must be declared with
+var (
+	headerFieldTypes = map[string]FieldType{
+		FieldFileDate:      FieldDate,
+		FieldSender:        FieldString,
+		FieldLayoutVersion: FieldString,
+	}
+	trailerFieldTypes = map[string]FieldType{
+		FieldRecordCount: FieldInteger,
+		FieldValueSum:    FieldDecimal,
+	}
+)
+
+// LayoutField describes where a field sits in a fixed-width line and how to read it. Offsets
+// and widths count characters, so a name with accents does not shift the fields after it
+type LayoutField struct {
+	Name   string    `json:"name"`
+	Offset int       `json:"offset"`
+	Width  int       `json:"width"`
+	Type   FieldType `json:"type"`
+	// Align is the side the value is aligned to, the padding is trimmed from the other side
+	Align FieldAlign `json:"align,omitempty"`
+	// Padding is the character filling the field, a space when empty
+	Padding string `json:"padding,omitempty"`
+	// Format is the date format of date fields, written with YYYY, YY, MM and DD
+	Format string `json:"format,omitempty"`
+	// Decimals is the amount of implied decimal places of decimal fields without a decimal point
+	Decimals int `json:"decimals,omitempty"`
+}
+
+// ControlRecord describes a header or trailer line, told apart from the data lines by the
+// identifier it starts with. Its fields must come after the identifier
+type ControlRecord struct {
+	Identifier string         `json:"identifier"`
+	Fields     []*LayoutField `json:"fields"`
+}
+
+// RecordLayout describes the fields of a fixed-width users data file line. Files of layouts
+// with a header must start with it, and files of layouts with a trailer must end with it
+type RecordLayout struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Fields      []*LayoutField `json:"fields"`
+	Header      *ControlRecord `json:"header,omitempty"`
+	Trailer     *ControlRecord `json:"trailer,omitempty"`
+}
+
+// LineLength is the minimum length of a line, up to the end of the last field
+func (l *RecordLayout) LineLength() int {
+	length := 0
+	for _, field := range l.Fields {
+		length = max(length, field.Offset+field.Width)
+	}
+
+	return length
+}
+
+// Field returns the field with the name, nil when the layout does not describe it
+func (l *RecordLayout) Field(name string) *LayoutField {
+	for _, field := range l.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+
+	return nil
+}
+
+// LineLength is the minimum length of a header or trailer line, up to the end of its last field
+func (r *ControlRecord) LineLength() int {
+	length := utf8.RuneCountInString(r.Identifier)
+	for _, field := range r.Fields {
+		length = max(length, field.Offset+field.Width)
+	}
+
+	return length
+}
+
+// Field returns the field with the name, nil when the record does not describe it
+func (r *ControlRecord) Field(name string) *LayoutField {
+	for _, field := range r.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+
+	return nil
+}
+
+// Validate checks that every record field is described once, with its type, and that the fields
+// do not overlap. The operation field is optional, as are the header and trailer fields, but a
+// trailer must count the records
+func (l *RecordLayout) Validate() error {
+	if strings.TrimSpace(l.Name) == "" {
+		return fmt.Errorf("layout must have a name")
+	}
+
+	fieldTypes := maps.Clone(recordFieldTypes)
+	fieldTypes[FieldOperation] = FieldString
+
+	seen, err := validateFields(l.Name, "field", l.Fields, fieldTypes, 0)
+	if err != nil {
+		return err
+	}
+
+	for name := range recordFieldTypes {
+		if !seen[name] {
+			return fmt.Errorf("layout %s: field %s is missing", l.Name, name)
+		}
+	}
+
+	if l.Header != nil {
+		if err := l.Header.validate(l.Name, "header", headerFieldTypes); err != nil {
+			return err
+		}
+	}
+
+	if l.Trailer != nil {
+		if err := l.Trailer.validate(l.Name, "trailer", trailerFieldTypes); err != nil {
+			return err
+		}
+		if l.Trailer.Field(FieldRecordCount) == nil {
+			return fmt.Errorf("layout %s: trailer field %s is missing", l.Name, FieldRecordCount)
+		}
+	}
+
+	if l.Header != nil && l.Trailer != nil && l.Header.Identifier == l.Trailer.Identifier {
+		return fmt.Errorf("layout %s: header and trailer must have different identifiers", l.Name)
+	}
+
+	return nil
+}
+
+// validate checks the identifier and the fields of a header or trailer record
+func (r *ControlRecord) validate(layoutName, kind string, fieldTypes map[string]FieldType) error {
+	if strings.TrimSpace(r.Identifier) == "" {
+		return fmt.Errorf("layout %s: %s must have an identifier", layoutName, kind)
+	}
+
+	_, err := validateFields(layoutName, kind+" field", r.Fields, fieldTypes, utf8.RuneCountInString(r.Identifier))
+	return err
+}
+
+// validateFields checks that the fields are known, described once, with their type, and do not
+// overlap each other nor the first reserved characters of the line. It returns the names seen
+func validateFields(
+	layoutName, kind string,
+	fields []*LayoutField,
+	fieldTypes map[string]FieldType,
+	reserved int,
+) (map[string]bool, error) {
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		expectedType, ok := fieldTypes[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("layout %s: unknown %s %q", layoutName, kind, field.Name)
+		}
+		if seen[field.Name] {
+			return nil, fmt.Errorf("layout %s: %s %s is described more than once", layoutName, kind, field.Name)
+		}
+		seen[field.Name] = true
+
+		if field.Type != expectedType {
+			return nil, fmt.Errorf("layout %s: %s %s must be of type %s", layoutName, kind, field.Name, expectedType)
+		}
+		if field.Offset < 0 || field.Width <= 0 {
+			return nil, fmt.Errorf("layout %s: %s %s must have a non-negative offset and a positive width", layoutName, kind, field.Name)
+		}
+		if field.Offset < reserved {
+			return nil, fmt.Errorf("layout %s: %s %s overlaps the identifier", layoutName, kind, field.Name)
+		}
+		if field.Align != "" && field.Align != AlignLeft && field.Align != AlignRight {
+			return nil, fmt.Errorf("layout %s: %s %s must be aligned to the left or to the right", layoutName, kind, field.Name)
+		}
+		if utf8.RuneCountInString(field.Padding) > 1 {
+			return nil, fmt.Errorf("layout %s: %s %s padding must be a single character", layoutName, kind, field.Name)
+		}
+		if field.Type == FieldDate && field.Format == "" {
+			return nil, fmt.Errorf("layout %s: %s %s must have a date format", layoutName, kind, field.Name)
+		}
+		if field.Decimals < 0 {
+			return nil, fmt.Errorf("layout %s: %s %s must not have negative decimals", layoutName, kind, field.Name)
+		}
+
+		for _, other := range fields {
+			if other != field && field.Offset < other.Offset+other.Width && other.Offset < field.Offset+field.Width {
+				return nil, fmt.Errorf("layout %s: %ss %s and %s overlap", layoutName, kind, field.Name, other.Name)
+			}
+		}
+	}
+
+	return seen, nil
+}
+
+// dateFormatReplacer translates the layout date formats into Go time layouts
+var dateFormatReplacer = strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02")
+
+// TimeLayout returns the Go time layout of a date field format
+func (f *LayoutField) TimeLayout() string {
+	return dateFormatReplacer.Replace(f.Format)
+}
+
+// Extract cuts the field out of a line, which must be at least LineLength characters long,
+// and trims its padding
+func (f *LayoutField) Extract(line string) string {
+	start, end := charOffsets(line, f.Offset, f.Offset+f.Width)
+	value := line[start:end]
+
+	padding := f.Padding
+	if padding == "" {
+		padding = " "
+	}
+
+	if f.Align == AlignLeft {
+		value = strings.TrimRight(value, padding)
+	} else {
+		value = strings.TrimLeft(value, padding)
+	}
+
+	return strings.TrimSpace(value)
+}
+
+// charOffsets returns the byte offsets where the characters from and to of the line start.
+// They are the same as the character offsets when the line is plain ASCII
+func charOffsets(line string, from, to int) (int, int) {
+	start, end := from, to
+	if isASCII(line[:min(to, len(line))]) {
+		return start, end
+	}
+
+	char := 0
+	for offset := range line {
+		if char == from {
+			start = offset
+		}
+		if char == to {
+			return start, offset
+		}
+		char++
+	}
+
+	return start, len(line)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
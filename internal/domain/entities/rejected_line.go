@@ -0,0 +1,15 @@
+package entities
+
+import "time"
+
+// RejectedLine is a rejected line of an uploaded file kept in quarantine as it was read, so it
+// can be downloaded, fixed and sent again, or reprocessed once the data it depends on is fixed.
+// Content is the line converted to UTF-8, empty when the line could not be read at all
+type RejectedLine struct {
+	BatchID    string
+	FileName   string
+	Line       int
+	Content    string
+	Rejections []*LineRejection
+	RejectedAt time.Time
+}
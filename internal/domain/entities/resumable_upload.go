@@ -0,0 +1,27 @@
+package entities
+
+import "time"
+
+// ResumableUpload is a file sent in chunks, appended one after the other until Offset reaches
+// Length, when it is handed to the upload queue as a single file. The options tell how it is
+// loaded then, as the ones of an upload job
+type ResumableUpload struct {
+	ID       string
+	FileName string
+	// Length is the size of the whole file and Offset how many of its bytes were received
+	Length     int64
+	Offset     int64
+	UploadedBy string
+	Forced     bool
+	Layout     string
+	Format     string
+	Encoding   string
+	Mode       string
+	// SnapshotStart and SnapshotEnd are the range of order dates of a snapshot file
+	SnapshotStart string
+	SnapshotEnd   string
+	DryRun        bool
+	CreatedAt     time.Time
+	// UpdatedAt is when the last chunk was received
+	UpdatedAt time.Time
+}
@@ -0,0 +1,71 @@
+package entities
+
+import "time"
+
+type UploadJobState string
+
+const (
+	UploadJobQueued  UploadJobState = "queued"
+	UploadJobRunning UploadJobState = "running"
+	UploadJobDone    UploadJobState = "done"
+	UploadJobFailed  UploadJobState = "failed"
+	// UploadJobRolledBack is a job whose writes were undone after it finished
+	UploadJobRolledBack UploadJobState = "rolled_back"
+)
+
+// UploadEntry is the outcome of a file uploaded alone or inside an archive
+type UploadEntry struct {
+	Name           string
+	Format         string
+	ProcessedLines int
+	AcceptedLines  int
+	RejectedLines  int
+	SkippedLines   int
+	Rejections     []*LineRejection
+	Conflicts      []*LineConflict
+	Writes         WriteSummary
+	// Header is what the header record of the file told, when its layout has one
+	Header *FileHeader
+	// OrderTotals are the totals of the orders a delta file changed
+	OrderTotals []*OrderTotal
+	Error       string
+}
+
+// UploadJob tracks the background processing of an uploaded users data file and is kept
+// as the ingestion batch of the file, the history of what was imported. The line counts,
+// rejections, conflicts and writes add up the ones of every entry. On a dry run nothing
+// is written and the writes tell what the load would do
+type UploadJob struct {
+	ID       string
+	FileName string
+	// SHA256 is the hex encoded fingerprint of the uploaded file, as received
+	SHA256     string
+	Size       int64
+	UploadedBy string
+	// Forced tells the file was loaded even though the same file was already imported
+	Forced   bool
+	Layout   string
+	Format   string
+	Encoding string
+	// Mode is how the records were applied, full, delta or snapshot, the last with the
+	// range of order dates the files held
+	Mode           string
+	SnapshotStart  string
+	SnapshotEnd    string
+	Compression    string
+	DryRun         bool
+	State          UploadJobState
+	ProcessedLines int
+	AcceptedLines  int
+	RejectedLines  int
+	SkippedLines   int
+	Rejections     []*LineRejection
+	Conflicts      []*LineConflict
+	Writes         WriteSummary
+	Entries        []*UploadEntry
+	Error          string
+	CreatedAt      time.Time
+	StartedAt      time.Time
+	FinishedAt     time.Time
+	RolledBackAt   time.Time
+}
@@ -0,0 +1,67 @@
+package entities
+
+// UpsertOutcome tells what an upsert did to the stored row
+type UpsertOutcome int
+
+const (
+	UpsertUnchanged UpsertOutcome = iota
+	UpsertInserted
+	UpsertUpdated
+)
+
+// WriteCounts counts the upsert outcomes of a table and the rows deleted from it, which only
+// delta files delete
+type WriteCounts struct {
+	Inserted  int
+	Updated   int
+	Unchanged int
+	Deleted   int
+}
+
+func (c *WriteCounts) Count(outcome UpsertOutcome) {
+	switch outcome {
+	case UpsertInserted:
+		c.Inserted++
+	case UpsertUpdated:
+		c.Updated++
+	default:
+		c.Unchanged++
+	}
+}
+
+func (c *WriteCounts) Merge(other WriteCounts) {
+	c.Inserted += other.Inserted
+	c.Updated += other.Updated
+	c.Unchanged += other.Unchanged
+	c.Deleted += other.Deleted
+}
+
+// WriteSummary counts the upsert outcomes of every table written by an ingestion
+type WriteSummary struct {
+	Users         WriteCounts
+	Products      WriteCounts
+	Orders        WriteCounts
+	OrderProducts WriteCounts
+}
+
+func (s *WriteSummary) Merge(other WriteSummary) {
+	s.Users.Merge(other.Users)
+	s.Products.Merge(other.Products)
+	s.Orders.Merge(other.Orders)
+	s.OrderProducts.Merge(other.OrderProducts)
+}
+
+// RollbackCounts counts the rows of a table a rollback removed, because the rolled back
+// batches inserted them, and restored to the values the batches overwrote
+type RollbackCounts struct {
+	Removed  int
+	Restored int
+}
+
+// RollbackSummary counts what a rollback did to every table written by an ingestion
+type RollbackSummary struct {
+	Users         RollbackCounts
+	Products      RollbackCounts
+	Orders        RollbackCounts
+	OrderProducts RollbackCounts
+}
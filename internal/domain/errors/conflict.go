@@ -0,0 +1,9 @@
+package errors
+
+import "errors"
+
+var (
+	ErrConflictNotFound      error = errors.New("conflict does not exist or is already resolved")
+	ErrUnknownConflictType   error = errors.New("conflict type is not supported, expected order_user, order_date or user_name")
+	ErrUnknownConflictPolicy error = errors.New("conflict policy is not supported, expected reject, keep-first or last-write-wins")
+)
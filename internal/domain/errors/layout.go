@@ -0,0 +1,7 @@
+package errors
+
+import "errors"
+
+var (
+	ErrLayoutNotFound error = errors.New("layout does not exist")
+)
@@ -7,4 +7,6 @@ var (
 	ErrOrderNotFound       error = errors.New("order does not exist")
 	ErrInvalidDateInterval error = errors.New("end date can not be smaller than start date")
 	ErrNoOrders            error = errors.New("no orders were found")
+	ErrInvalidStartDate    error = errors.New("invalid startDate format, expected 2006-01-02 or a RFC 3339 datetime")
+	ErrInvalidEndDate      error = errors.New("invalid endDate format, expected 2006-01-02 or a RFC 3339 datetime")
 )
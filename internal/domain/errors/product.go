@@ -0,0 +1,7 @@
+package errors
+
+import "errors"
+
+var (
+	ErrProductNotFound error = errors.New("product does not exist")
+)
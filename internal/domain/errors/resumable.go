@@ -0,0 +1,12 @@
+package errors
+
+import "errors"
+
+var (
+	ErrResumableUploadNotFound   error = errors.New("resumable upload does not exist")
+	ErrResumableUploadBusy       error = errors.New("resumable upload is already being written, wait for the other request to finish")
+	ErrResumableOffsetMismatch   error = errors.New("chunk offset is not the offset of the resumable upload, resume from the offset it reports")
+	ErrResumableChunkTooLong     error = errors.New("chunk goes past the length of the resumable upload")
+	ErrResumableUploadIncomplete error = errors.New("resumable upload did not receive all of its bytes")
+	ErrResumableMissingFileName  error = errors.New("resumable upload has no fileName")
+)
@@ -0,0 +1,7 @@
+package errors
+
+import "errors"
+
+var (
+	ErrTransactionDeadlock error = errors.New("transaction was aborted to break a deadlock")
+)
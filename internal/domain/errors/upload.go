@@ -0,0 +1,23 @@
+package errors
+
+import "errors"
+
+var (
+	ErrUploadJobNotFound     error = errors.New("upload job does not exist")
+	ErrUploadQueueFull       error = errors.New("upload queue is full, try again later")
+	ErrUploadQueueClosed     error = errors.New("upload queue is closed")
+	ErrUploadArchiveEmpty    error = errors.New("archive has no files")
+	ErrUploadEntryTooLarge   error = errors.New("file is too large once decompressed")
+	ErrUploadAlreadyImported error = errors.New("file was already imported or is being imported, send force=true to load it again")
+	ErrUploadMissingFile     error = errors.New("form has no users_data file")
+	ErrUploadTooLarge        error = errors.New("upload is too large")
+	ErrUploadJobNotFinished  error = errors.New("upload job is still queued or running")
+	ErrUploadDryRunRollback  error = errors.New("upload job is a dry run, it wrote nothing to roll back")
+	ErrUploadRolledBack      error = errors.New("upload job was already rolled back")
+	ErrUploadHasDependents   error = errors.New("later uploads changed or reference rows of this upload, send cascade=true to roll them back as well")
+	ErrUploadUntrackedLoad   error = errors.New("rows of this upload were changed by a load that was not kept as an upload, like the ones made before uploads were kept, and can not be rolled back")
+	ErrUploadDryRunRejected  error = errors.New("upload job is a dry run, its rejected lines are not kept")
+	ErrUploadReprocessing    error = errors.New("rejected lines of this upload are already being reprocessed")
+	ErrUploadSnapshotFiles   error = errors.New("snapshot uploads must hold a single file, as each file would remove the orders of the others")
+	ErrUploadInterrupted     error = errors.New("upload job was interrupted by a shutdown before it finished, send the file again")
+)
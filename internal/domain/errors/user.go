@@ -3,6 +3,16 @@ package errors
 import "errors"
 
 var (
-	ErrEmptyOrders  error = errors.New("there are no orders to be add to user")
-	ErrUserNotFound error = errors.New("user does not exist")
+	ErrEmptyOrders     error = errors.New("there are no orders to be add to user")
+	ErrUserNotFound    error = errors.New("user does not exist")
+	ErrUnknownFormat   error = errors.New("file format must be auto, fixed-width, csv, ndjson or json")
+	ErrUnknownEncoding error = errors.New("file encoding must be utf-8, iso-8859-1 or windows-1252")
+	ErrMissingHeader   error = errors.New("file does not start with the header record of its layout")
+	ErrMissingTrailer  error = errors.New("file does not end with the trailer record of its layout, it may have been cut short")
+	ErrTrailerMismatch error = errors.New("trailer record does not match the records read, the file may have been cut short or changed")
+	ErrUnknownMode     error = errors.New("load mode must be full, delta or snapshot")
+	ErrNoOperation     error = errors.New("layout has no operation field, which the records of delta files must carry")
+	ErrNoSnapshotRange error = errors.New("snapshot files must be sent with the start and end dates of the orders they hold")
+	ErrSnapshotPartial error = errors.New("snapshot file has lines that could not be read, so the orders missing from it were not removed and nothing was loaded")
+	ErrDryRunTooLarge  error = errors.New("file has too many users, products, orders and order lines to be simulated, split it in smaller files")
 )
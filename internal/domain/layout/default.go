@@ -0,0 +1,22 @@
+package layout
+
+import "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+
+// DefaultName is the layout used when an upload does not choose one
+const DefaultName = "v1"
+
+// Default is the original partner layout, with 95 characters per line
+func Default() *entities.RecordLayout {
+	return &entities.RecordLayout{
+		Name:        DefaultName,
+		Description: "original partner layout",
+		Fields: []*entities.LayoutField{
+			{Name: entities.FieldUserID, Offset: 0, Width: 10, Type: entities.FieldInteger, Align: entities.AlignRight},
+			{Name: entities.FieldUserName, Offset: 10, Width: 45, Type: entities.FieldString, Align: entities.AlignRight},
+			{Name: entities.FieldOrderID, Offset: 55, Width: 10, Type: entities.FieldInteger, Align: entities.AlignRight},
+			{Name: entities.FieldProductID, Offset: 65, Width: 10, Type: entities.FieldInteger, Align: entities.AlignRight},
+			{Name: entities.FieldProductValue, Offset: 75, Width: 12, Type: entities.FieldDecimal, Align: entities.AlignRight},
+			{Name: entities.FieldOrderDate, Offset: 87, Width: 8, Type: entities.FieldDate, Format: "YYYYMMDD"},
+		},
+	}
+}
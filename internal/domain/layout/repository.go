@@ -0,0 +1,8 @@
+package layout
+
+import "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+
+type Repository interface {
+	Get(name string) (*entities.RecordLayout, error)
+	GetAll() ([]*entities.RecordLayout, error)
+}
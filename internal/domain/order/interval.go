@@ -0,0 +1,59 @@
+package order
+
+import (
+	"strings"
+	"time"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+)
+
+// Interval is a range of order dates, from Start, included, until End, excluded
+type Interval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// ParseInterval parses the dates of an interval, each either a day, like 2021-03-08, in the
+// business location or a RFC 3339 datetime. A start day begins at its midnight and an end day
+// is included as a whole, up to the next midnight, while an end datetime is included itself.
+// Without an end the interval goes until the end of the current day
+func ParseInterval(startDate, endDate string, now time.Time, location *time.Location) (*Interval, error) {
+	start, _, ok := parseIntervalDate(startDate, location)
+	if !ok {
+		return nil, errors.ErrInvalidStartDate
+	}
+
+	if endDate == "" {
+		endDate = now.In(location).Format(time.DateOnly)
+	}
+
+	end, isDay, ok := parseIntervalDate(endDate, location)
+	if !ok {
+		return nil, errors.ErrInvalidEndDate
+	}
+
+	if isDay {
+		end = end.AddDate(0, 0, 1)
+	} else {
+		// Postgres keeps microseconds, so the next one is the first instant left out
+		end = end.Truncate(time.Microsecond).Add(time.Microsecond)
+	}
+
+	return &Interval{Start: start, End: end}, nil
+}
+
+// parseIntervalDate parses a day as its midnight in the location or a RFC 3339 datetime,
+// telling whether it was a day
+func parseIntervalDate(value string, location *time.Location) (time.Time, bool, bool) {
+	if date, err := time.ParseInLocation(time.DateOnly, value, location); err == nil {
+		return date, true, true
+	}
+
+	// The plus sign of an offset left unescaped in a query string arrives as a space
+	date, err := time.Parse(time.RFC3339Nano, strings.Replace(value, " ", "+", 1))
+	if err != nil {
+		return time.Time{}, false, false
+	}
+
+	return date, false, true
+}
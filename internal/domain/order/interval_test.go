@@ -0,0 +1,101 @@
+package order_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/order"
+)
+
+func Test_ParseInterval(t *testing.T) {
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		panic(err)
+	}
+
+	// Already the 9th in UTC, still the 8th in Sao Paulo
+	now := time.Date(2021, 3, 9, 1, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		description      string
+		startDate        string
+		endDate          string
+		expectedInterval *order.Interval
+		expectedErr      error
+	}{
+		{
+			description: "should include the whole end day in the business timezone",
+			startDate:   "2021-03-01",
+			endDate:     "2021-03-08",
+			expectedInterval: &order.Interval{
+				Start: time.Date(2021, 3, 1, 3, 0, 0, 0, time.UTC),
+				End:   time.Date(2021, 3, 9, 3, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			description: "should include a single day",
+			startDate:   "2021-03-08",
+			endDate:     "2021-03-08",
+			expectedInterval: &order.Interval{
+				Start: time.Date(2021, 3, 8, 3, 0, 0, 0, time.UTC),
+				End:   time.Date(2021, 3, 9, 3, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			description: "should go until the end of the current day in the business timezone",
+			startDate:   "2021-03-01",
+			expectedInterval: &order.Interval{
+				Start: time.Date(2021, 3, 1, 3, 0, 0, 0, time.UTC),
+				End:   time.Date(2021, 3, 9, 3, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			description: "should take datetimes as they are, including the end one",
+			startDate:   "2021-03-08T10:00:00-03:00",
+			endDate:     "2021-03-08T18:30:00Z",
+			expectedInterval: &order.Interval{
+				Start: time.Date(2021, 3, 8, 13, 0, 0, 0, time.UTC),
+				End:   time.Date(2021, 3, 8, 18, 30, 0, 1000, time.UTC),
+			},
+		},
+		{
+			description: "should read an offset whose plus sign arrived as a space",
+			startDate:   "2021-03-08T10:00:00 01:00",
+			endDate:     "2021-03-08",
+			expectedInterval: &order.Interval{
+				Start: time.Date(2021, 3, 8, 9, 0, 0, 0, time.UTC),
+				End:   time.Date(2021, 3, 9, 3, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			description: "should return error on an invalid start date",
+			startDate:   "08/03/2021",
+			endDate:     "2021-03-08",
+			expectedErr: errors.ErrInvalidStartDate,
+		},
+		{
+			description: "should return error on an invalid end date",
+			startDate:   "2021-03-01",
+			endDate:     "2021-03-08 10:00",
+			expectedErr: errors.ErrInvalidEndDate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			interval, err := order.ParseInterval(tt.startDate, tt.endDate, now, saoPaulo)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Nil(t, interval)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, tt.expectedInterval.Start.Equal(interval.Start), "start %s", interval.Start)
+			assert.True(t, tt.expectedInterval.End.Equal(interval.End), "end %s", interval.End)
+		})
+	}
+}
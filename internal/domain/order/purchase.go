@@ -9,5 +9,5 @@ type Purchase struct {
 	Name     string
 	Order    *entities.Order
 	Products []*entities.OrderProduct
-	Total    float64
+	Total    entities.Money
 }
@@ -9,6 +9,8 @@ import (
 type Repository interface {
 	Get(id uint) (*entities.Order, error)
 	GetByInterval(startDate, endDate time.Time) ([]*entities.Order, error)
-	Add(order *entities.Order) error
+	Upsert(order *entities.Order) (entities.UpsertOutcome, error)
 	GetAll() ([]*entities.Order, error)
+	// DeleteIfEmpty removes the order when it has no lines left, telling whether it did
+	DeleteIfEmpty(id uint) (bool, error)
 }
@@ -20,14 +20,14 @@ type PurchaseResponse struct {
 
 type OrderResponse struct {
 	OrderID  uint               `json:"order_id"`
-	Total    float64            `json:"total"`
+	Total    entities.Money     `json:"total"`
 	Date     time.Time          `json:"date"`
 	Products []*ProductResponse `json:"products"`
 }
 
 type ProductResponse struct {
-	ProductID uint    `json:"product_id"`
-	Value     float64 `json:"value"`
+	ProductID uint           `json:"product_id"`
+	Value     entities.Money `json:"value"`
 }
 
 func FromOrderToResponse(order *entities.Order) *Response {
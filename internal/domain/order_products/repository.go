@@ -5,6 +5,14 @@ import (
 )
 
 type Repository interface {
-	Add(orderProduct *entities.OrderProduct) error
+	Upsert(orderProduct *entities.OrderProduct) (entities.UpsertOutcome, error)
 	GetByOrderID(orderId uint) ([]*entities.OrderProduct, error)
+	// DeleteByID removes the line with the id, returning how many it removed
+	DeleteByID(id uint) (int, error)
+	// DeleteByOrderID removes every line of the order, returning how many it removed
+	DeleteByOrderID(orderId uint) (int, error)
+	// DeleteByProduct removes every line of the product in the order, returning how many it removed
+	DeleteByProduct(orderId, productId uint) (int, error)
+	// DeleteByValue removes the lines of the product with the value in the order, returning how many it removed
+	DeleteByValue(orderId, productId uint, value entities.Money) (int, error)
 }
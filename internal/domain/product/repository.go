@@ -3,5 +3,6 @@ package product
 import "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
 
 type Repository interface {
-	Add(product *entities.Product) error
+	Get(id uint) (*entities.Product, error)
+	Upsert(product *entities.Product) (entities.UpsertOutcome, error)
 }
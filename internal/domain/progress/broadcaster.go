@@ -0,0 +1,15 @@
+package progress
+
+import "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+
+// Broadcaster fans the progress of the uploads out to whoever follows them. Publishing never
+// waits for a subscriber: one that falls behind skips progress, but always gets the latest
+type Broadcaster interface {
+	// Publish sends the progress to the subscribers of its batch
+	Publish(progress *entities.IngestionProgress)
+	// Subscribe follows the progress of the batch published from now on. The channel is closed
+	// once the batch is closed or cancel is called, which must always be called
+	Subscribe(batchID string) (events <-chan *entities.IngestionProgress, cancel func())
+	// Close ends the subscriptions of the batch, once nothing else will be published for it
+	Close(batchID string)
+}
@@ -0,0 +1,48 @@
+package progress
+
+import (
+	"time"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+)
+
+type Response struct {
+	JobID          string  `json:"job_id"`
+	FileName       string  `json:"file_name"`
+	ReadLines      int     `json:"read_lines"`
+	WrittenLines   int     `json:"written_lines"`
+	RejectedLines  int     `json:"rejected_lines"`
+	SkippedLines   int     `json:"skipped_lines"`
+	ReadBytes      int64   `json:"read_bytes"`
+	TotalBytes     int64   `json:"total_bytes,omitempty"`
+	LinesPerSecond float64 `json:"lines_per_second"`
+	// ETASeconds is left out when the time left can not be estimated
+	ETASeconds *float64  `json:"eta_seconds,omitempty"`
+	Done       bool      `json:"done"`
+	StartedAt  time.Time `json:"started_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func FromIngestionProgressToResponse(progress *entities.IngestionProgress) *Response {
+	res := &Response{
+		JobID:          progress.BatchID,
+		FileName:       progress.FileName,
+		ReadLines:      progress.ReadLines,
+		WrittenLines:   progress.WrittenLines,
+		RejectedLines:  progress.RejectedLines,
+		SkippedLines:   progress.SkippedLines,
+		ReadBytes:      progress.ReadBytes,
+		TotalBytes:     progress.TotalBytes,
+		LinesPerSecond: progress.LinesPerSecond,
+		Done:           progress.Done,
+		StartedAt:      progress.StartedAt,
+		UpdatedAt:      progress.UpdatedAt,
+	}
+
+	if progress.ETA >= 0 {
+		eta := progress.ETA.Seconds()
+		res.ETASeconds = &eta
+	}
+
+	return res
+}
@@ -0,0 +1,14 @@
+package quarantine
+
+import "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+
+// Repository keeps the rejected lines of the uploads, identified by the batch, the file
+// of the batch they come from and their line
+type Repository interface {
+	// Save quarantines the lines, replacing the content and rejections of the lines already kept
+	Save(lines []*entities.RejectedLine) error
+	// Get returns up to limit lines of the file of the batch after the given line, ordered by line
+	Get(batchID, fileName string, afterLine, limit int) ([]*entities.RejectedLine, error)
+	// Remove releases the lines of the file of the batch from the quarantine
+	Remove(batchID, fileName string, lines []int) error
+}
@@ -0,0 +1,27 @@
+package resumable
+
+import (
+	"io"
+	"time"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+)
+
+// Repository keeps the resumable uploads along with the bytes they received. The offset of an
+// upload is how many bytes were kept, so a chunk cut short counts the part of it written
+type Repository interface {
+	// Create keeps the upload without any byte
+	Create(upload *entities.ResumableUpload) error
+	Get(id string) (*entities.ResumableUpload, error)
+	// Append writes the chunk at the end of the upload and returns its new offset, which is
+	// also returned when reading the chunk fails partway
+	Append(id string, chunk io.Reader) (int64, error)
+	// Checksum returns the SHA-256 of the bytes of the upload, hashed as they were appended
+	Checksum(id string) (string, error)
+	// Path returns the file holding the bytes of the upload, so they can be moved once complete
+	Path(id string) (string, error)
+	// Remove removes the upload, along with its bytes unless they were moved
+	Remove(id string) error
+	// RemoveIdle removes the uploads that received nothing since before, returning how many
+	RemoveIdle(before time.Time) (int, error)
+}
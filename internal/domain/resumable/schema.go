@@ -0,0 +1,30 @@
+package resumable
+
+import (
+	"time"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+)
+
+// Response tells how much of a resumable upload was received, chunks are sent to URL
+type Response struct {
+	ID        string    `json:"id"`
+	FileName  string    `json:"file_name"`
+	Length    int64     `json:"length"`
+	Offset    int64     `json:"offset"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func FromResumableUploadToResponse(upload *entities.ResumableUpload) *Response {
+	return &Response{
+		ID:        upload.ID,
+		FileName:  upload.FileName,
+		Length:    upload.Length,
+		Offset:    upload.Offset,
+		URL:       "/resumable-uploads/" + upload.ID,
+		CreatedAt: upload.CreatedAt,
+		UpdatedAt: upload.UpdatedAt,
+	}
+}
@@ -0,0 +1,21 @@
+package resumable
+
+import (
+	"io"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/upload"
+)
+
+type Service interface {
+	// Create starts the upload of a file of length bytes, loaded with opts once it is finished
+	Create(fileName string, length int64, opts upload.SubmitOptions) (*entities.ResumableUpload, error)
+	Get(id string) (*entities.ResumableUpload, error)
+	// WriteChunk appends the chunk to the upload. The offset must be the one of the upload, so
+	// a client resuming from a wrong offset is refused with ErrResumableOffsetMismatch
+	WriteChunk(id string, offset int64, chunk io.Reader) (*entities.ResumableUpload, error)
+	// Finish moves the complete file to the queue as an upload job. An upload refused by the
+	// queue is kept, so it can be finished again, forced when the file was already imported
+	Finish(id string, force bool) (*entities.UploadJob, error)
+	Cancel(id string) error
+}
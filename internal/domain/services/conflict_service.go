@@ -0,0 +1,36 @@
+package services
+
+import (
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/conflict"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+)
+
+type conflictService struct {
+	repository conflict.Repository
+}
+
+func NewConflictService(repository conflict.Repository) *conflictService {
+	return &conflictService{
+		repository: repository,
+	}
+}
+
+// GetUnresolvedConflicts returns a page of the conflicts not resolved yet, of every type
+// when conflictType is empty
+func (s *conflictService) GetUnresolvedConflicts(
+	conflictType entities.ConflictType,
+	limit, offset int,
+) ([]*entities.LineConflict, error) {
+	if conflictType != "" && !conflictType.IsValid() {
+		return nil, errors.ErrUnknownConflictType
+	}
+
+	limit, offset = pageBounds(limit, offset)
+
+	return s.repository.GetUnresolved(conflictType, limit, offset)
+}
+
+func (s *conflictService) ResolveConflict(id uint) error {
+	return s.repository.Resolve(id)
+}
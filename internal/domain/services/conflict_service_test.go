@@ -0,0 +1,84 @@
+package services_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/conflict"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_GetUnresolvedConflicts_ConflictService(t *testing.T) {
+	mockConflicts := []*entities.LineConflict{
+		{ID: 1, Type: entities.ConflictOrderUser, Value: "753", Existing: "12", Incoming: "70"},
+	}
+
+	tests := []struct {
+		description       string
+		conflictType      entities.ConflictType
+		limit             int
+		offset            int
+		setMocks          func(mcr *conflict.MockRepository)
+		expectedConflicts []*entities.LineConflict
+		expectedErr       error
+	}{
+		{
+			description: "should default the page size and list every type",
+			limit:       0,
+			offset:      -1,
+			setMocks: func(mcr *conflict.MockRepository) {
+				mcr.EXPECT().GetUnresolved(entities.ConflictType(""), 50, 0).Return(mockConflicts, nil)
+			},
+			expectedConflicts: mockConflicts,
+		},
+		{
+			description:  "should cap the page size and filter by type",
+			conflictType: entities.ConflictOrderUser,
+			limit:        10000,
+			offset:       20,
+			setMocks: func(mcr *conflict.MockRepository) {
+				mcr.EXPECT().GetUnresolved(entities.ConflictOrderUser, 500, 20).Return(mockConflicts, nil)
+			},
+			expectedConflicts: mockConflicts,
+		},
+		{
+			description:  "should refuse an unknown type",
+			conflictType: "order_value",
+			setMocks:     func(mcr *conflict.MockRepository) {},
+			expectedErr:  errors.ErrUnknownConflictType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mcr := conflict.NewMockRepository(ctrl)
+			tt.setMocks(mcr)
+
+			conflictService := services.NewConflictService(mcr)
+			conflicts, err := conflictService.GetUnresolvedConflicts(tt.conflictType, tt.limit, tt.offset)
+
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expectedConflicts, conflicts)
+		})
+	}
+}
+
+func Test_ResolveConflict_ConflictService(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mcr := conflict.NewMockRepository(ctrl)
+	mcr.EXPECT().Resolve(uint(1)).Return(nil)
+	mcr.EXPECT().Resolve(uint(2)).Return(errors.ErrConflictNotFound)
+
+	conflictService := services.NewConflictService(mcr)
+
+	assert.NoError(t, conflictService.ResolveConflict(1))
+	assert.ErrorIs(t, conflictService.ResolveConflict(2), errors.ErrConflictNotFound)
+}
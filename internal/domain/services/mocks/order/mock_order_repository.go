@@ -41,18 +41,19 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
-// Add mocks base method.
-func (m *MockRepository) Add(order *entities.Order) error {
+// DeleteIfEmpty mocks base method.
+func (m *MockRepository) DeleteIfEmpty(id uint) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Add", order)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "DeleteIfEmpty", id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// Add indicates an expected call of Add.
-func (mr *MockRepositoryMockRecorder) Add(order any) *gomock.Call {
+// DeleteIfEmpty indicates an expected call of DeleteIfEmpty.
+func (mr *MockRepositoryMockRecorder) DeleteIfEmpty(id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockRepository)(nil).Add), order)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIfEmpty", reflect.TypeOf((*MockRepository)(nil).DeleteIfEmpty), id)
 }
 
 // Get mocks base method.
@@ -99,3 +100,18 @@ func (mr *MockRepositoryMockRecorder) GetByInterval(startDate, endDate any) *gom
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByInterval", reflect.TypeOf((*MockRepository)(nil).GetByInterval), startDate, endDate)
 }
+
+// Upsert mocks base method.
+func (m *MockRepository) Upsert(order *entities.Order) (entities.UpsertOutcome, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Upsert", order)
+	ret0, _ := ret[0].(entities.UpsertOutcome)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Upsert indicates an expected call of Upsert.
+func (mr *MockRepositoryMockRecorder) Upsert(order any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upsert", reflect.TypeOf((*MockRepository)(nil).Upsert), order)
+}
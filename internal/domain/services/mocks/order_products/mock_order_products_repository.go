@@ -40,18 +40,64 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
-// Add mocks base method.
-func (m *MockRepository) Add(orderProduct *entities.OrderProduct) error {
+// DeleteByID mocks base method.
+func (m *MockRepository) DeleteByID(id uint) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Add", orderProduct)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "DeleteByID", id)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteByID indicates an expected call of DeleteByID.
+func (mr *MockRepositoryMockRecorder) DeleteByID(id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByID", reflect.TypeOf((*MockRepository)(nil).DeleteByID), id)
+}
+
+// DeleteByOrderID mocks base method.
+func (m *MockRepository) DeleteByOrderID(orderId uint) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteByOrderID", orderId)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteByOrderID indicates an expected call of DeleteByOrderID.
+func (mr *MockRepositoryMockRecorder) DeleteByOrderID(orderId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByOrderID", reflect.TypeOf((*MockRepository)(nil).DeleteByOrderID), orderId)
+}
+
+// DeleteByProduct mocks base method.
+func (m *MockRepository) DeleteByProduct(orderId, productId uint) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteByProduct", orderId, productId)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteByProduct indicates an expected call of DeleteByProduct.
+func (mr *MockRepositoryMockRecorder) DeleteByProduct(orderId, productId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByProduct", reflect.TypeOf((*MockRepository)(nil).DeleteByProduct), orderId, productId)
+}
+
+// DeleteByValue mocks base method.
+func (m *MockRepository) DeleteByValue(orderId, productId uint, value entities.Money) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteByValue", orderId, productId, value)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// Add indicates an expected call of Add.
-func (mr *MockRepositoryMockRecorder) Add(orderProduct any) *gomock.Call {
+// DeleteByValue indicates an expected call of DeleteByValue.
+func (mr *MockRepositoryMockRecorder) DeleteByValue(orderId, productId, value any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockRepository)(nil).Add), orderProduct)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteByValue", reflect.TypeOf((*MockRepository)(nil).DeleteByValue), orderId, productId, value)
 }
 
 // GetByOrderID mocks base method.
@@ -68,3 +114,18 @@ func (mr *MockRepositoryMockRecorder) GetByOrderID(orderId any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByOrderID", reflect.TypeOf((*MockRepository)(nil).GetByOrderID), orderId)
 }
+
+// Upsert mocks base method.
+func (m *MockRepository) Upsert(orderProduct *entities.OrderProduct) (entities.UpsertOutcome, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Upsert", orderProduct)
+	ret0, _ := ret[0].(entities.UpsertOutcome)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Upsert indicates an expected call of Upsert.
+func (mr *MockRepositoryMockRecorder) Upsert(orderProduct any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upsert", reflect.TypeOf((*MockRepository)(nil).Upsert), orderProduct)
+}
@@ -40,16 +40,32 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
-// Add mocks base method.
-func (m *MockRepository) Add(product *entities.Product) error {
+// Get mocks base method.
+func (m *MockRepository) Get(id uint) (*entities.Product, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Add", product)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "Get", id)
+	ret0, _ := ret[0].(*entities.Product)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// Add indicates an expected call of Add.
-func (mr *MockRepositoryMockRecorder) Add(product any) *gomock.Call {
+// Get indicates an expected call of Get.
+func (mr *MockRepositoryMockRecorder) Get(id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockRepository)(nil).Add), product)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockRepository)(nil).Get), id)
+}
+
+// Upsert mocks base method.
+func (m *MockRepository) Upsert(product *entities.Product) (entities.UpsertOutcome, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Upsert", product)
+	ret0, _ := ret[0].(entities.UpsertOutcome)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Upsert indicates an expected call of Upsert.
+func (mr *MockRepositoryMockRecorder) Upsert(product any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upsert", reflect.TypeOf((*MockRepository)(nil).Upsert), product)
 }
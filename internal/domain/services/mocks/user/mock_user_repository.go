@@ -13,6 +13,7 @@ import (
 	reflect "reflect"
 
 	entities "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	user "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -40,20 +41,6 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
-// Add mocks base method.
-func (m *MockRepository) Add(user *entities.User) error {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Add", user)
-	ret0, _ := ret[0].(error)
-	return ret0
-}
-
-// Add indicates an expected call of Add.
-func (mr *MockRepositoryMockRecorder) Add(user any) *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockRepository)(nil).Add), user)
-}
-
 // Get mocks base method.
 func (m *MockRepository) Get(id uint) (*entities.User, error) {
 	m.ctrl.T.Helper()
@@ -68,3 +55,101 @@ func (mr *MockRepositoryMockRecorder) Get(id any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockRepository)(nil).Get), id)
 }
+
+// Upsert mocks base method.
+func (m *MockRepository) Upsert(user *entities.User) (entities.UpsertOutcome, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Upsert", user)
+	ret0, _ := ret[0].(entities.UpsertOutcome)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Upsert indicates an expected call of Upsert.
+func (mr *MockRepositoryMockRecorder) Upsert(user any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upsert", reflect.TypeOf((*MockRepository)(nil).Upsert), user)
+}
+
+// MockBulkRepository is a mock of BulkRepository interface.
+type MockBulkRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockBulkRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockBulkRepositoryMockRecorder is the mock recorder for MockBulkRepository.
+type MockBulkRepositoryMockRecorder struct {
+	mock *MockBulkRepository
+}
+
+// NewMockBulkRepository creates a new mock instance.
+func NewMockBulkRepository(ctrl *gomock.Controller) *MockBulkRepository {
+	mock := &MockBulkRepository{ctrl: ctrl}
+	mock.recorder = &MockBulkRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBulkRepository) EXPECT() *MockBulkRepositoryMockRecorder {
+	return m.recorder
+}
+
+// DetectConflicts mocks base method.
+func (m *MockBulkRepository) DetectConflicts(policies entities.ConflictPolicies) ([]*entities.LineConflict, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DetectConflicts", policies)
+	ret0, _ := ret[0].([]*entities.LineConflict)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DetectConflicts indicates an expected call of DetectConflicts.
+func (mr *MockBulkRepositoryMockRecorder) DetectConflicts(policies any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DetectConflicts", reflect.TypeOf((*MockBulkRepository)(nil).DetectConflicts), policies)
+}
+
+// Discard mocks base method.
+func (m *MockBulkRepository) Discard(lines []int) (map[int]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Discard", lines)
+	ret0, _ := ret[0].(map[int]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Discard indicates an expected call of Discard.
+func (mr *MockBulkRepositoryMockRecorder) Discard(lines any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Discard", reflect.TypeOf((*MockBulkRepository)(nil).Discard), lines)
+}
+
+// Merge mocks base method.
+func (m *MockBulkRepository) Merge(policies entities.ConflictPolicies) (entities.WriteSummary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Merge", policies)
+	ret0, _ := ret[0].(entities.WriteSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Merge indicates an expected call of Merge.
+func (mr *MockBulkRepositoryMockRecorder) Merge(policies any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Merge", reflect.TypeOf((*MockBulkRepository)(nil).Merge), policies)
+}
+
+// Stage mocks base method.
+func (m *MockBulkRepository) Stage(usersData []*user.UserFileData) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stage", usersData)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Stage indicates an expected call of Stage.
+func (mr *MockBulkRepositoryMockRecorder) Stage(usersData any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stage", reflect.TypeOf((*MockBulkRepository)(nil).Stage), usersData)
+}
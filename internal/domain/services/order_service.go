@@ -41,8 +41,9 @@ func (s *orderService) GetOrderById(orderId uint) (*entities.Order, error) {
 	return order, nil
 }
 
+// GetOrdersInInterval returns the orders from startDate, included, until endDate, excluded
 func (s *orderService) GetOrdersInInterval(startDate, endDate time.Time) ([]*entities.Order, error) {
-	if endDate.Before(startDate) {
+	if !endDate.After(startDate) {
 		return nil, errors.ErrInvalidDateInterval
 	}
 
@@ -89,9 +90,9 @@ func (s *orderService) GetAllOrdersProducts() ([]*order.Purchase, error) {
 			return nil, err
 		}
 
-		total := 0.0
+		total := entities.Money(0)
 		for _, p := range ops {
-			total += float64(p.Value)
+			total += p.Value
 		}
 
 		purchase := &order.Purchase{
@@ -124,9 +125,9 @@ func (s *orderService) GetOrdersProductsByOrderId(orderId uint) ([]*order.Purcha
 		return nil, err
 	}
 
-	total := 0.0
+	total := entities.Money(0)
 	for _, p := range ops {
-		total += float64(p.Value)
+		total += p.Value
 	}
 
 	purchase := &order.Purchase{
@@ -160,9 +161,9 @@ func (s *orderService) GetOrdersProductsByInterval(startDate, endDate time.Time)
 			return nil, err
 		}
 
-		total := 0.0
+		total := entities.Money(0)
 		for _, p := range ops {
-			total += float64(p.Value)
+			total += p.Value
 		}
 
 		purchase := &order.Purchase{
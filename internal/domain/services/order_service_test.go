@@ -185,6 +185,19 @@ func Test_GetOrdersInInterval_OrderService(t *testing.T) {
 			expectedOrders: nil,
 			expectedErr:    errors.ErrInvalidDateInterval,
 		},
+		{
+			description: "should return error for an empty interval, as the end date is excluded",
+			startDate:   startDate,
+			endDate:     startDate,
+			setMocks: func(
+				mor *mockorder.MockRepository,
+				mopr *orderproducts.MockRepository,
+				mur *user.MockRepository,
+			) {
+			},
+			expectedOrders: nil,
+			expectedErr:    errors.ErrInvalidDateInterval,
+		},
 		{
 			description: "should return no error and empty orders",
 			startDate:   startDate,
@@ -352,9 +365,9 @@ func Test_GetAllOrdersProducts_OrderService(t *testing.T) {
 	}
 
 	mockOrderProducts := []*entities.OrderProduct{
-		{OrderID: 1, ProductID: 100, Value: 9.99},
-		{OrderID: 1, ProductID: 101, Value: 5.49},
-		{OrderID: 2, ProductID: 200, Value: 20.00},
+		{OrderID: 1, ProductID: 100, Value: 999},
+		{OrderID: 1, ProductID: 101, Value: 549},
+		{OrderID: 2, ProductID: 200, Value: 2000},
 	}
 
 	expectedPurchases := []*order.Purchase{
@@ -366,7 +379,7 @@ func Test_GetAllOrdersProducts_OrderService(t *testing.T) {
 				mockOrderProducts[0],
 				mockOrderProducts[1],
 			},
-			Total: 15.48,
+			Total: 1548,
 		},
 		{
 			UserID: 20,
@@ -375,7 +388,7 @@ func Test_GetAllOrdersProducts_OrderService(t *testing.T) {
 			Products: []*entities.OrderProduct{
 				mockOrderProducts[2],
 			},
-			Total: 20.00,
+			Total: 2000,
 		},
 	}
 
@@ -550,8 +563,8 @@ func Test_GetOrdersProductsByOrderId_OrderService(t *testing.T) {
 	}
 
 	mockOrderProducts := []*entities.OrderProduct{
-		{OrderID: uint(mockOrderId), ProductID: 501, Value: 25.99},
-		{OrderID: uint(mockOrderId), ProductID: 502, Value: 12.50},
+		{OrderID: uint(mockOrderId), ProductID: 501, Value: 2599},
+		{OrderID: uint(mockOrderId), ProductID: 502, Value: 1250},
 	}
 
 	expectedPurchase := &order.Purchase{
@@ -562,7 +575,7 @@ func Test_GetOrdersProductsByOrderId_OrderService(t *testing.T) {
 			mockOrderProducts[0],
 			mockOrderProducts[1],
 		},
-		Total: 38.49,
+		Total: 3849,
 	}
 
 	tests := []struct {
@@ -713,9 +726,9 @@ func Test_GetOrdersProductsByInterval_OrderService(t *testing.T) {
 	}
 
 	mockOrderProducts := []*entities.OrderProduct{
-		{OrderID: 1, ProductID: 100, Value: 19.99},
-		{OrderID: 1, ProductID: 101, Value: 7.50},
-		{OrderID: 2, ProductID: 200, Value: 35.00},
+		{OrderID: 1, ProductID: 100, Value: 1999},
+		{OrderID: 1, ProductID: 101, Value: 750},
+		{OrderID: 2, ProductID: 200, Value: 3500},
 	}
 
 	expectedPurchases := []*order.Purchase{
@@ -727,7 +740,7 @@ func Test_GetOrdersProductsByInterval_OrderService(t *testing.T) {
 				mockOrderProducts[0],
 				mockOrderProducts[1],
 			},
-			Total: 27.49,
+			Total: 2749,
 		},
 		{
 			UserID: 20,
@@ -736,7 +749,7 @@ func Test_GetOrdersProductsByInterval_OrderService(t *testing.T) {
 			Products: []*entities.OrderProduct{
 				mockOrderProducts[2],
 			},
-			Total: 35.00,
+			Total: 3500,
 		},
 	}
 
@@ -0,0 +1,17 @@
+package services
+
+// Page sizes of the listings
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// pageBounds defaults the page size to defaultPageSize, keeps it under maxPageSize
+// and keeps the offset from going negative
+func pageBounds(limit, offset int) (int, int) {
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+
+	return min(limit, maxPageSize), max(offset, 0)
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/resumable"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/upload"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+type resumableUploadService struct {
+	repository    resumable.Repository
+	uploadService upload.Service
+	// maxLength bounds the length of an upload, unbounded when not positive
+	maxLength int64
+	// expiry is how long an upload may go without receiving a chunk before it is removed
+	expiry time.Duration
+
+	// writing holds the uploads a request is writing to, so chunks are never appended concurrently
+	mu      sync.Mutex
+	writing map[string]bool
+}
+
+func NewResumableUploadService(
+	repository resumable.Repository,
+	uploadService upload.Service,
+	maxLength int64,
+	expiry time.Duration,
+) *resumableUploadService {
+	return &resumableUploadService{
+		repository:    repository,
+		uploadService: uploadService,
+		maxLength:     maxLength,
+		expiry:        expiry,
+		writing:       make(map[string]bool),
+	}
+}
+
+// Create starts a new upload, removing first the ones idle for longer than the expiry, so the
+// uploads given up by their clients do not fill the disk
+func (s *resumableUploadService) Create(
+	fileName string,
+	length int64,
+	opts upload.SubmitOptions,
+) (*entities.ResumableUpload, error) {
+	if s.maxLength > 0 && length > s.maxLength {
+		return nil, fmt.Errorf("%w: the limit is %d bytes", errors.ErrUploadTooLarge, s.maxLength)
+	}
+
+	if s.expiry > 0 {
+		if _, err := s.repository.RemoveIdle(time.Now().Add(-s.expiry)); err != nil {
+			log.Printf("Failed to remove idle resumable uploads. Details: %s\n", err.Error())
+		}
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	resumableUpload := &entities.ResumableUpload{
+		ID:            id,
+		FileName:      fileName,
+		Length:        length,
+		UploadedBy:    opts.UploadedBy,
+		Forced:        opts.Force,
+		Layout:        opts.Layout,
+		Format:        string(opts.Format),
+		Encoding:      string(opts.Encoding),
+		Mode:          string(opts.Mode),
+		SnapshotStart: opts.SnapshotStart,
+		SnapshotEnd:   opts.SnapshotEnd,
+		DryRun:        opts.DryRun,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	if err := s.repository.Create(resumableUpload); err != nil {
+		return nil, err
+	}
+
+	return resumableUpload, nil
+}
+
+func (s *resumableUploadService) Get(id string) (*entities.ResumableUpload, error) {
+	return s.repository.Get(id)
+}
+
+// WriteChunk appends the chunk up to the length of the upload. The bytes received before the
+// chunk fails, or goes past the length, are kept and counted in the returned offset
+func (s *resumableUploadService) WriteChunk(id string, offset int64, chunk io.Reader) (*entities.ResumableUpload, error) {
+	if !s.lock(id) {
+		return nil, errors.ErrResumableUploadBusy
+	}
+	defer s.unlock(id)
+
+	resumableUpload, err := s.repository.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != resumableUpload.Offset {
+		return resumableUpload, errors.ErrResumableOffsetMismatch
+	}
+
+	remaining := resumableUpload.Length - resumableUpload.Offset
+	resumableUpload.Offset, err = s.repository.Append(id, io.LimitReader(chunk, remaining))
+	resumableUpload.UpdatedAt = time.Now()
+	if err != nil {
+		return resumableUpload, err
+	}
+
+	if n, _ := io.ReadFull(chunk, make([]byte, 1)); n > 0 {
+		return resumableUpload, errors.ErrResumableChunkTooLong
+	}
+
+	return resumableUpload, nil
+}
+
+// Finish moves the file to the upload queue, without reading it again as it was hashed while
+// its chunks were appended, and removes the upload once a job was created.
+// A file already imported is refused with the job that imported it, unless force is set or
+// the upload was created forced
+func (s *resumableUploadService) Finish(id string, force bool) (*entities.UploadJob, error) {
+	if !s.lock(id) {
+		return nil, errors.ErrResumableUploadBusy
+	}
+	defer s.unlock(id)
+
+	resumableUpload, err := s.repository.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if resumableUpload.Offset < resumableUpload.Length {
+		return nil, fmt.Errorf(
+			"%w: %d of %d bytes received",
+			errors.ErrResumableUploadIncomplete,
+			resumableUpload.Offset,
+			resumableUpload.Length,
+		)
+	}
+
+	sha256, err := s.repository.Checksum(id)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := s.repository.Path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := s.uploadService.SubmitFile(resumableUpload.FileName, path, sha256, upload.SubmitOptions{
+		LoadOptions: user.LoadOptions{
+			Layout:        resumableUpload.Layout,
+			Format:        user.Format(resumableUpload.Format),
+			Encoding:      user.Encoding(resumableUpload.Encoding),
+			Mode:          user.Mode(resumableUpload.Mode),
+			SnapshotStart: resumableUpload.SnapshotStart,
+			SnapshotEnd:   resumableUpload.SnapshotEnd,
+			DryRun:        resumableUpload.DryRun,
+		},
+		UploadedBy: resumableUpload.UploadedBy,
+		Force:      resumableUpload.Forced || force,
+	})
+	if err != nil {
+		return job, err
+	}
+
+	// The bytes were moved to the job, so a failure here only leaves the options to expire
+	if err := s.repository.Remove(id); err != nil {
+		log.Printf("Failed to remove resumable upload [%s]. Details: %s\n", id, err.Error())
+	}
+
+	return job, nil
+}
+
+func (s *resumableUploadService) Cancel(id string) error {
+	if !s.lock(id) {
+		return errors.ErrResumableUploadBusy
+	}
+	defer s.unlock(id)
+
+	return s.repository.Remove(id)
+}
+
+// lock marks the upload as being written, telling false when it already is
+func (s *resumableUploadService) lock(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.writing[id] {
+		return false
+	}
+	s.writing[id] = true
+
+	return true
+}
+
+func (s *resumableUploadService) unlock(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.writing, id)
+}
@@ -0,0 +1,324 @@
+package services_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/resumable"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/upload"
+	domainupload "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/upload"
+	domainuser "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_Create_ResumableUploadService(t *testing.T) {
+	mockOpts := domainupload.SubmitOptions{
+		LoadOptions: domainuser.LoadOptions{
+			Layout:   "v1",
+			Format:   domainuser.FormatCSV,
+			Encoding: domainuser.EncodingUTF8,
+			DryRun:   true,
+		},
+		UploadedBy: "partner",
+		Force:      true,
+	}
+
+	tests := []struct {
+		description string
+		length      int64
+		setMocks    func(mrr *resumable.MockRepository)
+		expectedErr error
+	}{
+		{
+			description: "should remove the idle uploads and keep the new one with its options",
+			length:      1 << 20,
+			setMocks: func(mrr *resumable.MockRepository) {
+				mrr.EXPECT().RemoveIdle(gomock.Cond(func(before time.Time) bool {
+					return time.Since(before) >= time.Hour
+				})).Return(1, nil)
+				mrr.EXPECT().Create(gomock.Cond(func(upload *entities.ResumableUpload) bool {
+					return upload.ID != "" &&
+						upload.FileName == "users.csv" &&
+						upload.Length == 1<<20 &&
+						upload.Offset == 0 &&
+						upload.UploadedBy == "partner" &&
+						upload.Forced &&
+						upload.Layout == "v1" &&
+						upload.Format == "csv" &&
+						upload.Encoding == "utf-8" &&
+						upload.DryRun
+				})).Return(nil)
+			},
+		},
+		{
+			description: "should create the upload even when the idle ones can not be removed",
+			length:      1 << 20,
+			setMocks: func(mrr *resumable.MockRepository) {
+				mrr.EXPECT().RemoveIdle(gomock.Any()).Return(0, assert.AnError)
+				mrr.EXPECT().Create(gomock.Any()).Return(nil)
+			},
+		},
+		{
+			description: "should refuse a file longer than the limit",
+			length:      1<<30 + 1,
+			setMocks:    func(mrr *resumable.MockRepository) {},
+			expectedErr: errors.ErrUploadTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mrr := resumable.NewMockRepository(ctrl)
+			mus := upload.NewMockService(ctrl)
+			tt.setMocks(mrr)
+
+			resumableUploadService := services.NewResumableUploadService(mrr, mus, 1<<30, time.Hour)
+
+			resumableUpload, err := resumableUploadService.Create("users.csv", tt.length, mockOpts)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Nil(t, resumableUpload)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.length, resumableUpload.Length)
+		})
+	}
+}
+
+func Test_WriteChunk_ResumableUploadService(t *testing.T) {
+	newUpload := func(offset int64) *entities.ResumableUpload {
+		return &entities.ResumableUpload{ID: "a1b2c3", FileName: "users.txt", Length: 10, Offset: offset}
+	}
+
+	tests := []struct {
+		description    string
+		offset         int64
+		chunk          string
+		setMocks       func(mrr *resumable.MockRepository)
+		expectedOffset int64
+		expectedErr    error
+	}{
+		{
+			description: "should append the chunk at the offset of the upload",
+			offset:      4,
+			chunk:       "4567",
+			setMocks: func(mrr *resumable.MockRepository) {
+				mrr.EXPECT().Get("a1b2c3").Return(newUpload(4), nil)
+				mrr.EXPECT().Append("a1b2c3", gomock.Any()).DoAndReturn(func(id string, chunk io.Reader) (int64, error) {
+					content, err := io.ReadAll(chunk)
+					return 4 + int64(len(content)), err
+				})
+			},
+			expectedOffset: 8,
+		},
+		{
+			description: "should keep the bytes up to the length and refuse the rest of the chunk",
+			offset:      8,
+			chunk:       "89AB",
+			setMocks: func(mrr *resumable.MockRepository) {
+				mrr.EXPECT().Get("a1b2c3").Return(newUpload(8), nil)
+				mrr.EXPECT().Append("a1b2c3", gomock.Any()).DoAndReturn(func(id string, chunk io.Reader) (int64, error) {
+					content, err := io.ReadAll(chunk)
+					assert.Equal(t, "89", string(content))
+					return 8 + int64(len(content)), err
+				})
+			},
+			expectedOffset: 10,
+			expectedErr:    errors.ErrResumableChunkTooLong,
+		},
+		{
+			description: "should refuse a chunk that does not start at the offset of the upload",
+			offset:      0,
+			chunk:       "0123",
+			setMocks: func(mrr *resumable.MockRepository) {
+				mrr.EXPECT().Get("a1b2c3").Return(newUpload(4), nil)
+			},
+			expectedOffset: 4,
+			expectedErr:    errors.ErrResumableOffsetMismatch,
+		},
+		{
+			description: "should report the offset reached when the chunk is cut short",
+			offset:      4,
+			chunk:       "4567",
+			setMocks: func(mrr *resumable.MockRepository) {
+				mrr.EXPECT().Get("a1b2c3").Return(newUpload(4), nil)
+				mrr.EXPECT().Append("a1b2c3", gomock.Any()).Return(int64(6), io.ErrUnexpectedEOF)
+			},
+			expectedOffset: 6,
+			expectedErr:    io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mrr := resumable.NewMockRepository(ctrl)
+			mus := upload.NewMockService(ctrl)
+			tt.setMocks(mrr)
+
+			resumableUploadService := services.NewResumableUploadService(mrr, mus, 0, 0)
+
+			resumableUpload, err := resumableUploadService.WriteChunk("a1b2c3", tt.offset, strings.NewReader(tt.chunk))
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.expectedOffset, resumableUpload.Offset)
+		})
+	}
+}
+
+func Test_WriteChunk_ResumableUploadService_Busy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	mrr := resumable.NewMockRepository(ctrl)
+	mrr.EXPECT().Get("a1b2c3").Return(&entities.ResumableUpload{ID: "a1b2c3", Length: 10}, nil)
+	mrr.EXPECT().Append("a1b2c3", gomock.Any()).DoAndReturn(func(id string, chunk io.Reader) (int64, error) {
+		close(started)
+		<-release
+		content, err := io.ReadAll(chunk)
+		return int64(len(content)), err
+	})
+
+	resumableUploadService := services.NewResumableUploadService(mrr, upload.NewMockService(ctrl), 0, 0)
+
+	done := make(chan error)
+	go func() {
+		_, err := resumableUploadService.WriteChunk("a1b2c3", 0, strings.NewReader("0123"))
+		done <- err
+	}()
+
+	<-started
+	_, err := resumableUploadService.WriteChunk("a1b2c3", 0, strings.NewReader("0123"))
+	assert.ErrorIs(t, err, errors.ErrResumableUploadBusy)
+	assert.ErrorIs(t, resumableUploadService.Cancel("a1b2c3"), errors.ErrResumableUploadBusy)
+
+	close(release)
+	assert.NoError(t, <-done)
+}
+
+func Test_Finish_ResumableUploadService(t *testing.T) {
+	mockUpload := &entities.ResumableUpload{
+		ID:         "a1b2c3",
+		FileName:   "users.txt",
+		Length:     4,
+		Offset:     4,
+		UploadedBy: "partner",
+		Layout:     "v1",
+		Format:     "fixed-width",
+		Encoding:   "iso-8859-1",
+	}
+	mockOpts := func(force bool) domainupload.SubmitOptions {
+		return domainupload.SubmitOptions{
+			LoadOptions: domainuser.LoadOptions{
+				Layout:   "v1",
+				Format:   domainuser.FormatFixedWidth,
+				Encoding: domainuser.EncodingISO88591,
+			},
+			UploadedBy: "partner",
+			Force:      force,
+		}
+	}
+	mockJob := &entities.UploadJob{ID: "d4e5f6", FileName: "users.txt"}
+	mockSHA256 := "1be2e452b46d7a0d9656bbb1f768e8248eba1b75baed65f5d99eafa948899a6a"
+
+	tests := []struct {
+		description string
+		force       bool
+		setMocks    func(mrr *resumable.MockRepository, mus *upload.MockService)
+		expectedJob *entities.UploadJob
+		expectedErr error
+	}{
+		{
+			description: "should move the complete file to the queue with the options of the upload and remove the upload",
+			setMocks: func(mrr *resumable.MockRepository, mus *upload.MockService) {
+				mrr.EXPECT().Get("a1b2c3").Return(mockUpload, nil)
+				mrr.EXPECT().Checksum("a1b2c3").Return(mockSHA256, nil)
+				mrr.EXPECT().Path("a1b2c3").Return("/uploads/a1b2c3.part", nil)
+				mus.EXPECT().SubmitFile("users.txt", "/uploads/a1b2c3.part", mockSHA256, mockOpts(false)).Return(mockJob, nil)
+				mrr.EXPECT().Remove("a1b2c3").Return(nil)
+			},
+			expectedJob: mockJob,
+		},
+		{
+			description: "should keep the upload when the file was already imported, so it can be forced",
+			setMocks: func(mrr *resumable.MockRepository, mus *upload.MockService) {
+				mrr.EXPECT().Get("a1b2c3").Return(mockUpload, nil)
+				mrr.EXPECT().Checksum("a1b2c3").Return(mockSHA256, nil)
+				mrr.EXPECT().Path("a1b2c3").Return("/uploads/a1b2c3.part", nil)
+				mus.EXPECT().SubmitFile("users.txt", "/uploads/a1b2c3.part", mockSHA256, mockOpts(false)).Return(mockJob, errors.ErrUploadAlreadyImported)
+			},
+			expectedJob: mockJob,
+			expectedErr: errors.ErrUploadAlreadyImported,
+		},
+		{
+			description: "should force the file when asked to",
+			force:       true,
+			setMocks: func(mrr *resumable.MockRepository, mus *upload.MockService) {
+				mrr.EXPECT().Get("a1b2c3").Return(mockUpload, nil)
+				mrr.EXPECT().Checksum("a1b2c3").Return(mockSHA256, nil)
+				mrr.EXPECT().Path("a1b2c3").Return("/uploads/a1b2c3.part", nil)
+				mus.EXPECT().SubmitFile("users.txt", "/uploads/a1b2c3.part", mockSHA256, mockOpts(true)).Return(mockJob, nil)
+				mrr.EXPECT().Remove("a1b2c3").Return(nil)
+			},
+			expectedJob: mockJob,
+		},
+		{
+			description: "should refuse an upload that did not receive all of its bytes",
+			setMocks: func(mrr *resumable.MockRepository, mus *upload.MockService) {
+				incomplete := *mockUpload
+				incomplete.Offset = 2
+				mrr.EXPECT().Get("a1b2c3").Return(&incomplete, nil)
+			},
+			expectedErr: errors.ErrResumableUploadIncomplete,
+		},
+		{
+			description: "should return error when the upload does not exist",
+			setMocks: func(mrr *resumable.MockRepository, mus *upload.MockService) {
+				mrr.EXPECT().Get("a1b2c3").Return(nil, errors.ErrResumableUploadNotFound)
+			},
+			expectedErr: errors.ErrResumableUploadNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mrr := resumable.NewMockRepository(ctrl)
+			mus := upload.NewMockService(ctrl)
+			tt.setMocks(mrr, mus)
+
+			resumableUploadService := services.NewResumableUploadService(mrr, mus, 0, 0)
+
+			job, err := resumableUploadService.Finish("a1b2c3", tt.force)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.expectedJob, job)
+		})
+	}
+}
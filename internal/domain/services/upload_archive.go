@@ -0,0 +1,195 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+)
+
+// Compressions of an uploaded file, detected by its magic bytes
+const (
+	compressionNone  = ""
+	compressionGzip  = "gzip"
+	compressionZip   = "zip"
+	compressionTarGz = "tar.gz"
+)
+
+// maxUploadEntrySize caps how much a single file may grow once decompressed, so a small
+// archive can not fill the disk or keep a worker busy forever
+const maxUploadEntrySize = 1 << 30
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+// entryVisitor is called with every file found in an upload, along with its size once
+// decompressed, zero when the archive does not tell it
+type entryVisitor func(name string, entry io.Reader, size int64) error
+
+// visitUploadEntries detects whether the spooled upload is compressed and calls visit with
+// every file in it, or with the upload itself when it is not compressed. It returns the
+// detected compression. Errors returned by visit stop the walk and are returned as is
+func visitUploadEntries(file *os.File, fileName string, visit entryVisitor) (string, error) {
+	head := make([]byte, len(zipMagic))
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return compressionNone, err
+	}
+	head = head[:n]
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return compressionNone, err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, zipMagic):
+		return compressionZip, visitZipEntries(file, visit)
+	case bytes.HasPrefix(head, gzipMagic):
+		return visitGzipEntries(file, fileName, visit)
+	default:
+		info, err := file.Stat()
+		if err != nil {
+			return compressionNone, err
+		}
+
+		return compressionNone, visit(fileName, limitEntry(file), info.Size())
+	}
+}
+
+// visitGzipEntries decompresses the upload, which holds either a single file or a tar archive
+func visitGzipEntries(file io.Reader, fileName string, visit entryVisitor) (string, error) {
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return compressionGzip, err
+	}
+	defer gz.Close()
+
+	// A tar header carries the "ustar" magic at offset 257
+	reader := bufio.NewReaderSize(gz, 512)
+	head, _ := reader.Peek(262)
+	if len(head) == 262 && string(head[257:262]) == "ustar" {
+		return compressionTarGz, visitTarEntries(reader, visit)
+	}
+
+	name := gz.Name
+	if name == "" {
+		name = strings.TrimSuffix(strings.TrimSuffix(fileName, ".gz"), ".gzip")
+	}
+
+	return compressionGzip, visit(name, limitEntry(reader), 0)
+}
+
+func visitTarEntries(reader io.Reader, visit entryVisitor) error {
+	tr := tar.NewReader(reader)
+	visited := 0
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag != tar.TypeReg || isArchiveMetadata(header.Name) {
+			continue
+		}
+
+		visited++
+		if err := visit(header.Name, limitEntry(tr), header.Size); err != nil {
+			return err
+		}
+	}
+
+	if visited == 0 {
+		return errors.ErrUploadArchiveEmpty
+	}
+
+	return nil
+}
+
+func visitZipEntries(file *os.File, visit entryVisitor) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	zr, err := zip.NewReader(file, info.Size())
+	if err != nil {
+		return err
+	}
+
+	visited := 0
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || isArchiveMetadata(f.Name) {
+			continue
+		}
+
+		visited++
+		if err := visitZipEntry(f, visit); err != nil {
+			return err
+		}
+	}
+
+	if visited == 0 {
+		return errors.ErrUploadArchiveEmpty
+	}
+
+	return nil
+}
+
+func visitZipEntry(f *zip.File, visit entryVisitor) error {
+	entry, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer entry.Close()
+
+	return visit(f.Name, limitEntry(entry), int64(f.UncompressedSize64))
+}
+
+// isArchiveMetadata tells whether an archive entry holds operating system metadata
+// instead of a data file, like the resource forks macOS adds to the archives it creates
+func isArchiveMetadata(name string) bool {
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), "._")
+}
+
+// limitEntry fails the reads of an entry once it goes over maxUploadEntrySize
+func limitEntry(reader io.Reader) io.Reader {
+	return &limitedEntryReader{reader: reader, remaining: maxUploadEntrySize}
+}
+
+type limitedEntryReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (r *limitedEntryReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		// Only fail when there really is something left to read
+		var b [1]byte
+		if n, _ := r.reader.Read(b[:]); n > 0 {
+			return 0, errors.ErrUploadEntryTooLarge
+		}
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+
+	return n, err
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"slices"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/transaction"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+// WriteRejectedLines writes the quarantined lines of the job, file after file and in line order,
+// so they can be fixed and sent again. CSV lines come under the header they were written back
+// with and JSON records as NDJSON lines. Lines that could not be read at all are left out
+func (s *uploadService) WriteRejectedLines(job *entities.UploadJob, w io.Writer) error {
+	if job.DryRun {
+		return errors.ErrUploadDryRunRejected
+	}
+
+	for _, entry := range rejectedEntries(job) {
+		header := entry.Format == string(user.FormatCSV)
+
+		for last, done := 0, false; !done; {
+			var page []*entities.RejectedLine
+			err := s.unitOfWork.View(context.Background(), func(r *transaction.Repositories) error {
+				var err error
+				page, err = r.Quarantine.Get(job.ID, entry.Name, last, quarantinePageSize)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			done = len(page) < quarantinePageSize
+
+			for _, line := range page {
+				last = line.Line
+				if line.Content == "" {
+					continue
+				}
+
+				if header {
+					if _, err := io.WriteString(w, csvHeader(user.Mode(job.Mode))+"\n"); err != nil {
+						return err
+					}
+					header = false
+				}
+
+				if _, err := io.WriteString(w, line.Content+"\n"); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// ReprocessRejectedLines loads again the quarantined lines of every file of the job. The lines
+// stored are moved from the rejected to the accepted ones of the job, and the rejections of a
+// file are replaced by the ones of its lines rejected again. The returned result adds up what
+// the reprocess did
+func (s *uploadService) ReprocessRejectedLines(id string) (*entities.UploadJob, *user.UserFileResult, error) {
+	if !s.startReprocess(id) {
+		return nil, nil, errors.ErrUploadReprocessing
+	}
+	defer s.endReprocess(id)
+
+	job, err := s.repository.Get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkReprocess(job); err != nil {
+		return nil, nil, err
+	}
+
+	total := new(user.UserFileResult)
+
+	var reprocessErr error
+	for _, entry := range rejectedEntries(job) {
+		opts := user.LoadOptions{
+			Layout:   job.Layout,
+			Format:   user.Format(entry.Format),
+			Mode:     user.Mode(job.Mode),
+			BatchID:  job.ID,
+			FileName: entry.Name,
+		}
+
+		// The lines committed before a failure left the quarantine, so they are counted anyway
+		result, err := s.userService.ReprocessRejectedLines(s.ctx, opts)
+		addReprocessedEntry(job, entry, result)
+		addReprocessResult(total, entryName(job, entry.Name), result)
+
+		if err != nil {
+			reprocessErr = err
+			break
+		}
+	}
+
+	if err := s.repository.Save(job); err != nil {
+		return nil, nil, err
+	}
+
+	if reprocessErr != nil {
+		return nil, nil, reprocessErr
+	}
+
+	return job, total, nil
+}
+
+// checkReprocess tells whether the job kept rejected lines that can be loaded again
+func checkReprocess(job *entities.UploadJob) error {
+	switch {
+	case job.DryRun:
+		return errors.ErrUploadDryRunRejected
+	case job.State == entities.UploadJobRolledBack:
+		return errors.ErrUploadRolledBack
+	case job.State == entities.UploadJobQueued || job.State == entities.UploadJobRunning:
+		return errors.ErrUploadJobNotFinished
+	}
+
+	return nil
+}
+
+// startReprocess marks the job as being reprocessed, telling false when it already is
+func (s *uploadService) startReprocess(id string) bool {
+	s.reprocessMu.Lock()
+	defer s.reprocessMu.Unlock()
+
+	if s.reprocessing[id] {
+		return false
+	}
+	s.reprocessing[id] = true
+
+	return true
+}
+
+func (s *uploadService) endReprocess(id string) {
+	s.reprocessMu.Lock()
+	defer s.reprocessMu.Unlock()
+
+	delete(s.reprocessing, id)
+}
+
+// rejectedEntries returns the entries of the job with rejected lines
+func rejectedEntries(job *entities.UploadJob) []*entities.UploadEntry {
+	entries := make([]*entities.UploadEntry, 0)
+	for _, entry := range job.Entries {
+		if entry.RejectedLines > 0 {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// addReprocessedEntry moves the lines of the entry stored by a reprocess from its rejected lines
+// to its accepted ones, in the entry and in the job totals
+func addReprocessedEntry(job *entities.UploadJob, entry *entities.UploadEntry, result *user.UserFileResult) {
+	if result == nil {
+		return
+	}
+
+	entry.AcceptedLines += result.AcceptedLines
+	entry.RejectedLines -= result.AcceptedLines
+	entry.Rejections = result.Rejections
+	entry.Conflicts = append(entry.Conflicts, result.Conflicts...)
+	entry.Writes.Merge(result.Writes)
+	entry.OrderTotals = mergeOrderTotals(entry.OrderTotals, result.OrderTotals)
+
+	job.AcceptedLines += result.AcceptedLines
+	job.RejectedLines -= result.AcceptedLines
+	job.Writes.Merge(result.Writes)
+
+	name := entryName(job, entry.Name)
+	job.Rejections = slices.DeleteFunc(job.Rejections, func(rejection *entities.LineRejection) bool {
+		return rejection.Entry == name
+	})
+
+	addEntryFindings(job, entry.Name, result.Rejections, result.Conflicts)
+}
+
+// addReprocessResult adds up the result of a file to the result of the reprocess, its rejections
+// and conflicts listed under the name of the file
+func addReprocessResult(total *user.UserFileResult, name string, result *user.UserFileResult) {
+	if result == nil {
+		return
+	}
+
+	total.ProcessedLines += result.ProcessedLines
+	total.AcceptedLines += result.AcceptedLines
+	total.RejectedLines += result.RejectedLines
+	total.SkippedLines += result.SkippedLines
+	total.Writes.Merge(result.Writes)
+	total.OrderTotals = mergeOrderTotals(total.OrderTotals, result.OrderTotals)
+
+	for _, rejection := range result.Rejections {
+		if len(total.Rejections) >= user.MaxReportedRejections {
+			break
+		}
+
+		rejection := *rejection
+		rejection.Entry = name
+		total.Rejections = append(total.Rejections, &rejection)
+	}
+
+	for _, conflict := range result.Conflicts {
+		conflict := *conflict
+		conflict.Entry = name
+		total.Conflict(&conflict)
+	}
+}
+
+// mergeOrderTotals adds the totals of orders changed later to the kept ones, by order. An order
+// changed again keeps the total it had before it was first changed
+func mergeOrderTotals(kept, totals []*entities.OrderTotal) []*entities.OrderTotal {
+	for _, total := range totals {
+		i, found := slices.BinarySearchFunc(kept, total.OrderID, func(kept *entities.OrderTotal, orderID uint) int {
+			return cmp.Compare(kept.OrderID, orderID)
+		})
+		if found {
+			kept[i].Current = total.Current
+			kept[i].Removed = total.Removed
+			continue
+		}
+
+		if len(kept) < user.MaxReportedOrderTotals {
+			kept = slices.Insert(kept, i, total)
+		}
+	}
+
+	return kept
+}
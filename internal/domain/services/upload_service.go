@@ -0,0 +1,675 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/progress"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/transaction"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/upload"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+// uploadTask is a queued job together with the spooled copy of its file and how to load it
+type uploadTask struct {
+	job  *entities.UploadJob
+	path string
+	opts user.LoadOptions
+}
+
+type uploadService struct {
+	repository  upload.Repository
+	userService user.Service
+	unitOfWork  transaction.UnitOfWork
+	spoolDir    string
+	// broadcaster is closed for every job that finishes, nothing is followed when it is nil
+	broadcaster progress.Broadcaster
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan *uploadTask
+	wg     sync.WaitGroup
+
+	// submitMu keeps a file from being checked against the jobs loading it while another
+	// upload of the same file is saved, so only one of them is kept
+	submitMu sync.Mutex
+
+	// reprocessing holds the jobs whose rejected lines are being loaded again
+	reprocessMu  sync.Mutex
+	reprocessing map[string]bool
+
+	// ctx is canceled when Shutdown gives up waiting, aborting the jobs still running
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewUploadService(
+	repository upload.Repository,
+	userService user.Service,
+	unitOfWork transaction.UnitOfWork,
+	broadcaster progress.Broadcaster,
+	workers int,
+	queueSize int,
+	spoolDir string,
+) *uploadService {
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &uploadService{
+		repository:   repository,
+		userService:  userService,
+		unitOfWork:   unitOfWork,
+		spoolDir:     spoolDir,
+		broadcaster:  broadcaster,
+		queue:        make(chan *uploadTask, queueSize),
+		reprocessing: make(map[string]bool),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+
+	for i := 0; i < workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+
+	return s
+}
+
+// FailInterruptedJobs fails the jobs left queued or running by the last server. Jobs are only
+// run by the process that queued them, so those would never finish, and their files would
+// stay refused as being imported
+func (s *uploadService) FailInterruptedJobs() (int, error) {
+	return s.repository.FailUnfinished(errors.ErrUploadInterrupted.Error(), time.Now())
+}
+
+// Submit spools the file to disk and queues it to be processed in background,
+// so the caller does not need to keep the file open after it returns. A file whose
+// fingerprint matches one already imported, or still being imported, is refused unless
+// forced, returning the job that imported it. Dry runs are never refused, as they do not
+// import anything
+func (s *uploadService) Submit(fileName string, file io.Reader, opts upload.SubmitOptions) (*entities.UploadJob, error) {
+	spooled, err := s.spool(file)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := s.save(fileName, spooled, opts)
+	if err != nil {
+		os.Remove(spooled.path)
+		return job, err
+	}
+
+	if err := s.enqueue(&uploadTask{job: job, path: spooled.path, opts: opts.LoadOptions}); err != nil {
+		os.Remove(spooled.path)
+		s.finish(job, err)
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// SubmitFile queues the file at path like Submit, moving it into the spool directory instead
+// of copying it, so large files are handed over at once. The file is only moved once its job
+// is saved and left in place when it is refused. sha256 is the fingerprint of the file, which
+// the caller computed as it wrote it
+func (s *uploadService) SubmitFile(fileName, path, sha256 string, opts upload.SubmitOptions) (*entities.UploadJob, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := s.save(fileName, &spooledFile{sha256: sha256, size: stat.Size()}, opts)
+	if err != nil {
+		return job, err
+	}
+
+	spooled, moved, err := s.move(path)
+	if err != nil {
+		s.finish(job, err)
+		return nil, err
+	}
+
+	if err := s.enqueue(&uploadTask{job: job, path: spooled, opts: opts.LoadOptions}); err != nil {
+		if moved {
+			err = stderrors.Join(err, os.Rename(spooled, path))
+		} else {
+			os.Remove(spooled)
+		}
+		s.finish(job, err)
+		return nil, err
+	}
+
+	if !moved {
+		os.Remove(path)
+	}
+
+	return job, nil
+}
+
+// move renames the file into the spool directory, telling whether it did. A file on another
+// file system can not be renamed there, so it is copied instead, leaving the caller to remove it
+func (s *uploadService) move(path string) (string, bool, error) {
+	tmp, err := os.CreateTemp(s.spoolDir, "upload-*")
+	if err != nil {
+		return "", false, err
+	}
+	defer tmp.Close()
+
+	if err := os.Rename(path, tmp.Name()); err == nil {
+		return tmp.Name(), true, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", false, err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(tmp, file); err != nil {
+		os.Remove(tmp.Name())
+		return "", false, err
+	}
+
+	return tmp.Name(), false, nil
+}
+
+// Import loads the file right away in the calling goroutine, keeping it as a job like the
+// submitted ones, so it is listed, checked against the files already imported and can be
+// rolled back. The file is read twice, once to fingerprint it, instead of being spooled
+func (s *uploadService) Import(
+	ctx context.Context,
+	fileName string,
+	file io.ReadSeeker,
+	opts upload.SubmitOptions,
+) (job *entities.UploadJob, result *user.UserFileResult, err error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+
+	job, err = s.save(fileName, &spooledFile{sha256: hex.EncodeToString(hash.Sum(nil)), size: size}, opts)
+	if err != nil {
+		return job, nil, err
+	}
+
+	// A malformed file must fail its own job instead of taking the caller down
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = new(user.UserFileResult), fmt.Errorf("failed to process file: %v", r)
+			s.finish(job, err)
+		}
+	}()
+
+	job.State = entities.UploadJobRunning
+	job.StartedAt = time.Now()
+	if err := s.repository.Save(job); err != nil {
+		log.Printf("Failed to save upload job [%s]. Details: %s\n", job.ID, err.Error())
+	}
+
+	loadOpts := opts.LoadOptions
+	loadOpts.BatchID = job.ID
+	loadOpts.FileName = fileName
+	loadOpts.Size = size
+
+	result, err = s.userService.LoadUsersDataFile(ctx, file, loadOpts)
+	addUploadEntry(job, fileName, result, err)
+	if result == nil {
+		result = new(user.UserFileResult)
+	}
+	if result.Format != "" {
+		job.Format = string(result.Format)
+	}
+
+	s.finish(job, err)
+
+	return job, result, err
+}
+
+// save keeps the job of the spooled file, once checked against the jobs that imported the
+// file. The repository refuses the job as well when a job saved by another process since
+// imports the file, which is then returned
+func (s *uploadService) save(fileName string, spooled *spooledFile, opts upload.SubmitOptions) (*entities.UploadJob, error) {
+	checked := !opts.Force && !opts.DryRun
+	if checked {
+		s.submitMu.Lock()
+		defer s.submitMu.Unlock()
+
+		imported, err := s.repository.GetImportedBySHA256(spooled.sha256)
+		if err == nil {
+			return imported, errors.ErrUploadAlreadyImported
+		}
+		if err != errors.ErrUploadJobNotFound {
+			return nil, err
+		}
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	job := &entities.UploadJob{
+		ID:            id,
+		FileName:      fileName,
+		SHA256:        spooled.sha256,
+		Size:          spooled.size,
+		UploadedBy:    opts.UploadedBy,
+		Forced:        opts.Force,
+		Layout:        opts.Layout,
+		Format:        string(opts.Format),
+		Encoding:      string(opts.Encoding),
+		Mode:          string(opts.Mode),
+		SnapshotStart: opts.SnapshotStart,
+		SnapshotEnd:   opts.SnapshotEnd,
+		DryRun:        opts.DryRun,
+		State:         entities.UploadJobQueued,
+		CreatedAt:     time.Now(),
+	}
+
+	err = s.repository.Save(job)
+	if err == errors.ErrUploadAlreadyImported {
+		imported, lookupErr := s.repository.GetImportedBySHA256(spooled.sha256)
+		if lookupErr != nil {
+			return nil, err
+		}
+		return imported, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (s *uploadService) GetJob(id string) (*entities.UploadJob, error) {
+	return s.repository.Get(id)
+}
+
+// Follow subscribes to the progress of the job. The subscription ends once the job finishes,
+// right away when it already did. It subscribes before reading the state of the job, so a job
+// finishing in between is never missed
+func (s *uploadService) Follow(id string) (<-chan *entities.IngestionProgress, func(), error) {
+	events, cancel := s.broadcaster.Subscribe(id)
+
+	job, err := s.repository.Get(id)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	if job.State != entities.UploadJobQueued && job.State != entities.UploadJobRunning {
+		cancel()
+	}
+
+	return events, cancel, nil
+}
+
+// GetJobs returns a page of the jobs from the newest to the oldest
+func (s *uploadService) GetJobs(limit, offset int) ([]*entities.UploadJob, error) {
+	limit, offset = pageBounds(limit, offset)
+
+	return s.repository.GetAll(limit, offset)
+}
+
+// Rollback undoes what the job imported in a single transaction, along with the later jobs
+// depending on its rows when cascade is set. The rolled back jobs are kept in the history,
+// marked as rolled back, and their files can be imported again
+func (s *uploadService) Rollback(id string, cascade bool) ([]*entities.UploadJob, entities.RollbackSummary, error) {
+	var jobs []*entities.UploadJob
+	var summary entities.RollbackSummary
+
+	err := s.unitOfWork.Do(context.Background(), func(r *transaction.Repositories) error {
+		if err := r.Rollbacks.Lock(); err != nil {
+			return err
+		}
+
+		job, err := r.Batches.Get(id)
+		if err != nil {
+			return err
+		}
+		if err := checkRollback(job); err != nil {
+			return err
+		}
+
+		jobs = []*entities.UploadJob{job}
+		batchIDs := []string{job.ID}
+
+		// Dependents may have dependents of their own, so they are looked up until none is left
+		for {
+			dependents, err := r.Rollbacks.GetDependents(batchIDs)
+			if err != nil {
+				return err
+			}
+			if len(dependents) == 0 {
+				break
+			}
+
+			if slices.Contains(dependents, "") {
+				return errors.ErrUploadUntrackedLoad
+			}
+			if !cascade {
+				return fmt.Errorf("%w: %s", errors.ErrUploadHasDependents, strings.Join(dependents, ", "))
+			}
+
+			for _, dependentID := range dependents {
+				// A missing dependent is not the requested job missing, so it is not wrapped
+				dependent, err := r.Batches.Get(dependentID)
+				if err != nil {
+					return fmt.Errorf("dependent upload %s: %v", dependentID, err)
+				}
+				if err := checkRollback(dependent); err != nil {
+					return fmt.Errorf("upload %s: %w", dependentID, err)
+				}
+
+				jobs = append(jobs, dependent)
+				batchIDs = append(batchIDs, dependent.ID)
+			}
+		}
+
+		summary, err = r.Rollbacks.Rollback(batchIDs)
+		if err != nil {
+			return err
+		}
+
+		rolledBackAt := time.Now()
+		for _, job := range jobs {
+			job.State = entities.UploadJobRolledBack
+			job.RolledBackAt = rolledBackAt
+
+			if err := r.Batches.Save(job); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, entities.RollbackSummary{}, err
+	}
+
+	return jobs, summary, nil
+}
+
+// checkRollback tells whether the job may have written something that can be rolled back.
+// Failed jobs may have committed some of their lines before failing
+func checkRollback(job *entities.UploadJob) error {
+	switch {
+	case job.DryRun:
+		return errors.ErrUploadDryRunRollback
+	case job.State == entities.UploadJobRolledBack:
+		return errors.ErrUploadRolledBack
+	case job.State == entities.UploadJobQueued || job.State == entities.UploadJobRunning:
+		return errors.ErrUploadJobNotFinished
+	}
+
+	return nil
+}
+
+// Shutdown stops accepting new jobs and waits until the queued ones are processed.
+// When the context is done first, the running jobs are canceled and rolled back
+func (s *uploadService) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+func (s *uploadService) enqueue(task *uploadTask) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return errors.ErrUploadQueueClosed
+	}
+
+	select {
+	case s.queue <- task:
+		return nil
+	default:
+		return errors.ErrUploadQueueFull
+	}
+}
+
+func (s *uploadService) work() {
+	defer s.wg.Done()
+
+	for task := range s.queue {
+		s.run(task)
+	}
+}
+
+func (s *uploadService) run(task *uploadTask) {
+	job := task.job
+	defer os.Remove(task.path)
+
+	// A malformed file must fail its own job instead of taking the whole server down
+	defer func() {
+		if r := recover(); r != nil {
+			s.finish(job, fmt.Errorf("failed to process file: %v", r))
+		}
+	}()
+
+	job.State = entities.UploadJobRunning
+	job.StartedAt = time.Now()
+	if err := s.repository.Save(job); err != nil {
+		log.Printf("Failed to save upload job [%s]. Details: %s\n", job.ID, err.Error())
+	}
+
+	file, err := os.Open(task.path)
+	if err != nil {
+		s.finish(job, err)
+		return
+	}
+	defer file.Close()
+
+	// Every file of an archive is loaded on its own, so a bad file does not stop the others
+	job.Compression, err = visitUploadEntries(file, job.FileName, func(name string, entry io.Reader, size int64) error {
+		if task.opts.Mode == user.ModeSnapshot && len(job.Entries) > 0 {
+			return errors.ErrUploadSnapshotFiles
+		}
+
+		opts := task.opts
+		opts.BatchID = job.ID
+		opts.FileName = name
+		opts.Size = size
+
+		result, err := s.userService.LoadUsersDataFile(s.ctx, entry, opts)
+		addUploadEntry(job, name, result, err)
+
+		// The service is shutting down, the next files would be canceled as well
+		return s.ctx.Err()
+	})
+	if err == nil {
+		err = entriesError(job)
+	}
+
+	if len(job.Entries) == 1 && job.Entries[0].Format != "" {
+		job.Format = job.Entries[0].Format
+	}
+
+	s.finish(job, err)
+}
+
+// addUploadEntry records the result of an entry and adds it up to the job totals
+func addUploadEntry(job *entities.UploadJob, name string, result *user.UserFileResult, err error) {
+	if result == nil {
+		result = new(user.UserFileResult)
+	}
+
+	entry := &entities.UploadEntry{
+		Name:           name,
+		Format:         string(result.Format),
+		ProcessedLines: result.ProcessedLines,
+		AcceptedLines:  result.AcceptedLines,
+		RejectedLines:  result.RejectedLines,
+		SkippedLines:   result.SkippedLines,
+		Rejections:     result.Rejections,
+		Conflicts:      result.Conflicts,
+		Writes:         result.Writes,
+		Header:         result.Header,
+		OrderTotals:    result.OrderTotals,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	job.Entries = append(job.Entries, entry)
+
+	job.ProcessedLines += result.ProcessedLines
+	job.AcceptedLines += result.AcceptedLines
+	job.RejectedLines += result.RejectedLines
+	job.SkippedLines += result.SkippedLines
+	job.Writes.Merge(result.Writes)
+
+	addEntryFindings(job, name, result.Rejections, result.Conflicts)
+}
+
+// addEntryFindings adds the rejections and conflicts of an entry to the ones of the job
+func addEntryFindings(
+	job *entities.UploadJob,
+	name string,
+	rejections []*entities.LineRejection,
+	conflicts []*entities.LineConflict,
+) {
+	for _, rejection := range rejections {
+		if len(job.Rejections) >= user.MaxReportedRejections {
+			break
+		}
+
+		// Only the lines of archive entries need to tell which file they come from
+		rejection := *rejection
+		rejection.Entry = entryName(job, name)
+		job.Rejections = append(job.Rejections, &rejection)
+	}
+
+	for _, conflict := range conflicts {
+		if len(job.Conflicts) >= user.MaxReportedConflicts {
+			break
+		}
+
+		conflict := *conflict
+		conflict.Entry = entryName(job, name)
+		job.Conflicts = append(job.Conflicts, &conflict)
+	}
+}
+
+// entryName is the name the findings of an entry are listed under, empty for a single file
+func entryName(job *entities.UploadJob, name string) string {
+	if name == job.FileName {
+		return ""
+	}
+
+	return name
+}
+
+// entriesError tells whether any entry failed. A single file keeps its own error
+func entriesError(job *entities.UploadJob) error {
+	failed := make([]*entities.UploadEntry, 0)
+	for _, entry := range job.Entries {
+		if entry.Error != "" {
+			failed = append(failed, entry)
+		}
+	}
+
+	switch {
+	case len(failed) == 0:
+		return nil
+	case len(job.Entries) == 1:
+		return stderrors.New(failed[0].Error)
+	default:
+		return fmt.Errorf("%d of %d files failed, see their entries", len(failed), len(job.Entries))
+	}
+}
+
+// finish marks the job as done, or failed when err is not nil, and saves it
+func (s *uploadService) finish(job *entities.UploadJob, err error) {
+	job.State = entities.UploadJobDone
+	if err != nil {
+		job.State = entities.UploadJobFailed
+		job.Error = err.Error()
+	}
+	job.FinishedAt = time.Now()
+
+	if err := s.repository.Save(job); err != nil {
+		log.Printf("Failed to save upload job [%s]. Details: %s\n", job.ID, err.Error())
+	}
+
+	// The job is saved first, so the followers find it finished once their subscription ends
+	if s.broadcaster != nil {
+		s.broadcaster.Close(job.ID)
+	}
+}
+
+// spooledFile is the copy of an uploaded file waiting to be processed
+type spooledFile struct {
+	path   string
+	sha256 string
+	size   int64
+}
+
+// spool copies the file to disk, fingerprinting it on the way
+func (s *uploadService) spool(file io.Reader) (*spooledFile, error) {
+	tmp, err := os.CreateTemp(s.spoolDir, "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), file)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+
+	return &spooledFile{
+		path:   tmp.Name(),
+		sha256: hex.EncodeToString(hash.Sum(nil)),
+		size:   size,
+	}, nil
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
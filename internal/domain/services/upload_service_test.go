@@ -0,0 +1,1419 @@
+package services_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/progress"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/quarantine"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/transaction"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/upload"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/user"
+	domaintransaction "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/transaction"
+	domainupload "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/upload"
+	domainuser "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_FailInterruptedJobs_UploadService(t *testing.T) {
+	tests := []struct {
+		description   string
+		failed        int
+		err           error
+		isErrExpected bool
+	}{
+		{
+			description: "should fail the jobs left queued or running as interrupted",
+			failed:      2,
+		},
+		{
+			description:   "should return error",
+			err:           assert.AnError,
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mujr := upload.NewMockRepository(ctrl)
+			mujr.EXPECT().FailUnfinished(errors.ErrUploadInterrupted.Error(), gomock.Any()).Return(tt.failed, tt.err)
+
+			uploadService := services.NewUploadService(mujr, user.NewMockService(ctrl), nil, nil, 1, 1, t.TempDir())
+			defer uploadService.Shutdown(context.Background())
+
+			failed, err := uploadService.FailInterruptedJobs()
+
+			if tt.isErrExpected {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.failed, failed)
+		})
+	}
+}
+
+func Test_Submit_UploadService(t *testing.T) {
+	mockFileContent := "0000000070                              Palmer Prosacco00000007530000000003     1836.7420210308\n"
+
+	// The file is loaded with the options it was sent with, tagged with its job, name and size
+	mockOpts := gomock.Cond(func(opts domainuser.LoadOptions) bool {
+		return opts.Layout == "v1" &&
+			opts.FileName == "data_1.txt" &&
+			opts.BatchID != "" &&
+			opts.Size == int64(len(mockFileContent))
+	})
+
+	tests := []struct {
+		description     string
+		setMocks        func(mus *user.MockService)
+		expectedState   entities.UploadJobState
+		expectedResult  *domainuser.UserFileResult
+		isErrorExpected bool
+	}{
+		{
+			description: "should process the file in background and finish the job as done",
+			setMocks: func(mus *user.MockService) {
+				mus.
+					EXPECT().
+					LoadUsersDataFile(gomock.Any(), gomock.Any(), mockOpts).
+					DoAndReturn(func(ctx context.Context, file io.Reader, opts domainuser.LoadOptions) (*domainuser.UserFileResult, error) {
+						content, err := io.ReadAll(file)
+						assert.NoError(t, err)
+						assert.Equal(t, mockFileContent, string(content))
+
+						return &domainuser.UserFileResult{ProcessedLines: 1, AcceptedLines: 1}, nil
+					})
+			},
+			expectedState:  entities.UploadJobDone,
+			expectedResult: &domainuser.UserFileResult{ProcessedLines: 1, AcceptedLines: 1},
+		},
+		{
+			description: "should finish the job as failed when processing panics",
+			setMocks: func(mus *user.MockService) {
+				mus.
+					EXPECT().
+					LoadUsersDataFile(gomock.Any(), gomock.Any(), mockOpts).
+					DoAndReturn(func(ctx context.Context, file io.Reader, opts domainuser.LoadOptions) (*domainuser.UserFileResult, error) {
+						panic("slice bounds out of range")
+					})
+			},
+			expectedState:   entities.UploadJobFailed,
+			expectedResult:  &domainuser.UserFileResult{},
+			isErrorExpected: true,
+		},
+		{
+			description: "should finish the job as failed when the file is rolled back",
+			setMocks: func(mus *user.MockService) {
+				mus.
+					EXPECT().
+					LoadUsersDataFile(gomock.Any(), gomock.Any(), mockOpts).
+					Return(&domainuser.UserFileResult{ProcessedLines: 1}, assert.AnError)
+			},
+			expectedState:   entities.UploadJobFailed,
+			expectedResult:  &domainuser.UserFileResult{ProcessedLines: 1},
+			isErrorExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mus := user.NewMockService(ctrl)
+			mujr := upload.NewMockRepository(ctrl)
+			mujr.EXPECT().GetImportedBySHA256(gomock.Any()).Return(nil, errors.ErrUploadJobNotFound).AnyTimes()
+
+			var mu sync.Mutex
+			saved := make([]entities.UploadJob, 0)
+			mujr.
+				EXPECT().
+				Save(gomock.Any()).
+				DoAndReturn(func(job *entities.UploadJob) error {
+					mu.Lock()
+					defer mu.Unlock()
+					saved = append(saved, *job)
+					return nil
+				}).
+				AnyTimes()
+
+			tt.setMocks(mus)
+
+			// The followers of the job are let go once it finishes, whatever the outcome
+			mpb := progress.NewMockBroadcaster(ctrl)
+			closed := make([]string, 0)
+			mpb.EXPECT().Close(gomock.Any()).Do(func(batchID string) {
+				closed = append(closed, batchID)
+			})
+
+			uploadService := services.NewUploadService(mujr, mus, nil, mpb, 1, 1, t.TempDir())
+
+			job, err := uploadService.Submit(
+				"data_1.txt",
+				strings.NewReader(mockFileContent),
+				domainupload.SubmitOptions{LoadOptions: domainuser.LoadOptions{Layout: "v1"}},
+			)
+			assert.NoError(t, err)
+			assert.NotEmpty(t, job.ID)
+
+			assert.NoError(t, uploadService.Shutdown(context.Background()))
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			states := make([]entities.UploadJobState, 0)
+			for _, s := range saved {
+				states = append(states, s.State)
+			}
+			assert.Equal(t, []entities.UploadJobState{
+				entities.UploadJobQueued,
+				entities.UploadJobRunning,
+				tt.expectedState,
+			}, states)
+
+			last := saved[len(saved)-1]
+			assert.Equal(t, job.ID, last.ID)
+			assert.Equal(t, "data_1.txt", last.FileName)
+			assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte(mockFileContent))), last.SHA256)
+			assert.Equal(t, int64(len(mockFileContent)), last.Size)
+			assert.Equal(t, tt.expectedResult.ProcessedLines, last.ProcessedLines)
+			assert.Equal(t, tt.expectedResult.AcceptedLines, last.AcceptedLines)
+			assert.Equal(t, tt.expectedResult.RejectedLines, last.RejectedLines)
+			assert.False(t, last.StartedAt.IsZero())
+			assert.False(t, last.FinishedAt.IsZero())
+			if tt.isErrorExpected {
+				assert.NotEmpty(t, last.Error)
+			}
+			assert.Equal(t, []string{job.ID}, closed)
+		})
+	}
+}
+
+func Test_SubmitFile_UploadService(t *testing.T) {
+	mockFileContent := "0000000070                              Palmer Prosacco00000007530000000003     1836.7420210308\n"
+	mockSHA256 := fmt.Sprintf("%x", sha256.Sum256([]byte(mockFileContent)))
+
+	tests := []struct {
+		description   string
+		imported      bool
+		closed        bool
+		expectedErr   error
+		expectedMoved bool
+	}{
+		{
+			description:   "should move the file into the spool directory and load it in background",
+			expectedMoved: true,
+		},
+		{
+			description: "should leave a file already imported in place",
+			imported:    true,
+			expectedErr: errors.ErrUploadAlreadyImported,
+		},
+		{
+			description: "should move the file back when the queue refuses it",
+			closed:      true,
+			expectedErr: errors.ErrUploadQueueClosed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			path := filepath.Join(t.TempDir(), "a1b2c3.part")
+			assert.NoError(t, os.WriteFile(path, []byte(mockFileContent), 0o644))
+
+			mus := user.NewMockService(ctrl)
+			mujr := upload.NewMockRepository(ctrl)
+			if tt.imported {
+				mujr.EXPECT().GetImportedBySHA256(mockSHA256).Return(&entities.UploadJob{ID: "e5f6a7b8"}, nil)
+			} else {
+				mujr.EXPECT().GetImportedBySHA256(mockSHA256).Return(nil, errors.ErrUploadJobNotFound)
+			}
+
+			var mu sync.Mutex
+			saved := make([]entities.UploadJob, 0)
+			mujr.
+				EXPECT().
+				Save(gomock.Any()).
+				DoAndReturn(func(job *entities.UploadJob) error {
+					mu.Lock()
+					defer mu.Unlock()
+					saved = append(saved, *job)
+					return nil
+				}).
+				AnyTimes()
+
+			if tt.expectedMoved {
+				mus.
+					EXPECT().
+					LoadUsersDataFile(gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, file io.Reader, opts domainuser.LoadOptions) (*domainuser.UserFileResult, error) {
+						content, err := io.ReadAll(file)
+						assert.NoError(t, err)
+						assert.Equal(t, mockFileContent, string(content))
+
+						return &domainuser.UserFileResult{ProcessedLines: 1, AcceptedLines: 1}, nil
+					})
+			}
+
+			spoolDir := t.TempDir()
+			uploadService := services.NewUploadService(mujr, mus, nil, nil, 1, 1, spoolDir)
+			if tt.closed {
+				assert.NoError(t, uploadService.Shutdown(context.Background()))
+			}
+
+			_, err := uploadService.SubmitFile("users.txt", path, mockSHA256, domainupload.SubmitOptions{})
+			assert.NoError(t, uploadService.Shutdown(context.Background()))
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				content, err := os.ReadFile(path)
+				assert.NoError(t, err)
+				assert.Equal(t, mockFileContent, string(content))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.NoFileExists(t, path)
+
+			// The spooled file is removed once it is loaded
+			entries, err := os.ReadDir(spoolDir)
+			assert.NoError(t, err)
+			assert.Empty(t, entries)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			last := saved[len(saved)-1]
+			assert.Equal(t, entities.UploadJobDone, last.State)
+			assert.Equal(t, mockSHA256, last.SHA256)
+			assert.Equal(t, int64(len(mockFileContent)), last.Size)
+		})
+	}
+}
+
+func Test_Import_UploadService(t *testing.T) {
+	mockFileContent := "0000000070                              Palmer Prosacco00000007530000000003     1836.7420210308\n"
+	mockImported := &entities.UploadJob{ID: "e5f6a7b8", State: entities.UploadJobDone}
+
+	mockOpts := gomock.Cond(func(opts domainuser.LoadOptions) bool {
+		return opts.Layout == "v1" &&
+			opts.FileName == "data_1.txt" &&
+			opts.BatchID != "" &&
+			opts.Size == int64(len(mockFileContent))
+	})
+
+	tests := []struct {
+		description    string
+		setMocks       func(mus *user.MockService, mujr *upload.MockRepository)
+		expectedStates []entities.UploadJobState
+		expectedResult *domainuser.UserFileResult
+		expectedErr    error
+	}{
+		{
+			description: "should load the file as a job and finish it as done",
+			setMocks: func(mus *user.MockService, mujr *upload.MockRepository) {
+				mujr.EXPECT().GetImportedBySHA256(gomock.Any()).Return(nil, errors.ErrUploadJobNotFound)
+				mus.
+					EXPECT().
+					LoadUsersDataFile(gomock.Any(), gomock.Any(), mockOpts).
+					DoAndReturn(func(ctx context.Context, file io.Reader, opts domainuser.LoadOptions) (*domainuser.UserFileResult, error) {
+						// The file is read from the start again once fingerprinted
+						content, err := io.ReadAll(file)
+						assert.NoError(t, err)
+						assert.Equal(t, mockFileContent, string(content))
+
+						return &domainuser.UserFileResult{Format: domainuser.FormatFixedWidth, ProcessedLines: 1, AcceptedLines: 1}, nil
+					})
+			},
+			expectedStates: []entities.UploadJobState{entities.UploadJobQueued, entities.UploadJobRunning, entities.UploadJobDone},
+			expectedResult: &domainuser.UserFileResult{Format: domainuser.FormatFixedWidth, ProcessedLines: 1, AcceptedLines: 1},
+		},
+		{
+			description: "should finish the job as failed when the load fails",
+			setMocks: func(mus *user.MockService, mujr *upload.MockRepository) {
+				mujr.EXPECT().GetImportedBySHA256(gomock.Any()).Return(nil, errors.ErrUploadJobNotFound)
+				mus.
+					EXPECT().
+					LoadUsersDataFile(gomock.Any(), gomock.Any(), mockOpts).
+					Return(&domainuser.UserFileResult{ProcessedLines: 1}, assert.AnError)
+			},
+			expectedStates: []entities.UploadJobState{entities.UploadJobQueued, entities.UploadJobRunning, entities.UploadJobFailed},
+			expectedResult: &domainuser.UserFileResult{ProcessedLines: 1},
+			expectedErr:    assert.AnError,
+		},
+		{
+			description: "should refuse a file already imported, returning the job that imported it",
+			setMocks: func(mus *user.MockService, mujr *upload.MockRepository) {
+				mujr.EXPECT().GetImportedBySHA256(gomock.Any()).Return(mockImported, nil)
+			},
+			expectedStates: []entities.UploadJobState{},
+			expectedErr:    errors.ErrUploadAlreadyImported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mus := user.NewMockService(ctrl)
+			mujr := upload.NewMockRepository(ctrl)
+
+			saved := make([]entities.UploadJob, 0)
+			mujr.
+				EXPECT().
+				Save(gomock.Any()).
+				DoAndReturn(func(job *entities.UploadJob) error {
+					saved = append(saved, *job)
+					return nil
+				}).
+				AnyTimes()
+
+			tt.setMocks(mus, mujr)
+
+			uploadService := services.NewUploadService(mujr, mus, nil, nil, 1, 1, t.TempDir())
+			defer uploadService.Shutdown(context.Background())
+
+			job, result, err := uploadService.Import(
+				context.Background(),
+				"data_1.txt",
+				strings.NewReader(mockFileContent),
+				domainupload.SubmitOptions{LoadOptions: domainuser.LoadOptions{Layout: "v1"}, UploadedBy: "ingest"},
+			)
+
+			states := make([]entities.UploadJobState, 0)
+			for _, s := range saved {
+				states = append(states, s.State)
+			}
+			assert.Equal(t, tt.expectedStates, states)
+
+			if tt.expectedErr == errors.ErrUploadAlreadyImported {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Equal(t, mockImported, job)
+				return
+			}
+
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expectedResult, result)
+
+			last := saved[len(saved)-1]
+			assert.Equal(t, job.ID, last.ID)
+			assert.Equal(t, "ingest", last.UploadedBy)
+			assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte(mockFileContent))), last.SHA256)
+			assert.Equal(t, int64(len(mockFileContent)), last.Size)
+			assert.Equal(t, string(tt.expectedResult.Format), last.Format)
+			assert.Equal(t, tt.expectedResult.ProcessedLines, last.ProcessedLines)
+			assert.Len(t, last.Entries, 1)
+			assert.False(t, last.FinishedAt.IsZero())
+		})
+	}
+}
+
+func Test_Follow_UploadService(t *testing.T) {
+	tests := []struct {
+		description      string
+		setMocks         func(mujr *upload.MockRepository)
+		expectedCanceled bool
+		expectedErr      error
+	}{
+		{
+			description: "should follow a job still running",
+			setMocks: func(mujr *upload.MockRepository) {
+				mujr.EXPECT().Get("f1e2d3c4").Return(&entities.UploadJob{ID: "f1e2d3c4", State: entities.UploadJobRunning}, nil)
+			},
+		},
+		{
+			description: "should follow a job waiting in the queue",
+			setMocks: func(mujr *upload.MockRepository) {
+				mujr.EXPECT().Get("f1e2d3c4").Return(&entities.UploadJob{ID: "f1e2d3c4", State: entities.UploadJobQueued}, nil)
+			},
+		},
+		{
+			description: "should end the subscription right away when the job already finished",
+			setMocks: func(mujr *upload.MockRepository) {
+				mujr.EXPECT().Get("f1e2d3c4").Return(&entities.UploadJob{ID: "f1e2d3c4", State: entities.UploadJobDone}, nil)
+			},
+			expectedCanceled: true,
+		},
+		{
+			description: "should return error when the job does not exist",
+			setMocks: func(mujr *upload.MockRepository) {
+				mujr.EXPECT().Get("f1e2d3c4").Return(nil, errors.ErrUploadJobNotFound)
+			},
+			expectedCanceled: true,
+			expectedErr:      errors.ErrUploadJobNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mujr := upload.NewMockRepository(ctrl)
+			tt.setMocks(mujr)
+
+			canceled := false
+			mockEvents := make(chan *entities.IngestionProgress)
+			mpb := progress.NewMockBroadcaster(ctrl)
+			mpb.EXPECT().Subscribe("f1e2d3c4").Return((<-chan *entities.IngestionProgress)(mockEvents), func() {
+				canceled = true
+			})
+
+			uploadService := services.NewUploadService(mujr, user.NewMockService(ctrl), nil, mpb, 1, 1, t.TempDir())
+			defer uploadService.Shutdown(context.Background())
+
+			events, cancel, err := uploadService.Follow("f1e2d3c4")
+			assert.Equal(t, tt.expectedCanceled, canceled)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, (<-chan *entities.IngestionProgress)(mockEvents), events)
+			cancel()
+			assert.True(t, canceled)
+		})
+	}
+}
+
+func Test_Submit_UploadService_Archives(t *testing.T) {
+	// Every mocked file is accepted when its content is "ok" and fails otherwise
+	mockEntries := map[string]string{"data_1.txt": "ok", "data_2.txt": "ok"}
+
+	tests := []struct {
+		description         string
+		fileName            string
+		mode                domainuser.Mode
+		archive             func() []byte
+		expectedCompression string
+		expectedEntries     []string
+		expectedState       entities.UploadJobState
+		expectedError       string
+	}{
+		{
+			description:         "should load the decompressed file of a gzip upload",
+			fileName:            "data_1.txt.gz",
+			archive:             func() []byte { return gzipFile("", "ok") },
+			expectedCompression: "gzip",
+			expectedEntries:     []string{"data_1.txt"},
+			expectedState:       entities.UploadJobDone,
+		},
+		{
+			description:         "should name the gzip entry after its header",
+			fileName:            "upload.gz",
+			archive:             func() []byte { return gzipFile("data_9.txt", "ok") },
+			expectedCompression: "gzip",
+			expectedEntries:     []string{"data_9.txt"},
+			expectedState:       entities.UploadJobDone,
+		},
+		{
+			description: "should load every file of a zip upload, skipping folders and metadata",
+			fileName:    "data.zip",
+			archive: func() []byte {
+				return zipFiles(map[string]string{
+					"data_1.txt":            "ok",
+					"nested/":               "",
+					"nested/data_2.txt":     "ok",
+					"__MACOSX/._data_1.txt": "metadata",
+				})
+			},
+			expectedCompression: "zip",
+			expectedEntries:     []string{"data_1.txt", "nested/data_2.txt"},
+			expectedState:       entities.UploadJobDone,
+		},
+		{
+			description:         "should load every file of a tar.gz upload",
+			fileName:            "data.tar.gz",
+			archive:             func() []byte { return tarGzFiles(mockEntries) },
+			expectedCompression: "tar.gz",
+			expectedEntries:     []string{"data_1.txt", "data_2.txt"},
+			expectedState:       entities.UploadJobDone,
+		},
+		{
+			description:         "should keep loading the other files when one fails",
+			fileName:            "data.zip",
+			archive:             func() []byte { return zipFiles(map[string]string{"data_1.txt": "ok", "data_2.txt": "broken"}) },
+			expectedCompression: "zip",
+			expectedEntries:     []string{"data_1.txt", "data_2.txt"},
+			expectedState:       entities.UploadJobFailed,
+			expectedError:       "1 of 2 files failed, see their entries",
+		},
+		{
+			description:         "should only load the first file of a snapshot upload",
+			fileName:            "data.zip",
+			mode:                domainuser.ModeSnapshot,
+			archive:             func() []byte { return zipFiles(mockEntries) },
+			expectedCompression: "zip",
+			expectedEntries:     []string{"data_1.txt"},
+			expectedState:       entities.UploadJobFailed,
+			expectedError:       errors.ErrUploadSnapshotFiles.Error(),
+		},
+		{
+			description:         "should fail when the archive has no files",
+			fileName:            "data.zip",
+			archive:             func() []byte { return zipFiles(map[string]string{"nested/": ""}) },
+			expectedCompression: "zip",
+			expectedEntries:     []string{},
+			expectedState:       entities.UploadJobFailed,
+			expectedError:       errors.ErrUploadArchiveEmpty.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mus := user.NewMockService(ctrl)
+			mus.
+				EXPECT().
+				LoadUsersDataFile(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, file io.Reader, opts domainuser.LoadOptions) (*domainuser.UserFileResult, error) {
+					content, err := io.ReadAll(file)
+					assert.NoError(t, err)
+
+					result := &domainuser.UserFileResult{Format: domainuser.FormatFixedWidth, ProcessedLines: 1}
+					if string(content) != "ok" {
+						result.Reject(&entities.LineRejection{Line: 1, Field: "line", Value: string(content)})
+						return result, assert.AnError
+					}
+					result.AcceptedLines = 1
+
+					return result, nil
+				}).
+				AnyTimes()
+
+			var mu sync.Mutex
+			var last entities.UploadJob
+			mujr := upload.NewMockRepository(ctrl)
+			mujr.EXPECT().GetImportedBySHA256(gomock.Any()).Return(nil, errors.ErrUploadJobNotFound).AnyTimes()
+			mujr.
+				EXPECT().
+				Save(gomock.Any()).
+				DoAndReturn(func(job *entities.UploadJob) error {
+					mu.Lock()
+					defer mu.Unlock()
+					last = *job
+					return nil
+				}).
+				AnyTimes()
+
+			uploadService := services.NewUploadService(mujr, mus, nil, nil, 1, 1, t.TempDir())
+
+			opts := domainupload.SubmitOptions{LoadOptions: domainuser.LoadOptions{Mode: tt.mode}}
+			_, err := uploadService.Submit(tt.fileName, bytes.NewReader(tt.archive()), opts)
+			assert.NoError(t, err)
+			assert.NoError(t, uploadService.Shutdown(context.Background()))
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			names := make([]string, 0)
+			for _, entry := range last.Entries {
+				names = append(names, entry.Name)
+			}
+			assert.ElementsMatch(t, tt.expectedEntries, names)
+			assert.Equal(t, tt.expectedCompression, last.Compression)
+			assert.Equal(t, tt.expectedState, last.State)
+			assert.Equal(t, tt.expectedError, last.Error)
+			assert.Equal(t, len(tt.expectedEntries), last.ProcessedLines)
+			assert.Equal(t, last.ProcessedLines-last.RejectedLines, last.AcceptedLines)
+			for _, rejection := range last.Rejections {
+				assert.NotEmpty(t, rejection.Entry)
+			}
+		})
+	}
+}
+
+func gzipFile(name, content string) []byte {
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	gz.Name = name
+	gz.Write([]byte(content))
+	gz.Close()
+
+	return buf.Bytes()
+}
+
+// zipFiles archives the files in the order of their names
+func zipFiles(files map[string]string) []byte {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		w, err := zw.Create(name)
+		if err != nil {
+			panic(err)
+		}
+		w.Write([]byte(files[name]))
+	}
+	zw.Close()
+
+	return buf.Bytes()
+}
+
+func tarGzFiles(files map[string]string) []byte {
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+
+	return buf.Bytes()
+}
+
+func Test_Submit_UploadService_Closed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mus := user.NewMockService(ctrl)
+	mujr := upload.NewMockRepository(ctrl)
+	mujr.EXPECT().GetImportedBySHA256(gomock.Any()).Return(nil, errors.ErrUploadJobNotFound).AnyTimes()
+	mujr.EXPECT().Save(gomock.Any()).Return(nil).AnyTimes()
+
+	uploadService := services.NewUploadService(mujr, mus, nil, nil, 1, 1, t.TempDir())
+	assert.NoError(t, uploadService.Shutdown(context.Background()))
+
+	job, err := uploadService.Submit("data_1.txt", strings.NewReader(""), domainupload.SubmitOptions{})
+	assert.Nil(t, job)
+	assert.ErrorIs(t, err, errors.ErrUploadQueueClosed)
+}
+
+func Test_Submit_UploadService_Duplicates(t *testing.T) {
+	mockFileContent := "0000000070                              Palmer Prosacco00000007530000000003     1836.7420210308\n"
+	mockSHA256 := fmt.Sprintf("%x", sha256.Sum256([]byte(mockFileContent)))
+	importedJob := &entities.UploadJob{
+		ID:     "a1b2c3",
+		SHA256: mockSHA256,
+		State:  entities.UploadJobDone,
+	}
+
+	tests := []struct {
+		description    string
+		opts           domainupload.SubmitOptions
+		setMocks       func(mujr *upload.MockRepository, mus *user.MockService)
+		expectedJobID  string
+		expectedForced bool
+		expectedErr    error
+	}{
+		{
+			description: "should refuse a file already imported, returning the job that imported it",
+			setMocks: func(mujr *upload.MockRepository, mus *user.MockService) {
+				mujr.EXPECT().GetImportedBySHA256(mockSHA256).Return(importedJob, nil)
+			},
+			expectedJobID: importedJob.ID,
+			expectedErr:   errors.ErrUploadAlreadyImported,
+		},
+		{
+			description: "should load a file already imported when forced",
+			opts:        domainupload.SubmitOptions{Force: true, UploadedBy: "partner-a"},
+			setMocks: func(mujr *upload.MockRepository, mus *user.MockService) {
+				mujr.EXPECT().Save(gomock.Any()).Return(nil).AnyTimes()
+				mus.EXPECT().LoadUsersDataFile(gomock.Any(), gomock.Any(), gomock.Any()).Return(new(domainuser.UserFileResult), nil)
+			},
+			expectedForced: true,
+		},
+		{
+			description: "should not look for imported files on dry runs",
+			opts:        domainupload.SubmitOptions{LoadOptions: domainuser.LoadOptions{DryRun: true}},
+			setMocks: func(mujr *upload.MockRepository, mus *user.MockService) {
+				mujr.EXPECT().Save(gomock.Any()).Return(nil).AnyTimes()
+				mus.EXPECT().LoadUsersDataFile(gomock.Any(), gomock.Any(), gomock.Any()).Return(new(domainuser.UserFileResult), nil)
+			},
+		},
+		{
+			description: "should refuse a file a job saved meanwhile is importing, returning that job",
+			setMocks: func(mujr *upload.MockRepository, mus *user.MockService) {
+				runningJob := &entities.UploadJob{ID: "d4e5f6", SHA256: mockSHA256, State: entities.UploadJobRunning}
+
+				gomock.InOrder(
+					mujr.EXPECT().GetImportedBySHA256(mockSHA256).Return(nil, errors.ErrUploadJobNotFound),
+					mujr.EXPECT().Save(gomock.Any()).Return(errors.ErrUploadAlreadyImported),
+					mujr.EXPECT().GetImportedBySHA256(mockSHA256).Return(runningJob, nil),
+				)
+			},
+			expectedJobID: "d4e5f6",
+			expectedErr:   errors.ErrUploadAlreadyImported,
+		},
+		{
+			description: "should return error when the imported files can not be looked up",
+			setMocks: func(mujr *upload.MockRepository, mus *user.MockService) {
+				mujr.EXPECT().GetImportedBySHA256(mockSHA256).Return(nil, assert.AnError)
+			},
+			expectedErr: assert.AnError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mus := user.NewMockService(ctrl)
+			mujr := upload.NewMockRepository(ctrl)
+			tt.setMocks(mujr, mus)
+
+			spoolDir := t.TempDir()
+			uploadService := services.NewUploadService(mujr, mus, nil, nil, 1, 1, spoolDir)
+
+			job, err := uploadService.Submit("data_1.txt", strings.NewReader(mockFileContent), tt.opts)
+			assert.NoError(t, uploadService.Shutdown(context.Background()))
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedForced, job.Forced)
+				assert.Equal(t, tt.opts.UploadedBy, job.UploadedBy)
+			}
+
+			if tt.expectedJobID != "" {
+				assert.Equal(t, tt.expectedJobID, job.ID)
+			}
+
+			// The spooled copy of a refused file is removed right away
+			spooled, err := os.ReadDir(spoolDir)
+			assert.NoError(t, err)
+			assert.Empty(t, spooled)
+		})
+	}
+}
+
+func Test_Submit_UploadService_ConcurrentDuplicates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// The jobs are checked and saved as the repositories do, slowly enough for the uploads
+	// to check the file before any of them is saved if they were not kept apart
+	var mu sync.Mutex
+	saved := make([]*entities.UploadJob, 0)
+
+	mujr := upload.NewMockRepository(ctrl)
+	mujr.
+		EXPECT().
+		GetImportedBySHA256(gomock.Any()).
+		DoAndReturn(func(sha256 string) (*entities.UploadJob, error) {
+			mu.Lock()
+			var found *entities.UploadJob
+			if len(saved) > 0 {
+				found = saved[0]
+			}
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+			if found != nil {
+				return found, nil
+			}
+			return nil, errors.ErrUploadJobNotFound
+		}).
+		AnyTimes()
+	mujr.
+		EXPECT().
+		Save(gomock.Any()).
+		DoAndReturn(func(job *entities.UploadJob) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if job.State == entities.UploadJobQueued {
+				saved = append(saved, job)
+			}
+			return nil
+		}).
+		AnyTimes()
+
+	mus := user.NewMockService(ctrl)
+	mus.EXPECT().LoadUsersDataFile(gomock.Any(), gomock.Any(), gomock.Any()).Return(new(domainuser.UserFileResult), nil).AnyTimes()
+
+	uploadService := services.NewUploadService(mujr, mus, nil, nil, 1, 10, t.TempDir())
+
+	var wg sync.WaitGroup
+	refused := make(chan error, 5)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := uploadService.Submit("data_1.txt", strings.NewReader("ok"), domainupload.SubmitOptions{}); err != nil {
+				refused <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(refused)
+	assert.NoError(t, uploadService.Shutdown(context.Background()))
+
+	assert.Len(t, saved, 1)
+	assert.Len(t, refused, 4)
+	for err := range refused {
+		assert.ErrorIs(t, err, errors.ErrUploadAlreadyImported)
+	}
+}
+
+func Test_GetJobs_UploadService(t *testing.T) {
+	tests := []struct {
+		description    string
+		limit          int
+		offset         int
+		expectedLimit  int
+		expectedOffset int
+	}{
+		{
+			description:    "should use the default page size when no limit is given",
+			expectedLimit:  50,
+			expectedOffset: 0,
+		},
+		{
+			description:    "should cap the page size",
+			limit:          10000,
+			offset:         20,
+			expectedLimit:  500,
+			expectedOffset: 20,
+		},
+		{
+			description:    "should keep a valid page",
+			limit:          10,
+			offset:         -1,
+			expectedLimit:  10,
+			expectedOffset: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockJobs := []*entities.UploadJob{{ID: "a1b2c3"}}
+
+			mus := user.NewMockService(ctrl)
+			mujr := upload.NewMockRepository(ctrl)
+			mujr.EXPECT().GetAll(tt.expectedLimit, tt.expectedOffset).Return(mockJobs, nil)
+
+			uploadService := services.NewUploadService(mujr, mus, nil, nil, 1, 1, t.TempDir())
+			defer uploadService.Shutdown(context.Background())
+
+			jobs, err := uploadService.GetJobs(tt.limit, tt.offset)
+			assert.NoError(t, err)
+			assert.Equal(t, mockJobs, jobs)
+		})
+	}
+}
+
+func Test_GetJob_UploadService(t *testing.T) {
+	mockJob := &entities.UploadJob{
+		ID:    "a1b2c3",
+		State: entities.UploadJobRunning,
+	}
+
+	tests := []struct {
+		description string
+		setMocks    func(mujr *upload.MockRepository)
+		expectedErr error
+	}{
+		{
+			description: "should return the job",
+			setMocks: func(mujr *upload.MockRepository) {
+				mujr.EXPECT().Get(mockJob.ID).Return(mockJob, nil)
+			},
+			expectedErr: nil,
+		},
+		{
+			description: "should return error when job does not exist",
+			setMocks: func(mujr *upload.MockRepository) {
+				mujr.EXPECT().Get(mockJob.ID).Return(nil, errors.ErrUploadJobNotFound)
+			},
+			expectedErr: errors.ErrUploadJobNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mus := user.NewMockService(ctrl)
+			mujr := upload.NewMockRepository(ctrl)
+			tt.setMocks(mujr)
+
+			uploadService := services.NewUploadService(mujr, mus, nil, nil, 1, 1, t.TempDir())
+			defer uploadService.Shutdown(context.Background())
+
+			job, err := uploadService.GetJob(mockJob.ID)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, mockJob, job)
+		})
+	}
+}
+
+func Test_Rollback_UploadService(t *testing.T) {
+	newJob := func(id string, state entities.UploadJobState) *entities.UploadJob {
+		return &entities.UploadJob{ID: id, State: state}
+	}
+	mockSummary := entities.RollbackSummary{
+		Users:         entities.RollbackCounts{Removed: 1, Restored: 1},
+		OrderProducts: entities.RollbackCounts{Removed: 2},
+	}
+
+	tests := []struct {
+		description     string
+		cascade         bool
+		setMocks        func(mbr *upload.MockRepository, mrr *upload.MockRollbackRepository)
+		expectedJobs    []string
+		expectedSummary entities.RollbackSummary
+		expectedErr     error
+	}{
+		{
+			description: "should roll back a job nothing depends on and mark it as rolled back",
+			setMocks: func(mbr *upload.MockRepository, mrr *upload.MockRollbackRepository) {
+				mrr.EXPECT().Lock().Return(nil)
+				mbr.EXPECT().Get("a").Return(newJob("a", entities.UploadJobDone), nil)
+				mrr.EXPECT().GetDependents([]string{"a"}).Return([]string{}, nil)
+				mrr.EXPECT().Rollback([]string{"a"}).Return(mockSummary, nil)
+				mbr.EXPECT().Save(gomock.Cond(func(job *entities.UploadJob) bool {
+					return job.ID == "a" && job.State == entities.UploadJobRolledBack && !job.RolledBackAt.IsZero()
+				})).Return(nil)
+			},
+			expectedJobs:    []string{"a"},
+			expectedSummary: mockSummary,
+		},
+		{
+			description: "should roll back a failed job, which may have committed some lines",
+			setMocks: func(mbr *upload.MockRepository, mrr *upload.MockRollbackRepository) {
+				mrr.EXPECT().Lock().Return(nil)
+				mbr.EXPECT().Get("a").Return(newJob("a", entities.UploadJobFailed), nil)
+				mrr.EXPECT().GetDependents([]string{"a"}).Return([]string{}, nil)
+				mrr.EXPECT().Rollback([]string{"a"}).Return(mockSummary, nil)
+				mbr.EXPECT().Save(gomock.Any()).Return(nil)
+			},
+			expectedJobs:    []string{"a"},
+			expectedSummary: mockSummary,
+		},
+		{
+			description: "should refuse when later jobs depend on the job and cascade is not set",
+			setMocks: func(mbr *upload.MockRepository, mrr *upload.MockRollbackRepository) {
+				mrr.EXPECT().Lock().Return(nil)
+				mbr.EXPECT().Get("a").Return(newJob("a", entities.UploadJobDone), nil)
+				mrr.EXPECT().GetDependents([]string{"a"}).Return([]string{"b"}, nil)
+			},
+			expectedErr: errors.ErrUploadHasDependents,
+		},
+		{
+			description: "should roll back the dependents and their own dependents when cascading",
+			cascade:     true,
+			setMocks: func(mbr *upload.MockRepository, mrr *upload.MockRollbackRepository) {
+				mrr.EXPECT().Lock().Return(nil)
+				mbr.EXPECT().Get("a").Return(newJob("a", entities.UploadJobDone), nil)
+				mrr.EXPECT().GetDependents([]string{"a"}).Return([]string{"b"}, nil)
+				mbr.EXPECT().Get("b").Return(newJob("b", entities.UploadJobDone), nil)
+				mrr.EXPECT().GetDependents([]string{"a", "b"}).Return([]string{"c"}, nil)
+				mbr.EXPECT().Get("c").Return(newJob("c", entities.UploadJobFailed), nil)
+				mrr.EXPECT().GetDependents([]string{"a", "b", "c"}).Return([]string{}, nil)
+				mrr.EXPECT().Rollback([]string{"a", "b", "c"}).Return(mockSummary, nil)
+				mbr.EXPECT().Save(gomock.Any()).Return(nil).Times(3)
+			},
+			expectedJobs:    []string{"a", "b", "c"},
+			expectedSummary: mockSummary,
+		},
+		{
+			description: "should refuse to cascade to a job still running",
+			cascade:     true,
+			setMocks: func(mbr *upload.MockRepository, mrr *upload.MockRollbackRepository) {
+				mrr.EXPECT().Lock().Return(nil)
+				mbr.EXPECT().Get("a").Return(newJob("a", entities.UploadJobDone), nil)
+				mrr.EXPECT().GetDependents([]string{"a"}).Return([]string{"b"}, nil)
+				mbr.EXPECT().Get("b").Return(newJob("b", entities.UploadJobRunning), nil)
+			},
+			expectedErr: errors.ErrUploadJobNotFinished,
+		},
+		{
+			description: "should refuse when a load that is not an upload depends on the job",
+			cascade:     true,
+			setMocks: func(mbr *upload.MockRepository, mrr *upload.MockRollbackRepository) {
+				mrr.EXPECT().Lock().Return(nil)
+				mbr.EXPECT().Get("a").Return(newJob("a", entities.UploadJobDone), nil)
+				mrr.EXPECT().GetDependents([]string{"a"}).Return([]string{"", "b"}, nil)
+			},
+			expectedErr: errors.ErrUploadUntrackedLoad,
+		},
+		{
+			description: "should refuse a dry run",
+			setMocks: func(mbr *upload.MockRepository, mrr *upload.MockRollbackRepository) {
+				mrr.EXPECT().Lock().Return(nil)
+				mbr.EXPECT().Get("a").Return(&entities.UploadJob{ID: "a", State: entities.UploadJobDone, DryRun: true}, nil)
+			},
+			expectedErr: errors.ErrUploadDryRunRollback,
+		},
+		{
+			description: "should refuse a job already rolled back",
+			setMocks: func(mbr *upload.MockRepository, mrr *upload.MockRollbackRepository) {
+				mrr.EXPECT().Lock().Return(nil)
+				mbr.EXPECT().Get("a").Return(newJob("a", entities.UploadJobRolledBack), nil)
+			},
+			expectedErr: errors.ErrUploadRolledBack,
+		},
+		{
+			description: "should return error when the job does not exist",
+			setMocks: func(mbr *upload.MockRepository, mrr *upload.MockRollbackRepository) {
+				mrr.EXPECT().Lock().Return(nil)
+				mbr.EXPECT().Get("a").Return(nil, errors.ErrUploadJobNotFound)
+			},
+			expectedErr: errors.ErrUploadJobNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mus := user.NewMockService(ctrl)
+			mujr := upload.NewMockRepository(ctrl)
+			mbr := upload.NewMockRepository(ctrl)
+			mrr := upload.NewMockRollbackRepository(ctrl)
+			tt.setMocks(mbr, mrr)
+
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			muow.
+				EXPECT().
+				Do(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+					return fn(&domaintransaction.Repositories{
+						Batches:   mbr,
+						Rollbacks: mrr,
+					})
+				})
+
+			uploadService := services.NewUploadService(mujr, mus, muow, nil, 1, 1, t.TempDir())
+			defer uploadService.Shutdown(context.Background())
+
+			jobs, summary, err := uploadService.Rollback("a", tt.cascade)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Nil(t, jobs)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedSummary, summary)
+
+			ids := make([]string, 0, len(jobs))
+			for _, job := range jobs {
+				ids = append(ids, job.ID)
+				assert.Equal(t, entities.UploadJobRolledBack, job.State)
+			}
+			assert.Equal(t, tt.expectedJobs, ids)
+		})
+	}
+}
+
+func Test_ReprocessRejectedLines_UploadService(t *testing.T) {
+	newJob := func() *entities.UploadJob {
+		return &entities.UploadJob{
+			ID:             "a",
+			FileName:       "users.zip",
+			Layout:         "default",
+			State:          entities.UploadJobDone,
+			ProcessedLines: 5,
+			AcceptedLines:  2,
+			RejectedLines:  3,
+			Rejections: []*entities.LineRejection{
+				{Entry: "data_1.txt", Line: 2, Field: "order_date", Reason: "order 753 already has the date 2021-03-07"},
+				{Entry: "data_1.txt", Line: 4, Field: "line", Reason: "line has 26 characters, expected 95"},
+				{Entry: "data_2.csv", Line: 3, Field: "order_date", Reason: "order 798 already has the date 2021-11-15"},
+			},
+			Entries: []*entities.UploadEntry{
+				{Name: "data_1.txt", Format: "fixed-width", ProcessedLines: 3, AcceptedLines: 1, RejectedLines: 2},
+				{Name: "data_2.csv", Format: "csv", ProcessedLines: 2, AcceptedLines: 1, RejectedLines: 1},
+				{Name: "data_3.txt", Format: "fixed-width", ProcessedLines: 1, AcceptedLines: 1},
+			},
+		}
+	}
+
+	mockRejection := &entities.LineRejection{Line: 4, Field: "line", Reason: "line has 26 characters, expected 95"}
+	mockWrites := entities.WriteSummary{Orders: entities.WriteCounts{Updated: 1}}
+
+	tests := []struct {
+		description       string
+		job               *entities.UploadJob
+		setMocks          func(mus *user.MockService, mujr *upload.MockRepository)
+		expectedAccepted  int
+		expectedRejected  int
+		expectedEntries   [][2]int
+		expectedRejection []*entities.LineRejection
+		expectedErr       error
+	}{
+		{
+			description: "should reprocess every file with rejected lines and move the stored ones to the accepted lines",
+			job:         newJob(),
+			setMocks: func(mus *user.MockService, mujr *upload.MockRepository) {
+				mus.EXPECT().ReprocessRejectedLines(gomock.Any(), domainuser.LoadOptions{
+					Layout:   "default",
+					Format:   domainuser.FormatFixedWidth,
+					BatchID:  "a",
+					FileName: "data_1.txt",
+				}).Return(&domainuser.UserFileResult{
+					Format:         domainuser.FormatFixedWidth,
+					ProcessedLines: 2,
+					AcceptedLines:  1,
+					RejectedLines:  1,
+					Rejections:     []*entities.LineRejection{mockRejection},
+					Writes:         mockWrites,
+				}, nil)
+				mus.EXPECT().ReprocessRejectedLines(gomock.Any(), domainuser.LoadOptions{
+					Layout:   "default",
+					Format:   domainuser.FormatCSV,
+					BatchID:  "a",
+					FileName: "data_2.csv",
+				}).Return(&domainuser.UserFileResult{
+					Format:         domainuser.FormatCSV,
+					ProcessedLines: 1,
+					AcceptedLines:  1,
+					Writes:         mockWrites,
+				}, nil)
+				mujr.EXPECT().Save(gomock.Any()).Return(nil)
+			},
+			expectedAccepted: 4,
+			expectedRejected: 1,
+			expectedEntries:  [][2]int{{2, 1}, {2, 0}, {1, 0}},
+			expectedRejection: []*entities.LineRejection{
+				{Entry: "data_1.txt", Line: 4, Field: "line", Reason: "line has 26 characters, expected 95"},
+			},
+		},
+		{
+			description: "should keep the lines stored before a file failed",
+			job:         newJob(),
+			setMocks: func(mus *user.MockService, mujr *upload.MockRepository) {
+				mus.EXPECT().ReprocessRejectedLines(gomock.Any(), gomock.Any()).Return(&domainuser.UserFileResult{
+					ProcessedLines: 1,
+					AcceptedLines:  1,
+				}, assert.AnError)
+				mujr.EXPECT().Save(gomock.Cond(func(job *entities.UploadJob) bool {
+					return job.AcceptedLines == 3 && job.RejectedLines == 2
+				})).Return(nil)
+			},
+			expectedErr: assert.AnError,
+		},
+		{
+			description: "should refuse a dry run, which keeps no rejected lines",
+			job:         &entities.UploadJob{ID: "a", State: entities.UploadJobDone, DryRun: true},
+			expectedErr: errors.ErrUploadDryRunRejected,
+		},
+		{
+			description: "should refuse a job already rolled back",
+			job:         &entities.UploadJob{ID: "a", State: entities.UploadJobRolledBack},
+			expectedErr: errors.ErrUploadRolledBack,
+		},
+		{
+			description: "should refuse a job still running",
+			job:         &entities.UploadJob{ID: "a", State: entities.UploadJobRunning},
+			expectedErr: errors.ErrUploadJobNotFinished,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mus := user.NewMockService(ctrl)
+			mujr := upload.NewMockRepository(ctrl)
+			mujr.EXPECT().Get("a").Return(tt.job, nil)
+			if tt.setMocks != nil {
+				tt.setMocks(mus, mujr)
+			}
+
+			uploadService := services.NewUploadService(mujr, mus, nil, nil, 1, 1, t.TempDir())
+			defer uploadService.Shutdown(context.Background())
+
+			job, result, err := uploadService.ReprocessRejectedLines("a")
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Nil(t, job)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, 3, result.ProcessedLines)
+			assert.Equal(t, 2, result.AcceptedLines)
+			assert.Equal(t, 1, result.RejectedLines)
+			assert.Equal(t, tt.expectedRejection, result.Rejections)
+
+			assert.Equal(t, tt.expectedAccepted, job.AcceptedLines)
+			assert.Equal(t, tt.expectedRejected, job.RejectedLines)
+			assert.Equal(t, tt.expectedRejection, job.Rejections)
+			for i, entry := range job.Entries {
+				assert.Equal(t, tt.expectedEntries[i], [2]int{entry.AcceptedLines, entry.RejectedLines})
+			}
+		})
+	}
+}
+
+func Test_ReprocessRejectedLines_UploadService_Concurrent(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	job := &entities.UploadJob{
+		ID:       "a",
+		FileName: "users.txt",
+		State:    entities.UploadJobDone,
+		Entries:  []*entities.UploadEntry{{Name: "users.txt", Format: "fixed-width", RejectedLines: 1}},
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	mus := user.NewMockService(ctrl)
+	mus.EXPECT().ReprocessRejectedLines(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, opts domainuser.LoadOptions) (*domainuser.UserFileResult, error) {
+			close(started)
+			<-release
+			return new(domainuser.UserFileResult), nil
+		})
+	mujr := upload.NewMockRepository(ctrl)
+	mujr.EXPECT().Get("a").Return(job, nil)
+	mujr.EXPECT().Save(job).Return(nil)
+
+	uploadService := services.NewUploadService(mujr, mus, nil, nil, 1, 1, t.TempDir())
+	defer uploadService.Shutdown(context.Background())
+
+	done := make(chan error)
+	go func() {
+		_, _, err := uploadService.ReprocessRejectedLines("a")
+		done <- err
+	}()
+
+	<-started
+	_, _, err := uploadService.ReprocessRejectedLines("a")
+	assert.ErrorIs(t, err, errors.ErrUploadReprocessing)
+
+	close(release)
+	assert.NoError(t, <-done)
+}
+
+func Test_WriteRejectedLines_UploadService(t *testing.T) {
+	newLine := func(fileName string, line int, content string) *entities.RejectedLine {
+		return &entities.RejectedLine{BatchID: "a", FileName: fileName, Line: line, Content: content}
+	}
+
+	tests := []struct {
+		description string
+		job         *entities.UploadJob
+		setMocks    func(mqr *quarantine.MockRepository)
+		expected    string
+		expectedErr error
+	}{
+		{
+			description: "should write the lines of every file in order, csv lines under their header",
+			job: &entities.UploadJob{
+				ID:       "a",
+				FileName: "users.zip",
+				State:    entities.UploadJobDone,
+				Entries: []*entities.UploadEntry{
+					{Name: "data_1.txt", Format: "fixed-width", RejectedLines: 2},
+					{Name: "data_2.txt", Format: "fixed-width"},
+					{Name: "data_3.csv", Format: "csv", RejectedLines: 1},
+				},
+			},
+			setMocks: func(mqr *quarantine.MockRepository) {
+				mqr.EXPECT().Get("a", "data_1.txt", 0, 500).Return([]*entities.RejectedLine{
+					newLine("data_1.txt", 2, "0000000070 Palmer Prosacco"),
+					newLine("data_1.txt", 5, ""),
+				}, nil)
+				mqr.EXPECT().Get("a", "data_3.csv", 0, 500).Return([]*entities.RejectedLine{
+					newLine("data_3.csv", 3, "75,Bobbie Batz,798,2,1578.57,2021-11-16"),
+				}, nil)
+			},
+			expected: "0000000070 Palmer Prosacco\n" +
+				"user_id,user_name,order_id,product_id,product_value,order_date\n" +
+				"75,Bobbie Batz,798,2,1578.57,2021-11-16\n",
+		},
+		{
+			description: "should return error when the lines can not be read",
+			job: &entities.UploadJob{
+				ID:       "a",
+				FileName: "users.txt",
+				State:    entities.UploadJobDone,
+				Entries:  []*entities.UploadEntry{{Name: "users.txt", Format: "fixed-width", RejectedLines: 1}},
+			},
+			setMocks: func(mqr *quarantine.MockRepository) {
+				mqr.EXPECT().Get("a", "users.txt", 0, 500).Return(nil, assert.AnError)
+			},
+			expectedErr: assert.AnError,
+		},
+		{
+			description: "should refuse a dry run, which keeps no rejected lines",
+			job:         &entities.UploadJob{ID: "a", State: entities.UploadJobDone, DryRun: true},
+			expectedErr: errors.ErrUploadDryRunRejected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mus := user.NewMockService(ctrl)
+			mujr := upload.NewMockRepository(ctrl)
+			mqr := quarantine.NewMockRepository(ctrl)
+			if tt.setMocks != nil {
+				tt.setMocks(mqr)
+			}
+
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			muow.
+				EXPECT().
+				View(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+					return fn(&domaintransaction.Repositories{Quarantine: mqr})
+				}).
+				AnyTimes()
+
+			uploadService := services.NewUploadService(mujr, mus, muow, nil, 1, 1, t.TempDir())
+			defer uploadService.Shutdown(context.Background())
+
+			w := new(bytes.Buffer)
+			err := uploadService.WriteRejectedLines(tt.job, w)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, w.String())
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/transaction"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+// storedOrder is what is known about an order before a line is stored, found tells whether it exists
+type storedOrder struct {
+	found  bool
+	userID uint
+	date   time.Time
+}
+
+// writeReport is what storing a batch did: the writes of the stored lines, the lines
+// rejected by a conflict policy, every conflict found and, for delta files, the totals of
+// the orders changed
+type writeReport struct {
+	writes    entities.WriteSummary
+	rejected  []*entities.RejectedLine
+	conflicts []*entities.LineConflict
+	totals    map[uint]*entities.OrderTotal
+}
+
+func (w *writeReport) merge(other *writeReport) {
+	w.writes.Merge(other.writes)
+	w.rejected = append(w.rejected, other.rejected...)
+	w.conflicts = append(w.conflicts, other.conflicts...)
+	w.addTotals(other.totals)
+}
+
+// addTotals keeps the totals of orders changed later, keeping the total an order had
+// before it was first changed
+func (w *writeReport) addTotals(totals map[uint]*entities.OrderTotal) {
+	for orderID, total := range totals {
+		if w.totals == nil {
+			w.totals = make(map[uint]*entities.OrderTotal)
+		}
+
+		if kept, ok := w.totals[orderID]; ok {
+			kept.Current = total.Current
+			kept.Removed = total.Removed
+			continue
+		}
+		w.totals[orderID] = total
+	}
+}
+
+// addConflicts keeps the conflicts of a line, rejecting the line with its text when a policy
+// says so, and tells whether it was rejected
+func (w *writeReport) addConflicts(conflicts []*entities.LineConflict, text string) bool {
+	w.conflicts = append(w.conflicts, conflicts...)
+
+	rejected := &entities.RejectedLine{Content: text}
+	for _, conflict := range conflicts {
+		if conflict.Policy == entities.ConflictReject {
+			rejected.Line = conflict.Line
+			rejected.Rejections = append(rejected.Rejections, &entities.LineRejection{
+				Line:   conflict.Line,
+				Field:  conflict.Field,
+				Value:  conflict.Incoming,
+				Reason: conflict.Reason,
+			})
+		}
+	}
+
+	if len(rejected.Rejections) == 0 {
+		return false
+	}
+	w.rejected = append(w.rejected, rejected)
+
+	return true
+}
+
+// resolvedLine is what is written for a line once its conflicts are resolved
+type resolvedLine struct {
+	userName    string
+	orderUserID uint
+	orderDate   time.Time
+	conflicts   []*entities.LineConflict
+}
+
+// resolveLine compares the line with the stored user name, nil when the user does not exist,
+// and the stored order, returning the values the policies leave to be written. The line must
+// not be written at all when a conflict policy rejects it
+func resolveLine(
+	policies entities.ConflictPolicies,
+	userData *user.UserFileData,
+	storedName *string,
+	order *storedOrder,
+) *resolvedLine {
+	resolved := &resolvedLine{
+		userName:    userData.UserName,
+		orderUserID: userData.UserID,
+		orderDate:   userData.OrderDate,
+		conflicts:   make([]*entities.LineConflict, 0),
+	}
+
+	userID := strconv.FormatUint(uint64(userData.UserID), 10)
+	orderID := strconv.FormatUint(uint64(userData.OrderID), 10)
+
+	// keep records the conflict and tells whether the stored value stays
+	keep := func(conflict *entities.LineConflict) bool {
+		resolved.conflicts = append(resolved.conflicts, conflict)
+		return conflict.Policy != entities.ConflictLastWriteWins
+	}
+
+	if order.found && order.userID != userData.UserID {
+		conflict := newLineConflict(
+			policies,
+			entities.ConflictOrderUser,
+			userData.Line,
+			orderID,
+			strconv.FormatUint(uint64(order.userID), 10),
+			userID,
+		)
+		if keep(conflict) {
+			resolved.orderUserID = order.userID
+		}
+	}
+
+	if order.found && !order.date.Equal(userData.OrderDate) {
+		conflict := newLineConflict(
+			policies,
+			entities.ConflictOrderDate,
+			userData.Line,
+			orderID,
+			order.date.Format(time.DateOnly),
+			userData.OrderDate.Format(time.DateOnly),
+		)
+		if keep(conflict) {
+			resolved.orderDate = order.date
+		}
+	}
+
+	if storedName != nil && *storedName != userData.UserName {
+		conflict := newLineConflict(
+			policies,
+			entities.ConflictUserName,
+			userData.Line,
+			userID,
+			*storedName,
+			userData.UserName,
+		)
+		if keep(conflict) {
+			resolved.userName = *storedName
+		}
+	}
+
+	return resolved
+}
+
+// newLineConflict describes a conflict of the given type on the order or user with the key,
+// with the policy it is resolved by
+func newLineConflict(
+	policies entities.ConflictPolicies,
+	conflictType entities.ConflictType,
+	line int,
+	key, existing, incoming string,
+) *entities.LineConflict {
+	conflict := &entities.LineConflict{
+		Line:     line,
+		Type:     conflictType,
+		Value:    key,
+		Existing: existing,
+		Incoming: incoming,
+		Policy:   policies.For(conflictType),
+	}
+	describeConflict(conflict)
+
+	return conflict
+}
+
+// describeConflict sets the field and the reason of a conflict from its type
+func describeConflict(conflict *entities.LineConflict) {
+	switch conflict.Type {
+	case entities.ConflictOrderUser:
+		conflict.Field = entities.FieldUserID
+		conflict.Reason = fmt.Sprintf("order %s already belongs to user %s", conflict.Value, conflict.Existing)
+	case entities.ConflictOrderDate:
+		conflict.Field = entities.FieldOrderDate
+		conflict.Reason = fmt.Sprintf("order %s already has the date %s", conflict.Value, conflict.Existing)
+	case entities.ConflictUserName:
+		conflict.Field = entities.FieldUserName
+		conflict.Reason = fmt.Sprintf("user %s is already named %s", conflict.Value, conflict.Existing)
+	}
+}
+
+// sortConflicts orders conflicts by line, keeping the order of the ones of the same line
+func sortConflicts(conflicts []*entities.LineConflict) {
+	slices.SortStableFunc(conflicts, func(a, b *entities.LineConflict) int {
+		return a.Line - b.Line
+	})
+}
+
+// getStoredUserName returns the name of the user of the line, or nil when it does not exist
+func getStoredUserName(r *transaction.Repositories, userData *user.UserFileData) (*string, error) {
+	stored, err := r.Users.Get(userData.UserID)
+	if err == errors.ErrUserNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("line %d: failed to look up user: %w", userData.Line, err)
+	}
+
+	return &stored.Name, nil
+}
+
+// getStoredOrder returns the order of the line as it is stored
+func getStoredOrder(r *transaction.Repositories, userData *user.UserFileData) (*storedOrder, error) {
+	stored, err := r.Orders.Get(userData.OrderID)
+	if err == errors.ErrOrderNotFound {
+		return new(storedOrder), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("line %d: failed to look up order: %w", userData.Line, err)
+	}
+
+	return &storedOrder{found: true, userID: stored.UserID, date: stored.Date}, nil
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+// controlDecoder recognizes the header and trailer records of a fixed-width file around the
+// data lines read by the decoder it wraps. The header must be the first line that is not blank
+// and the trailer the last one, so a file cut short fails instead of loading fewer lines. A
+// line starting as the trailer does is only taken as the trailer when no other line follows it,
+// so a data line with the same start does not end the file early
+type controlDecoder struct {
+	decoder  user.Decoder
+	layout   *entities.RecordLayout
+	location *time.Location
+
+	// started tells a line that is not blank was read, ended that the trailer was
+	started bool
+	ended   bool
+	// queue holds the records read ahead of a line starting as the trailer does
+	queue []*user.RawRecord
+}
+
+func newControlDecoder(decoder user.Decoder, layout *entities.RecordLayout, location *time.Location) *controlDecoder {
+	return &controlDecoder{
+		decoder:  decoder,
+		layout:   layout,
+		location: location,
+	}
+}
+
+func (d *controlDecoder) Fields() []*entities.LayoutField {
+	return d.decoder.Fields()
+}
+
+func (d *controlDecoder) Next() (*user.RawRecord, error) {
+	record, err := d.read()
+	if err == io.EOF {
+		switch {
+		case d.layout.Header != nil && !d.started:
+			return nil, errors.ErrMissingHeader
+		case d.layout.Trailer != nil && !d.ended:
+			return nil, errors.ErrMissingTrailer
+		}
+	}
+	if err != nil || record.Skipped {
+		return record, err
+	}
+
+	first := !d.started
+	d.started = true
+
+	if first && d.layout.Header != nil {
+		if !strings.HasPrefix(record.Text, d.layout.Header.Identifier) {
+			return nil, fmt.Errorf("line %d: %w", record.Line, errors.ErrMissingHeader)
+		}
+
+		values, err := controlValues(record.Text, "header", d.layout.Header)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", record.Line, err)
+		}
+
+		header, err := parseFileHeader(values, d.layout.Header, d.location)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", record.Line, err)
+		}
+
+		return &user.RawRecord{Line: record.Line, Text: record.Text, Header: header}, nil
+	}
+
+	if d.layout.Trailer != nil && strings.HasPrefix(record.Text, d.layout.Trailer.Identifier) {
+		last, err := d.isLast()
+		if err != nil {
+			return nil, err
+		}
+		if !last {
+			return record, nil
+		}
+
+		d.ended = true
+
+		values, err := controlValues(record.Text, "trailer", d.layout.Trailer)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", record.Line, err)
+		}
+
+		trailer, err := parseFileTrailer(values, d.layout.Trailer)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", record.Line, err)
+		}
+
+		return &user.RawRecord{Line: record.Line, Text: record.Text, Trailer: trailer}, nil
+	}
+
+	return record, nil
+}
+
+// read returns the records read ahead, in order, before reading the next one from the decoder
+func (d *controlDecoder) read() (*user.RawRecord, error) {
+	if len(d.queue) > 0 {
+		record := d.queue[0]
+		d.queue = d.queue[1:]
+		return record, nil
+	}
+
+	return d.decoder.Next()
+}
+
+// isLast tells whether only blank lines are left in the file, reading ahead up to the next
+// line that is not blank
+func (d *controlDecoder) isLast() (bool, error) {
+	for _, record := range d.queue {
+		if !record.Skipped {
+			return false, nil
+		}
+	}
+
+	for {
+		record, err := d.decoder.Next()
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		d.queue = append(d.queue, record)
+		if !record.Skipped {
+			return false, nil
+		}
+	}
+}
+
+// controlValues cuts the fields of a header or trailer line
+func controlValues(text, kind string, control *entities.ControlRecord) (map[string]string, error) {
+	if !utf8.ValidString(text) {
+		return nil, fmt.Errorf("%s is not valid UTF-8, send the encoding of the file", kind)
+	}
+
+	if lineLength, length := control.LineLength(), utf8.RuneCountInString(text); length < lineLength {
+		return nil, fmt.Errorf("%s has %d characters, expected %d", kind, length, lineLength)
+	}
+
+	values := make(map[string]string, len(control.Fields))
+	for _, field := range control.Fields {
+		values[field.Name] = field.Extract(text)
+	}
+
+	return values, nil
+}
+
+// parseFileHeader reads the header fields, dates in the location
+func parseFileHeader(
+	values map[string]string,
+	control *entities.ControlRecord,
+	location *time.Location,
+) (*entities.FileHeader, error) {
+	header := new(entities.FileHeader)
+
+	for _, field := range control.Fields {
+		value := values[field.Name]
+
+		switch field.Name {
+		case entities.FieldFileDate:
+			date, err := parseDate(value, field, location)
+			if err != nil {
+				return nil, fmt.Errorf("header field %s %q %s", field.Name, value, err.Error())
+			}
+			header.Date = date
+		case entities.FieldSender:
+			header.Sender = value
+		case entities.FieldLayoutVersion:
+			header.LayoutVersion = value
+		}
+	}
+
+	return header, nil
+}
+
+// parseFileTrailer reads the trailer fields
+func parseFileTrailer(values map[string]string, control *entities.ControlRecord) (*entities.FileTrailer, error) {
+	trailer := new(entities.FileTrailer)
+
+	for _, field := range control.Fields {
+		value := values[field.Name]
+
+		switch field.Name {
+		case entities.FieldRecordCount:
+			count, err := strconv.ParseUint(value, 10, 31)
+			if err != nil {
+				return nil, fmt.Errorf("trailer field %s %q must be a non-negative integer", field.Name, value)
+			}
+			trailer.RecordCount = int(count)
+		case entities.FieldValueSum:
+			sum, err := parseMoney(value, field.Decimals)
+			if err != nil {
+				return nil, fmt.Errorf("trailer field %s %q %s", field.Name, value, err.Error())
+			}
+			trailer.ValueSum = &sum
+		}
+	}
+
+	return trailer, nil
+}
+
+// checkTrailer compares the trailer with the records read before it and the sum of their values
+func checkTrailer(trailer *entities.FileTrailer, records int, values entities.Money) error {
+	if trailer.RecordCount != records {
+		return fmt.Errorf("%w: it counts %d records, %d were read", errors.ErrTrailerMismatch, trailer.RecordCount, records)
+	}
+
+	if trailer.ValueSum != nil && *trailer.ValueSum != values {
+		return fmt.Errorf("%w: its values add up to %s, the values read to %s", errors.ErrTrailerMismatch, trailer.ValueSum, values)
+	}
+
+	return nil
+}
+
+// recordValue is the product value of a record, parsed even when another field of the record
+// was rejected, so the values add up to what the trailer of the file sums. Zero when the record
+// has no readable value
+func recordValue(userData *user.UserFileData, record *user.RawRecord, field *entities.LayoutField) entities.Money {
+	if userData != nil {
+		return userData.ProductValue
+	}
+
+	if record.Fields == nil || field == nil {
+		return 0
+	}
+
+	value, err := parseMoney(record.Fields[field.Name], field.Decimals)
+	if err != nil {
+		return 0
+	}
+
+	return value
+}
@@ -0,0 +1,679 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+// decoderFactory builds the decoder of a format. The layout only matters for fixed-width files,
+// the mode tells whether the records carry an operation, as the ones of delta files do, and
+// maxLineSize is the longest line, csv record or JSON array element, zero for the default one
+type decoderFactory func(file io.Reader, layout *entities.RecordLayout, mode user.Mode, maxLineSize int) user.Decoder
+
+var decoderFactories = map[user.Format]decoderFactory{
+	user.FormatFixedWidth: newFixedWidthDecoder,
+	user.FormatCSV:        newCSVDecoder,
+	user.FormatNDJSON:     newNDJSONDecoder,
+	user.FormatJSON:       newJSONArrayDecoder,
+}
+
+// textRecordFields describes the fields of the formats where values are delimited instead of
+// placed by offset. Dates are written as YYYY-MM-DD or as RFC 3339 timestamps
+var textRecordFields = []*entities.LayoutField{
+	{Name: entities.FieldUserID, Type: entities.FieldInteger},
+	{Name: entities.FieldUserName, Type: entities.FieldString},
+	{Name: entities.FieldOrderID, Type: entities.FieldInteger},
+	{Name: entities.FieldProductID, Type: entities.FieldInteger},
+	{Name: entities.FieldProductValue, Type: entities.FieldDecimal},
+	{Name: entities.FieldOrderDate, Type: entities.FieldDate},
+}
+
+// textFields returns the fields of the text records of files loaded in the mode, which are the
+// text record fields followed by the operation in delta files
+func textFields(mode user.Mode) []*entities.LayoutField {
+	if mode != user.ModeDelta {
+		return textRecordFields
+	}
+
+	return append(slices.Clone(textRecordFields), &entities.LayoutField{
+		Name: entities.FieldOperation,
+		Type: entities.FieldString,
+	})
+}
+
+// csvHeader names the columns of the csv records of files loaded in the mode, as written back
+// by csvRecordText
+func csvHeader(mode user.Mode) string {
+	return csvRecordText(fieldNames(textFields(mode)))
+}
+
+// formatSniffSize is how much of the file is looked at to detect its format
+const formatSniffSize = 4096
+
+// detectFormat returns the format of the file, looking at its first bytes when the format is
+// auto or empty. The returned reader must be used in place of file
+func detectFormat(file io.Reader, format user.Format) (user.Format, io.Reader) {
+	if format != "" && format != user.FormatAuto {
+		return format, file
+	}
+
+	reader := bufio.NewReaderSize(file, formatSniffSize)
+	head, _ := reader.Peek(formatSniffSize)
+	head = bytes.TrimLeft(head, " \t\r\n\ufeff")
+
+	switch {
+	case bytes.HasPrefix(head, []byte("[")):
+		return user.FormatJSON, reader
+	case bytes.HasPrefix(head, []byte("{")):
+		return user.FormatNDJSON, reader
+	}
+
+	firstLine, _, _ := bytes.Cut(head, []byte("\n"))
+	if isCSVHeader(string(firstLine)) {
+		return user.FormatCSV, reader
+	}
+
+	return user.FormatFixedWidth, reader
+}
+
+// isCSVHeader tells whether the line is a header naming every record field, leaving out the
+// operation, so the files of every mode are detected
+func isCSVHeader(line string) bool {
+	columns := make(map[string]bool)
+	for _, column := range strings.Split(strings.TrimSpace(line), ",") {
+		columns[strings.TrimSpace(column)] = true
+	}
+
+	for _, field := range textRecordFields {
+		if !columns[field.Name] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// defaultMaxLineSize is the longest line, in bytes, of the files read line by line when no
+// maximum is configured
+const defaultMaxLineSize = 1 << 20
+
+// lineSplitter splits lines as bufio.ScanLines does, but goes past the lines longer than its
+// maximum size instead of stopping the scanner with bufio.ErrTooLong. Each of them is split
+// as an empty token flagged as too long, so the decoder rejects that line alone
+type lineSplitter struct {
+	maxSize int
+	// skipping tells the rest of a long line is being dropped, tooLong that the last token
+	// was a long line
+	skipping bool
+	tooLong  bool
+}
+
+// newLineScanner returns a scanner of the lines of the file and the splitter telling whether
+// the last line read was longer than maxSize
+func newLineScanner(file io.Reader, maxSize int) (*bufio.Scanner, *lineSplitter) {
+	if maxSize <= 0 {
+		maxSize = defaultMaxLineSize
+	}
+
+	splitter := &lineSplitter{maxSize: maxSize}
+	scanner := bufio.NewScanner(file)
+	// The buffer holds a whole line and its line break, so a longer one is seen before the
+	// scanner gives up on it
+	scanner.Buffer(nil, maxSize+len("\r\n"))
+	scanner.Split(splitter.split)
+
+	return scanner, splitter
+}
+
+func (s *lineSplitter) split(data []byte, atEOF bool) (int, []byte, error) {
+	s.tooLong = false
+
+	if s.skipping {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 && !atEOF {
+			return len(data), nil, nil
+		}
+
+		s.skipping = false
+		s.tooLong = true
+		if end < 0 {
+			return len(data), []byte{}, nil
+		}
+		return end + 1, []byte{}, nil
+	}
+
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	switch {
+	case err == nil && token == nil && len(data) > s.maxSize+1:
+		// Without a line break in the buffer the line is already too long, so the rest of it
+		// is dropped as it is read
+		s.skipping = true
+		return len(data), nil, nil
+	case len(token) > s.maxSize:
+		s.tooLong = true
+		return advance, []byte{}, nil
+	}
+
+	return advance, token, err
+}
+
+// rejection rejects the last line read for being too long
+func (s *lineSplitter) rejection() *entities.LineRejection {
+	return &entities.LineRejection{
+		Field:  "line",
+		Reason: fmt.Sprintf("line is longer than %d bytes", s.maxSize),
+	}
+}
+
+// errRecordTooLong stops the reader of a record once the record goes over its maximum size
+var errRecordTooLong = errors.New("record is too long")
+
+// recordLimiter hands the file to the reader of records that may span lines, like the csv
+// ones, at most a line per read. The reader then never reads past the record it is on, so the
+// bytes read since reset are those of the record, and a record longer than maxSize stops it
+// with errRecordTooLong, the rest of the line it was cut at being dropped
+type recordLimiter struct {
+	reader  *bufio.Reader
+	maxSize int
+	// size is how many bytes of the record were read, line how many lines of the file and
+	// start the line the record starts at, zero until it does
+	size  int
+	line  int
+	start int
+	// tooLong tells the last record was cut
+	tooLong bool
+}
+
+func newRecordLimiter(file io.Reader, maxSize int) *recordLimiter {
+	if maxSize <= 0 {
+		maxSize = defaultMaxLineSize
+	}
+
+	return &recordLimiter{reader: bufio.NewReader(file), maxSize: maxSize}
+}
+
+// reset starts the next record, once the reader returned the last one
+func (l *recordLimiter) reset() {
+	l.size = 0
+	l.start = 0
+	l.tooLong = false
+}
+
+func (l *recordLimiter) Read(p []byte) (int, error) {
+	// The line break ending the record is not counted
+	n := min(len(p), l.maxSize+len("\r\n")-l.size)
+	if n <= 0 {
+		return 0, l.cut()
+	}
+
+	if _, err := l.reader.Peek(1); err != nil {
+		return 0, err
+	}
+
+	data, _ := l.reader.Peek(min(l.reader.Buffered(), n))
+	if end := bytes.IndexByte(data, '\n'); end >= 0 {
+		data = data[:end+1]
+	}
+
+	size := l.size + len(bytes.TrimSuffix(bytes.TrimSuffix(data, []byte("\n")), []byte("\r")))
+	if size > l.maxSize {
+		return 0, l.cut()
+	}
+
+	// Blank lines are skipped by the csv reader, so they are no part of the record
+	if l.start == 0 && size > l.size {
+		l.start = l.line + 1
+	}
+	if l.start != 0 {
+		l.size += len(data)
+	}
+
+	copy(p, data)
+	l.reader.Discard(len(data))
+	if bytes.HasSuffix(data, []byte("\n")) {
+		l.line++
+	}
+
+	return len(data), nil
+}
+
+// cut drops the rest of the line the record went over its maximum size at
+func (l *recordLimiter) cut() error {
+	l.tooLong = true
+	if l.start == 0 {
+		l.start = l.line + 1
+	}
+
+	for {
+		_, err := l.reader.ReadSlice('\n')
+		if err == nil {
+			l.line++
+		}
+		if err != bufio.ErrBufferFull {
+			break
+		}
+	}
+
+	return errRecordTooLong
+}
+
+// rejection rejects the last record for being too long
+func (l *recordLimiter) rejection() *entities.LineRejection {
+	return &entities.LineRejection{
+		Field:  "line",
+		Reason: fmt.Sprintf("record is longer than %d bytes", l.maxSize),
+	}
+}
+
+// elementLimiter stops the reads of a JSON decoder with errRecordTooLong once it reads
+// further than limit, which is moved past the end of the stream read before every element
+type elementLimiter struct {
+	reader io.Reader
+	read   int64
+	limit  int64
+}
+
+func (l *elementLimiter) Read(p []byte) (int, error) {
+	if l.read >= l.limit {
+		return 0, errRecordTooLong
+	}
+
+	p = p[:min(int64(len(p)), l.limit-l.read)]
+	n, err := l.reader.Read(p)
+	l.read += int64(n)
+
+	return n, err
+}
+
+// fixedWidthDecoder cuts every line of the file as described by a record layout
+type fixedWidthDecoder struct {
+	scanner  *bufio.Scanner
+	splitter *lineSplitter
+	layout   *entities.RecordLayout
+	fields   []*entities.LayoutField
+	line     int
+}
+
+// newFixedWidthDecoder cuts the fields of the layout, leaving out its operation field unless
+// the file is a delta file
+func newFixedWidthDecoder(file io.Reader, layout *entities.RecordLayout, mode user.Mode, maxLineSize int) user.Decoder {
+	fields := layout.Fields
+	if mode != user.ModeDelta {
+		fields = slices.DeleteFunc(slices.Clone(fields), func(field *entities.LayoutField) bool {
+			return field.Name == entities.FieldOperation
+		})
+	}
+
+	scanner, splitter := newLineScanner(file, maxLineSize)
+
+	return &fixedWidthDecoder{
+		scanner:  scanner,
+		splitter: splitter,
+		layout:   layout,
+		fields:   fields,
+	}
+}
+
+func (d *fixedWidthDecoder) Fields() []*entities.LayoutField {
+	return d.fields
+}
+
+func (d *fixedWidthDecoder) Next() (*user.RawRecord, error) {
+	if !d.scanner.Scan() {
+		if err := d.scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	d.line++
+	if d.splitter.tooLong {
+		return &user.RawRecord{Line: d.line, Rejection: d.splitter.rejection()}, nil
+	}
+
+	text := d.scanner.Text()
+	record := &user.RawRecord{Line: d.line, Text: text}
+
+	if strings.TrimSpace(text) == "" {
+		record.Skipped = true
+		return record, nil
+	}
+
+	if !utf8.ValidString(text) {
+		record.Rejection = &entities.LineRejection{
+			Field:  "line",
+			Value:  strings.ToValidUTF8(text, "\ufffd"),
+			Reason: "line is not valid UTF-8, send the encoding of the file",
+		}
+		return record, nil
+	}
+
+	if lineLength, length := d.layout.LineLength(), utf8.RuneCountInString(text); length < lineLength {
+		record.Rejection = &entities.LineRejection{
+			Field:  "line",
+			Value:  text,
+			Reason: fmt.Sprintf("line has %d characters, expected %d", length, lineLength),
+		}
+		return record, nil
+	}
+
+	record.Fields = make(map[string]string, len(d.fields))
+	for _, field := range d.fields {
+		record.Fields[field.Name] = field.Extract(text)
+	}
+
+	return record, nil
+}
+
+// csvDecoder reads comma separated files whose first line names the columns, in any order.
+// Records longer than maxLineSize, quoted line breaks included, are rejected
+type csvDecoder struct {
+	reader  *csv.Reader
+	limiter *recordLimiter
+	fields  []*entities.LayoutField
+	columns map[string]int
+}
+
+func newCSVDecoder(file io.Reader, layout *entities.RecordLayout, mode user.Mode, maxLineSize int) user.Decoder {
+	limiter := newRecordLimiter(file, maxLineSize)
+
+	reader := csv.NewReader(limiter)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	return &csvDecoder{
+		reader:  reader,
+		limiter: limiter,
+		fields:  textFields(mode),
+	}
+}
+
+func (d *csvDecoder) Fields() []*entities.LayoutField {
+	return d.fields
+}
+
+func (d *csvDecoder) Next() (*user.RawRecord, error) {
+	if d.columns == nil {
+		if err := d.readHeader(); err != nil {
+			return nil, err
+		}
+	}
+
+	row, err := d.reader.Read()
+	defer d.limiter.reset()
+
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+
+	// The part of the record read before it was cut may not parse, so it is checked first
+	if d.limiter.tooLong {
+		return &user.RawRecord{Line: d.limiter.start, Rejection: d.limiter.rejection()}, nil
+	}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &user.RawRecord{
+			Line: parseErr.StartLine,
+			Rejection: &entities.LineRejection{
+				Field:  "line",
+				Reason: parseErr.Err.Error(),
+			},
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	line, _ := d.reader.FieldPos(0)
+	record := &user.RawRecord{Line: line}
+
+	if len(row) != len(d.columns) {
+		record.Text = csvRecordText(row)
+		record.Rejection = &entities.LineRejection{
+			Field:  "line",
+			Value:  strings.Join(row, ","),
+			Reason: fmt.Sprintf("record has %d columns, expected %d", len(row), len(d.columns)),
+		}
+		return record, nil
+	}
+
+	// The text lists the values in the order of csvHeader, so the records of files with
+	// their columns in different orders can be written back under the same header
+	values := make([]string, 0, len(d.fields))
+	record.Fields = make(map[string]string, len(d.fields))
+	for _, field := range d.fields {
+		value := row[d.columns[field.Name]]
+		values = append(values, value)
+		record.Fields[field.Name] = strings.TrimSpace(value)
+	}
+	record.Text = csvRecordText(values)
+
+	return record, nil
+}
+
+func (d *csvDecoder) readHeader() error {
+	header, err := d.reader.Read()
+	d.limiter.reset()
+	if err == io.EOF {
+		return io.EOF
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	d.columns = make(map[string]int, len(header))
+	for i, column := range header {
+		d.columns[strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))] = i
+	}
+
+	for _, field := range d.fields {
+		if _, ok := d.columns[field.Name]; !ok {
+			return fmt.Errorf("csv header misses the %s column", field.Name)
+		}
+	}
+
+	return nil
+}
+
+// ndjsonDecoder reads files with a JSON object per line
+type ndjsonDecoder struct {
+	scanner  *bufio.Scanner
+	splitter *lineSplitter
+	fields   []*entities.LayoutField
+	line     int
+}
+
+func newNDJSONDecoder(file io.Reader, layout *entities.RecordLayout, mode user.Mode, maxLineSize int) user.Decoder {
+	scanner, splitter := newLineScanner(file, maxLineSize)
+
+	return &ndjsonDecoder{
+		scanner:  scanner,
+		splitter: splitter,
+		fields:   textFields(mode),
+	}
+}
+
+func (d *ndjsonDecoder) Fields() []*entities.LayoutField {
+	return d.fields
+}
+
+func (d *ndjsonDecoder) Next() (*user.RawRecord, error) {
+	if !d.scanner.Scan() {
+		if err := d.scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	d.line++
+	if d.splitter.tooLong {
+		return &user.RawRecord{Line: d.line, Rejection: d.splitter.rejection()}, nil
+	}
+
+	text := d.scanner.Text()
+	record := &user.RawRecord{Line: d.line, Text: text}
+
+	if strings.TrimSpace(text) == "" {
+		record.Skipped = true
+		return record, nil
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+
+	object := make(map[string]any)
+	if err := decoder.Decode(&object); err != nil {
+		record.Rejection = &entities.LineRejection{
+			Field:  "line",
+			Value:  text,
+			Reason: fmt.Sprintf("invalid JSON object: %s", err.Error()),
+		}
+		return record, nil
+	}
+
+	record.Fields = jsonObjectFields(object, d.fields)
+
+	return record, nil
+}
+
+// jsonArrayDecoder reads files holding a single JSON array of objects. Records are numbered
+// by their index in the array, starting at 1. An element longer than maxSize, counting the
+// separator before it, fails the file, as the decoder can not go on past an element it did not read
+type jsonArrayDecoder struct {
+	decoder *json.Decoder
+	limiter *elementLimiter
+	maxSize int
+	fields  []*entities.LayoutField
+	started bool
+	index   int
+}
+
+func newJSONArrayDecoder(file io.Reader, layout *entities.RecordLayout, mode user.Mode, maxLineSize int) user.Decoder {
+	if maxLineSize <= 0 {
+		maxLineSize = defaultMaxLineSize
+	}
+
+	limiter := &elementLimiter{reader: file}
+	decoder := json.NewDecoder(limiter)
+	decoder.UseNumber()
+
+	return &jsonArrayDecoder{
+		decoder: decoder,
+		limiter: limiter,
+		maxSize: maxLineSize,
+		fields:  textFields(mode),
+	}
+}
+
+func (d *jsonArrayDecoder) Fields() []*entities.LayoutField {
+	return d.fields
+}
+
+func (d *jsonArrayDecoder) Next() (*user.RawRecord, error) {
+	// The decoder may go as far as the next element is allowed to, from where it stopped
+	d.limiter.limit = d.decoder.InputOffset() + int64(d.maxSize) + 1
+
+	if !d.started {
+		token, err := d.decoder.Token()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %w", err)
+		}
+		if token != json.Delim('[') {
+			return nil, fmt.Errorf("invalid JSON array: file must start with [")
+		}
+		d.started = true
+	}
+
+	if !d.decoder.More() {
+		if _, err := d.decoder.Token(); err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %w", err)
+		}
+		return nil, io.EOF
+	}
+
+	d.index++
+
+	var element json.RawMessage
+	if err := d.decoder.Decode(&element); errors.Is(err, errRecordTooLong) {
+		return nil, fmt.Errorf("JSON array element %d is longer than %d bytes", d.index, d.maxSize)
+	} else if err != nil {
+		// A malformed element leaves the decoder in an unknown position, so the file stops here
+		return nil, fmt.Errorf("invalid JSON array element %d: %w", d.index, err)
+	}
+
+	// The element is kept in a single line, so it reads back as a NDJSON record
+	text := new(bytes.Buffer)
+	if err := json.Compact(text, element); err != nil {
+		return nil, fmt.Errorf("invalid JSON array element %d: %w", d.index, err)
+	}
+
+	elementDecoder := json.NewDecoder(bytes.NewReader(element))
+	elementDecoder.UseNumber()
+
+	var object map[string]any
+	if err := elementDecoder.Decode(&object); err != nil {
+		return nil, fmt.Errorf("invalid JSON array element %d: %w", d.index, err)
+	}
+
+	return &user.RawRecord{
+		Line:   d.index,
+		Text:   text.String(),
+		Fields: jsonObjectFields(object, d.fields),
+	}, nil
+}
+
+// jsonObjectFields turns the values of the fields of a JSON object into raw field values.
+// Numbers keep their literal text and nulls become empty values
+func jsonObjectFields(object map[string]any, fields []*entities.LayoutField) map[string]string {
+	values := make(map[string]string, len(fields))
+	for _, field := range fields {
+		switch value := object[field.Name].(type) {
+		case nil:
+			values[field.Name] = ""
+		case string:
+			values[field.Name] = strings.TrimSpace(value)
+		case json.Number:
+			values[field.Name] = value.String()
+		default:
+			values[field.Name] = fmt.Sprint(value)
+		}
+	}
+
+	return values
+}
+
+// fieldNames lists the names of the fields in order
+func fieldNames(fields []*entities.LayoutField) []string {
+	names := make([]string, 0, len(fields))
+	for _, field := range fields {
+		names = append(names, field.Name)
+	}
+
+	return names
+}
+
+// csvRecordText writes the values as a csv record, without the line break
+func csvRecordText(values []string) string {
+	text := new(strings.Builder)
+
+	writer := csv.NewWriter(text)
+	writer.Write(values)
+	writer.Flush()
+
+	return strings.TrimSuffix(text.String(), "\n")
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/transaction"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+// storeDeltaChunk applies the operations of the lines of a delta file one by one. Inserts are
+// stored as the lines of full files, updates set the value of the lines the order has of the
+// product and deletes remove the lines of the product with the value, removing the order too
+// once it has no line left. The total of every order touched is kept before the first line
+// and after the last one
+func storeDeltaChunk(
+	r *transaction.Repositories,
+	usersData []*user.UserFileData,
+	policies entities.ConflictPolicies,
+	report *writeReport,
+) error {
+	products, err := storeProducts(r, usersData)
+	if err != nil {
+		return err
+	}
+
+	totals := make(map[uint]*entities.OrderTotal)
+
+	for _, userData := range usersData {
+		total, ok := totals[userData.OrderID]
+		if !ok {
+			previous, err := getOrderTotal(r, userData.OrderID)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", userData.Line, err)
+			}
+
+			total = &entities.OrderTotal{OrderID: userData.OrderID, Previous: previous}
+			totals[userData.OrderID] = total
+		}
+
+		if userData.Operation == user.OperationDelete {
+			removed, err := deleteOrderLines(r, userData, report)
+			if err != nil {
+				return err
+			}
+			total.Removed = total.Removed || removed
+			continue
+		}
+
+		stored, err := storeLineOrder(r, userData, policies, products, report)
+		if err != nil {
+			return err
+		}
+		if !stored {
+			continue
+		}
+		total.Removed = false
+
+		if userData.Operation == user.OperationUpdate {
+			err = updateOrderLines(r, userData, report)
+		} else {
+			err = storeOrderProduct(r, userData, report)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, total := range totals {
+		current, err := getOrderTotal(r, total.OrderID)
+		if err != nil {
+			return err
+		}
+		total.Current = current
+	}
+	report.addTotals(totals)
+
+	return nil
+}
+
+// updateOrderLines sets the value of every line the order has of the product, which is
+// stored as a single line when the order has none. The lines are replaced, renumbered from 1,
+// unless they all have the value already
+func updateOrderLines(r *transaction.Repositories, userData *user.UserFileData, report *writeReport) error {
+	stored, err := r.OrderProducts.GetByOrderID(userData.OrderID)
+	if err != nil {
+		return fmt.Errorf("line %d: failed to look up products of order: %w", userData.Line, err)
+	}
+
+	lines, changed := 0, false
+	for _, orderProduct := range stored {
+		if orderProduct.ProductID == userData.ProductID {
+			lines++
+			changed = changed || orderProduct.Value != userData.ProductValue
+		}
+	}
+
+	writes := &report.writes
+	if lines > 0 && !changed {
+		for range lines {
+			writes.OrderProducts.Count(entities.UpsertUnchanged)
+		}
+		return nil
+	}
+
+	if _, err := r.OrderProducts.DeleteByProduct(userData.OrderID, userData.ProductID); err != nil {
+		return fmt.Errorf("line %d: failed to delete products of order: %w", userData.Line, err)
+	}
+
+	for position := 1; position <= max(lines, 1); position++ {
+		orderProduct := &entities.OrderProduct{
+			OrderID:   userData.OrderID,
+			ProductID: userData.ProductID,
+			Value:     userData.ProductValue,
+			Position:  position,
+		}
+
+		if _, err := r.OrderProducts.Upsert(orderProduct); err != nil {
+			return fmt.Errorf("line %d: failed to update product of order: %w", userData.Line, err)
+		}
+
+		if lines == 0 {
+			writes.OrderProducts.Count(entities.UpsertInserted)
+		} else {
+			writes.OrderProducts.Count(entities.UpsertUpdated)
+		}
+	}
+
+	return nil
+}
+
+// deleteOrderLines removes the lines the order has of the product with the value, and the
+// order once it has no line left, telling whether the order was removed
+func deleteOrderLines(r *transaction.Repositories, userData *user.UserFileData, report *writeReport) (bool, error) {
+	deleted, err := r.OrderProducts.DeleteByValue(userData.OrderID, userData.ProductID, userData.ProductValue)
+	if err != nil {
+		return false, fmt.Errorf("line %d: failed to delete products of order: %w", userData.Line, err)
+	}
+	report.writes.OrderProducts.Deleted += deleted
+
+	removed, err := r.Orders.DeleteIfEmpty(userData.OrderID)
+	if err != nil {
+		return false, fmt.Errorf("line %d: failed to delete order: %w", userData.Line, err)
+	}
+	if removed {
+		report.writes.Orders.Deleted++
+	}
+
+	return removed, nil
+}
+
+// getOrderTotal adds up the values of the stored lines of the order
+func getOrderTotal(r *transaction.Repositories, orderID uint) (entities.Money, error) {
+	stored, err := r.OrderProducts.GetByOrderID(orderID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up products of order %d: %w", orderID, err)
+	}
+
+	total := entities.Money(0)
+	for _, orderProduct := range stored {
+		total += orderProduct.Value
+	}
+
+	return total, nil
+}
+
+// sortedOrderTotals lists the totals by order
+func sortedOrderTotals(totals map[uint]*entities.OrderTotal) []*entities.OrderTotal {
+	if len(totals) == 0 {
+		return nil
+	}
+
+	sorted := make([]*entities.OrderTotal, 0, len(totals))
+	for _, orderID := range slices.Sorted(maps.Keys(totals)) {
+		sorted = append(sorted, totals[orderID])
+	}
+
+	return sorted
+}
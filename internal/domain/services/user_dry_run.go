@@ -0,0 +1,403 @@
+package services
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/transaction"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+// orderProductKey identifies an order product by its natural key inside an order
+type orderProductKey struct {
+	productID uint
+	value     entities.Money
+	position  int
+}
+
+// defaultDryRunMaxRecords is how many records a dry run remembers when no limit is configured
+const defaultDryRunMaxRecords = 1_000_000
+
+// dryRun previews what storing the lines would do by looking the rows up instead of
+// upserting them. It remembers what the previous lines would have written, so a file
+// repeating a user or an order is previewed the same way it would be loaded. A line may
+// repeat any record read before it, so nothing is forgotten and a file making it remember
+// more than maxRecords users, products, orders and order lines fails instead
+type dryRun struct {
+	mu         sync.Mutex
+	maxRecords int
+	// lines is how many order lines orderProducts holds
+	lines int
+
+	users         map[uint]*string
+	products      map[uint]bool
+	orders        map[uint]*storedOrder
+	orderProducts map[uint]map[orderProductKey]bool
+}
+
+func newDryRun(maxRecords int) *dryRun {
+	if maxRecords <= 0 {
+		maxRecords = defaultDryRunMaxRecords
+	}
+
+	return &dryRun{
+		maxRecords:    maxRecords,
+		users:         make(map[uint]*string),
+		products:      make(map[uint]bool),
+		orders:        make(map[uint]*storedOrder),
+		orderProducts: make(map[uint]map[orderProductKey]bool),
+	}
+}
+
+// previewChunk reports in report the outcome each upsert of the lines would have, the
+// conflicts they would raise and the lines the conflict policies would reject
+func (d *dryRun) previewChunk(
+	r *transaction.Repositories,
+	usersData []*user.UserFileData,
+	policies entities.ConflictPolicies,
+	report *writeReport,
+) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, userData := range usersData {
+		if err := d.previewUserData(r, userData, policies, report); err != nil {
+			return err
+		}
+		if err := d.checkSize(userData); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkSize fails once the lines previewed made the dry run remember more records than allowed
+func (d *dryRun) checkSize(userData *user.UserFileData) error {
+	if len(d.users)+len(d.products)+len(d.orders)+d.lines > d.maxRecords {
+		return fmt.Errorf("line %d: %w (%d records)", userData.Line, errors.ErrDryRunTooLarge, d.maxRecords)
+	}
+
+	return nil
+}
+
+func (d *dryRun) previewUserData(
+	r *transaction.Repositories,
+	userData *user.UserFileData,
+	policies entities.ConflictPolicies,
+	report *writeReport,
+) error {
+	stored, err := d.previewLineOrder(r, userData, policies, report)
+	if err != nil || !stored {
+		return err
+	}
+
+	return d.previewOrderProduct(r, userData, report)
+}
+
+// previewDeltaChunk reports what applying the operations of the lines of a delta file would
+// do, as storeDeltaChunk applies them, with the totals the orders touched would have
+func (d *dryRun) previewDeltaChunk(
+	r *transaction.Repositories,
+	usersData []*user.UserFileData,
+	policies entities.ConflictPolicies,
+	report *writeReport,
+) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	totals := make(map[uint]*entities.OrderTotal)
+
+	for _, userData := range usersData {
+		lines, err := d.lookupOrderProducts(r, userData)
+		if err != nil {
+			return err
+		}
+
+		total, ok := totals[userData.OrderID]
+		if !ok {
+			total = &entities.OrderTotal{OrderID: userData.OrderID, Previous: linesTotal(lines)}
+			totals[userData.OrderID] = total
+		}
+
+		if userData.Operation == user.OperationDelete {
+			removed, err := d.previewDelete(r, userData, lines, report)
+			if err != nil {
+				return err
+			}
+			total.Removed = total.Removed || removed
+			continue
+		}
+
+		stored, err := d.previewLineOrder(r, userData, policies, report)
+		if err != nil {
+			return err
+		}
+		if !stored {
+			continue
+		}
+		total.Removed = false
+
+		if userData.Operation == user.OperationUpdate {
+			before := len(lines)
+			previewUpdate(userData, lines, report)
+			d.lines += len(lines) - before
+		} else if err := d.previewOrderProduct(r, userData, report); err != nil {
+			return err
+		}
+
+		if err := d.checkSize(userData); err != nil {
+			return err
+		}
+	}
+
+	for _, total := range totals {
+		total.Current = linesTotal(d.orderProducts[total.OrderID])
+	}
+	report.addTotals(totals)
+
+	return nil
+}
+
+// previewUpdate counts what setting the value of the lines the order has of the product
+// would do, as updateOrderLines sets it, and sets it in the lines of the order
+func previewUpdate(userData *user.UserFileData, lines map[orderProductKey]bool, report *writeReport) {
+	count, changed := 0, false
+	for key := range lines {
+		if key.productID == userData.ProductID {
+			count++
+			changed = changed || key.value != userData.ProductValue
+		}
+	}
+
+	writes := &report.writes
+	if count > 0 && !changed {
+		for range count {
+			writes.OrderProducts.Count(entities.UpsertUnchanged)
+		}
+		return
+	}
+
+	maps.DeleteFunc(lines, func(key orderProductKey, _ bool) bool {
+		return key.productID == userData.ProductID
+	})
+
+	for position := 1; position <= max(count, 1); position++ {
+		lines[orderProductKey{productID: userData.ProductID, value: userData.ProductValue, position: position}] = true
+
+		if count == 0 {
+			writes.OrderProducts.Count(entities.UpsertInserted)
+		} else {
+			writes.OrderProducts.Count(entities.UpsertUpdated)
+		}
+	}
+}
+
+// previewDelete counts the lines the order has of the product with the value, which it
+// takes out of the lines of the order, and tells whether the order would be removed
+func (d *dryRun) previewDelete(
+	r *transaction.Repositories,
+	userData *user.UserFileData,
+	lines map[orderProductKey]bool,
+	report *writeReport,
+) (bool, error) {
+	writes := &report.writes
+	for key := range lines {
+		if key.productID == userData.ProductID && key.value == userData.ProductValue {
+			delete(lines, key)
+			d.lines--
+			writes.OrderProducts.Deleted++
+		}
+	}
+
+	order, err := d.lookupOrder(r, userData)
+	if err != nil {
+		return false, err
+	}
+	if !order.found || len(lines) > 0 {
+		return false, nil
+	}
+
+	writes.Orders.Deleted++
+	d.orders[userData.OrderID] = new(storedOrder)
+
+	return true, nil
+}
+
+// previewLineOrder counts what upserting the user, product and order of a line would do,
+// telling whether the line would be stored or a conflict policy would reject it
+func (d *dryRun) previewLineOrder(
+	r *transaction.Repositories,
+	userData *user.UserFileData,
+	policies entities.ConflictPolicies,
+	report *writeReport,
+) (bool, error) {
+	name, err := d.lookupUser(r, userData)
+	if err != nil {
+		return false, err
+	}
+
+	order, err := d.lookupOrder(r, userData)
+	if err != nil {
+		return false, err
+	}
+
+	resolved := resolveLine(policies, userData, name, order)
+	if report.addConflicts(resolved.conflicts, userData.Text) {
+		return false, nil
+	}
+
+	writes := &report.writes
+
+	// Users
+	switch {
+	case name == nil:
+		writes.Users.Count(entities.UpsertInserted)
+	case *name != resolved.userName:
+		writes.Users.Count(entities.UpsertUpdated)
+	default:
+		writes.Users.Count(entities.UpsertUnchanged)
+	}
+	d.users[userData.UserID] = &resolved.userName
+
+	// Products
+	found, err := d.lookupProduct(r, userData)
+	if err != nil {
+		return false, err
+	}
+
+	if found {
+		writes.Products.Count(entities.UpsertUnchanged)
+	} else {
+		writes.Products.Count(entities.UpsertInserted)
+	}
+	d.products[userData.ProductID] = true
+
+	// Orders
+	switch {
+	case !order.found:
+		writes.Orders.Count(entities.UpsertInserted)
+	case order.userID != resolved.orderUserID || !order.date.Equal(resolved.orderDate):
+		writes.Orders.Count(entities.UpsertUpdated)
+	default:
+		writes.Orders.Count(entities.UpsertUnchanged)
+	}
+	d.orders[userData.OrderID] = &storedOrder{found: true, userID: resolved.orderUserID, date: resolved.orderDate}
+
+	return true, nil
+}
+
+// previewOrderProduct counts what upserting the order product of a line would do
+func (d *dryRun) previewOrderProduct(
+	r *transaction.Repositories,
+	userData *user.UserFileData,
+	report *writeReport,
+) error {
+	lines, err := d.lookupOrderProducts(r, userData)
+	if err != nil {
+		return err
+	}
+
+	writes := &report.writes
+
+	key := orderProductKey{
+		productID: userData.ProductID,
+		value:     userData.ProductValue,
+		position:  userData.Position,
+	}
+	if lines[key] {
+		writes.OrderProducts.Count(entities.UpsertUnchanged)
+	} else {
+		writes.OrderProducts.Count(entities.UpsertInserted)
+		lines[key] = true
+		d.lines++
+	}
+
+	return nil
+}
+
+// linesTotal adds up the values of the lines of an order
+func linesTotal(lines map[orderProductKey]bool) entities.Money {
+	total := entities.Money(0)
+	for key := range lines {
+		total += key.value
+	}
+
+	return total
+}
+
+// lookupUser returns the name of the user, or nil when it does not exist
+func (d *dryRun) lookupUser(r *transaction.Repositories, userData *user.UserFileData) (*string, error) {
+	if name, ok := d.users[userData.UserID]; ok {
+		return name, nil
+	}
+
+	name, err := getStoredUserName(r, userData)
+	if err != nil {
+		return nil, err
+	}
+	d.users[userData.UserID] = name
+
+	return name, nil
+}
+
+func (d *dryRun) lookupProduct(r *transaction.Repositories, userData *user.UserFileData) (bool, error) {
+	if found, ok := d.products[userData.ProductID]; ok {
+		return found, nil
+	}
+
+	_, err := r.Products.Get(userData.ProductID)
+	if err != nil && err != errors.ErrProductNotFound {
+		return false, fmt.Errorf("line %d: failed to look up product: %w", userData.Line, err)
+	}
+
+	found := err == nil
+	d.products[userData.ProductID] = found
+
+	return found, nil
+}
+
+func (d *dryRun) lookupOrder(r *transaction.Repositories, userData *user.UserFileData) (*storedOrder, error) {
+	if order, ok := d.orders[userData.OrderID]; ok {
+		return order, nil
+	}
+
+	order, err := getStoredOrder(r, userData)
+	if err != nil {
+		return nil, err
+	}
+	d.orders[userData.OrderID] = order
+
+	return order, nil
+}
+
+// lookupOrderProducts returns the lines of the order, loading the stored ones the first time the order is seen
+func (d *dryRun) lookupOrderProducts(
+	r *transaction.Repositories,
+	userData *user.UserFileData,
+) (map[orderProductKey]bool, error) {
+	if lines, ok := d.orderProducts[userData.OrderID]; ok {
+		return lines, nil
+	}
+
+	stored, err := r.OrderProducts.GetByOrderID(userData.OrderID)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: failed to look up products of order: %w", userData.Line, err)
+	}
+
+	lines := make(map[orderProductKey]bool, len(stored))
+	for _, orderProduct := range stored {
+		lines[orderProductKey{
+			productID: orderProduct.ProductID,
+			value:     orderProduct.Value,
+			position:  orderProduct.Position,
+		}] = true
+	}
+	d.orderProducts[userData.OrderID] = lines
+	d.lines += len(lines)
+
+	return lines, nil
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode/utf8"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+// utf8BOM marks the beginning of some UTF-8 files and is not part of their first line
+var utf8BOM = []byte("\ufeff")
+
+// charsets maps every byte of the single-byte encodings to the character it stands for
+var charsets = map[user.Encoding]*[256]rune{
+	user.EncodingISO88591:    newCharset(nil),
+	user.EncodingWindows1252: newCharset(windows1252Specials),
+}
+
+// windows1252Specials are the characters Windows-1252 puts where ISO-8859-1 has control
+// characters. The five bytes Windows-1252 leaves undefined keep their ISO-8859-1 meaning
+var windows1252Specials = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡', 0x88: 'ˆ',
+	0x89: '‰', 0x8A: 'Š', 0x8B: '‹', 0x8C: 'Œ', 0x8E: 'Ž', 0x91: '‘', 0x92: '’', 0x93: '“',
+	0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—', 0x98: '˜', 0x99: '™', 0x9A: 'š', 0x9B: '›',
+	0x9C: 'œ', 0x9E: 'ž', 0x9F: 'Ÿ',
+}
+
+// newCharset builds the table of an ISO-8859-1 based encoding, where every byte is the
+// character with the same code unless it is one of the specials
+func newCharset(specials map[byte]rune) *[256]rune {
+	charset := new([256]rune)
+	for b := range charset {
+		charset[b] = rune(b)
+	}
+	for b, r := range specials {
+		charset[b] = r
+	}
+
+	return charset
+}
+
+// validateEncoding tells whether the encoding is known, an empty one being UTF-8
+func validateEncoding(encoding user.Encoding) error {
+	if encoding == "" || encoding == user.EncodingUTF8 {
+		return nil
+	}
+	if _, ok := charsets[encoding]; !ok {
+		return errors.ErrUnknownEncoding
+	}
+
+	return nil
+}
+
+// newUTF8Reader returns a reader of the file converted from its encoding to UTF-8, so
+// the decoders only ever deal with UTF-8. A leading UTF-8 byte order mark is dropped
+func newUTF8Reader(file io.Reader, encoding user.Encoding) (io.Reader, error) {
+	if err := validateEncoding(encoding); err != nil {
+		return nil, err
+	}
+
+	charset, ok := charsets[encoding]
+	if !ok {
+		reader := bufio.NewReader(file)
+		if head, _ := reader.Peek(len(utf8BOM)); bytes.Equal(head, utf8BOM) {
+			reader.Discard(len(utf8BOM))
+		}
+		return reader, nil
+	}
+
+	return &charsetReader{
+		src:     file,
+		charset: charset,
+		buf:     make([]byte, 32<<10),
+	}, nil
+}
+
+// charsetReader converts a single-byte encoding to UTF-8 as the file is read
+type charsetReader struct {
+	src     io.Reader
+	charset *[256]rune
+	buf     []byte
+	// pending is converted text not read yet, err the error to return once it is read
+	pending []byte
+	out     []byte
+	err     error
+}
+
+func (r *charsetReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		n, err := r.src.Read(r.buf)
+		r.err = err
+
+		r.out = r.out[:0]
+		for _, b := range r.buf[:n] {
+			if b < utf8.RuneSelf {
+				r.out = append(r.out, b)
+				continue
+			}
+			r.out = utf8.AppendRune(r.out, r.charset[b])
+		}
+		r.pending = r.out
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+
+	return n, nil
+}
@@ -0,0 +1,783 @@
+package services
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/order"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/progress"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/transaction"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+const (
+	// stageBufferSize bounds how many lines wait between two stages, so a slow stage
+	// blocks the previous one instead of letting the lines pile up in memory
+	stageBufferSize = 1024
+	// defaultWriteBatchSize is how many lines a writer stores at once when no chunk size is set
+	defaultWriteBatchSize = 500
+	// maxDeadlockRetries is how many times a batch is stored again once its transaction is
+	// aborted to break a deadlock with the transaction of another writer
+	maxDeadlockRetries = 3
+)
+
+// orderLineKey identifies equal order lines, which are told apart by their position
+type orderLineKey struct {
+	orderID   uint
+	productID uint
+	value     entities.Money
+}
+
+// ingestion streams a file through a decoder, a parser and a pool of writers connected
+// by bounded channels. Lines are routed so the lines of a user and of its orders are written
+// by a single writer in the same order they appear in the file
+type ingestion struct {
+	unitOfWork transaction.UnitOfWork
+	config     user.LoadConfig
+	decoder    user.Decoder
+	opts       user.LoadOptions
+	workers    int
+	batchSize  int
+
+	// preview is set on dry runs, where the lines are looked up through read-only
+	// transactions instead of stored
+	preview *dryRun
+
+	// bulk is decided by the parser before the first line reaches a writer
+	bulk bool
+	// drained gets a signal from every writer once it stored the lines routed to it before
+	// the parser drains the writers
+	drained chan struct{}
+
+	// reprocess is set when the lines come from the quarantine, which releases the lines
+	// stored and keeps the ones rejected again
+	reprocess bool
+
+	// verified is set when the file ends with a trailer, only checked once every line was
+	// read, so the file is loaded in a single transaction a mismatch rolls back entirely
+	verified bool
+
+	// snapshot is the range of order dates of a snapshot file, which is loaded in a single
+	// transaction removing the orders and lines of the range missing from it. The parser keeps
+	// the lines of every order of the file in snapshotOrders and tells in partial whether it
+	// rejected a line
+	snapshot       *order.Interval
+	snapshotOrders map[uint]map[orderProductKey]bool
+	partial        bool
+
+	// broadcaster is where the progress of uploads is published, read counts the bytes of
+	// the file when it is read from one
+	broadcaster progress.Broadcaster
+	read        *countingReader
+	startedAt   time.Time
+
+	// mu guards result, written and totals, which the parser and the writers update
+	// concurrently. totals are the order totals of the committed lines of delta files
+	mu      sync.Mutex
+	result  *user.UserFileResult
+	written int
+	totals  map[uint]*entities.OrderTotal
+
+	// shared is the file transaction when the whole file is committed at once. Its connection
+	// can not run statements concurrently, so the writers take turns through sharedMu and
+	// count what they wrote in pendingLines and pending until the transaction commits
+	shared       *transaction.Repositories
+	sharedMu     sync.Mutex
+	pendingLines int
+	pending      writeReport
+}
+
+func newIngestion(
+	unitOfWork transaction.UnitOfWork,
+	config user.LoadConfig,
+	decoder user.Decoder,
+	opts user.LoadOptions,
+	broadcaster progress.Broadcaster,
+) *ingestion {
+	workers := max(config.Workers, 1)
+
+	batchSize := config.ChunkSize
+	if batchSize <= 0 {
+		batchSize = defaultWriteBatchSize
+	}
+
+	in := &ingestion{
+		unitOfWork:     unitOfWork,
+		config:         config,
+		decoder:        decoder,
+		opts:           opts,
+		workers:        workers,
+		batchSize:      batchSize,
+		broadcaster:    broadcaster,
+		drained:        make(chan struct{}, workers),
+		result:         &user.UserFileResult{DryRun: opts.DryRun},
+		totals:         make(map[uint]*entities.OrderTotal),
+		snapshotOrders: make(map[uint]map[orderProductKey]bool),
+	}
+	if opts.DryRun {
+		in.preview = newDryRun(config.DryRunMaxRecords)
+	}
+
+	return in
+}
+
+// transact runs fn in a transaction, a read-only one on dry runs
+func (in *ingestion) transact(ctx context.Context, fn func(r *transaction.Repositories) error) error {
+	if in.preview != nil {
+		return in.unitOfWork.View(ctx, fn)
+	}
+
+	return in.unitOfWork.Do(ctx, fn)
+}
+
+// run loads the file, publishing its progress on the way when it is the file of an upload
+func (in *ingestion) run(ctx context.Context) (*user.UserFileResult, error) {
+	result, err := in.follow(ctx)
+	result.OrderTotals = sortedOrderTotals(in.totals)
+
+	return result, err
+}
+
+// follow loads the file, reporting its progress while it is loaded when it is published
+func (in *ingestion) follow(ctx context.Context) (*user.UserFileResult, error) {
+	if !in.publishes() {
+		return in.load(ctx)
+	}
+
+	in.startedAt = time.Now()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		in.report(stop)
+	}()
+
+	result, err := in.load(ctx)
+
+	close(stop)
+	wg.Wait()
+	in.publish(true)
+
+	return result, err
+}
+
+// load loads the file, in a single transaction when no chunk size is set, the file is
+// verified by a trailer or it is a snapshot, or in one transaction per writer batch otherwise
+func (in *ingestion) load(ctx context.Context) (*user.UserFileResult, error) {
+	if in.config.ChunkSize > 0 && !in.verified && in.snapshot == nil {
+		err := in.stream(ctx)
+		return in.result, err
+	}
+
+	err := in.transact(ctx, func(r *transaction.Repositories) error {
+		in.shared = r
+
+		if err := in.stream(ctx); err != nil {
+			return err
+		}
+
+		if in.bulk && in.pendingLines > 0 {
+			report := writeReport{}
+			if err := mergeStagedLines(r, in.config.ConflictPolicies, &report); err != nil {
+				return err
+			}
+			if err := in.saveConflicts(r, report.conflicts); err != nil {
+				return err
+			}
+			if err := in.quarantine(r, report.rejected); err != nil {
+				return err
+			}
+			in.pending.merge(&report)
+		}
+
+		if in.snapshot != nil {
+			return in.removeMissingOrders(r, &in.pending)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return in.result, err
+	}
+
+	in.commit(in.pendingLines, &in.pending)
+
+	return in.result, nil
+}
+
+// commit counts in the result the lines of a committed transaction and what storing them did
+func (in *ingestion) commit(lines int, report *writeReport) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	in.result.AcceptedLines += lines - len(report.rejected)
+	in.result.Writes.Merge(report.writes)
+	for _, rejected := range report.rejected {
+		in.result.Reject(rejected.Rejections...)
+	}
+	for _, conflict := range report.conflicts {
+		in.result.Conflict(conflict)
+	}
+
+	for orderID, total := range report.totals {
+		if kept, ok := in.totals[orderID]; ok {
+			kept.Current = total.Current
+			kept.Removed = total.Removed
+			continue
+		}
+
+		if len(in.totals) < user.MaxReportedOrderTotals {
+			in.totals[orderID] = total
+		}
+	}
+}
+
+// stream runs the stages until the file is fully written, a stage fails or ctx is done.
+// The first failure cancels every other stage and is the returned error
+func (in *ingestion) stream(ctx context.Context) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	rawRecords := make(chan *user.RawRecord, stageBufferSize)
+	records := make([]chan *user.UserFileData, in.workers)
+	for i := range records {
+		records[i] = make(chan *user.UserFileData, stageBufferSize/in.workers+1)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2 + in.workers)
+
+	go func() {
+		defer wg.Done()
+		defer close(rawRecords)
+
+		if err := in.decode(ctx, rawRecords); err != nil {
+			cancel(err)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		defer func() {
+			for _, ch := range records {
+				close(ch)
+			}
+		}()
+
+		if err := in.parse(ctx, rawRecords, records); err != nil {
+			cancel(err)
+		}
+	}()
+
+	for _, ch := range records {
+		go func() {
+			defer wg.Done()
+
+			if err := in.write(ctx, ch); err != nil {
+				cancel(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return context.Cause(ctx)
+}
+
+// decode reads the records of the file
+func (in *ingestion) decode(ctx context.Context, rawRecords chan<- *user.RawRecord) error {
+	for {
+		record, err := in.decoder.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		select {
+		case rawRecords <- record:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// parse validates the records and routes the valid ones to the writers, as router says.
+// Only a position counter per distinct order line is kept, never the parsed lines
+// themselves, except for the first BulkThreshold lines held to choose the load mode.
+// Dry runs and delta files never bulk load, as staging writes to the database and
+// merging knows no other operation than upserts. The lines of delta files are numbered by
+// their inserts, which start over once the line is deleted. The invalid lines of
+// uploads are routed to a writer as well, which quarantines them. The header of the file is
+// kept in the result and its trailer checked against the records read before it. The orders
+// of snapshot files are kept, and their lines dated out of the range of the snapshot rejected
+func (in *ingestion) parse(ctx context.Context, rawRecords <-chan *user.RawRecord, records []chan *user.UserFileData) error {
+	positions := make(map[orderLineKey]int)
+	decided := in.config.BulkThreshold <= 0 || in.preview != nil || in.opts.Mode == user.ModeDelta
+	held := make([]*user.UserFileData, 0)
+
+	routes := newRouter(len(records))
+	dispatch := func(userData *user.UserFileData) error {
+		writer, ok := routes.route(userData)
+		if !ok {
+			if err := in.drain(ctx, records); err != nil {
+				return err
+			}
+			routes.reset()
+			writer, _ = routes.route(userData)
+		}
+
+		select {
+		case records[writer] <- userData:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	release := func() error {
+		decided = true
+		for _, userData := range held {
+			if err := dispatch(userData); err != nil {
+				return err
+			}
+		}
+		held = nil
+
+		return nil
+	}
+
+	fields := in.decoder.Fields()
+
+	// The records read and the sum of their values, as the trailer of the file counts them
+	readRecords, readValues := 0, entities.Money(0)
+	var valueField *entities.LayoutField
+	for _, field := range fields {
+		if field.Name == entities.FieldProductValue {
+			valueField = field
+		}
+	}
+
+	for record := range rawRecords {
+		if record.Header != nil {
+			in.mu.Lock()
+			in.result.Header = record.Header
+			in.mu.Unlock()
+			continue
+		}
+
+		if record.Trailer != nil {
+			if err := checkTrailer(record.Trailer, readRecords, readValues); err != nil {
+				return err
+			}
+			continue
+		}
+
+		in.mu.Lock()
+		in.result.ProcessedLines++
+		in.mu.Unlock()
+
+		if record.Skipped {
+			in.mu.Lock()
+			in.result.SkippedLines++
+			in.mu.Unlock()
+			continue
+		}
+
+		var userData *user.UserFileData
+		var rejections []*entities.LineRejection
+		if record.Rejection != nil {
+			rejections = []*entities.LineRejection{record.Rejection}
+		} else {
+			userData, rejections = parseUserDataFromRecord(fields, record.Fields, in.config.Location)
+		}
+
+		if in.snapshot != nil && len(rejections) == 0 {
+			if rejection := in.checkSnapshotDate(userData); rejection != nil {
+				rejections = []*entities.LineRejection{rejection}
+			}
+		}
+
+		readRecords++
+		readValues += recordValue(userData, record, valueField)
+
+		if len(rejections) > 0 {
+			in.partial = true
+			for _, rejection := range rejections {
+				rejection.Line = record.Line
+			}
+			in.mu.Lock()
+			in.result.Reject(rejections...)
+			in.mu.Unlock()
+
+			if in.quarantines() {
+				rejected := &user.UserFileData{
+					Line:       record.Line,
+					Text:       quarantineText(record.Text),
+					Rejections: rejections,
+				}
+				if err := dispatch(rejected); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		key := orderLineKey{
+			orderID:   userData.OrderID,
+			productID: userData.ProductID,
+			value:     userData.ProductValue,
+		}
+		switch userData.Operation {
+		case user.OperationDelete:
+			positions[key] = 0
+		case user.OperationUpdate:
+			// Updates number the lines they set themselves
+		default:
+			positions[key]++
+		}
+
+		userData.Line = record.Line
+		userData.Position = positions[key]
+		if in.snapshot != nil {
+			in.keepSnapshotLine(userData)
+		}
+		if in.quarantines() {
+			userData.Text = quarantineText(record.Text)
+		}
+
+		if decided {
+			if err := dispatch(userData); err != nil {
+				return err
+			}
+			continue
+		}
+
+		held = append(held, userData)
+		if len(held) >= in.config.BulkThreshold {
+			in.bulk = true
+			if err := release(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !decided {
+		return release()
+	}
+
+	return nil
+}
+
+// drain waits until every writer stored the lines routed to it so far
+func (in *ingestion) drain(ctx context.Context, records []chan *user.UserFileData) error {
+	for _, ch := range records {
+		select {
+		case ch <- nil:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for range records {
+		select {
+		case <-in.drained:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// write stores the lines it receives in batches. A nil line asks it to store its batch
+// right away, as the parser drains the writers
+func (in *ingestion) write(ctx context.Context, records <-chan *user.UserFileData) error {
+	batch := make([]*user.UserFileData, 0, in.batchSize)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case userData, ok := <-records:
+			if !ok {
+				return in.flush(ctx, batch)
+			}
+
+			if userData == nil {
+				if err := in.flush(ctx, batch); err != nil {
+					return err
+				}
+				batch = make([]*user.UserFileData, 0, in.batchSize)
+				in.drained <- struct{}{}
+				continue
+			}
+
+			batch = append(batch, userData)
+			if len(batch) < in.batchSize {
+				continue
+			}
+
+			if err := in.flush(ctx, batch); err != nil {
+				return err
+			}
+			batch = make([]*user.UserFileData, 0, in.batchSize)
+		}
+	}
+}
+
+// flush stores a batch, through the file transaction when there is one or in its own
+// transaction otherwise, which is retried when it is aborted by a deadlock. Bulk batches in
+// the file transaction are only staged here and merged once every writer is done
+func (in *ingestion) flush(ctx context.Context, batch []*user.UserFileData) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	valid, invalid := splitInvalidLines(batch)
+
+	if in.shared != nil {
+		in.sharedMu.Lock()
+		defer in.sharedMu.Unlock()
+
+		if err := in.store(in.shared, valid, invalid, &in.pending, false); err != nil {
+			return err
+		}
+		in.pendingLines += len(valid)
+		in.wrote(len(valid))
+
+		return nil
+	}
+
+	var report writeReport
+	for attempt := 0; ; attempt++ {
+		report = writeReport{}
+		err := in.transact(ctx, func(r *transaction.Repositories) error {
+			return in.store(r, valid, invalid, &report, true)
+		})
+		if err == nil {
+			break
+		}
+		if !stderrors.Is(err, errors.ErrTransactionDeadlock) || attempt == maxDeadlockRetries {
+			return err
+		}
+	}
+
+	in.wrote(len(valid))
+	in.commit(len(valid), &report)
+
+	return nil
+}
+
+// router picks the writer of every line, keeping on a single writer the lines of a user and
+// the lines of its orders, even when an order is split over several users. The writers of
+// the users and orders seen are kept until reset
+type router struct {
+	writers int
+	users   map[uint]int
+	orders  map[uint]int
+}
+
+func newRouter(writers int) *router {
+	return &router{
+		writers: writers,
+		users:   make(map[uint]int),
+		orders:  make(map[uint]int),
+	}
+}
+
+// route returns the writer of the line, or false when its user and its order were routed to
+// different writers. The line can only be written after both then, so the writers must be
+// drained and the router reset before routing it again
+func (r *router) route(userData *user.UserFileData) (int, bool) {
+	// Invalid lines are only quarantined, in no particular order
+	if len(userData.Rejections) > 0 {
+		return 0, true
+	}
+
+	userWriter, userRouted := r.users[userData.UserID]
+	orderWriter, orderRouted := r.orders[userData.OrderID]
+
+	writer := int(userData.UserID % uint(r.writers))
+	switch {
+	case userRouted && orderRouted && userWriter != orderWriter:
+		return 0, false
+	case userRouted:
+		writer = userWriter
+	case orderRouted:
+		writer = orderWriter
+	}
+
+	r.users[userData.UserID] = writer
+	r.orders[userData.OrderID] = writer
+
+	return writer, true
+}
+
+// reset forgets every route, once the lines routed so far were all written
+func (r *router) reset() {
+	clear(r.users)
+	clear(r.orders)
+}
+
+// splitInvalidLines separates the valid lines of a batch from the invalid ones, which are
+// only there to be quarantined
+func splitInvalidLines(batch []*user.UserFileData) ([]*user.UserFileData, []*entities.RejectedLine) {
+	valid := make([]*user.UserFileData, 0, len(batch))
+	invalid := make([]*entities.RejectedLine, 0)
+
+	for _, userData := range batch {
+		if len(userData.Rejections) == 0 {
+			valid = append(valid, userData)
+			continue
+		}
+
+		invalid = append(invalid, &entities.RejectedLine{
+			Line:       userData.Line,
+			Content:    userData.Text,
+			Rejections: userData.Rejections,
+		})
+	}
+
+	return valid, invalid
+}
+
+// store quarantines the invalid lines and writes the valid ones as the mode of the load says,
+// saving with the batch the conflicts found and the lines they reject. Bulk loads only merge
+// the staged lines when merge is set, and dry runs only preview the batch
+func (in *ingestion) store(
+	r *transaction.Repositories,
+	batch []*user.UserFileData,
+	invalid []*entities.RejectedLine,
+	report *writeReport,
+	merge bool,
+) error {
+	if in.preview != nil {
+		if in.opts.Mode == user.ModeDelta {
+			return in.preview.previewDeltaChunk(r, batch, in.config.ConflictPolicies, report)
+		}
+		return in.preview.previewChunk(r, batch, in.config.ConflictPolicies, report)
+	}
+
+	if err := in.quarantine(r, invalid); err != nil {
+		return err
+	}
+
+	// The bulk mode may not be decided yet when a batch only holds invalid lines
+	if len(batch) == 0 {
+		return nil
+	}
+
+	// The lines rejected again are quarantined back once they are stored
+	if in.reprocess {
+		if err := in.releaseLines(r, batch); err != nil {
+			return err
+		}
+	}
+
+	if in.bulk {
+		if err := stageLines(r, batch); err != nil {
+			return err
+		}
+
+		if !merge {
+			return nil
+		}
+	}
+
+	stored := writeReport{}
+	switch {
+	case in.bulk:
+		if err := mergeStagedLines(r, in.config.ConflictPolicies, &stored); err != nil {
+			return err
+		}
+	case in.opts.Mode == user.ModeDelta:
+		if err := storeDeltaChunk(r, batch, in.config.ConflictPolicies, &stored); err != nil {
+			return err
+		}
+	default:
+		if err := storeChunk(r, batch, in.config.ConflictPolicies, &stored); err != nil {
+			return err
+		}
+	}
+
+	if err := in.saveConflicts(r, stored.conflicts); err != nil {
+		return err
+	}
+	if err := in.quarantine(r, stored.rejected); err != nil {
+		return err
+	}
+	report.merge(&stored)
+
+	return nil
+}
+
+// saveConflicts keeps the conflicts in the transaction that stored their lines, so they
+// are only kept when the lines are
+func (in *ingestion) saveConflicts(r *transaction.Repositories, conflicts []*entities.LineConflict) error {
+	for _, conflict := range conflicts {
+		conflict.BatchID = in.opts.BatchID
+		conflict.FileName = in.opts.FileName
+
+		if err := r.Conflicts.Save(conflict); err != nil {
+			return fmt.Errorf("line %d: failed to save conflict: %w", conflict.Line, err)
+		}
+	}
+
+	return nil
+}
+
+// quarantines tells whether the rejected lines are kept, which is only the case of uploads, as
+// the other loads are not batches the lines could be found by. Dry runs keep nothing
+func (in *ingestion) quarantines() bool {
+	return in.preview == nil && in.opts.BatchID != ""
+}
+
+// quarantine keeps the rejected lines in the transaction that rejected them, with the file
+// they come from, so they are only kept when the lines stored with them are
+func (in *ingestion) quarantine(r *transaction.Repositories, lines []*entities.RejectedLine) error {
+	if len(lines) == 0 || !in.quarantines() {
+		return nil
+	}
+
+	for _, line := range lines {
+		line.BatchID = in.opts.BatchID
+		line.FileName = in.opts.FileName
+	}
+
+	if err := r.Quarantine.Save(lines); err != nil {
+		return fmt.Errorf("failed to quarantine rejected lines: %w", err)
+	}
+
+	return nil
+}
+
+// releaseLines takes the lines out of the quarantine
+func (in *ingestion) releaseLines(r *transaction.Repositories, batch []*user.UserFileData) error {
+	lines := make([]int, 0, len(batch))
+	for _, userData := range batch {
+		lines = append(lines, userData.Line)
+	}
+
+	if err := r.Quarantine.Remove(in.opts.BatchID, in.opts.FileName, lines); err != nil {
+		return fmt.Errorf("failed to release reprocessed lines: %w", err)
+	}
+
+	return nil
+}
+
+// quarantineText makes the text of a line storable, replacing the invalid UTF-8 and the NUL
+// characters the database does not accept in a text
+func quarantineText(text string) string {
+	return strings.ReplaceAll(strings.ToValidUTF8(text, "\ufffd"), "\x00", "\ufffd")
+}
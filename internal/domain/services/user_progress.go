@@ -0,0 +1,96 @@
+package services
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+)
+
+// progressInterval is how often the progress of a file being loaded is published
+const progressInterval = 500 * time.Millisecond
+
+// countingReader counts the bytes read through it, which the progress reporter reads
+// while the decoder is still reading
+type countingReader struct {
+	reader io.Reader
+	n      atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n.Add(int64(n))
+
+	return n, err
+}
+
+// publishes tells whether the progress is published, which is only the case of uploads,
+// the loads someone may be following
+func (in *ingestion) publishes() bool {
+	return in.broadcaster != nil && in.opts.BatchID != ""
+}
+
+// report publishes the progress every progressInterval until stop is closed
+func (in *ingestion) report(stop <-chan struct{}) {
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			in.publish(false)
+		}
+	}
+}
+
+// publish sends how far the load got. The time left is estimated from the rate the bytes of
+// the file were read at, so it is only known when the size of the file is
+func (in *ingestion) publish(done bool) {
+	now := time.Now()
+
+	in.mu.Lock()
+	progress := &entities.IngestionProgress{
+		BatchID:       in.opts.BatchID,
+		FileName:      in.opts.FileName,
+		ReadLines:     in.result.ProcessedLines,
+		WrittenLines:  in.written,
+		RejectedLines: in.result.RejectedLines,
+		SkippedLines:  in.result.SkippedLines,
+		TotalBytes:    in.opts.Size,
+		ETA:           -1,
+		Done:          done,
+		StartedAt:     in.startedAt,
+		UpdatedAt:     now,
+	}
+	in.mu.Unlock()
+
+	if in.read != nil {
+		progress.ReadBytes = in.read.n.Load()
+	}
+
+	elapsed := now.Sub(in.startedAt)
+	if elapsed > 0 {
+		progress.LinesPerSecond = float64(progress.ReadLines) / elapsed.Seconds()
+	}
+
+	switch {
+	case done:
+		progress.ETA = 0
+	case progress.TotalBytes > 0 && progress.ReadBytes > 0:
+		left := max(progress.TotalBytes-progress.ReadBytes, 0)
+		progress.ETA = time.Duration(float64(elapsed) * float64(left) / float64(progress.ReadBytes))
+	}
+
+	in.broadcaster.Publish(progress)
+}
+
+// wrote counts the lines handed to the database
+func (in *ingestion) wrote(lines int) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	in.written += lines
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/transaction"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+// quarantinePageSize is how many quarantined lines are read at once
+const quarantinePageSize = 500
+
+// ReprocessRejectedLines loads again the quarantined lines of a file of an upload, after the
+// data they depend on was fixed. Every line is decoded again with the decoder of the format of
+// the file, so it goes through the same validation as when it was first read and keeps its
+// line. The lines stored leave the quarantine in the transaction that stores them, and the
+// ones rejected again stay in it with their new reasons. The orders a snapshot misses were
+// removed when it was loaded, so its lines are reprocessed as the ones of full files
+func (s *userService) ReprocessRejectedLines(ctx context.Context, opts user.LoadOptions) (*user.UserFileResult, error) {
+	if opts.Mode == user.ModeSnapshot {
+		opts.Mode = user.ModeFull
+	}
+
+	recordLayout, err := s.getLayout(opts.Layout)
+	if err != nil {
+		return new(user.UserFileResult), err
+	}
+
+	if _, ok := decoderFactories[opts.Format]; !ok {
+		return new(user.UserFileResult), errors.ErrUnknownFormat
+	}
+
+	ctx = transaction.WithBatch(ctx, opts.BatchID)
+
+	decoder := newQuarantineDecoder(ctx, s.unitOfWork, opts, recordLayout)
+
+	in := newIngestion(s.unitOfWork, s.config, decoder, opts, s.broadcaster)
+	in.reprocess = true
+
+	result, err := in.run(ctx)
+	result.Format = opts.Format
+
+	return result, err
+}
+
+// quarantineDecoder reads the quarantined lines of a file page by page, through read-only
+// transactions, and decodes each of them again in the format of the file
+type quarantineDecoder struct {
+	ctx        context.Context
+	unitOfWork transaction.UnitOfWork
+	opts       user.LoadOptions
+	layout     *entities.RecordLayout
+	fields     []*entities.LayoutField
+
+	page []*entities.RejectedLine
+	// last is the line the next page starts after, done tells the last page was read
+	last int
+	done bool
+}
+
+func newQuarantineDecoder(
+	ctx context.Context,
+	unitOfWork transaction.UnitOfWork,
+	opts user.LoadOptions,
+	layout *entities.RecordLayout,
+) *quarantineDecoder {
+	return &quarantineDecoder{
+		ctx:        ctx,
+		unitOfWork: unitOfWork,
+		opts:       opts,
+		layout:     layout,
+		fields:     decoderFactories[opts.Format](strings.NewReader(""), layout, opts.Mode, 0).Fields(),
+	}
+}
+
+func (d *quarantineDecoder) Fields() []*entities.LayoutField {
+	return d.fields
+}
+
+func (d *quarantineDecoder) Next() (*user.RawRecord, error) {
+	if len(d.page) == 0 {
+		if d.done {
+			return nil, io.EOF
+		}
+
+		if err := d.nextPage(); err != nil {
+			return nil, err
+		}
+		if len(d.page) == 0 {
+			return nil, io.EOF
+		}
+	}
+
+	line := d.page[0]
+	d.page = d.page[1:]
+	d.last = line.Line
+
+	record, err := decodeRejectedLine(line, d.opts.Format, d.opts.Mode, d.layout)
+	if err != nil {
+		return nil, err
+	}
+	record.Line = line.Line
+
+	return record, nil
+}
+
+func (d *quarantineDecoder) nextPage() error {
+	return d.unitOfWork.View(d.ctx, func(r *transaction.Repositories) error {
+		page, err := r.Quarantine.Get(d.opts.BatchID, d.opts.FileName, d.last, quarantinePageSize)
+		if err != nil {
+			return err
+		}
+
+		d.page = page
+		d.done = len(page) < quarantinePageSize
+
+		return nil
+	})
+}
+
+// decodeRejectedLine reads the record of a quarantined line. CSV lines are read under the header
+// they were written back with and JSON array elements, kept in a single line, as NDJSON records.
+// A line that could not be read at all is rejected again for the same reason
+func decodeRejectedLine(
+	line *entities.RejectedLine,
+	format user.Format,
+	mode user.Mode,
+	layout *entities.RecordLayout,
+) (*user.RawRecord, error) {
+	if line.Content == "" {
+		rejection := &entities.LineRejection{Field: "line", Reason: "line could not be read"}
+		if len(line.Rejections) > 0 {
+			rejection = line.Rejections[0]
+		}
+
+		return &user.RawRecord{Rejection: rejection}, nil
+	}
+
+	text := line.Content
+	switch format {
+	case user.FormatCSV:
+		text = csvHeader(mode) + "\n" + text
+	case user.FormatJSON:
+		format = user.FormatNDJSON
+	}
+
+	record, err := decoderFactories[format](strings.NewReader(text), layout, mode, 0).Next()
+	if err == io.EOF {
+		return &user.RawRecord{Skipped: true}, nil
+	}
+
+	return record, err
+}
@@ -1,40 +1,46 @@
 package services
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"log"
-	"mime/multipart"
+	"io"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
-	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/order"
-	orderproducts "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/order_products"
-	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/product"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/layout"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/progress"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/transaction"
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
 )
 
 type userService struct {
-	repository              user.Repository
-	orderRepository         order.Repository
-	productRepository       product.Repository
-	orderProductsRepository orderproducts.Repository
+	repository       user.Repository
+	layoutRepository layout.Repository
+	unitOfWork       transaction.UnitOfWork
+	// broadcaster follows the loads of the uploads, nothing is published when it is nil
+	broadcaster progress.Broadcaster
+	config      user.LoadConfig
 }
 
 func NewUserService(
 	repository user.Repository,
-	orderRepository order.Repository,
-	productRepository product.Repository,
-	orderProductsRepository orderproducts.Repository,
+	layoutRepository layout.Repository,
+	unitOfWork transaction.UnitOfWork,
+	broadcaster progress.Broadcaster,
+	config user.LoadConfig,
 ) *userService {
 	return &userService{
-		repository:              repository,
-		orderRepository:         orderRepository,
-		productRepository:       productRepository,
-		orderProductsRepository: orderProductsRepository,
+		repository:       repository,
+		layoutRepository: layoutRepository,
+		unitOfWork:       unitOfWork,
+		broadcaster:      broadcaster,
+		config:           config,
 	}
 }
 
@@ -51,108 +57,479 @@ func (s *userService) GetUserByID(userId uint) (*entities.User, error) {
 	return user, nil
 }
 
-func (s *userService) LoadUsersDataFile(file multipart.File) int {
-	scanner := bufio.NewScanner(file)
-	usersData := make([]*user.UserFileData, 0)
-	processedLines := 0
+// LoadUsersDataFile streams the file through the ingestion pipeline and stores its valid records
+// as the mode in opts says. A failed transaction or a done ctx stops the load, rolling back the
+// pending transactions, and the result only counts the lines already committed
+func (s *userService) LoadUsersDataFile(
+	ctx context.Context,
+	file io.Reader,
+	opts user.LoadOptions,
+) (*user.UserFileResult, error) {
+	if err := validateMode(opts.Mode); err != nil {
+		return new(user.UserFileResult), err
+	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		processedLines++
-		userData := parseUserDataFromLine(line)
-		usersData = append(usersData, userData)
+	snapshot, err := s.snapshotInterval(opts)
+	if err != nil {
+		return new(user.UserFileResult), err
 	}
 
-	for _, userData := range usersData {
-		// Users
-		user := &entities.User{
-			ID:   userData.UserID,
-			Name: userData.UserName,
-		}
+	recordLayout, err := s.getLayout(opts.Layout)
+	if err != nil {
+		return new(user.UserFileResult), err
+	}
+
+	// The bytes are counted as read from the file, to estimate the time left against its size
+	read := &countingReader{reader: file}
+
+	file, err = newUTF8Reader(read, opts.Encoding)
+	if err != nil {
+		return new(user.UserFileResult), err
+	}
+
+	// The rows written for an upload are tagged with its batch, so the upload can be rolled back
+	if opts.BatchID != "" {
+		ctx = transaction.WithBatch(ctx, opts.BatchID)
+	}
+
+	format, file := detectFormat(file, opts.Format)
+	newDecoder, ok := decoderFactories[format]
+	if !ok {
+		return new(user.UserFileResult), errors.ErrUnknownFormat
+	}
+
+	if err := checkOperationField(format, opts.Mode, recordLayout); err != nil {
+		return &user.UserFileResult{Format: format}, err
+	}
+
+	decoder := newDecoder(file, recordLayout, opts.Mode, s.config.MaxLineSize)
 
-		if err := s.repository.Add(user); err != nil {
-			log.Printf("Failed to create user. Details: %s\n", err.Error())
+	// Only fixed-width files have header and trailer records
+	hasControl := format == user.FormatFixedWidth && (recordLayout.Header != nil || recordLayout.Trailer != nil)
+	if hasControl {
+		decoder = newControlDecoder(decoder, recordLayout, s.config.Location)
+	}
+
+	in := newIngestion(s.unitOfWork, s.config, decoder, opts, s.broadcaster)
+	in.read = read
+	in.verified = hasControl && recordLayout.Trailer != nil
+	in.snapshot = snapshot
+
+	result, err := in.run(ctx)
+	result.Format = format
+
+	return result, err
+}
+
+// ValidateLoadOptions checks the options before a file is accepted, so a wrong choice
+// is reported to the uploader instead of failing the load later
+func (s *userService) ValidateLoadOptions(opts user.LoadOptions) error {
+	if opts.Format != "" && opts.Format != user.FormatAuto {
+		if _, ok := decoderFactories[opts.Format]; !ok {
+			return errors.ErrUnknownFormat
 		}
+	}
+
+	if err := validateEncoding(opts.Encoding); err != nil {
+		return err
+	}
+
+	if err := validateMode(opts.Mode); err != nil {
+		return err
+	}
+
+	if _, err := s.snapshotInterval(opts); err != nil {
+		return err
+	}
+
+	recordLayout, err := s.getLayout(opts.Layout)
+	if err != nil {
+		return err
+	}
+
+	return checkOperationField(opts.Format, opts.Mode, recordLayout)
+}
+
+// validateMode checks the mode is a known one, empty standing for the full mode
+func validateMode(mode user.Mode) error {
+	switch mode {
+	case "", user.ModeFull, user.ModeDelta, user.ModeSnapshot:
+		return nil
+	}
+
+	return errors.ErrUnknownMode
+}
+
+// checkOperationField checks the layout of a fixed-width delta file has the field with the
+// operation of its records, which the text formats always carry
+func checkOperationField(format user.Format, mode user.Mode, recordLayout *entities.RecordLayout) error {
+	if mode != user.ModeDelta || format != user.FormatFixedWidth {
+		return nil
+	}
+
+	if recordLayout.Field(entities.FieldOperation) == nil {
+		return errors.ErrNoOperation
+	}
+
+	return nil
+}
+
+// getLayout returns the registered layout with the given name, or the default one when name is empty
+func (s *userService) getLayout(name string) (*entities.RecordLayout, error) {
+	if name == "" {
+		name = layout.DefaultName
+	}
+
+	return s.layoutRepository.Get(name)
+}
+
+// storeChunk upserts the products of the lines, then the lines one by one
+func storeChunk(
+	r *transaction.Repositories,
+	usersData []*user.UserFileData,
+	policies entities.ConflictPolicies,
+	report *writeReport,
+) error {
+	products, err := storeProducts(r, usersData)
+	if err != nil {
+		return err
+	}
 
-		// Products
-		product := &entities.Product{
-			ID: userData.ProductID,
+	for _, userData := range usersData {
+		if err := storeUserData(r, userData, policies, products, report); err != nil {
+			return err
 		}
+	}
+
+	return nil
+}
 
-		if err := s.productRepository.Add(product); err != nil {
-			log.Printf("Failed to create product. Details: %s\n", err.Error())
+// storeProducts upserts the products of the lines in the order of their IDs, returning what
+// each upsert did. Products are shared by the lines of every writer, so the transactions of
+// the writers lock them in the same order instead of waiting on each other in a deadlock.
+// A product holds nothing but its ID, so it is stored even when its line is later rejected
+func storeProducts(r *transaction.Repositories, usersData []*user.UserFileData) (map[uint]entities.UpsertOutcome, error) {
+	products := make(map[uint]entities.UpsertOutcome)
+	for _, userData := range usersData {
+		if userData.Operation != user.OperationDelete {
+			products[userData.ProductID] = entities.UpsertUnchanged
 		}
+	}
 
-		// Orders
-		order := &entities.Order{
-			ID:     userData.OrderID,
-			UserID: user.ID,
-			Date:   userData.OrderDate,
+	for _, productID := range slices.Sorted(maps.Keys(products)) {
+		outcome, err := r.Products.Upsert(&entities.Product{ID: productID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create product %d: %w", productID, err)
 		}
+		products[productID] = outcome
+	}
+
+	return products, nil
+}
+
+// stageLines copies the lines to the staging table
+func stageLines(r *transaction.Repositories, usersData []*user.UserFileData) error {
+	if err := r.Bulk.Stage(usersData); err != nil {
+		return fmt.Errorf("failed to stage lines: %w", err)
+	}
+
+	return nil
+}
+
+// mergeStagedLines finds the conflicts of the staged lines, discards the lines rejected by
+// them, taking back their text, and merges the others with set-based upserts
+func mergeStagedLines(
+	r *transaction.Repositories,
+	policies entities.ConflictPolicies,
+	report *writeReport,
+) error {
+	conflicts, err := r.Bulk.DetectConflicts(policies)
+	if err != nil {
+		return fmt.Errorf("failed to detect conflicts of staged lines: %w", err)
+	}
 
-		if err := s.orderRepository.Add(order); err != nil {
-			log.Printf("Failed to create order. Details: %s\n", err.Error())
+	sortConflicts(conflicts)
+
+	rejected := make([]int, 0)
+	for i := 0; i < len(conflicts); {
+		line := conflicts[i].Line
+		lineConflicts := make([]*entities.LineConflict, 0)
+		for ; i < len(conflicts) && conflicts[i].Line == line; i++ {
+			describeConflict(conflicts[i])
+			lineConflicts = append(lineConflicts, conflicts[i])
 		}
 
-		// Orders and products related
-		orderProduct := &entities.OrderProduct{
-			OrderID:   order.ID,
-			ProductID: product.ID,
-			Value:     userData.ProductValue,
+		if report.addConflicts(lineConflicts, "") {
+			rejected = append(rejected, line)
 		}
+	}
 
-		if err := s.orderProductsRepository.Add(orderProduct); err != nil {
-			log.Printf("Failed to create product to order. Details: %s\n", err.Error())
+	contents, err := r.Bulk.Discard(rejected)
+	if err != nil {
+		return fmt.Errorf("failed to discard rejected lines: %w", err)
+	}
+	for _, rejectedLine := range report.rejected {
+		if content, ok := contents[rejectedLine.Line]; ok {
+			rejectedLine.Content = content
 		}
 	}
 
-	return processedLines
+	summary, err := r.Bulk.Merge(policies)
+	if err != nil {
+		return fmt.Errorf("failed to merge staged lines: %w", err)
+	}
+	report.writes.Merge(summary)
+
+	return nil
 }
 
-func parseUserDataFromLine(line string) *user.UserFileData {
-	userID := strings.TrimSpace(line[0:10])
-	userName := strings.TrimSpace(line[10:55])
-	orderID := strings.TrimSpace(line[55:65])
-	productID := strings.TrimSpace(line[65:75])
-	productValue := strings.TrimSpace(line[75:87])
-	orderDate := strings.TrimSpace(line[87:95])
+// storeUserData upserts the user, order and order product of a parsed line, counting the
+// outcome of each upsert in report. The line is first compared with the stored user and
+// order, and its conflicts are resolved by the policies
+func storeUserData(
+	r *transaction.Repositories,
+	userData *user.UserFileData,
+	policies entities.ConflictPolicies,
+	products map[uint]entities.UpsertOutcome,
+	report *writeReport,
+) error {
+	stored, err := storeLineOrder(r, userData, policies, products, report)
+	if err != nil || !stored {
+		return err
+	}
 
-	orderYear := orderDate[0:4]
-	orderMonth := orderDate[4:6]
-	orderDay := orderDate[6:8]
-	date := fmt.Sprintf("%s-%s-%s", orderYear, orderMonth, orderDay)
+	return storeOrderProduct(r, userData, report)
+}
 
-	parsedUserId, err := strconv.ParseInt(userID, 10, 64)
+// storeLineOrder upserts the user and order of a parsed line once its conflicts are resolved,
+// telling whether it did or a conflict policy rejected the line. The product, stored by
+// storeProducts, is counted by the first line stored with it and unchanged by the others
+func storeLineOrder(
+	r *transaction.Repositories,
+	userData *user.UserFileData,
+	policies entities.ConflictPolicies,
+	products map[uint]entities.UpsertOutcome,
+	report *writeReport,
+) (bool, error) {
+	storedName, err := getStoredUserName(r, userData)
 	if err != nil {
-		log.Printf("Failed to parse user id [%s]. Details: %s\n", userID, err.Error())
+		return false, err
+	}
+
+	storedOrder, err := getStoredOrder(r, userData)
+	if err != nil {
+		return false, err
+	}
+
+	resolved := resolveLine(policies, userData, storedName, storedOrder)
+	if report.addConflicts(resolved.conflicts, userData.Text) {
+		return false, nil
+	}
+
+	writes := &report.writes
+
+	// Users
+	user := &entities.User{
+		ID:   userData.UserID,
+		Name: resolved.userName,
 	}
 
-	parsedOrderId, err := strconv.ParseInt(orderID, 10, 64)
+	outcome, err := r.Users.Upsert(user)
 	if err != nil {
-		log.Printf("Failed to parse order id [%s]. Details: %s\n", orderID, err.Error())
+		return false, fmt.Errorf("line %d: failed to create user: %w", userData.Line, err)
 	}
+	writes.Users.Count(outcome)
 
-	parsedProductId, err := strconv.ParseInt(productID, 10, 64)
+	// Products
+	writes.Products.Count(products[userData.ProductID])
+	products[userData.ProductID] = entities.UpsertUnchanged
+
+	// Orders
+	order := &entities.Order{
+		ID:     userData.OrderID,
+		UserID: resolved.orderUserID,
+		Date:   resolved.orderDate,
+	}
+
+	outcome, err = r.Orders.Upsert(order)
 	if err != nil {
-		log.Printf("Failed to parse product id [%s]. Details: %s\n", productID, err.Error())
+		return false, fmt.Errorf("line %d: failed to create order: %w", userData.Line, err)
 	}
+	writes.Orders.Count(outcome)
+
+	return true, nil
+}
 
-	parsedProductValue, err := strconv.ParseFloat(productValue, 64)
+// storeOrderProduct upserts the order product of a line whose order is stored
+func storeOrderProduct(r *transaction.Repositories, userData *user.UserFileData, report *writeReport) error {
+	orderProduct := &entities.OrderProduct{
+		OrderID:   userData.OrderID,
+		ProductID: userData.ProductID,
+		Value:     userData.ProductValue,
+		Position:  userData.Position,
+	}
+
+	outcome, err := r.OrderProducts.Upsert(orderProduct)
 	if err != nil {
-		log.Printf("Failed to parse product value [%s]. Details: %s\n", productID, err.Error())
+		return fmt.Errorf("line %d: failed to create product to order: %w", userData.Line, err)
+	}
+	report.writes.OrderProducts.Count(outcome)
+
+	return nil
+}
+
+// parseUserDataFromRecord validates the raw field values of a record, returning every field
+// that could not be parsed as a rejection. Dates are read in the location. The returned data
+// must only be used when there are no rejections
+func parseUserDataFromRecord(
+	fields []*entities.LayoutField,
+	values map[string]string,
+	location *time.Location,
+) (*user.UserFileData, []*entities.LineRejection) {
+	userData := new(user.UserFileData)
+	rejections := make([]*entities.LineRejection, 0)
+
+	for _, field := range fields {
+		value := values[field.Name]
+		if err := setUserDataField(userData, field, value, location); err != nil {
+			rejections = append(rejections, &entities.LineRejection{
+				Field:  field.Name,
+				Value:  value,
+				Reason: err.Error(),
+			})
+		}
+	}
+
+	if len(rejections) > 0 {
+		return nil, rejections
+	}
+
+	return userData, nil
+}
+
+// setUserDataField parses a field value by its type and sets it in the user data
+func setUserDataField(
+	userData *user.UserFileData,
+	field *entities.LayoutField,
+	value string,
+	location *time.Location,
+) error {
+	// The operation is the only string field besides the user name
+	if field.Name == entities.FieldOperation {
+		operation, err := parseOperation(value)
+		if err != nil {
+			return err
+		}
+		userData.Operation = operation
+		return nil
+	}
+
+	switch field.Type {
+	case entities.FieldInteger:
+		id, err := parseID(value)
+		if err != nil {
+			return err
+		}
+
+		switch field.Name {
+		case entities.FieldUserID:
+			userData.UserID = id
+		case entities.FieldOrderID:
+			userData.OrderID = id
+		case entities.FieldProductID:
+			userData.ProductID = id
+		}
+	case entities.FieldString:
+		if value == "" {
+			return fmt.Errorf("must not be empty")
+		}
+		if !utf8.ValidString(value) {
+			return fmt.Errorf("must be valid UTF-8, send the encoding of the file")
+		}
+		userData.UserName = value
+	case entities.FieldDecimal:
+		productValue, err := parseMoney(value, field.Decimals)
+		if err != nil {
+			return err
+		}
+		userData.ProductValue = productValue
+	case entities.FieldDate:
+		orderDate, err := parseDate(value, field, location)
+		if err != nil {
+			return err
+		}
+		userData.OrderDate = orderDate
+	}
+
+	return nil
+}
+
+// parseMoney parses a non-negative decimal number as an exact amount of money, rounded to
+// cents. Values without a decimal point have the given amount of implied decimal places
+func parseMoney(value string, decimals int) (entities.Money, error) {
+	if decimals > 0 && !strings.ContainsAny(value, ".eE") {
+		value = strings.Repeat("0", max(decimals-len(value)+1, 0)) + value
+		value = value[:len(value)-decimals] + "." + value[len(value)-decimals:]
+	}
+
+	money, err := entities.ParseMoney(value)
+	if err != nil || money < 0 {
+		return 0, fmt.Errorf("must be a non-negative decimal number")
+	}
+
+	return money, nil
+}
+
+// parseDate parses a date in the field format or, when the field has no format, as
+// YYYY-MM-DD or as a RFC 3339 timestamp. Dates are the midnight of the day in the location,
+// UTC when nil, and timestamps keep their instant but are moved to the location too, so
+// every date prints as the day of the business
+func parseDate(value string, field *entities.LayoutField, location *time.Location) (time.Time, error) {
+	if location == nil {
+		location = time.UTC
 	}
 
-	parsedOrderDate, err := time.Parse(time.DateOnly, date)
+	if field.Format != "" {
+		date, err := time.ParseInLocation(field.TimeLayout(), value, location)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("must be a valid date in %s format", field.Format)
+		}
+		return date, nil
+	}
+
+	if date, err := time.ParseInLocation(time.DateOnly, value, location); err == nil {
+		return date, nil
+	}
+
+	date, err := time.Parse(time.RFC3339, value)
 	if err != nil {
-		log.Printf("Failed to parse order date [%s]. Details: %s\n", date, err.Error())
+		return time.Time{}, fmt.Errorf("must be a valid date in YYYY-MM-DD or RFC 3339 format")
+	}
+
+	return date.In(location), nil
+}
+
+// parseOperation parses the operation code of a record of a delta file, either the name of the
+// operation or its initial, in any case
+func parseOperation(value string) (user.Operation, error) {
+	switch strings.ToLower(value) {
+	case "i", string(user.OperationInsert):
+		return user.OperationInsert, nil
+	case "u", string(user.OperationUpdate):
+		return user.OperationUpdate, nil
+	case "d", string(user.OperationDelete):
+		return user.OperationDelete, nil
 	}
 
-	return &user.UserFileData{
-		UserID:       uint(parsedUserId),
-		UserName:     userName,
-		OrderID:      uint(parsedOrderId),
-		ProductID:    uint(parsedProductId),
-		ProductValue: parsedProductValue,
-		OrderDate:    parsedOrderDate,
+	return "", fmt.Errorf("must be an operation: I (insert), U (update) or D (delete)")
+}
+
+// parseID parses an identifier field, which must be a positive integer
+func parseID(value string) (uint, error) {
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("must be a positive integer")
 	}
+
+	return uint(id), nil
 }
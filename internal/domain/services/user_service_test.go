@@ -1,18 +1,30 @@
 package services_test
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/layout"
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/conflict"
+	mocklayout "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/layout"
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/order"
 	orderproducts "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/order_products"
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/product"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/progress"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/quarantine"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/transaction"
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/services/mocks/user"
+	domaintransaction "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/transaction"
+	domainuser "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
 	"go.uber.org/mock/gomock"
 )
 
@@ -103,9 +115,10 @@ func Test_GetUserById_UserService(t *testing.T) {
 
 			userService := services.NewUserService(
 				mur,
-				mor,
-				mpr,
-				mopr,
+				mocklayout.NewMockRepository(ctrl),
+				transaction.NewMockUnitOfWork(ctrl),
+				nil,
+				domainuser.LoadConfig{},
 			)
 
 			u, err := userService.GetUserByID(uint(mockUserId))
@@ -142,7 +155,8 @@ func Test_LoadUsersDataFile_UserService(t *testing.T) {
 	mockOrderProduct := &entities.OrderProduct{
 		OrderID:   753,
 		ProductID: 3,
-		Value:     1836.74,
+		Value:     183674,
+		Position:  1,
 	}
 
 	mockUsers := []*entities.User{
@@ -174,7 +188,8 @@ func Test_LoadUsersDataFile_UserService(t *testing.T) {
 		{
 			OrderID:   798,
 			ProductID: 2,
-			Value:     1578.57,
+			Value:     157857,
+			Position:  1,
 		},
 	}
 
@@ -188,6 +203,13 @@ func Test_LoadUsersDataFile_UserService(t *testing.T) {
 			mopr *orderproducts.MockRepository,
 		)
 		expectedProcessedLines int
+		expectedAcceptedLines  int
+		expectedRejectedLines  int
+		expectedSkippedLines   int
+		expectedRejections     []*entities.LineRejection
+		expectedWrites         *entities.WriteSummary
+		chunkSize              int
+		isErrExpected          bool
 	}{
 		{
 			description: "should process a single line and save parsed data from file",
@@ -198,12 +220,13 @@ func Test_LoadUsersDataFile_UserService(t *testing.T) {
 				mpr *product.MockRepository,
 				mopr *orderproducts.MockRepository,
 			) {
-				mur.EXPECT().Add(mockUser).Return(nil)
-				mpr.EXPECT().Add(mockProduct).Return(nil)
-				mor.EXPECT().Add(mockOrder).Return(nil)
-				mopr.EXPECT().Add(mockOrderProduct).Return(nil)
+				mur.EXPECT().Upsert(mockUser).Return(entities.UpsertInserted, nil)
+				mpr.EXPECT().Upsert(mockProduct).Return(entities.UpsertInserted, nil)
+				mor.EXPECT().Upsert(mockOrder).Return(entities.UpsertInserted, nil)
+				mopr.EXPECT().Upsert(mockOrderProduct).Return(entities.UpsertInserted, nil)
 			},
 			expectedProcessedLines: 1,
+			expectedAcceptedLines:  1,
 		},
 		{
 			description: "should process multiple valid lines and save parsed data from file",
@@ -215,22 +238,23 @@ func Test_LoadUsersDataFile_UserService(t *testing.T) {
 				mopr *orderproducts.MockRepository,
 			) {
 				for _, mockUser := range mockUsers {
-					mur.EXPECT().Add(mockUser).Return(nil)
+					mur.EXPECT().Upsert(mockUser).Return(entities.UpsertInserted, nil)
 				}
 				for _, mockProduct := range mockProducts {
-					mpr.EXPECT().Add(mockProduct).Return(nil)
+					mpr.EXPECT().Upsert(mockProduct).Return(entities.UpsertInserted, nil)
 				}
 				for _, mockOrder := range mockOrders {
-					mor.EXPECT().Add(mockOrder).Return(nil)
+					mor.EXPECT().Upsert(mockOrder).Return(entities.UpsertInserted, nil)
 				}
 				for _, mockOrderProduct := range mockOrderProducts {
-					mopr.EXPECT().Add(mockOrderProduct).Return(nil)
+					mopr.EXPECT().Upsert(mockOrderProduct).Return(entities.UpsertInserted, nil)
 				}
 			},
 			expectedProcessedLines: 2,
+			expectedAcceptedLines:  2,
 		},
 		{
-			description: "should process a valid line and handle user error",
+			description: "should roll back the file and return error when user fails",
 			mockedFile:  "./mocks/user/mock_failed_data_file.txt",
 			setMocks: func(
 				mur *user.MockRepository,
@@ -238,15 +262,14 @@ func Test_LoadUsersDataFile_UserService(t *testing.T) {
 				mpr *product.MockRepository,
 				mopr *orderproducts.MockRepository,
 			) {
-				mur.EXPECT().Add(mockUser).Return(assert.AnError)
-				mpr.EXPECT().Add(mockProduct).Return(nil)
-				mor.EXPECT().Add(mockOrder).Return(nil)
-				mopr.EXPECT().Add(mockOrderProduct).Return(nil)
+				mpr.EXPECT().Upsert(mockProduct).Return(entities.UpsertInserted, nil)
+				mur.EXPECT().Upsert(mockUser).Return(entities.UpsertUnchanged, assert.AnError)
 			},
 			expectedProcessedLines: 1,
+			isErrExpected:          true,
 		},
 		{
-			description: "should process a valid line and handle product error",
+			description: "should roll back the file and return error when product fails",
 			mockedFile:  "./mocks/user/mock_failed_data_file.txt",
 			setMocks: func(
 				mur *user.MockRepository,
@@ -254,15 +277,13 @@ func Test_LoadUsersDataFile_UserService(t *testing.T) {
 				mpr *product.MockRepository,
 				mopr *orderproducts.MockRepository,
 			) {
-				mur.EXPECT().Add(mockUser).Return(nil)
-				mpr.EXPECT().Add(mockProduct).Return(assert.AnError)
-				mor.EXPECT().Add(mockOrder).Return(nil)
-				mopr.EXPECT().Add(mockOrderProduct).Return(nil)
+				mpr.EXPECT().Upsert(mockProduct).Return(entities.UpsertUnchanged, assert.AnError)
 			},
 			expectedProcessedLines: 1,
+			isErrExpected:          true,
 		},
 		{
-			description: "should process a valid line and handle order error",
+			description: "should roll back the file and return error when order fails",
 			mockedFile:  "./mocks/user/mock_failed_data_file.txt",
 			setMocks: func(
 				mur *user.MockRepository,
@@ -270,15 +291,15 @@ func Test_LoadUsersDataFile_UserService(t *testing.T) {
 				mpr *product.MockRepository,
 				mopr *orderproducts.MockRepository,
 			) {
-				mur.EXPECT().Add(mockUser).Return(nil)
-				mpr.EXPECT().Add(mockProduct).Return(nil)
-				mor.EXPECT().Add(mockOrder).Return(assert.AnError)
-				mopr.EXPECT().Add(mockOrderProduct).Return(nil)
+				mur.EXPECT().Upsert(mockUser).Return(entities.UpsertInserted, nil)
+				mpr.EXPECT().Upsert(mockProduct).Return(entities.UpsertInserted, nil)
+				mor.EXPECT().Upsert(mockOrder).Return(entities.UpsertUnchanged, assert.AnError)
 			},
 			expectedProcessedLines: 1,
+			isErrExpected:          true,
 		},
 		{
-			description: "should process a valid line and handle order products error",
+			description: "should roll back the file and return error when order products fails",
 			mockedFile:  "./mocks/user/mock_failed_data_file.txt",
 			setMocks: func(
 				mur *user.MockRepository,
@@ -286,12 +307,13 @@ func Test_LoadUsersDataFile_UserService(t *testing.T) {
 				mpr *product.MockRepository,
 				mopr *orderproducts.MockRepository,
 			) {
-				mur.EXPECT().Add(mockUser).Return(nil)
-				mpr.EXPECT().Add(mockProduct).Return(nil)
-				mor.EXPECT().Add(mockOrder).Return(nil)
-				mopr.EXPECT().Add(mockOrderProduct).Return(assert.AnError)
+				mur.EXPECT().Upsert(mockUser).Return(entities.UpsertInserted, nil)
+				mpr.EXPECT().Upsert(mockProduct).Return(entities.UpsertInserted, nil)
+				mor.EXPECT().Upsert(mockOrder).Return(entities.UpsertInserted, nil)
+				mopr.EXPECT().Upsert(mockOrderProduct).Return(entities.UpsertUnchanged, assert.AnError)
 			},
 			expectedProcessedLines: 1,
+			isErrExpected:          true,
 		},
 		{
 			description: "should process an empty file and return zero lines processed",
@@ -305,6 +327,79 @@ func Test_LoadUsersDataFile_UserService(t *testing.T) {
 			},
 			expectedProcessedLines: 0,
 		},
+		{
+			description: "should reject invalid lines with their field errors and skip blank lines",
+			mockedFile:  "./mocks/user/mock_invalid_data_file.txt",
+			setMocks: func(
+				mur *user.MockRepository,
+				mor *order.MockRepository,
+				mpr *product.MockRepository,
+				mopr *orderproducts.MockRepository,
+			) {
+				mur.EXPECT().Upsert(mockUser).Return(entities.UpsertInserted, nil)
+				mpr.EXPECT().Upsert(mockProduct).Return(entities.UpsertInserted, nil)
+				mor.EXPECT().Upsert(mockOrder).Return(entities.UpsertInserted, nil)
+				mopr.EXPECT().Upsert(mockOrderProduct).Return(entities.UpsertInserted, nil)
+			},
+			expectedProcessedLines: 4,
+			expectedAcceptedLines:  1,
+			expectedRejectedLines:  2,
+			expectedSkippedLines:   1,
+			expectedRejections: []*entities.LineRejection{
+				{Line: 3, Field: "user_id", Value: "00000000AB", Reason: "must be a positive integer"},
+				{Line: 3, Field: "order_date", Value: "20211399", Reason: "must be a valid date in YYYYMMDD format"},
+				{Line: 4, Field: "line", Value: "0000000070 Palmer Prosacco", Reason: "line has 26 characters, expected 95"},
+			},
+		},
+		{
+			description: "should number equal lines by position and count the upsert outcomes",
+			mockedFile:  "./mocks/user/mock_duplicated_data_file.txt",
+			setMocks: func(
+				mur *user.MockRepository,
+				mor *order.MockRepository,
+				mpr *product.MockRepository,
+				mopr *orderproducts.MockRepository,
+			) {
+				secondOrderProduct := *mockOrderProduct
+				secondOrderProduct.Position = 2
+
+				mur.EXPECT().Upsert(mockUser).Return(entities.UpsertUnchanged, nil).Times(2)
+				mpr.EXPECT().Upsert(mockProduct).Return(entities.UpsertUnchanged, nil)
+				mor.EXPECT().Upsert(mockOrder).Return(entities.UpsertUpdated, nil)
+				mor.EXPECT().Upsert(mockOrder).Return(entities.UpsertUnchanged, nil)
+				mopr.EXPECT().Upsert(mockOrderProduct).Return(entities.UpsertUnchanged, nil)
+				mopr.EXPECT().Upsert(&secondOrderProduct).Return(entities.UpsertInserted, nil)
+			},
+			expectedProcessedLines: 2,
+			expectedAcceptedLines:  2,
+			expectedWrites: &entities.WriteSummary{
+				Users:         entities.WriteCounts{Unchanged: 2},
+				Products:      entities.WriteCounts{Unchanged: 2},
+				Orders:        entities.WriteCounts{Updated: 1, Unchanged: 1},
+				OrderProducts: entities.WriteCounts{Inserted: 1, Unchanged: 1},
+			},
+		},
+		{
+			description: "should keep committed chunks and stop when a chunk fails",
+			mockedFile:  "./mocks/user/mock_mult_data_file.txt",
+			chunkSize:   1,
+			setMocks: func(
+				mur *user.MockRepository,
+				mor *order.MockRepository,
+				mpr *product.MockRepository,
+				mopr *orderproducts.MockRepository,
+			) {
+				mur.EXPECT().Upsert(mockUsers[0]).Return(entities.UpsertInserted, nil)
+				mpr.EXPECT().Upsert(mockProducts[0]).Return(entities.UpsertInserted, nil)
+				mor.EXPECT().Upsert(mockOrders[0]).Return(entities.UpsertInserted, nil)
+				mopr.EXPECT().Upsert(mockOrderProducts[0]).Return(entities.UpsertInserted, nil)
+				mpr.EXPECT().Upsert(mockProducts[1]).Return(entities.UpsertInserted, nil)
+				mur.EXPECT().Upsert(mockUsers[1]).Return(entities.UpsertUnchanged, assert.AnError)
+			},
+			expectedProcessedLines: 2,
+			expectedAcceptedLines:  1,
+			isErrExpected:          true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -324,11 +419,33 @@ func Test_LoadUsersDataFile_UserService(t *testing.T) {
 				mopr,
 			)
 
+			// Every line is compared with the stored user and order, none is stored here
+			mur.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrUserNotFound).AnyTimes()
+			mor.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrOrderNotFound).AnyTimes()
+
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			muow.
+				EXPECT().
+				Do(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+					return fn(&domaintransaction.Repositories{
+						Users:         mur,
+						Products:      mpr,
+						Orders:        mor,
+						OrderProducts: mopr,
+					})
+				}).
+				AnyTimes()
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get(layout.DefaultName).Return(layout.Default(), nil).AnyTimes()
+
 			userService := services.NewUserService(
 				mur,
-				mor,
-				mpr,
-				mopr,
+				mlr,
+				muow,
+				nil,
+				domainuser.LoadConfig{ChunkSize: tt.chunkSize},
 			)
 
 			file, err := os.Open(tt.mockedFile)
@@ -337,8 +454,2550 @@ func Test_LoadUsersDataFile_UserService(t *testing.T) {
 			}
 			defer file.Close()
 
-			processedLines := userService.LoadUsersDataFile(file)
-			assert.Equal(t, tt.expectedProcessedLines, processedLines)
+			result, err := userService.LoadUsersDataFile(context.Background(), file, domainuser.LoadOptions{})
+			if tt.isErrExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.expectedProcessedLines, result.ProcessedLines)
+			assert.Equal(t, tt.expectedAcceptedLines, result.AcceptedLines)
+			assert.Equal(t, tt.expectedRejectedLines, result.RejectedLines)
+			assert.Equal(t, tt.expectedSkippedLines, result.SkippedLines)
+			if tt.expectedRejections != nil {
+				assert.Equal(t, tt.expectedRejections, result.Rejections)
+			}
+			if tt.expectedWrites != nil {
+				assert.Equal(t, *tt.expectedWrites, result.Writes)
+			}
+		})
+	}
+}
+
+func Test_LoadUsersDataFile_UserService_Bulk(t *testing.T) {
+	mockUsersData := []*domainuser.UserFileData{
+		{
+			Line:         1,
+			Position:     1,
+			UserID:       70,
+			UserName:     "Palmer Prosacco",
+			OrderID:      753,
+			ProductID:    3,
+			ProductValue: 183674,
+			OrderDate:    time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Line:         2,
+			Position:     1,
+			UserID:       75,
+			UserName:     "Bobbie Batz",
+			OrderID:      798,
+			ProductID:    2,
+			ProductValue: 157857,
+			OrderDate:    time.Date(2021, 11, 16, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	mockWrites := entities.WriteSummary{
+		Users:         entities.WriteCounts{Inserted: 2},
+		Products:      entities.WriteCounts{Inserted: 1, Unchanged: 1},
+		Orders:        entities.WriteCounts{Inserted: 2},
+		OrderProducts: entities.WriteCounts{Inserted: 2},
+	}
+
+	mockPolicies := entities.ConflictPolicies{entities.ConflictOrderUser: entities.ConflictReject}
+
+	mockConflict := &entities.LineConflict{
+		Line:     2,
+		Type:     entities.ConflictOrderUser,
+		Value:    "798",
+		Existing: "12",
+		Incoming: "75",
+		Policy:   entities.ConflictReject,
+	}
+
+	tests := []struct {
+		description           string
+		setMocks              func(mbr *user.MockBulkRepository, mcr *conflict.MockRepository)
+		expectedAcceptedLines int
+		expectedRejectedLines int
+		expectedWrites        entities.WriteSummary
+		isErrExpected         bool
+	}{
+		{
+			description: "should stage and merge the lines when the file reaches the bulk threshold",
+			setMocks: func(mbr *user.MockBulkRepository, mcr *conflict.MockRepository) {
+				mbr.EXPECT().Stage(mockUsersData).Return(nil)
+				mbr.EXPECT().DetectConflicts(mockPolicies).Return([]*entities.LineConflict{}, nil)
+				mbr.EXPECT().Discard([]int{}).Return(map[int]string{}, nil)
+				mbr.EXPECT().Merge(mockPolicies).Return(mockWrites, nil)
+			},
+			expectedAcceptedLines: 2,
+			expectedWrites:        mockWrites,
+		},
+		{
+			description: "should discard and save the lines rejected by a conflict before merging",
+			setMocks: func(mbr *user.MockBulkRepository, mcr *conflict.MockRepository) {
+				mbr.EXPECT().Stage(mockUsersData).Return(nil)
+				mbr.EXPECT().DetectConflicts(mockPolicies).Return([]*entities.LineConflict{mockConflict}, nil)
+				mbr.EXPECT().Discard([]int{2}).Return(map[int]string{2: "line 2"}, nil)
+				mbr.EXPECT().Merge(mockPolicies).Return(mockWrites, nil)
+				mcr.EXPECT().Save(mockConflict).Return(nil)
+			},
+			expectedAcceptedLines: 1,
+			expectedRejectedLines: 1,
+			expectedWrites:        mockWrites,
+		},
+		{
+			description: "should return error when staging fails",
+			setMocks: func(mbr *user.MockBulkRepository, mcr *conflict.MockRepository) {
+				mbr.EXPECT().Stage(mockUsersData).Return(assert.AnError)
+			},
+			isErrExpected: true,
+		},
+		{
+			description: "should return error when conflicts can not be detected",
+			setMocks: func(mbr *user.MockBulkRepository, mcr *conflict.MockRepository) {
+				mbr.EXPECT().Stage(mockUsersData).Return(nil)
+				mbr.EXPECT().DetectConflicts(mockPolicies).Return(nil, assert.AnError)
+			},
+			isErrExpected: true,
+		},
+		{
+			description: "should return error when merging fails",
+			setMocks: func(mbr *user.MockBulkRepository, mcr *conflict.MockRepository) {
+				mbr.EXPECT().Stage(mockUsersData).Return(nil)
+				mbr.EXPECT().DetectConflicts(mockPolicies).Return([]*entities.LineConflict{}, nil)
+				mbr.EXPECT().Discard([]int{}).Return(map[int]string{}, nil)
+				mbr.EXPECT().Merge(mockPolicies).Return(entities.WriteSummary{}, assert.AnError)
+			},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mur := user.NewMockRepository(ctrl)
+			mbr := user.NewMockBulkRepository(ctrl)
+			mcr := conflict.NewMockRepository(ctrl)
+			tt.setMocks(mbr, mcr)
+
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			muow.
+				EXPECT().
+				Do(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+					return fn(&domaintransaction.Repositories{
+						Users:     mur,
+						Bulk:      mbr,
+						Conflicts: mcr,
+					})
+				})
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get(layout.DefaultName).Return(layout.Default(), nil).AnyTimes()
+
+			userService := services.NewUserService(
+				mur,
+				mlr,
+				muow,
+				nil,
+				domainuser.LoadConfig{BulkThreshold: 2, ConflictPolicies: mockPolicies},
+			)
+
+			file, err := os.Open("./mocks/user/mock_mult_data_file.txt")
+			if err != nil {
+				panic(err)
+			}
+			defer file.Close()
+
+			result, err := userService.LoadUsersDataFile(context.Background(), file, domainuser.LoadOptions{})
+			if tt.isErrExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, 2, result.ProcessedLines)
+			assert.Equal(t, tt.expectedAcceptedLines, result.AcceptedLines)
+			assert.Equal(t, tt.expectedRejectedLines, result.RejectedLines)
+			assert.Equal(t, tt.expectedWrites, result.Writes)
+		})
+	}
+}
+
+func Test_LoadUsersDataFile_UserService_Conflicts(t *testing.T) {
+	orderDate := time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC)
+	storedUser := &entities.User{ID: 70, Name: "Palmer P."}
+	storedOrder := &entities.Order{ID: 753, UserID: 12, Date: orderDate.AddDate(0, 0, -1)}
+	mockOpts := domainuser.LoadOptions{BatchID: "a1b2c3", FileName: "users.txt"}
+	mockSingleLine := "0000000070                              Palmer Prosacco00000007530000000003     1836.7420210308"
+
+	orderUserConflict := func(policy entities.ConflictPolicy) *entities.LineConflict {
+		return &entities.LineConflict{
+			BatchID:  "a1b2c3",
+			FileName: "users.txt",
+			Line:     1,
+			Type:     entities.ConflictOrderUser,
+			Field:    entities.FieldUserID,
+			Value:    "753",
+			Existing: "12",
+			Incoming: "70",
+			Policy:   policy,
+			Reason:   "order 753 already belongs to user 12",
+		}
+	}
+	orderDateConflict := func(policy entities.ConflictPolicy) *entities.LineConflict {
+		return &entities.LineConflict{
+			BatchID:  "a1b2c3",
+			FileName: "users.txt",
+			Line:     1,
+			Type:     entities.ConflictOrderDate,
+			Field:    entities.FieldOrderDate,
+			Value:    "753",
+			Existing: "2021-03-07",
+			Incoming: "2021-03-08",
+			Policy:   policy,
+			Reason:   "order 753 already has the date 2021-03-07",
+		}
+	}
+	userNameConflict := func(policy entities.ConflictPolicy) *entities.LineConflict {
+		return &entities.LineConflict{
+			BatchID:  "a1b2c3",
+			FileName: "users.txt",
+			Line:     1,
+			Type:     entities.ConflictUserName,
+			Field:    entities.FieldUserName,
+			Value:    "70",
+			Existing: "Palmer P.",
+			Incoming: "Palmer Prosacco",
+			Policy:   policy,
+			Reason:   "user 70 is already named Palmer P.",
+		}
+	}
+
+	tests := []struct {
+		description       string
+		policies          entities.ConflictPolicies
+		expectedUser      *entities.User
+		expectedOrder     *entities.Order
+		expectedConflicts []*entities.LineConflict
+		expectedRejected  int
+	}{
+		{
+			description:   "should replace the stored values when the last write wins",
+			expectedUser:  &entities.User{ID: 70, Name: "Palmer Prosacco"},
+			expectedOrder: &entities.Order{ID: 753, UserID: 70, Date: orderDate},
+			expectedConflicts: []*entities.LineConflict{
+				orderUserConflict(entities.ConflictLastWriteWins),
+				orderDateConflict(entities.ConflictLastWriteWins),
+				userNameConflict(entities.ConflictLastWriteWins),
+			},
+		},
+		{
+			description: "should keep the stored values of the conflict types that keep the first one",
+			policies: entities.ConflictPolicies{
+				entities.ConflictOrderUser: entities.ConflictKeepFirst,
+				entities.ConflictUserName:  entities.ConflictKeepFirst,
+			},
+			expectedUser:  &entities.User{ID: 70, Name: "Palmer P."},
+			expectedOrder: &entities.Order{ID: 753, UserID: 12, Date: orderDate},
+			expectedConflicts: []*entities.LineConflict{
+				orderUserConflict(entities.ConflictKeepFirst),
+				orderDateConflict(entities.ConflictLastWriteWins),
+				userNameConflict(entities.ConflictKeepFirst),
+			},
+		},
+		{
+			description: "should reject the whole line when a conflict policy rejects it",
+			policies:    entities.ConflictPolicies{entities.ConflictOrderDate: entities.ConflictReject},
+			expectedConflicts: []*entities.LineConflict{
+				orderUserConflict(entities.ConflictLastWriteWins),
+				orderDateConflict(entities.ConflictReject),
+				userNameConflict(entities.ConflictLastWriteWins),
+			},
+			expectedRejected: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mur := user.NewMockRepository(ctrl)
+			mor := order.NewMockRepository(ctrl)
+			mpr := product.NewMockRepository(ctrl)
+			mopr := orderproducts.NewMockRepository(ctrl)
+			mcr := conflict.NewMockRepository(ctrl)
+			mqr := quarantine.NewMockRepository(ctrl)
+
+			mur.EXPECT().Get(uint(70)).Return(storedUser, nil)
+			mor.EXPECT().Get(uint(753)).Return(storedOrder, nil)
+			for _, expected := range tt.expectedConflicts {
+				mcr.EXPECT().Save(expected).Return(nil)
+			}
+
+			// Products are stored before the lines. The rejected line is quarantined as it was
+			// read, the other upserts are only expected when it is stored
+			mpr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertUnchanged, nil)
+			if tt.expectedRejected > 0 {
+				mqr.EXPECT().Save([]*entities.RejectedLine{
+					{
+						BatchID:  "a1b2c3",
+						FileName: "users.txt",
+						Line:     1,
+						Content:  mockSingleLine,
+						Rejections: []*entities.LineRejection{
+							{Line: 1, Field: entities.FieldOrderDate, Value: "2021-03-08", Reason: "order 753 already has the date 2021-03-07"},
+						},
+					},
+				}).Return(nil)
+			} else {
+				mur.EXPECT().Upsert(tt.expectedUser).Return(entities.UpsertUpdated, nil)
+				mor.EXPECT().Upsert(tt.expectedOrder).Return(entities.UpsertUpdated, nil)
+				mopr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil)
+			}
+
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			muow.
+				EXPECT().
+				Do(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+					return fn(&domaintransaction.Repositories{
+						Users:         mur,
+						Products:      mpr,
+						Orders:        mor,
+						OrderProducts: mopr,
+						Conflicts:     mcr,
+						Quarantine:    mqr,
+					})
+				})
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get(layout.DefaultName).Return(layout.Default(), nil).AnyTimes()
+
+			userService := services.NewUserService(mur, mlr, muow, nil, domainuser.LoadConfig{ConflictPolicies: tt.policies})
+
+			file, err := os.Open("./mocks/user/mock_single_data_file.txt")
+			if err != nil {
+				panic(err)
+			}
+			defer file.Close()
+
+			result, err := userService.LoadUsersDataFile(context.Background(), file, mockOpts)
+
+			assert.NoError(t, err)
+			assert.Equal(t, 1-tt.expectedRejected, result.AcceptedLines)
+			assert.Equal(t, tt.expectedRejected, result.RejectedLines)
+			assert.Equal(t, tt.expectedConflicts, result.Conflicts)
+			if tt.expectedRejected > 0 {
+				assert.Equal(t, []*entities.LineRejection{
+					{Line: 1, Field: entities.FieldOrderDate, Value: "2021-03-08", Reason: "order 753 already has the date 2021-03-07"},
+				}, result.Rejections)
+				assert.Equal(t, entities.WriteSummary{}, result.Writes)
+			}
+		})
+	}
+}
+
+func Test_LoadUsersDataFile_UserService_Pipeline(t *testing.T) {
+	// 60 lines spread over 6 orders, each order listing its products in increasing id order
+	buf := new(strings.Builder)
+	for i := 0; i < 60; i++ {
+		fmt.Fprintf(
+			buf,
+			"%010d%45s%010d%010d%12.2f%s\n",
+			i%6+1,
+			fmt.Sprintf("User %d", i%6+1),
+			i%6+1,
+			i+1,
+			10.5,
+			"20210308",
+		)
+	}
+	mockFileContent := buf.String()
+
+	tests := []struct {
+		description           string
+		chunkSize             int
+		cancel                bool
+		expectedAcceptedLines int
+		isErrExpected         bool
+	}{
+		{
+			description:           "should keep the lines of each order in file order in a single transaction",
+			chunkSize:             0,
+			expectedAcceptedLines: 60,
+		},
+		{
+			description:           "should keep the lines of each order in file order with a transaction per chunk",
+			chunkSize:             4,
+			expectedAcceptedLines: 60,
+		},
+		{
+			description:   "should stop when the context is canceled",
+			chunkSize:     4,
+			cancel:        true,
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mur := user.NewMockRepository(ctrl)
+			mor := order.NewMockRepository(ctrl)
+			mpr := product.NewMockRepository(ctrl)
+			mopr := orderproducts.NewMockRepository(ctrl)
+
+			mur.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+			mpr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+			mor.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+
+			// Every line is compared with the stored user and order, none is stored here
+			mur.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrUserNotFound).AnyTimes()
+			mor.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrOrderNotFound).AnyTimes()
+
+			var mu sync.Mutex
+			written := make(map[uint][]uint)
+			mopr.
+				EXPECT().
+				Upsert(gomock.Any()).
+				DoAndReturn(func(orderProduct *entities.OrderProduct) (entities.UpsertOutcome, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					written[orderProduct.OrderID] = append(written[orderProduct.OrderID], orderProduct.ProductID)
+					return entities.UpsertInserted, nil
+				}).
+				AnyTimes()
+
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			muow.
+				EXPECT().
+				Do(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+					return fn(&domaintransaction.Repositories{
+						Users:         mur,
+						Products:      mpr,
+						Orders:        mor,
+						OrderProducts: mopr,
+					})
+				}).
+				AnyTimes()
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get(layout.DefaultName).Return(layout.Default(), nil).AnyTimes()
+
+			userService := services.NewUserService(
+				mur,
+				mlr,
+				muow,
+				nil,
+				domainuser.LoadConfig{ChunkSize: tt.chunkSize, Workers: 3},
+			)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+
+			result, err := userService.LoadUsersDataFile(ctx, strings.NewReader(mockFileContent), domainuser.LoadOptions{})
+			if tt.isErrExpected {
+				assert.ErrorIs(t, err, context.Canceled)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedAcceptedLines, result.AcceptedLines)
+			assert.Len(t, written, 6)
+			for orderID, productIDs := range written {
+				assert.Len(t, productIDs, 10)
+				assert.IsIncreasing(t, productIDs, "order %d", orderID)
+			}
+		})
+	}
+}
+
+func Test_LoadUsersDataFile_UserService_Workers(t *testing.T) {
+	// 40 lines of 2 users, renamed on every line, spread over 8 orders and listing products
+	// in decreasing id order
+	buf := new(strings.Builder)
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(
+			buf,
+			"%010d%45s%010d%010d%12.2f%s\n",
+			70+i%2,
+			fmt.Sprintf("Name %02d", i),
+			i%8+1,
+			100-i,
+			10.5,
+			"20210308",
+		)
+	}
+	mockFileContent := buf.String()
+
+	tests := []struct {
+		description string
+		deadlocks   int
+	}{
+		{
+			description: "should write the lines of a user in file order with a transaction per chunk",
+		},
+		{
+			description: "should store a chunk again when its transaction is aborted by a deadlock",
+			deadlocks:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mor := order.NewMockRepository(ctrl)
+			mopr := orderproducts.NewMockRepository(ctrl)
+
+			mor.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrOrderNotFound).AnyTimes()
+			mor.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+			mopr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+
+			// The names and products each transaction wrote, kept once it commits
+			var mu sync.Mutex
+			names := make(map[uint][]string)
+			products := make([][]uint, 0)
+			deadlocks := tt.deadlocks
+
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			muow.
+				EXPECT().
+				Do(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+					txNames := make(map[uint][]string)
+					txProducts := make([]uint, 0)
+
+					mur := user.NewMockRepository(ctrl)
+					mur.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrUserNotFound).AnyTimes()
+					mur.
+						EXPECT().
+						Upsert(gomock.Any()).
+						DoAndReturn(func(u *entities.User) (entities.UpsertOutcome, error) {
+							txNames[u.ID] = append(txNames[u.ID], u.Name)
+							return entities.UpsertInserted, nil
+						}).
+						AnyTimes()
+
+					mpr := product.NewMockRepository(ctrl)
+					mpr.
+						EXPECT().
+						Upsert(gomock.Any()).
+						DoAndReturn(func(p *entities.Product) (entities.UpsertOutcome, error) {
+							txProducts = append(txProducts, p.ID)
+							return entities.UpsertInserted, nil
+						}).
+						AnyTimes()
+
+					if err := fn(&domaintransaction.Repositories{
+						Users:         mur,
+						Products:      mpr,
+						Orders:        mor,
+						OrderProducts: mopr,
+					}); err != nil {
+						return err
+					}
+
+					mu.Lock()
+					defer mu.Unlock()
+					if deadlocks > 0 {
+						deadlocks--
+						return fmt.Errorf("%w: deadlock detected", errors.ErrTransactionDeadlock)
+					}
+					for userID, userNames := range txNames {
+						names[userID] = append(names[userID], userNames...)
+					}
+					products = append(products, txProducts)
+
+					return nil
+				}).
+				AnyTimes()
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get(layout.DefaultName).Return(layout.Default(), nil).AnyTimes()
+
+			userService := services.NewUserService(
+				user.NewMockRepository(ctrl),
+				mlr,
+				muow,
+				nil,
+				domainuser.LoadConfig{ChunkSize: 5, Workers: 4},
+			)
+
+			result, err := userService.LoadUsersDataFile(
+				context.Background(),
+				strings.NewReader(mockFileContent),
+				domainuser.LoadOptions{},
+			)
+
+			assert.NoError(t, err)
+			assert.Equal(t, 40, result.AcceptedLines)
+			assert.Equal(t, 40, result.Writes.Users.Inserted)
+			assert.Equal(t, 40, result.Writes.Products.Inserted)
+			assert.Len(t, names, 2)
+			for userID, userNames := range names {
+				assert.Len(t, userNames, 20)
+				assert.IsIncreasing(t, userNames, "user %d", userID)
+			}
+			assert.Len(t, products, 8)
+			for _, chunkProducts := range products {
+				assert.IsIncreasing(t, chunkProducts)
+			}
+		})
+	}
+}
+
+func Test_LoadUsersDataFile_UserService_SharedOrders(t *testing.T) {
+	// 40 lines of 8 users, every fifth line adding to the order 500, which is spread over the
+	// users, and the others adding to orders of their own
+	buf := new(strings.Builder)
+	for i := 0; i < 40; i++ {
+		orderID := 600 + i
+		if i%5 == 0 {
+			orderID = 500
+		}
+
+		fmt.Fprintf(
+			buf,
+			"%010d%45s%010d%010d%12.2f%s\n",
+			70+i%8,
+			fmt.Sprintf("User %d", 70+i%8),
+			orderID,
+			3,
+			10.5,
+			"20210308",
+		)
+	}
+	mockFileContent := buf.String()
+
+	// The order changes hands on every line of its own after the first one, from the user of
+	// the line before to the user of the line
+	expectedConflicts := make([]string, 0)
+	for i := 5; i < 40; i += 5 {
+		expectedConflicts = append(expectedConflicts, fmt.Sprintf("line %d: %d -> %d", i+1, 70+(i-5)%8, 70+i%8))
+	}
+
+	tests := []struct {
+		description string
+		chunkSize   int
+	}{
+		{
+			description: "should write the lines of an order spread over users in file order in a single transaction",
+		},
+		{
+			description: "should write the lines of an order spread over users in file order with a transaction per chunk",
+			chunkSize:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// The users and orders stored so far, as the lines written before see them
+			var mu sync.Mutex
+			users := make(map[uint]*entities.User)
+			orders := make(map[uint]*entities.Order)
+
+			mur := user.NewMockRepository(ctrl)
+			mur.
+				EXPECT().
+				Get(gomock.Any()).
+				DoAndReturn(func(userID uint) (*entities.User, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					if stored, ok := users[userID]; ok {
+						return stored, nil
+					}
+					return nil, errors.ErrUserNotFound
+				}).
+				AnyTimes()
+			mur.
+				EXPECT().
+				Upsert(gomock.Any()).
+				DoAndReturn(func(u *entities.User) (entities.UpsertOutcome, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					users[u.ID] = u
+					return entities.UpsertInserted, nil
+				}).
+				AnyTimes()
+
+			mor := order.NewMockRepository(ctrl)
+			mor.
+				EXPECT().
+				Get(gomock.Any()).
+				DoAndReturn(func(orderID uint) (*entities.Order, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					if stored, ok := orders[orderID]; ok {
+						return stored, nil
+					}
+					return nil, errors.ErrOrderNotFound
+				}).
+				AnyTimes()
+			mor.
+				EXPECT().
+				Upsert(gomock.Any()).
+				DoAndReturn(func(o *entities.Order) (entities.UpsertOutcome, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					orders[o.ID] = o
+					return entities.UpsertInserted, nil
+				}).
+				AnyTimes()
+
+			mpr := product.NewMockRepository(ctrl)
+			mpr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertUnchanged, nil).AnyTimes()
+			mopr := orderproducts.NewMockRepository(ctrl)
+			mopr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+			mcr := conflict.NewMockRepository(ctrl)
+			mcr.EXPECT().Save(gomock.Any()).Return(nil).AnyTimes()
+
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			muow.
+				EXPECT().
+				Do(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+					return fn(&domaintransaction.Repositories{
+						Users:         mur,
+						Products:      mpr,
+						Orders:        mor,
+						OrderProducts: mopr,
+						Conflicts:     mcr,
+					})
+				}).
+				AnyTimes()
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get(layout.DefaultName).Return(layout.Default(), nil).AnyTimes()
+
+			userService := services.NewUserService(
+				mur,
+				mlr,
+				muow,
+				nil,
+				domainuser.LoadConfig{ChunkSize: tt.chunkSize, Workers: 4},
+			)
+
+			result, err := userService.LoadUsersDataFile(
+				context.Background(),
+				strings.NewReader(mockFileContent),
+				domainuser.LoadOptions{},
+			)
+
+			assert.NoError(t, err)
+			assert.Equal(t, 40, result.AcceptedLines)
+
+			// The last line of the order wins, and every conflict was found in file order
+			assert.Equal(t, uint(73), orders[500].UserID)
+
+			conflicts := make([]string, 0)
+			for _, conflict := range result.Conflicts {
+				conflicts = append(conflicts, fmt.Sprintf("line %d: %s -> %s", conflict.Line, conflict.Existing, conflict.Incoming))
+			}
+			assert.ElementsMatch(t, expectedConflicts, conflicts)
+		})
+	}
+}
+
+func Test_LoadUsersDataFile_UserService_Layout(t *testing.T) {
+	mockLayout := &entities.RecordLayout{
+		Name: "v2",
+		Fields: []*entities.LayoutField{
+			{Name: entities.FieldOrderDate, Offset: 0, Width: 10, Type: entities.FieldDate, Format: "DD/MM/YYYY"},
+			{Name: entities.FieldUserID, Offset: 10, Width: 10, Type: entities.FieldInteger, Padding: "0"},
+			{Name: entities.FieldUserName, Offset: 20, Width: 30, Type: entities.FieldString, Align: entities.AlignLeft},
+			{Name: entities.FieldOrderID, Offset: 50, Width: 10, Type: entities.FieldInteger},
+			{Name: entities.FieldProductID, Offset: 60, Width: 10, Type: entities.FieldInteger},
+			{Name: entities.FieldProductValue, Offset: 70, Width: 10, Type: entities.FieldDecimal, Decimals: 2},
+		},
+	}
+	mockLine := fmt.Sprintf("%s%010d%-30s%10d%10d%010d\n", "08/03/2021", 70, "Palmer Prosacco", 753, 3, 183674)
+
+	tests := []struct {
+		description        string
+		layout             string
+		mockedFile         string
+		setMocks           func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository)
+		expectedRejections []*entities.LineRejection
+		expectedErr        error
+	}{
+		{
+			description: "should parse the line with the chosen layout",
+			layout:      "v2",
+			mockedFile:  mockLine,
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+				mur.EXPECT().Upsert(&entities.User{ID: 70, Name: "Palmer Prosacco"}).Return(entities.UpsertInserted, nil)
+				mpr.EXPECT().Upsert(&entities.Product{ID: 3}).Return(entities.UpsertInserted, nil)
+				mor.EXPECT().Upsert(&entities.Order{ID: 753, UserID: 70, Date: time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC)}).Return(entities.UpsertInserted, nil)
+				mopr.EXPECT().Upsert(&entities.OrderProduct{OrderID: 753, ProductID: 3, Value: 183674, Position: 1}).Return(entities.UpsertInserted, nil)
+			},
+			expectedRejections: []*entities.LineRejection{},
+		},
+		{
+			description: "should reject fields with the chosen layout rules",
+			layout:      "v2",
+			mockedFile:  strings.Replace(mockLine, "08/03/2021", "2021-03-08", 1),
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+			},
+			expectedRejections: []*entities.LineRejection{
+				{Line: 1, Field: "order_date", Value: "2021-03-08", Reason: "must be a valid date in DD/MM/YYYY format"},
+			},
+		},
+		{
+			description: "should reject lines shorter than the chosen layout",
+			layout:      "v2",
+			mockedFile:  mockLine[:60] + "\n",
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+			},
+			expectedRejections: []*entities.LineRejection{
+				{Line: 1, Field: "line", Value: mockLine[:60], Reason: "line has 60 characters, expected 80"},
+			},
+		},
+		{
+			description: "should return error when the layout is not registered",
+			layout:      "v3",
+			mockedFile:  mockLine,
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+			},
+			expectedErr: errors.ErrLayoutNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mur := user.NewMockRepository(ctrl)
+			mor := order.NewMockRepository(ctrl)
+			mpr := product.NewMockRepository(ctrl)
+			mopr := orderproducts.NewMockRepository(ctrl)
+			tt.setMocks(mur, mor, mpr, mopr)
+
+			// Every line is compared with the stored user and order, none is stored here
+			mur.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrUserNotFound).AnyTimes()
+			mor.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrOrderNotFound).AnyTimes()
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get("v2").Return(mockLayout, nil).AnyTimes()
+			mlr.EXPECT().Get("v3").Return(nil, errors.ErrLayoutNotFound).AnyTimes()
+
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			muow.
+				EXPECT().
+				Do(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+					return fn(&domaintransaction.Repositories{
+						Users:         mur,
+						Products:      mpr,
+						Orders:        mor,
+						OrderProducts: mopr,
+					})
+				}).
+				AnyTimes()
+
+			userService := services.NewUserService(mur, mlr, muow, nil, domainuser.LoadConfig{})
+
+			opts := domainuser.LoadOptions{Layout: tt.layout}
+			assert.ErrorIs(t, userService.ValidateLoadOptions(opts), tt.expectedErr)
+
+			result, err := userService.LoadUsersDataFile(context.Background(), strings.NewReader(tt.mockedFile), opts)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			if len(tt.expectedRejections) > 0 {
+				assert.Equal(t, tt.expectedRejections, result.Rejections)
+			} else {
+				assert.Empty(t, result.Rejections)
+				assert.Equal(t, 1, result.AcceptedLines)
+			}
+		})
+	}
+}
+
+func Test_LoadUsersDataFile_UserService_HeaderAndTrailer(t *testing.T) {
+	mockLayout := &entities.RecordLayout{
+		Name: "v2",
+		Fields: []*entities.LayoutField{
+			{Name: entities.FieldOrderDate, Offset: 0, Width: 10, Type: entities.FieldDate, Format: "DD/MM/YYYY"},
+			{Name: entities.FieldUserID, Offset: 10, Width: 10, Type: entities.FieldInteger, Padding: "0"},
+			{Name: entities.FieldUserName, Offset: 20, Width: 30, Type: entities.FieldString, Align: entities.AlignLeft},
+			{Name: entities.FieldOrderID, Offset: 50, Width: 10, Type: entities.FieldInteger},
+			{Name: entities.FieldProductID, Offset: 60, Width: 10, Type: entities.FieldInteger},
+			{Name: entities.FieldProductValue, Offset: 70, Width: 10, Type: entities.FieldDecimal, Decimals: 2},
+		},
+		Header: &entities.ControlRecord{
+			Identifier: "HDR",
+			Fields: []*entities.LayoutField{
+				{Name: entities.FieldFileDate, Offset: 3, Width: 8, Type: entities.FieldDate, Format: "YYYYMMDD"},
+				{Name: entities.FieldSender, Offset: 11, Width: 20, Type: entities.FieldString, Align: entities.AlignLeft},
+				{Name: entities.FieldLayoutVersion, Offset: 31, Width: 4, Type: entities.FieldString, Align: entities.AlignLeft},
+			},
+		},
+		Trailer: &entities.ControlRecord{
+			Identifier: "TRL",
+			Fields: []*entities.LayoutField{
+				{Name: entities.FieldRecordCount, Offset: 3, Width: 10, Type: entities.FieldInteger, Padding: "0"},
+				{Name: entities.FieldValueSum, Offset: 13, Width: 15, Type: entities.FieldDecimal, Decimals: 2, Padding: "0"},
+			},
+		},
+	}
+
+	header := fmt.Sprintf("HDR%s%-20s%-4s\n", "20210309", "LUIZALABS", "v2")
+	palmer := fmt.Sprintf("%s%010d%-30s%10d%10d%010d\n", "08/03/2021", 70, "Palmer Prosacco", 753, 3, 183674)
+	bobbie := fmt.Sprintf("%s%010d%-30s%10d%10d%010d\n", "16/11/2021", 75, "Bobbie Batz", 798, 2, 157857)
+	// The user id is rejected, but the line was read and its value still adds up
+	rejected := strings.Replace(bobbie, "0000000075", "00000000AB", 1)
+	trailer := func(count, sum int) string {
+		return fmt.Sprintf("TRL%010d%015d\n", count, sum)
+	}
+
+	tests := []struct {
+		description        string
+		mockedFile         string
+		expectedLines      int
+		expectedRejections int
+		expectedHeader     *entities.FileHeader
+		expectedErr        error
+		isErrExpected      bool
+	}{
+		{
+			description:    "should load the lines when the trailer matches them and keep the header",
+			mockedFile:     header + palmer + "\n" + bobbie + trailer(2, 341531) + "\n",
+			expectedLines:  2,
+			expectedHeader: &entities.FileHeader{Date: time.Date(2021, 3, 9, 0, 0, 0, 0, time.UTC), Sender: "LUIZALABS", LayoutVersion: "v2"},
+		},
+		{
+			description:        "should count the rejected lines and their values",
+			mockedFile:         header + palmer + rejected + trailer(2, 341531),
+			expectedLines:      1,
+			expectedRejections: 1,
+			expectedHeader:     &entities.FileHeader{Date: time.Date(2021, 3, 9, 0, 0, 0, 0, time.UTC), Sender: "LUIZALABS", LayoutVersion: "v2"},
+		},
+		{
+			description:        "should read a data line starting as the trailer does as a record when more lines follow it",
+			mockedFile:         header + palmer + "TRL" + palmer[3:] + bobbie + trailer(3, 525205) + "\n\n",
+			expectedLines:      2,
+			expectedRejections: 1,
+			expectedHeader:     &entities.FileHeader{Date: time.Date(2021, 3, 9, 0, 0, 0, 0, time.UTC), Sender: "LUIZALABS", LayoutVersion: "v2"},
+		},
+		{
+			description:   "should reject the file when the trailer counts more records",
+			mockedFile:    header + palmer + trailer(2, 183674),
+			expectedErr:   errors.ErrTrailerMismatch,
+			isErrExpected: true,
+		},
+		{
+			description:   "should reject the file when the values do not add up to the trailer sum",
+			mockedFile:    header + palmer + bobbie + trailer(2, 341530),
+			expectedErr:   errors.ErrTrailerMismatch,
+			isErrExpected: true,
+		},
+		{
+			description:   "should reject the file when it was cut before the trailer",
+			mockedFile:    header + palmer + bobbie[:40],
+			expectedErr:   errors.ErrMissingTrailer,
+			isErrExpected: true,
+		},
+		{
+			description:   "should reject the file when it does not start with the header",
+			mockedFile:    palmer + trailer(1, 183674),
+			expectedErr:   errors.ErrMissingHeader,
+			isErrExpected: true,
+		},
+		{
+			description:   "should reject an empty file",
+			mockedFile:    "",
+			expectedErr:   errors.ErrMissingHeader,
+			isErrExpected: true,
+		},
+		{
+			description:   "should reject the file when lines follow the trailer",
+			mockedFile:    header + palmer + trailer(1, 183674) + bobbie,
+			expectedErr:   errors.ErrMissingTrailer,
+			isErrExpected: true,
+		},
+		{
+			description:   "should reject a header that can not be read",
+			mockedFile:    strings.Replace(header, "20210309", "20211309", 1) + palmer + trailer(1, 183674),
+			isErrExpected: true,
+		},
+		{
+			description:   "should reject a trailer that can not be read",
+			mockedFile:    header + palmer + "TRL0000000001\n",
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mur := user.NewMockRepository(ctrl)
+			mor := order.NewMockRepository(ctrl)
+			mpr := product.NewMockRepository(ctrl)
+			mopr := orderproducts.NewMockRepository(ctrl)
+
+			mur.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrUserNotFound).AnyTimes()
+			mor.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrOrderNotFound).AnyTimes()
+			mur.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+			mpr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+			mor.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+			mopr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get("v2").Return(mockLayout, nil)
+
+			// A chunk size is set, still the file is loaded in a single transaction, so a
+			// trailer mismatch rolls every line back
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			muow.
+				EXPECT().
+				Do(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+					return fn(&domaintransaction.Repositories{
+						Users:         mur,
+						Products:      mpr,
+						Orders:        mor,
+						OrderProducts: mopr,
+					})
+				}).
+				Times(1)
+
+			userService := services.NewUserService(mur, mlr, muow, nil, domainuser.LoadConfig{ChunkSize: 1})
+
+			result, err := userService.LoadUsersDataFile(
+				context.Background(),
+				strings.NewReader(tt.mockedFile),
+				domainuser.LoadOptions{Layout: "v2", Format: domainuser.FormatFixedWidth},
+			)
+
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				if tt.expectedErr != nil {
+					assert.ErrorIs(t, err, tt.expectedErr)
+				}
+				assert.Zero(t, result.AcceptedLines)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedLines, result.AcceptedLines)
+			assert.Equal(t, tt.expectedRejections, result.RejectedLines)
+			assert.Equal(t, tt.expectedLines+tt.expectedRejections+result.SkippedLines, result.ProcessedLines)
+			assert.Equal(t, tt.expectedHeader, result.Header)
+		})
+	}
+}
+
+func Test_LoadUsersDataFile_UserService_Location(t *testing.T) {
+	saoPaulo, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		panic(err)
+	}
+
+	tests := []struct {
+		description  string
+		orderDate    string
+		location     *time.Location
+		expectedDate time.Time
+		expectedDay  string
+	}{
+		{
+			description:  "should read a day as its midnight in the business timezone",
+			orderDate:    "2021-03-08",
+			location:     saoPaulo,
+			expectedDate: time.Date(2021, 3, 8, 3, 0, 0, 0, time.UTC),
+			expectedDay:  "2021-03-08",
+		},
+		{
+			description:  "should keep the instant of a timestamp, on its day in the business timezone",
+			orderDate:    "2021-03-08T02:00:00Z",
+			location:     saoPaulo,
+			expectedDate: time.Date(2021, 3, 8, 2, 0, 0, 0, time.UTC),
+			expectedDay:  "2021-03-07",
+		},
+		{
+			description:  "should read a day as its midnight in UTC without a business timezone",
+			orderDate:    "2021-03-08",
+			expectedDate: time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC),
+			expectedDay:  "2021-03-08",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mur := user.NewMockRepository(ctrl)
+			mor := order.NewMockRepository(ctrl)
+			mpr := product.NewMockRepository(ctrl)
+			mopr := orderproducts.NewMockRepository(ctrl)
+
+			var written *entities.Order
+			mur.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrUserNotFound).AnyTimes()
+			mor.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrOrderNotFound).AnyTimes()
+			mur.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil)
+			mpr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil)
+			mopr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil)
+			mor.
+				EXPECT().
+				Upsert(gomock.Any()).
+				DoAndReturn(func(o *entities.Order) (entities.UpsertOutcome, error) {
+					written = o
+					return entities.UpsertInserted, nil
+				})
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get(layout.DefaultName).Return(layout.Default(), nil).AnyTimes()
+
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			muow.
+				EXPECT().
+				Do(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+					return fn(&domaintransaction.Repositories{
+						Users:         mur,
+						Products:      mpr,
+						Orders:        mor,
+						OrderProducts: mopr,
+					})
+				}).
+				AnyTimes()
+
+			userService := services.NewUserService(mur, mlr, muow, nil, domainuser.LoadConfig{Location: tt.location})
+
+			mockedFile := fmt.Sprintf(
+				`{"user_id":70,"user_name":"Palmer Prosacco","order_id":753,"product_id":3,"product_value":1836.74,"order_date":%q}`,
+				tt.orderDate,
+			)
+			result, err := userService.LoadUsersDataFile(
+				context.Background(),
+				strings.NewReader(mockedFile),
+				domainuser.LoadOptions{Format: domainuser.FormatNDJSON},
+			)
+
+			assert.NoError(t, err)
+			assert.Empty(t, result.Rejections)
+			assert.True(t, tt.expectedDate.Equal(written.Date), "stored %s", written.Date)
+			assert.Equal(t, tt.expectedDay, written.Date.Format(time.DateOnly))
+		})
+	}
+}
+
+func Test_LoadUsersDataFile_UserService_Formats(t *testing.T) {
+	palmer := &entities.OrderProduct{OrderID: 753, ProductID: 3, Value: 183674, Position: 1}
+	bobbie := &entities.OrderProduct{OrderID: 798, ProductID: 2, Value: 157857, Position: 1}
+
+	tests := []struct {
+		description            string
+		mockedFile             string
+		format                 domainuser.Format
+		expectedFormat         domainuser.Format
+		expectedOrderProducts  []*entities.OrderProduct
+		expectedProcessedLines int
+		expectedSkippedLines   int
+		expectedRejections     []*entities.LineRejection
+		expectedErr            error
+		isErrExpected          bool
+	}{
+		{
+			description:            "should detect and load a csv file",
+			mockedFile:             "./mocks/user/mock_data_file.csv",
+			expectedFormat:         domainuser.FormatCSV,
+			expectedOrderProducts:  []*entities.OrderProduct{palmer, bobbie},
+			expectedProcessedLines: 2,
+		},
+		{
+			description:            "should read csv columns by name and reject records with missing columns",
+			mockedFile:             "./mocks/user/mock_reordered_data_file.csv",
+			format:                 domainuser.FormatCSV,
+			expectedFormat:         domainuser.FormatCSV,
+			expectedOrderProducts:  []*entities.OrderProduct{palmer},
+			expectedProcessedLines: 2,
+			expectedRejections: []*entities.LineRejection{
+				{Line: 3, Field: "line", Value: "2021-11-16,1578.57,2", Reason: "record has 3 columns, expected 7"},
+			},
+		},
+		{
+			description:            "should detect and load a ndjson file, skipping blank lines and rejecting malformed objects",
+			mockedFile:             "./mocks/user/mock_data_file.ndjson",
+			expectedFormat:         domainuser.FormatNDJSON,
+			expectedOrderProducts:  []*entities.OrderProduct{palmer, bobbie},
+			expectedProcessedLines: 4,
+			expectedSkippedLines:   1,
+			expectedRejections: []*entities.LineRejection{
+				{Line: 4, Field: "line", Value: `{"user_id":75,`, Reason: "invalid JSON object: unexpected EOF"},
+			},
+		},
+		{
+			description:            "should detect and load a json array file",
+			mockedFile:             "./mocks/user/mock_data_file.json",
+			expectedFormat:         domainuser.FormatJSON,
+			expectedOrderProducts:  []*entities.OrderProduct{palmer, bobbie},
+			expectedProcessedLines: 2,
+		},
+		{
+			description:            "should detect a fixed-width file",
+			mockedFile:             "./mocks/user/mock_mult_data_file.txt",
+			expectedFormat:         domainuser.FormatFixedWidth,
+			expectedOrderProducts:  []*entities.OrderProduct{palmer, bobbie},
+			expectedProcessedLines: 2,
+		},
+		{
+			description:    "should return error when a csv file misses a column",
+			mockedFile:     "./mocks/user/mock_mult_data_file.txt",
+			format:         domainuser.FormatCSV,
+			expectedFormat: domainuser.FormatCSV,
+			isErrExpected:  true,
+		},
+		{
+			description:    "should return error when a json file is not an array",
+			mockedFile:     "./mocks/user/mock_data_file.ndjson",
+			format:         domainuser.FormatJSON,
+			expectedFormat: domainuser.FormatJSON,
+			isErrExpected:  true,
+		},
+		{
+			description:   "should return error when the format is unknown",
+			mockedFile:    "./mocks/user/mock_data_file.csv",
+			format:        "xml",
+			expectedErr:   errors.ErrUnknownFormat,
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mur := user.NewMockRepository(ctrl)
+			mor := order.NewMockRepository(ctrl)
+			mpr := product.NewMockRepository(ctrl)
+			mopr := orderproducts.NewMockRepository(ctrl)
+
+			mur.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+			mpr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+			mor.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+
+			// Every line is compared with the stored user and order, none is stored here
+			mur.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrUserNotFound).AnyTimes()
+			mor.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrOrderNotFound).AnyTimes()
+
+			written := make([]*entities.OrderProduct, 0)
+			mopr.
+				EXPECT().
+				Upsert(gomock.Any()).
+				DoAndReturn(func(orderProduct *entities.OrderProduct) (entities.UpsertOutcome, error) {
+					written = append(written, orderProduct)
+					return entities.UpsertInserted, nil
+				}).
+				AnyTimes()
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get(layout.DefaultName).Return(layout.Default(), nil).AnyTimes()
+
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			muow.
+				EXPECT().
+				Do(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+					return fn(&domaintransaction.Repositories{
+						Users:         mur,
+						Products:      mpr,
+						Orders:        mor,
+						OrderProducts: mopr,
+					})
+				}).
+				AnyTimes()
+
+			userService := services.NewUserService(mur, mlr, muow, nil, domainuser.LoadConfig{})
+
+			file, err := os.Open(tt.mockedFile)
+			if err != nil {
+				panic(err)
+			}
+			defer file.Close()
+
+			opts := domainuser.LoadOptions{Format: tt.format}
+			result, err := userService.LoadUsersDataFile(context.Background(), file, opts)
+			assert.Equal(t, tt.expectedFormat, result.Format)
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				if tt.expectedErr != nil {
+					assert.ErrorIs(t, err, tt.expectedErr)
+					assert.ErrorIs(t, userService.ValidateLoadOptions(opts), tt.expectedErr)
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedOrderProducts, written)
+			assert.Equal(t, len(tt.expectedOrderProducts), result.AcceptedLines)
+			assert.Equal(t, tt.expectedProcessedLines, result.ProcessedLines)
+			assert.Equal(t, tt.expectedSkippedLines, result.SkippedLines)
+			if tt.expectedRejections != nil {
+				assert.Equal(t, tt.expectedRejections, result.Rejections)
+			} else {
+				assert.Empty(t, result.Rejections)
+			}
+		})
+	}
+}
+
+func Test_LoadUsersDataFile_UserService_LongLines(t *testing.T) {
+	palmerJSON := `{"user_id":70,"user_name":"Palmer Prosacco","order_id":753,"product_id":3,"product_value":1836.74,"order_date":"2021-03-08"}`
+	bobbieJSON := `{"user_id":75,"user_name":"Bobbie Batz","order_id":798,"product_id":2,"product_value":1578.57,"order_date":"2021-11-16"}`
+	palmerText := "0000000070                              Palmer Prosacco00000007530000000003     1836.7420210308"
+	bobbieText := "0000000075                                  Bobbie Batz00000007980000000002     1578.5720211116"
+	csvHeader := "user_id,user_name,order_id,product_id,product_value,order_date\n"
+	palmerCSV := "70,Palmer Prosacco,753,3,1836.74,2021-03-08\n"
+	bobbieCSV := "75,Bobbie Batz,798,2,1578.57,2021-11-16\n"
+
+	palmer := &entities.OrderProduct{OrderID: 753, ProductID: 3, Value: 183674, Position: 1}
+	bobbie := &entities.OrderProduct{OrderID: 798, ProductID: 2, Value: 157857, Position: 1}
+
+	const maxLineSize = 200
+	tooLong := &entities.LineRejection{Line: 2, Field: "line", Reason: "line is longer than 200 bytes"}
+	recordTooLong := &entities.LineRejection{Line: 3, Field: "line", Reason: "record is longer than 200 bytes"}
+
+	tests := []struct {
+		description            string
+		mockedFile             string
+		format                 domainuser.Format
+		expectedOrderProducts  []*entities.OrderProduct
+		expectedProcessedLines int
+		expectedRejections     []*entities.LineRejection
+		expectedErr            string
+	}{
+		{
+			description: "should reject a ndjson line longer than the maximum and keep reading the file",
+			mockedFile: palmerJSON + "\n" +
+				`{"user_id":71,"user_name":"` + strings.Repeat("a", 10*maxLineSize) + `"}` + "\n" +
+				bobbieJSON + "\n",
+			format:                 domainuser.FormatNDJSON,
+			expectedOrderProducts:  []*entities.OrderProduct{palmer, bobbie},
+			expectedProcessedLines: 3,
+			expectedRejections:     []*entities.LineRejection{tooLong},
+		},
+		{
+			description:            "should reject a fixed-width line longer than the maximum and keep reading the file",
+			mockedFile:             palmerText + "\n" + strings.Repeat(bobbieText, 3) + "\n" + bobbieText + "\n",
+			format:                 domainuser.FormatFixedWidth,
+			expectedOrderProducts:  []*entities.OrderProduct{palmer, bobbie},
+			expectedProcessedLines: 3,
+			expectedRejections:     []*entities.LineRejection{tooLong},
+		},
+		{
+			description:            "should reject a last line longer than the maximum without a line break",
+			mockedFile:             palmerJSON + "\n" + bobbieJSON + strings.Repeat(" ", maxLineSize),
+			format:                 domainuser.FormatNDJSON,
+			expectedOrderProducts:  []*entities.OrderProduct{palmer},
+			expectedProcessedLines: 2,
+			expectedRejections:     []*entities.LineRejection{tooLong},
+		},
+		{
+			description: "should accept lines as long as the maximum, not counting their line breaks",
+			mockedFile: palmerText + strings.Repeat(" ", maxLineSize-len(palmerText)) + "\r\n" +
+				bobbieText + strings.Repeat(" ", maxLineSize-len(bobbieText)),
+			format:                 domainuser.FormatFixedWidth,
+			expectedOrderProducts:  []*entities.OrderProduct{palmer, bobbie},
+			expectedProcessedLines: 2,
+		},
+		{
+			description:            "should reject a csv record longer than the maximum and keep reading the file",
+			mockedFile:             csvHeader + palmerCSV + "71," + strings.Repeat("a", 10*maxLineSize) + ",754,3,10,2021-03-08\n" + bobbieCSV,
+			format:                 domainuser.FormatCSV,
+			expectedOrderProducts:  []*entities.OrderProduct{palmer, bobbie},
+			expectedProcessedLines: 3,
+			expectedRejections:     []*entities.LineRejection{recordTooLong},
+		},
+		{
+			description: "should reject a csv record whose quoted line breaks make it longer than the maximum",
+			mockedFile: csvHeader + palmerCSV +
+				`71,"` + strings.Repeat("a", maxLineSize/2) + "\n" + strings.Repeat("a", maxLineSize/2) + `",754,3,10,2021-03-08` + "\n" +
+				"\n" + bobbieCSV,
+			format:                 domainuser.FormatCSV,
+			expectedOrderProducts:  []*entities.OrderProduct{palmer, bobbie},
+			expectedProcessedLines: 3,
+			expectedRejections:     []*entities.LineRejection{recordTooLong},
+		},
+		{
+			description:           "should fail a json array with an element longer than the maximum",
+			mockedFile:            "[" + palmerJSON + ",\n" + `{"user_id":71,"user_name":"` + strings.Repeat("a", 10*maxLineSize) + `"},` + bobbieJSON + "]",
+			format:                domainuser.FormatJSON,
+			expectedOrderProducts: []*entities.OrderProduct{},
+			expectedErr:           "JSON array element 2 is longer than 200 bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mur := user.NewMockRepository(ctrl)
+			mor := order.NewMockRepository(ctrl)
+			mpr := product.NewMockRepository(ctrl)
+			mopr := orderproducts.NewMockRepository(ctrl)
+
+			mur.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+			mpr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+			mor.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+			mur.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrUserNotFound).AnyTimes()
+			mor.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrOrderNotFound).AnyTimes()
+
+			written := make([]*entities.OrderProduct, 0)
+			mopr.
+				EXPECT().
+				Upsert(gomock.Any()).
+				DoAndReturn(func(orderProduct *entities.OrderProduct) (entities.UpsertOutcome, error) {
+					written = append(written, orderProduct)
+					return entities.UpsertInserted, nil
+				}).
+				AnyTimes()
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get(layout.DefaultName).Return(layout.Default(), nil).AnyTimes()
+
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			muow.
+				EXPECT().
+				Do(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+					return fn(&domaintransaction.Repositories{
+						Users:         mur,
+						Products:      mpr,
+						Orders:        mor,
+						OrderProducts: mopr,
+					})
+				}).
+				AnyTimes()
+
+			userService := services.NewUserService(mur, mlr, muow, nil, domainuser.LoadConfig{MaxLineSize: maxLineSize})
+
+			result, err := userService.LoadUsersDataFile(
+				context.Background(),
+				strings.NewReader(tt.mockedFile),
+				domainuser.LoadOptions{Format: tt.format},
+			)
+
+			assert.Equal(t, tt.expectedOrderProducts, written)
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedProcessedLines, result.ProcessedLines)
+			if tt.expectedRejections != nil {
+				assert.Equal(t, tt.expectedRejections, result.Rejections)
+			} else {
+				assert.Empty(t, result.Rejections)
+			}
+		})
+	}
+}
+
+func Test_LoadUsersDataFile_UserService_Encodings(t *testing.T) {
+	tests := []struct {
+		description           string
+		mockedFile            string
+		encoding              domainuser.Encoding
+		expectedNames         []string
+		expectedOrderProducts []*entities.OrderProduct
+		expectedRejections    []*entities.LineRejection
+		expectedErr           error
+		isErrExpected         bool
+	}{
+		{
+			description:   "should convert the names of an iso-8859-1 file to utf-8 without shifting the fields",
+			mockedFile:    "./mocks/user/mock_latin1_data_file.txt",
+			encoding:      domainuser.EncodingISO88591,
+			expectedNames: []string{"João da Conceição", "Bobbie Batz"},
+			expectedOrderProducts: []*entities.OrderProduct{
+				{OrderID: 753, ProductID: 3, Value: 183674, Position: 1},
+				{OrderID: 798, ProductID: 2, Value: 157857, Position: 1},
+			},
+		},
+		{
+			description:   "should convert the characters windows-1252 adds to iso-8859-1",
+			mockedFile:    "./mocks/user/mock_windows1252_data_file.txt",
+			encoding:      domainuser.EncodingWindows1252,
+			expectedNames: []string{"Palmer D’Ávila", "Bobbie Batz"},
+			expectedOrderProducts: []*entities.OrderProduct{
+				{OrderID: 753, ProductID: 3, Value: 183674, Position: 1},
+				{OrderID: 798, ProductID: 2, Value: 157857, Position: 1},
+			},
+		},
+		{
+			description:   "should count utf-8 offsets in characters and drop the byte order mark",
+			mockedFile:    "./mocks/user/mock_utf8_data_file.txt",
+			expectedNames: []string{"João da Conceição", "Bobbie Batz"},
+			expectedOrderProducts: []*entities.OrderProduct{
+				{OrderID: 753, ProductID: 3, Value: 183674, Position: 1},
+				{OrderID: 798, ProductID: 2, Value: 157857, Position: 1},
+			},
+		},
+		{
+			description:   "should reject the lines that are not valid utf-8 when the encoding is not sent",
+			mockedFile:    "./mocks/user/mock_latin1_data_file.txt",
+			expectedNames: []string{"Bobbie Batz"},
+			expectedOrderProducts: []*entities.OrderProduct{
+				{OrderID: 798, ProductID: 2, Value: 157857, Position: 1},
+			},
+			expectedRejections: []*entities.LineRejection{
+				{
+					Line:   1,
+					Field:  "line",
+					Value:  "0000000070" + strings.Repeat(" ", 28) + "Jo\ufffdo da Concei\ufffdo00000007530000000003     1836.7420210308",
+					Reason: "line is not valid UTF-8, send the encoding of the file",
+				},
+			},
+		},
+		{
+			description:   "should return error when the encoding is unknown",
+			mockedFile:    "./mocks/user/mock_latin1_data_file.txt",
+			encoding:      "ebcdic",
+			expectedErr:   errors.ErrUnknownEncoding,
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mur := user.NewMockRepository(ctrl)
+			mor := order.NewMockRepository(ctrl)
+			mpr := product.NewMockRepository(ctrl)
+			mopr := orderproducts.NewMockRepository(ctrl)
+
+			names := make([]string, 0)
+			mur.
+				EXPECT().
+				Upsert(gomock.Any()).
+				DoAndReturn(func(u *entities.User) (entities.UpsertOutcome, error) {
+					names = append(names, u.Name)
+					return entities.UpsertInserted, nil
+				}).
+				AnyTimes()
+			mpr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+			mor.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).AnyTimes()
+
+			mur.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrUserNotFound).AnyTimes()
+			mor.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrOrderNotFound).AnyTimes()
+
+			written := make([]*entities.OrderProduct, 0)
+			mopr.
+				EXPECT().
+				Upsert(gomock.Any()).
+				DoAndReturn(func(orderProduct *entities.OrderProduct) (entities.UpsertOutcome, error) {
+					written = append(written, orderProduct)
+					return entities.UpsertInserted, nil
+				}).
+				AnyTimes()
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get(layout.DefaultName).Return(layout.Default(), nil).AnyTimes()
+
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			muow.
+				EXPECT().
+				Do(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+					return fn(&domaintransaction.Repositories{
+						Users:         mur,
+						Products:      mpr,
+						Orders:        mor,
+						OrderProducts: mopr,
+					})
+				}).
+				AnyTimes()
+
+			userService := services.NewUserService(mur, mlr, muow, nil, domainuser.LoadConfig{})
+
+			file, err := os.Open(tt.mockedFile)
+			if err != nil {
+				panic(err)
+			}
+			defer file.Close()
+
+			opts := domainuser.LoadOptions{Encoding: tt.encoding}
+			result, err := userService.LoadUsersDataFile(context.Background(), file, opts)
+			if tt.isErrExpected {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.ErrorIs(t, userService.ValidateLoadOptions(opts), tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedNames, names)
+			assert.Equal(t, tt.expectedOrderProducts, written)
+			if tt.expectedRejections != nil {
+				assert.Equal(t, tt.expectedRejections, result.Rejections)
+			} else {
+				assert.Empty(t, result.Rejections)
+			}
+		})
+	}
+}
+
+func Test_LoadUsersDataFile_UserService_DryRun(t *testing.T) {
+	orderDate := time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC)
+	mockLine := func(userID uint, name string, orderID, productID uint, value float64) string {
+		return fmt.Sprintf("%010d%45s%010d%010d%12.2f%s\n", userID, name, orderID, productID, value, "20210308")
+	}
+
+	tests := []struct {
+		description       string
+		mockedFile        string
+		policies          entities.ConflictPolicies
+		maxRecords        int
+		setMocks          func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository)
+		expectedAccepted  int
+		expectedRejected  int
+		expectedWrites    entities.WriteSummary
+		expectedConflicts []*entities.LineConflict
+		expectedErr       error
+		isErrExpected     bool
+	}{
+		{
+			description: "should preview inserts of new data, counting repeated rows once",
+			mockedFile: mockLine(70, "Palmer Prosacco", 753, 3, 1836.74) +
+				mockLine(70, "Palmer Prosacco", 753, 4, 10) +
+				"0000000070 invalid line\n",
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+				mur.EXPECT().Get(uint(70)).Return(nil, errors.ErrUserNotFound)
+				mpr.EXPECT().Get(uint(3)).Return(nil, errors.ErrProductNotFound)
+				mpr.EXPECT().Get(uint(4)).Return(nil, errors.ErrProductNotFound)
+				mor.EXPECT().Get(uint(753)).Return(nil, errors.ErrOrderNotFound)
+				mopr.EXPECT().GetByOrderID(uint(753)).Return([]*entities.OrderProduct{}, nil)
+			},
+			expectedAccepted: 2,
+			expectedRejected: 1,
+			expectedWrites: entities.WriteSummary{
+				Users:         entities.WriteCounts{Inserted: 1, Unchanged: 1},
+				Products:      entities.WriteCounts{Inserted: 2},
+				Orders:        entities.WriteCounts{Inserted: 1, Unchanged: 1},
+				OrderProducts: entities.WriteCounts{Inserted: 2},
+			},
+			expectedConflicts: []*entities.LineConflict{},
+		},
+		{
+			description: "should report an order already owned by another user as a conflict",
+			mockedFile:  mockLine(70, "Palmer Prosacco", 753, 3, 1836.74),
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+				mur.EXPECT().Get(uint(70)).Return(&entities.User{ID: 70, Name: "Palmer Prosacco"}, nil)
+				mpr.EXPECT().Get(uint(3)).Return(&entities.Product{ID: 3}, nil)
+				mor.EXPECT().Get(uint(753)).Return(&entities.Order{ID: 753, UserID: 12, Date: orderDate}, nil)
+				mopr.EXPECT().GetByOrderID(uint(753)).Return([]*entities.OrderProduct{
+					{OrderID: 753, ProductID: 3, Value: 183674, Position: 1},
+				}, nil)
+			},
+			expectedAccepted: 1,
+			expectedWrites: entities.WriteSummary{
+				Users:         entities.WriteCounts{Unchanged: 1},
+				Products:      entities.WriteCounts{Unchanged: 1},
+				Orders:        entities.WriteCounts{Updated: 1},
+				OrderProducts: entities.WriteCounts{Unchanged: 1},
+			},
+			expectedConflicts: []*entities.LineConflict{
+				{
+					Line:     1,
+					Type:     entities.ConflictOrderUser,
+					Field:    entities.FieldUserID,
+					Value:    "753",
+					Existing: "12",
+					Incoming: "70",
+					Policy:   entities.ConflictLastWriteWins,
+					Reason:   "order 753 already belongs to user 12",
+				},
+			},
+		},
+		{
+			description: "should preview a line rejected by its conflict policy without counting its writes",
+			mockedFile:  mockLine(70, "Palmer P.", 753, 3, 1836.74),
+			policies:    entities.ConflictPolicies{entities.ConflictUserName: entities.ConflictReject},
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+				mur.EXPECT().Get(uint(70)).Return(&entities.User{ID: 70, Name: "Palmer Prosacco"}, nil)
+				mor.EXPECT().Get(uint(753)).Return(nil, errors.ErrOrderNotFound)
+			},
+			expectedRejected: 1,
+			expectedConflicts: []*entities.LineConflict{
+				{
+					Line:     1,
+					Type:     entities.ConflictUserName,
+					Field:    entities.FieldUserName,
+					Value:    "70",
+					Existing: "Palmer Prosacco",
+					Incoming: "Palmer P.",
+					Policy:   entities.ConflictReject,
+					Reason:   "user 70 is already named Palmer Prosacco",
+				},
+			},
+		},
+		{
+			description: "should return error when a lookup fails",
+			mockedFile:  mockLine(70, "Palmer Prosacco", 753, 3, 1836.74),
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+				mur.EXPECT().Get(uint(70)).Return(nil, fmt.Errorf("connection refused"))
+			},
+			isErrExpected: true,
+		},
+		{
+			description: "should fail once the file makes the dry run remember more records than allowed",
+			mockedFile: mockLine(70, "Palmer Prosacco", 753, 3, 1836.74) +
+				mockLine(71, "Gaston Ebert", 754, 4, 10),
+			// The first line is one user, product, order and order line, the second one is too many
+			maxRecords: 5,
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+				mur.EXPECT().Get(uint(70)).Return(nil, errors.ErrUserNotFound)
+				mur.EXPECT().Get(uint(71)).Return(nil, errors.ErrUserNotFound)
+				mpr.EXPECT().Get(uint(3)).Return(nil, errors.ErrProductNotFound)
+				mpr.EXPECT().Get(uint(4)).Return(nil, errors.ErrProductNotFound)
+				mor.EXPECT().Get(uint(753)).Return(nil, errors.ErrOrderNotFound)
+				mor.EXPECT().Get(uint(754)).Return(nil, errors.ErrOrderNotFound)
+				mopr.EXPECT().GetByOrderID(uint(753)).Return([]*entities.OrderProduct{}, nil)
+				mopr.EXPECT().GetByOrderID(uint(754)).Return([]*entities.OrderProduct{}, nil)
+			},
+			expectedErr:   errors.ErrDryRunTooLarge,
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mur := user.NewMockRepository(ctrl)
+			mor := order.NewMockRepository(ctrl)
+			mpr := product.NewMockRepository(ctrl)
+			mopr := orderproducts.NewMockRepository(ctrl)
+			tt.setMocks(mur, mor, mpr, mopr)
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get(layout.DefaultName).Return(layout.Default(), nil).AnyTimes()
+
+			// Upserts and Do are not expected, so any write fails the test
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			muow.
+				EXPECT().
+				View(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+					return fn(&domaintransaction.Repositories{
+						Users:         mur,
+						Products:      mpr,
+						Orders:        mor,
+						OrderProducts: mopr,
+					})
+				}).
+				AnyTimes()
+
+			// A bulk threshold of one line would stage the file if dry runs could bulk load
+			userService := services.NewUserService(mur, mlr, muow, nil, domainuser.LoadConfig{
+				BulkThreshold:    1,
+				DryRunMaxRecords: tt.maxRecords,
+				ConflictPolicies: tt.policies,
+			})
+
+			result, err := userService.LoadUsersDataFile(
+				context.Background(),
+				strings.NewReader(tt.mockedFile),
+				domainuser.LoadOptions{DryRun: true},
+			)
+			if tt.isErrExpected {
+				assert.Error(t, err)
+				if tt.expectedErr != nil {
+					assert.ErrorIs(t, err, tt.expectedErr)
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, result.DryRun)
+			assert.Equal(t, tt.expectedAccepted, result.AcceptedLines)
+			assert.Equal(t, tt.expectedRejected, result.RejectedLines)
+			assert.Equal(t, tt.expectedWrites, result.Writes)
+			if len(tt.expectedConflicts) > 0 {
+				assert.Equal(t, tt.expectedConflicts, result.Conflicts)
+			} else {
+				assert.Empty(t, result.Conflicts)
+			}
+		})
+	}
+}
+
+func Test_LoadUsersDataFile_UserService_Delta(t *testing.T) {
+	orderDate := time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC)
+	mockRecord := func(operation string, orderID, productID uint, value string) string {
+		return fmt.Sprintf(
+			`{"user_id":70,"user_name":"Palmer Prosacco","order_id":%d,"product_id":%d,"product_value":%s,"order_date":"2021-03-08","operation":%q}`+"\n",
+			orderID, productID, value, operation,
+		)
+	}
+	// storedLine is a line of order 753 as stored
+	storedLine := func(productID uint, value entities.Money, position int) *entities.OrderProduct {
+		return &entities.OrderProduct{OrderID: 753, ProductID: productID, Value: value, Position: position}
+	}
+	// storedParents makes the user and order of the records stored as the records tell
+	storedParents := func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository) {
+		mur.EXPECT().Get(uint(70)).Return(&entities.User{ID: 70, Name: "Palmer Prosacco"}, nil).AnyTimes()
+		mor.EXPECT().Get(uint(753)).Return(&entities.Order{ID: 753, UserID: 70, Date: orderDate}, nil).AnyTimes()
+		mur.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertUnchanged, nil).AnyTimes()
+		mpr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertUnchanged, nil).AnyTimes()
+		mor.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertUnchanged, nil).AnyTimes()
+	}
+
+	tests := []struct {
+		description        string
+		mockedFile         string
+		format             domainuser.Format
+		mode               domainuser.Mode
+		dryRun             bool
+		setMocks           func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository)
+		expectedAccepted   int
+		expectedWrites     entities.WriteSummary
+		expectedTotals     []*entities.OrderTotal
+		expectedRejections []*entities.LineRejection
+		expectedErr        error
+	}{
+		{
+			description: "should delete the lines with the value and the order left without lines",
+			mockedFile:  mockRecord("D", 753, 3, "1836.74"),
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+				mopr.EXPECT().GetByOrderID(uint(753)).Return([]*entities.OrderProduct{storedLine(3, 183674, 1)}, nil)
+				mopr.EXPECT().DeleteByValue(uint(753), uint(3), entities.Money(183674)).Return(1, nil)
+				mor.EXPECT().DeleteIfEmpty(uint(753)).Return(true, nil)
+				mopr.EXPECT().GetByOrderID(uint(753)).Return([]*entities.OrderProduct{}, nil)
+			},
+			expectedAccepted: 1,
+			expectedWrites: entities.WriteSummary{
+				Orders:        entities.WriteCounts{Deleted: 1},
+				OrderProducts: entities.WriteCounts{Deleted: 1},
+			},
+			expectedTotals: []*entities.OrderTotal{{OrderID: 753, Previous: 183674, Removed: true}},
+		},
+		{
+			description: "should set the value of every line the order has of the product",
+			mockedFile:  mockRecord("u", 753, 3, "20"),
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+				storedParents(mur, mor, mpr)
+
+				stored := []*entities.OrderProduct{storedLine(3, 1000, 1), storedLine(3, 1000, 2), storedLine(4, 500, 1)}
+				mopr.EXPECT().GetByOrderID(uint(753)).Return(stored, nil).Times(2)
+				mopr.EXPECT().DeleteByProduct(uint(753), uint(3)).Return(2, nil)
+				mopr.EXPECT().Upsert(storedLine(3, 2000, 1)).Return(entities.UpsertInserted, nil)
+				mopr.EXPECT().Upsert(storedLine(3, 2000, 2)).Return(entities.UpsertInserted, nil)
+				mopr.
+					EXPECT().
+					GetByOrderID(uint(753)).
+					Return([]*entities.OrderProduct{storedLine(4, 500, 1), storedLine(3, 2000, 1), storedLine(3, 2000, 2)}, nil)
+			},
+			expectedAccepted: 1,
+			expectedWrites: entities.WriteSummary{
+				Users:         entities.WriteCounts{Unchanged: 1},
+				Products:      entities.WriteCounts{Unchanged: 1},
+				Orders:        entities.WriteCounts{Unchanged: 1},
+				OrderProducts: entities.WriteCounts{Updated: 2},
+			},
+			expectedTotals: []*entities.OrderTotal{{OrderID: 753, Previous: 2500, Current: 4500}},
+		},
+		{
+			description: "should number inserts among the inserts of the file, starting over after a delete",
+			mockedFile: mockRecord("I", 753, 3, "10") +
+				mockRecord("insert", 753, 3, "10") +
+				mockRecord("delete", 753, 3, "10") +
+				mockRecord("Insert", 753, 3, "10"),
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+				storedParents(mur, mor, mpr)
+
+				mopr.EXPECT().GetByOrderID(uint(753)).Return([]*entities.OrderProduct{storedLine(4, 500, 1)}, nil)
+				mopr.EXPECT().Upsert(storedLine(3, 1000, 1)).Return(entities.UpsertInserted, nil)
+				mopr.EXPECT().Upsert(storedLine(3, 1000, 2)).Return(entities.UpsertInserted, nil)
+				mopr.EXPECT().DeleteByValue(uint(753), uint(3), entities.Money(1000)).Return(2, nil)
+				mor.EXPECT().DeleteIfEmpty(uint(753)).Return(false, nil)
+				mopr.EXPECT().Upsert(storedLine(3, 1000, 1)).Return(entities.UpsertInserted, nil)
+				mopr.
+					EXPECT().
+					GetByOrderID(uint(753)).
+					Return([]*entities.OrderProduct{storedLine(4, 500, 1), storedLine(3, 1000, 1)}, nil)
+			},
+			expectedAccepted: 4,
+			expectedWrites: entities.WriteSummary{
+				Users:         entities.WriteCounts{Unchanged: 3},
+				Products:      entities.WriteCounts{Unchanged: 3},
+				Orders:        entities.WriteCounts{Unchanged: 3},
+				OrderProducts: entities.WriteCounts{Inserted: 3, Deleted: 2},
+			},
+			expectedTotals: []*entities.OrderTotal{{OrderID: 753, Previous: 500, Current: 1500}},
+		},
+		{
+			description: "should reject a record with an unknown operation",
+			mockedFile:  mockRecord("X", 753, 3, "10"),
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+			},
+			expectedRejections: []*entities.LineRejection{
+				{Line: 1, Field: "operation", Value: "X", Reason: "must be an operation: I (insert), U (update) or D (delete)"},
+			},
+		},
+		{
+			description: "should preview a delete leaving the order without lines",
+			mockedFile:  mockRecord("D", 753, 3, "1836.74"),
+			dryRun:      true,
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+				mopr.EXPECT().GetByOrderID(uint(753)).Return([]*entities.OrderProduct{storedLine(3, 183674, 1)}, nil)
+				mor.EXPECT().Get(uint(753)).Return(&entities.Order{ID: 753, UserID: 70, Date: orderDate}, nil)
+			},
+			expectedAccepted: 1,
+			expectedWrites: entities.WriteSummary{
+				Orders:        entities.WriteCounts{Deleted: 1},
+				OrderProducts: entities.WriteCounts{Deleted: 1},
+			},
+			expectedTotals: []*entities.OrderTotal{{OrderID: 753, Previous: 183674, Removed: true}},
+		},
+		{
+			description: "should return error when the mode is unknown",
+			mockedFile:  mockRecord("I", 753, 3, "10"),
+			mode:        "partial",
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+			},
+			expectedErr: errors.ErrUnknownMode,
+		},
+		{
+			description: "should return error when the layout of a fixed-width file has no operation field",
+			mockedFile:  "",
+			format:      domainuser.FormatFixedWidth,
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+			},
+			expectedErr: errors.ErrNoOperation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mur := user.NewMockRepository(ctrl)
+			mor := order.NewMockRepository(ctrl)
+			mpr := product.NewMockRepository(ctrl)
+			mopr := orderproducts.NewMockRepository(ctrl)
+			tt.setMocks(mur, mor, mpr, mopr)
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get(layout.DefaultName).Return(layout.Default(), nil).AnyTimes()
+
+			repositories := func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+				return fn(&domaintransaction.Repositories{
+					Users:         mur,
+					Products:      mpr,
+					Orders:        mor,
+					OrderProducts: mopr,
+				})
+			}
+
+			// A bulk threshold of one line would stage the file if delta files could bulk load
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			if tt.dryRun {
+				muow.EXPECT().View(gomock.Any(), gomock.Any()).DoAndReturn(repositories).AnyTimes()
+			} else {
+				muow.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(repositories).AnyTimes()
+			}
+
+			userService := services.NewUserService(mur, mlr, muow, nil, domainuser.LoadConfig{BulkThreshold: 1})
+
+			mode := tt.mode
+			if mode == "" {
+				mode = domainuser.ModeDelta
+			}
+
+			opts := domainuser.LoadOptions{Format: tt.format, Mode: mode, DryRun: tt.dryRun}
+			result, err := userService.LoadUsersDataFile(context.Background(), strings.NewReader(tt.mockedFile), opts)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.ErrorIs(t, userService.ValidateLoadOptions(opts), tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedAccepted, result.AcceptedLines)
+			assert.Equal(t, tt.expectedWrites, result.Writes)
+			assert.Equal(t, tt.expectedTotals, result.OrderTotals)
+			if tt.expectedRejections != nil {
+				assert.Equal(t, tt.expectedRejections, result.Rejections)
+			} else {
+				assert.Empty(t, result.Rejections)
+			}
+		})
+	}
+}
+
+func Test_LoadUsersDataFile_UserService_Snapshot(t *testing.T) {
+	orderDate := time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC)
+	start := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)
+	mockRecord := func(orderID uint, date string) string {
+		return fmt.Sprintf(
+			`{"user_id":70,"user_name":"Palmer Prosacco","order_id":%d,"product_id":3,"product_value":10,"order_date":%q}`+"\n",
+			orderID, date,
+		)
+	}
+	storedOrders := []*entities.Order{
+		{ID: 753, UserID: 70, Date: orderDate},
+		{ID: 800, UserID: 12, Date: orderDate},
+	}
+	missingLines := []*entities.OrderProduct{
+		{OrderID: 800, ProductID: 4, Value: 1000, Position: 1},
+		{OrderID: 800, ProductID: 5, Value: 500, Position: 1},
+	}
+	fileLine := &entities.OrderProduct{ID: 1, OrderID: 753, ProductID: 3, Value: 1000, Position: 1}
+	// The order of the file lost the product 6, the snapshot only lists the product 3
+	lostLine := &entities.OrderProduct{ID: 2, OrderID: 753, ProductID: 6, Value: 250, Position: 1}
+
+	tests := []struct {
+		description        string
+		mockedFile         string
+		snapshotEnd        string
+		dryRun             bool
+		setMocks           func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository)
+		expectedWrites     entities.WriteSummary
+		expectedTotals     []*entities.OrderTotal
+		expectedRejections []*entities.LineRejection
+		expectedErr        error
+		isOptionErr        bool
+	}{
+		{
+			description: "should upsert the file and remove the orders of the range missing from it",
+			snapshotEnd: "2021-03-31",
+			mockedFile:  mockRecord(753, "2021-03-08"),
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+				mur.EXPECT().Get(uint(70)).Return(&entities.User{ID: 70, Name: "Palmer Prosacco"}, nil)
+				mor.EXPECT().Get(uint(753)).Return(storedOrders[0], nil)
+				mur.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertUnchanged, nil)
+				mpr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertUnchanged, nil)
+				mor.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertUnchanged, nil)
+				mopr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil)
+
+				mor.EXPECT().GetByInterval(start, end).Return(storedOrders, nil)
+				mopr.EXPECT().GetByOrderID(uint(800)).Return(missingLines, nil)
+				mopr.EXPECT().DeleteByOrderID(uint(800)).Return(2, nil)
+				mor.EXPECT().DeleteIfEmpty(uint(800)).Return(true, nil)
+				mopr.EXPECT().GetByOrderID(uint(753)).Return([]*entities.OrderProduct{fileLine}, nil)
+			},
+			expectedWrites: entities.WriteSummary{
+				Users:         entities.WriteCounts{Unchanged: 1},
+				Products:      entities.WriteCounts{Unchanged: 1},
+				Orders:        entities.WriteCounts{Unchanged: 1, Deleted: 1},
+				OrderProducts: entities.WriteCounts{Inserted: 1, Deleted: 2},
+			},
+			expectedTotals: []*entities.OrderTotal{{OrderID: 800, Previous: 1500, Removed: true}},
+		},
+		{
+			description: "should preview the removal of the orders of the range missing from the file",
+			snapshotEnd: "2021-03-31",
+			mockedFile:  mockRecord(753, "2021-03-08"),
+			dryRun:      true,
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+				mur.EXPECT().Get(uint(70)).Return(&entities.User{ID: 70, Name: "Palmer Prosacco"}, nil)
+				mor.EXPECT().Get(uint(753)).Return(storedOrders[0], nil)
+				mpr.EXPECT().Get(uint(3)).Return(&entities.Product{ID: 3}, nil)
+				mopr.EXPECT().GetByOrderID(uint(753)).Return([]*entities.OrderProduct{}, nil)
+
+				mor.EXPECT().GetByInterval(start, end).Return(storedOrders, nil)
+				mopr.EXPECT().GetByOrderID(uint(800)).Return(missingLines, nil)
+			},
+			expectedWrites: entities.WriteSummary{
+				Users:         entities.WriteCounts{Unchanged: 1},
+				Products:      entities.WriteCounts{Unchanged: 1},
+				Orders:        entities.WriteCounts{Unchanged: 1, Deleted: 1},
+				OrderProducts: entities.WriteCounts{Inserted: 1, Deleted: 2},
+			},
+			expectedTotals: []*entities.OrderTotal{{OrderID: 800, Previous: 1500, Removed: true}},
+		},
+		{
+			description: "should remove the lines an order of the file lost and tell its totals",
+			snapshotEnd: "2021-03-31",
+			mockedFile:  mockRecord(753, "2021-03-08"),
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+				mur.EXPECT().Get(uint(70)).Return(&entities.User{ID: 70, Name: "Palmer Prosacco"}, nil)
+				mor.EXPECT().Get(uint(753)).Return(storedOrders[0], nil)
+				mur.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertUnchanged, nil)
+				mpr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertUnchanged, nil)
+				mor.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertUnchanged, nil)
+				mopr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertUnchanged, nil)
+
+				mor.EXPECT().GetByInterval(start, end).Return(storedOrders[:1], nil)
+				mopr.EXPECT().GetByOrderID(uint(753)).Return([]*entities.OrderProduct{fileLine, lostLine}, nil)
+				mopr.EXPECT().DeleteByID(uint(2)).Return(1, nil)
+			},
+			expectedWrites: entities.WriteSummary{
+				Users:         entities.WriteCounts{Unchanged: 1},
+				Products:      entities.WriteCounts{Unchanged: 1},
+				Orders:        entities.WriteCounts{Unchanged: 1},
+				OrderProducts: entities.WriteCounts{Unchanged: 1, Deleted: 1},
+			},
+			expectedTotals: []*entities.OrderTotal{{OrderID: 753, Previous: 1250, Current: 1000}},
+		},
+		{
+			description: "should preview the removal of the lines an order of the file lost",
+			snapshotEnd: "2021-03-31",
+			mockedFile:  mockRecord(753, "2021-03-08"),
+			dryRun:      true,
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+				mur.EXPECT().Get(uint(70)).Return(&entities.User{ID: 70, Name: "Palmer Prosacco"}, nil)
+				mor.EXPECT().Get(uint(753)).Return(storedOrders[0], nil)
+				mpr.EXPECT().Get(uint(3)).Return(&entities.Product{ID: 3}, nil)
+				mopr.EXPECT().GetByOrderID(uint(753)).Return([]*entities.OrderProduct{fileLine, lostLine}, nil)
+
+				mor.EXPECT().GetByInterval(start, end).Return(storedOrders[:1], nil)
+			},
+			expectedWrites: entities.WriteSummary{
+				Users:         entities.WriteCounts{Unchanged: 1},
+				Products:      entities.WriteCounts{Unchanged: 1},
+				Orders:        entities.WriteCounts{Unchanged: 1},
+				OrderProducts: entities.WriteCounts{Unchanged: 1, Deleted: 1},
+			},
+			expectedTotals: []*entities.OrderTotal{{OrderID: 753, Previous: 1250, Current: 1000}},
+		},
+		{
+			description: "should reject a record dated out of the range and remove nothing",
+			snapshotEnd: "2021-03-31",
+			mockedFile:  mockRecord(753, "2021-04-01"),
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+			},
+			expectedRejections: []*entities.LineRejection{
+				{Line: 1, Field: "order_date", Value: "2021-04-01T00:00:00Z", Reason: "must be in the range of dates of the snapshot"},
+			},
+			expectedErr: errors.ErrSnapshotPartial,
+		},
+		{
+			description: "should return error when the range has no end",
+			mockedFile:  mockRecord(753, "2021-03-08"),
+			setMocks: func(mur *user.MockRepository, mor *order.MockRepository, mpr *product.MockRepository, mopr *orderproducts.MockRepository) {
+			},
+			expectedErr: errors.ErrNoSnapshotRange,
+			isOptionErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mur := user.NewMockRepository(ctrl)
+			mor := order.NewMockRepository(ctrl)
+			mpr := product.NewMockRepository(ctrl)
+			mopr := orderproducts.NewMockRepository(ctrl)
+			tt.setMocks(mur, mor, mpr, mopr)
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get(layout.DefaultName).Return(layout.Default(), nil).AnyTimes()
+
+			repositories := func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+				return fn(&domaintransaction.Repositories{
+					Users:         mur,
+					Products:      mpr,
+					Orders:        mor,
+					OrderProducts: mopr,
+				})
+			}
+
+			// Snapshots are loaded at once even with a chunk size, or the first chunk would
+			// be committed before the orders are removed
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			if tt.dryRun {
+				muow.EXPECT().View(gomock.Any(), gomock.Any()).DoAndReturn(repositories).MaxTimes(1)
+			} else {
+				muow.EXPECT().Do(gomock.Any(), gomock.Any()).DoAndReturn(repositories).MaxTimes(1)
+			}
+
+			userService := services.NewUserService(mur, mlr, muow, nil, domainuser.LoadConfig{ChunkSize: 1})
+
+			opts := domainuser.LoadOptions{
+				Mode:          domainuser.ModeSnapshot,
+				SnapshotStart: "2021-03-01",
+				SnapshotEnd:   tt.snapshotEnd,
+				DryRun:        tt.dryRun,
+			}
+			result, err := userService.LoadUsersDataFile(context.Background(), strings.NewReader(tt.mockedFile), opts)
+			if tt.isOptionErr {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.ErrorIs(t, userService.ValidateLoadOptions(opts), tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, userService.ValidateLoadOptions(opts))
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Equal(t, tt.expectedRejections, result.Rejections)
+				assert.Zero(t, result.AcceptedLines)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, 1, result.AcceptedLines)
+			assert.Equal(t, tt.expectedWrites, result.Writes)
+			assert.Equal(t, tt.expectedTotals, result.OrderTotals)
+			assert.Empty(t, result.Rejections)
+		})
+	}
+}
+
+func Test_LoadUsersDataFile_UserService_Quarantine(t *testing.T) {
+	mockOpts := domainuser.LoadOptions{BatchID: "a1b2c3", FileName: "users.txt"}
+
+	mockRejectedLines := []*entities.RejectedLine{
+		{
+			BatchID:  "a1b2c3",
+			FileName: "users.txt",
+			Line:     3,
+			Content:  "00000000AB                              Palmer Prosacco00000007530000000003     1836.7420211399",
+			Rejections: []*entities.LineRejection{
+				{Line: 3, Field: "user_id", Value: "00000000AB", Reason: "must be a positive integer"},
+				{Line: 3, Field: "order_date", Value: "20211399", Reason: "must be a valid date in YYYYMMDD format"},
+			},
+		},
+		{
+			BatchID:  "a1b2c3",
+			FileName: "users.txt",
+			Line:     4,
+			Content:  "0000000070 Palmer Prosacco",
+			Rejections: []*entities.LineRejection{
+				{Line: 4, Field: "line", Value: "0000000070 Palmer Prosacco", Reason: "line has 26 characters, expected 95"},
+			},
+		},
+	}
+
+	tests := []struct {
+		description           string
+		setMocks              func(mqr *quarantine.MockRepository)
+		expectedAcceptedLines int
+		isErrExpected         bool
+	}{
+		{
+			description: "should quarantine the invalid lines of an upload as they were read",
+			setMocks: func(mqr *quarantine.MockRepository) {
+				mqr.EXPECT().Save(mockRejectedLines).Return(nil)
+			},
+			expectedAcceptedLines: 1,
+		},
+		{
+			description: "should roll back the lines stored with them when they can not be quarantined",
+			setMocks: func(mqr *quarantine.MockRepository) {
+				mqr.EXPECT().Save(mockRejectedLines).Return(assert.AnError)
+			},
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mur := user.NewMockRepository(ctrl)
+			mor := order.NewMockRepository(ctrl)
+			mpr := product.NewMockRepository(ctrl)
+			mopr := orderproducts.NewMockRepository(ctrl)
+			mqr := quarantine.NewMockRepository(ctrl)
+			tt.setMocks(mqr)
+
+			mur.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrUserNotFound).AnyTimes()
+			mor.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrOrderNotFound).AnyTimes()
+			mur.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).MaxTimes(1)
+			mpr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).MaxTimes(1)
+			mor.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).MaxTimes(1)
+			mopr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).MaxTimes(1)
+
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			muow.
+				EXPECT().
+				Do(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+					return fn(&domaintransaction.Repositories{
+						Users:         mur,
+						Products:      mpr,
+						Orders:        mor,
+						OrderProducts: mopr,
+						Quarantine:    mqr,
+					})
+				})
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get(layout.DefaultName).Return(layout.Default(), nil).AnyTimes()
+
+			userService := services.NewUserService(mur, mlr, muow, nil, domainuser.LoadConfig{})
+
+			file, err := os.Open("./mocks/user/mock_invalid_data_file.txt")
+			if err != nil {
+				panic(err)
+			}
+			defer file.Close()
+
+			result, err := userService.LoadUsersDataFile(context.Background(), file, mockOpts)
+			if tt.isErrExpected {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.expectedAcceptedLines, result.AcceptedLines)
+		})
+	}
+}
+
+func Test_LoadUsersDataFile_UserService_Progress(t *testing.T) {
+	tests := []struct {
+		description      string
+		opts             domainuser.LoadOptions
+		expectedProgress *entities.IngestionProgress
+	}{
+		{
+			description: "should publish the progress of the file of an upload until it is loaded",
+			opts:        domainuser.LoadOptions{BatchID: "a1b2c3", FileName: "users.txt", Size: 219},
+			expectedProgress: &entities.IngestionProgress{
+				BatchID:       "a1b2c3",
+				FileName:      "users.txt",
+				ReadLines:     4,
+				WrittenLines:  1,
+				RejectedLines: 2,
+				SkippedLines:  1,
+				ReadBytes:     219,
+				TotalBytes:    219,
+				Done:          true,
+			},
+		},
+		{
+			description: "should not publish the progress of a load that is not an upload",
+			opts:        domainuser.LoadOptions{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mur := user.NewMockRepository(ctrl)
+			mor := order.NewMockRepository(ctrl)
+			mpr := product.NewMockRepository(ctrl)
+			mopr := orderproducts.NewMockRepository(ctrl)
+			mqr := quarantine.NewMockRepository(ctrl)
+
+			mur.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrUserNotFound).AnyTimes()
+			mor.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrOrderNotFound).AnyTimes()
+			mur.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil)
+			mpr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil)
+			mor.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil)
+			mopr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil)
+			mqr.EXPECT().Save(gomock.Any()).Return(nil).AnyTimes()
+
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			muow.
+				EXPECT().
+				Do(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+					return fn(&domaintransaction.Repositories{
+						Users:         mur,
+						Products:      mpr,
+						Orders:        mor,
+						OrderProducts: mopr,
+						Quarantine:    mqr,
+					})
+				})
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get(layout.DefaultName).Return(layout.Default(), nil).AnyTimes()
+
+			// Progress may be published on the way, depending on how long the load takes
+			published := make([]*entities.IngestionProgress, 0)
+			mpb := progress.NewMockBroadcaster(ctrl)
+			mpb.EXPECT().Publish(gomock.Any()).Do(func(p *entities.IngestionProgress) {
+				published = append(published, p)
+			}).AnyTimes()
+
+			userService := services.NewUserService(mur, mlr, muow, mpb, domainuser.LoadConfig{})
+
+			file, err := os.Open("./mocks/user/mock_invalid_data_file.txt")
+			if err != nil {
+				panic(err)
+			}
+			defer file.Close()
+
+			_, err = userService.LoadUsersDataFile(context.Background(), file, tt.opts)
+			assert.NoError(t, err)
+
+			if tt.expectedProgress == nil {
+				assert.Empty(t, published)
+				return
+			}
+
+			assert.NotEmpty(t, published)
+			last := published[len(published)-1]
+			assert.False(t, last.StartedAt.IsZero())
+			assert.False(t, last.UpdatedAt.Before(last.StartedAt))
+
+			last.LinesPerSecond, last.StartedAt, last.UpdatedAt = 0, time.Time{}, time.Time{}
+			assert.Equal(t, tt.expectedProgress, last)
+		})
+	}
+}
+
+func Test_ReprocessRejectedLines_UserService(t *testing.T) {
+	mockFixedWidthLine := "0000000070                              Palmer Prosacco00000007530000000003     1836.7420210308"
+	mockShortLine := "0000000070 Palmer Prosacco"
+	mockConflict := &entities.LineRejection{Line: 2, Field: "order_date", Value: "2021-03-08", Reason: "order 753 already has the date 2021-03-07"}
+
+	quarantined := func(line int, content string, rejections ...*entities.LineRejection) *entities.RejectedLine {
+		return &entities.RejectedLine{
+			BatchID:    "a1b2c3",
+			FileName:   "users.txt",
+			Line:       line,
+			Content:    content,
+			Rejections: rejections,
+		}
+	}
+
+	tests := []struct {
+		description           string
+		format                domainuser.Format
+		quarantined           []*entities.RejectedLine
+		expectedReleased      []int
+		expectedRejected      []*entities.RejectedLine
+		expectedAcceptedLines int
+		expectedRejections    []*entities.LineRejection
+		isErrExpected         bool
+	}{
+		{
+			description: "should store the lines that are valid now and quarantine back the others with their line",
+			format:      domainuser.FormatFixedWidth,
+			quarantined: []*entities.RejectedLine{
+				quarantined(2, mockFixedWidthLine, mockConflict),
+				quarantined(4, mockShortLine),
+			},
+			expectedReleased: []int{2},
+			expectedRejected: []*entities.RejectedLine{
+				quarantined(4, mockShortLine, &entities.LineRejection{
+					Line: 4, Field: "line", Value: mockShortLine, Reason: "line has 26 characters, expected 95",
+				}),
+			},
+			expectedAcceptedLines: 1,
+			expectedRejections: []*entities.LineRejection{
+				{Line: 4, Field: "line", Value: mockShortLine, Reason: "line has 26 characters, expected 95"},
+			},
+		},
+		{
+			description: "should read csv lines under the header they were written back with",
+			format:      domainuser.FormatCSV,
+			quarantined: []*entities.RejectedLine{
+				quarantined(3, "70,Palmer Prosacco,753,3,1836.74,2021-03-08", mockConflict),
+			},
+			expectedReleased:      []int{3},
+			expectedAcceptedLines: 1,
+		},
+		{
+			description:   "should refuse a file without a known format",
+			format:        domainuser.FormatAuto,
+			isErrExpected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mur := user.NewMockRepository(ctrl)
+			mor := order.NewMockRepository(ctrl)
+			mpr := product.NewMockRepository(ctrl)
+			mopr := orderproducts.NewMockRepository(ctrl)
+			mqr := quarantine.NewMockRepository(ctrl)
+
+			mur.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrUserNotFound).AnyTimes()
+			mor.EXPECT().Get(gomock.Any()).Return(nil, errors.ErrOrderNotFound).AnyTimes()
+			mur.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).Times(tt.expectedAcceptedLines)
+			mpr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).Times(tt.expectedAcceptedLines)
+			mor.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).Times(tt.expectedAcceptedLines)
+			mopr.EXPECT().Upsert(gomock.Any()).Return(entities.UpsertInserted, nil).Times(tt.expectedAcceptedLines)
+
+			repositories := &domaintransaction.Repositories{
+				Users:         mur,
+				Products:      mpr,
+				Orders:        mor,
+				OrderProducts: mopr,
+				Quarantine:    mqr,
+			}
+
+			muow := transaction.NewMockUnitOfWork(ctrl)
+			if !tt.isErrExpected {
+				mqr.EXPECT().Get("a1b2c3", "users.txt", 0, 500).Return(tt.quarantined, nil)
+				mqr.EXPECT().Remove("a1b2c3", "users.txt", tt.expectedReleased).Return(nil)
+				if tt.expectedRejected != nil {
+					mqr.EXPECT().Save(tt.expectedRejected).Return(nil)
+				}
+
+				muow.
+					EXPECT().
+					View(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+						return fn(repositories)
+					})
+				muow.
+					EXPECT().
+					Do(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, fn func(*domaintransaction.Repositories) error) error {
+						return fn(repositories)
+					})
+			}
+
+			mlr := mocklayout.NewMockRepository(ctrl)
+			mlr.EXPECT().Get(layout.DefaultName).Return(layout.Default(), nil).AnyTimes()
+
+			userService := services.NewUserService(mur, mlr, muow, nil, domainuser.LoadConfig{})
+
+			result, err := userService.ReprocessRejectedLines(context.Background(), domainuser.LoadOptions{
+				Format:   tt.format,
+				BatchID:  "a1b2c3",
+				FileName: "users.txt",
+			})
+			if tt.isErrExpected {
+				assert.ErrorIs(t, err, errors.ErrUnknownFormat)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.format, result.Format)
+			assert.Equal(t, len(tt.quarantined), result.ProcessedLines)
+			assert.Equal(t, tt.expectedAcceptedLines, result.AcceptedLines)
+			assert.Equal(t, tt.expectedRejections, result.Rejections)
 		})
 	}
 }
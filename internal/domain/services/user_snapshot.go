@@ -0,0 +1,234 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/errors"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/order"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/transaction"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+// snapshotInterval returns the range of order dates of a snapshot file, read in the business
+// location, or nil when the file is not a snapshot. Both dates must be sent
+func (s *userService) snapshotInterval(opts user.LoadOptions) (*order.Interval, error) {
+	if opts.Mode != user.ModeSnapshot {
+		return nil, nil
+	}
+
+	if opts.SnapshotStart == "" || opts.SnapshotEnd == "" {
+		return nil, errors.ErrNoSnapshotRange
+	}
+
+	location := s.config.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	return order.ParseInterval(opts.SnapshotStart, opts.SnapshotEnd, time.Now(), location)
+}
+
+// checkSnapshotDate rejects the line of a snapshot file whose order is dated out of its range
+func (in *ingestion) checkSnapshotDate(userData *user.UserFileData) *entities.LineRejection {
+	date := userData.OrderDate
+	if !date.Before(in.snapshot.Start) && date.Before(in.snapshot.End) {
+		return nil
+	}
+
+	return &entities.LineRejection{
+		Field:  entities.FieldOrderDate,
+		Value:  date.Format(time.RFC3339),
+		Reason: "must be in the range of dates of the snapshot",
+	}
+}
+
+// keepSnapshotLine keeps the line of a snapshot file among the lines of its order
+func (in *ingestion) keepSnapshotLine(userData *user.UserFileData) {
+	lines, ok := in.snapshotOrders[userData.OrderID]
+	if !ok {
+		lines = make(map[orderProductKey]bool)
+		in.snapshotOrders[userData.OrderID] = lines
+	}
+
+	lines[orderProductKey{
+		productID: userData.ProductID,
+		value:     userData.ProductValue,
+		position:  userData.Position,
+	}] = true
+}
+
+// removeMissingOrders removes the stored orders of the range of the snapshot missing from
+// the file, with their lines, and the stored lines the file misses of the orders it holds,
+// keeping in report the totals of the orders before and after the removal. A file with
+// lines that could not be read removes nothing, as the orders of those lines are not known,
+// and fails the load instead. Dry runs only preview the removal
+func (in *ingestion) removeMissingOrders(r *transaction.Repositories, report *writeReport) error {
+	if in.partial {
+		return errors.ErrSnapshotPartial
+	}
+
+	stored, err := r.Orders.GetByInterval(in.snapshot.Start, in.snapshot.End)
+	if err != nil {
+		return fmt.Errorf("failed to look up orders of the snapshot: %w", err)
+	}
+
+	missing := make([]uint, 0)
+	held := make([]uint, 0)
+	for _, storedOrder := range stored {
+		if _, ok := in.snapshotOrders[storedOrder.ID]; ok {
+			held = append(held, storedOrder.ID)
+		} else {
+			missing = append(missing, storedOrder.ID)
+		}
+	}
+
+	if in.preview != nil {
+		if err := in.preview.previewRemoval(r, missing, report); err != nil {
+			return err
+		}
+		return in.preview.previewMissingLines(r, held, in.snapshotOrders, report)
+	}
+
+	totals := make(map[uint]*entities.OrderTotal, len(missing))
+	for _, orderID := range missing {
+		previous, err := getOrderTotal(r, orderID)
+		if err != nil {
+			return err
+		}
+
+		deleted, err := r.OrderProducts.DeleteByOrderID(orderID)
+		if err != nil {
+			return fmt.Errorf("failed to delete products of order %d: %w", orderID, err)
+		}
+		report.writes.OrderProducts.Deleted += deleted
+
+		removed, err := r.Orders.DeleteIfEmpty(orderID)
+		if err != nil {
+			return fmt.Errorf("failed to delete order %d: %w", orderID, err)
+		}
+		if removed {
+			report.writes.Orders.Deleted++
+		}
+
+		totals[orderID] = &entities.OrderTotal{OrderID: orderID, Previous: previous, Removed: removed}
+	}
+
+	for _, orderID := range held {
+		total, err := removeMissingLines(r, orderID, in.snapshotOrders[orderID], report)
+		if err != nil {
+			return err
+		}
+		if total != nil {
+			totals[orderID] = total
+		}
+	}
+	report.addTotals(totals)
+
+	return nil
+}
+
+// removeMissingLines removes the stored lines of the order missing from the lines of the
+// file, returning the total of the order before and after the removal, or nil when every
+// stored line is in the file. The total before counts the lines the file added
+func removeMissingLines(
+	r *transaction.Repositories,
+	orderID uint,
+	lines map[orderProductKey]bool,
+	report *writeReport,
+) (*entities.OrderTotal, error) {
+	stored, err := r.OrderProducts.GetByOrderID(orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up products of order %d: %w", orderID, err)
+	}
+
+	total := &entities.OrderTotal{OrderID: orderID}
+	removed := 0
+	for _, orderProduct := range stored {
+		total.Previous += orderProduct.Value
+
+		key := orderProductKey{
+			productID: orderProduct.ProductID,
+			value:     orderProduct.Value,
+			position:  orderProduct.Position,
+		}
+		if lines[key] {
+			total.Current += orderProduct.Value
+			continue
+		}
+
+		deleted, err := r.OrderProducts.DeleteByID(orderProduct.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete product %d of order %d: %w", orderProduct.ProductID, orderID, err)
+		}
+		removed += deleted
+	}
+
+	if removed == 0 {
+		return nil, nil
+	}
+	report.writes.OrderProducts.Deleted += removed
+
+	return total, nil
+}
+
+// previewRemoval counts the orders a snapshot would remove and their lines, as the previous
+// lines of the file left them, keeping in report their totals
+func (d *dryRun) previewRemoval(r *transaction.Repositories, orderIDs []uint, report *writeReport) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	totals := make(map[uint]*entities.OrderTotal, len(orderIDs))
+	for _, orderID := range orderIDs {
+		lines, err := d.lookupOrderProducts(r, &user.UserFileData{OrderID: orderID})
+		if err != nil {
+			return err
+		}
+
+		report.writes.OrderProducts.Deleted += len(lines)
+		report.writes.Orders.Deleted++
+		totals[orderID] = &entities.OrderTotal{OrderID: orderID, Previous: linesTotal(lines), Removed: true}
+	}
+	report.addTotals(totals)
+
+	return nil
+}
+
+// previewMissingLines counts the stored lines of the orders a snapshot would remove for
+// missing from the lines of the file, keeping in report the totals of the orders losing any
+func (d *dryRun) previewMissingLines(
+	r *transaction.Repositories,
+	orderIDs []uint,
+	fileLines map[uint]map[orderProductKey]bool,
+	report *writeReport,
+) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	totals := make(map[uint]*entities.OrderTotal)
+	for _, orderID := range orderIDs {
+		lines, err := d.lookupOrderProducts(r, &user.UserFileData{OrderID: orderID})
+		if err != nil {
+			return err
+		}
+
+		previous := linesTotal(lines)
+		removed := 0
+		for key := range lines {
+			if !fileLines[orderID][key] {
+				delete(lines, key)
+				removed++
+			}
+		}
+		if removed == 0 {
+			continue
+		}
+
+		report.writes.OrderProducts.Deleted += removed
+		totals[orderID] = &entities.OrderTotal{OrderID: orderID, Previous: previous, Current: linesTotal(lines)}
+	}
+	report.addTotals(totals)
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package transaction
+
+import (
+	"context"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/conflict"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/order"
+	orderproducts "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/order_products"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/product"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/quarantine"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/upload"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+// Repositories groups the repositories bound to the same transaction
+type Repositories struct {
+	Users         user.Repository
+	Products      product.Repository
+	Orders        order.Repository
+	OrderProducts orderproducts.Repository
+	Bulk          user.BulkRepository
+	Conflicts     conflict.Repository
+	Quarantine    quarantine.Repository
+	Batches       upload.Repository
+	Rollbacks     upload.RollbackRepository
+}
+
+// batchKey is the context key of the ingestion batch a transaction writes for
+type batchKey struct{}
+
+// WithBatch tells the transactions run with the returned context that they write the lines
+// of the ingestion batch, so the rows they insert and change can be rolled back with it
+func WithBatch(ctx context.Context, batchID string) context.Context {
+	return context.WithValue(ctx, batchKey{}, batchID)
+}
+
+// BatchFrom returns the ingestion batch of the context, empty when there is none
+func BatchFrom(ctx context.Context) string {
+	batchID, _ := ctx.Value(batchKey{}).(string)
+	return batchID
+}
+
+// UnitOfWork runs a set of repository operations as a single unit
+type UnitOfWork interface {
+	// Do runs fn inside a transaction, committing it when fn returns nil
+	// and rolling it back otherwise. The writes are tagged with the batch of ctx
+	Do(ctx context.Context, fn func(repositories *Repositories) error) error
+	// View runs fn inside a read-only transaction, which is always rolled back,
+	// so any write attempted by fn fails
+	View(ctx context.Context, fn func(repositories *Repositories) error) error
+}
@@ -0,0 +1,36 @@
+package upload
+
+import (
+	"time"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+)
+
+type Repository interface {
+	Get(id string) (*entities.UploadJob, error)
+	// GetAll returns the jobs from the newest to the oldest, skipping offset jobs and returning up to limit
+	GetAll(limit, offset int) ([]*entities.UploadJob, error)
+	// GetImportedBySHA256 returns the latest job that loaded the file with the fingerprint or
+	// is still loading it, leaving out failed and rolled back jobs and dry runs
+	GetImportedBySHA256(sha256 string) (*entities.UploadJob, error)
+	// Save inserts the job or updates it. A new job loading a file another job is already
+	// loading or loaded is refused with ErrUploadAlreadyImported, unless it is forced
+	Save(job *entities.UploadJob) error
+	// FailUnfinished marks the jobs left queued or running as failed with the reason,
+	// returning how many it marked
+	FailUnfinished(reason string, finishedAt time.Time) (int, error)
+}
+
+// RollbackRepository undoes what ingestion batches wrote. Rows are tagged with the batch
+// that inserted them and every change to a stored row keeps the values it overwrote
+type RollbackRepository interface {
+	// Lock keeps any other load from writing until the transaction ends, so the batches
+	// depending on the rolled back ones can not change while they are rolled back
+	Lock() error
+	// GetDependents returns the other batches that changed or reference rows the batches
+	// wrote after them. Loads that are not batches are returned as an empty ID
+	GetDependents(batchIDs []string) ([]string, error)
+	// Rollback removes the rows the batches inserted, restores the values they overwrote
+	// to the ones before the first of them and removes the conflicts they raised
+	Rollback(batchIDs []string) (entities.RollbackSummary, error)
+}
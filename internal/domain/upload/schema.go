@@ -0,0 +1,256 @@
+package upload
+
+import (
+	"time"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+type EntryResponse struct {
+	Name           string                        `json:"name"`
+	Format         string                        `json:"format"`
+	ProcessedLines int                           `json:"processed_lines"`
+	AcceptedLines  int                           `json:"accepted_lines"`
+	RejectedLines  int                           `json:"rejected_lines"`
+	SkippedLines   int                           `json:"skipped_lines"`
+	Rejections     []*user.LineRejectionResponse `json:"rejections"`
+	Conflicts      []*user.LineConflictResponse  `json:"conflicts"`
+	Writes         *user.WriteSummaryResponse    `json:"writes"`
+	Header         *user.FileHeaderResponse      `json:"header,omitempty"`
+	OrderTotals    []*user.OrderTotalResponse    `json:"order_totals,omitempty"`
+	Error          string                        `json:"error,omitempty"`
+}
+
+// JobSummaryResponse is a job in the upload history, without its rejections, conflicts and entries
+type JobSummaryResponse struct {
+	ID             string                     `json:"id"`
+	FileName       string                     `json:"file_name"`
+	SHA256         string                     `json:"sha256"`
+	Size           int64                      `json:"size"`
+	UploadedBy     string                     `json:"uploaded_by,omitempty"`
+	Forced         bool                       `json:"forced"`
+	DryRun         bool                       `json:"dry_run"`
+	State          string                     `json:"state"`
+	ProcessedLines int                        `json:"processed_lines"`
+	AcceptedLines  int                        `json:"accepted_lines"`
+	RejectedLines  int                        `json:"rejected_lines"`
+	SkippedLines   int                        `json:"skipped_lines"`
+	Writes         *user.WriteSummaryResponse `json:"writes"`
+	Error          string                     `json:"error,omitempty"`
+	CreatedAt      time.Time                  `json:"created_at"`
+	StartedAt      *time.Time                 `json:"started_at,omitempty"`
+	FinishedAt     *time.Time                 `json:"finished_at,omitempty"`
+}
+
+type JobResponse struct {
+	ID             string                        `json:"id"`
+	FileName       string                        `json:"file_name"`
+	SHA256         string                        `json:"sha256"`
+	Size           int64                         `json:"size"`
+	UploadedBy     string                        `json:"uploaded_by,omitempty"`
+	Forced         bool                          `json:"forced"`
+	Layout         string                        `json:"layout"`
+	Format         string                        `json:"format"`
+	Encoding       string                        `json:"encoding,omitempty"`
+	Mode           string                        `json:"mode,omitempty"`
+	SnapshotStart  string                        `json:"snapshot_start,omitempty"`
+	SnapshotEnd    string                        `json:"snapshot_end,omitempty"`
+	Compression    string                        `json:"compression,omitempty"`
+	DryRun         bool                          `json:"dry_run"`
+	State          string                        `json:"state"`
+	ProcessedLines int                           `json:"processed_lines"`
+	AcceptedLines  int                           `json:"accepted_lines"`
+	RejectedLines  int                           `json:"rejected_lines"`
+	SkippedLines   int                           `json:"skipped_lines"`
+	Rejections     []*user.LineRejectionResponse `json:"rejections"`
+	Conflicts      []*user.LineConflictResponse  `json:"conflicts"`
+	Writes         *user.WriteSummaryResponse    `json:"writes"`
+	Entries        []*EntryResponse              `json:"entries"`
+	Error          string                        `json:"error,omitempty"`
+	CreatedAt      time.Time                     `json:"created_at"`
+	StartedAt      *time.Time                    `json:"started_at,omitempty"`
+	FinishedAt     *time.Time                    `json:"finished_at,omitempty"`
+	RolledBackAt   *time.Time                    `json:"rolled_back_at,omitempty"`
+}
+
+type RollbackCountsResponse struct {
+	Removed  int `json:"removed"`
+	Restored int `json:"restored"`
+}
+
+type RollbackSummaryResponse struct {
+	Users         RollbackCountsResponse `json:"users"`
+	Products      RollbackCountsResponse `json:"products"`
+	Orders        RollbackCountsResponse `json:"orders"`
+	OrderProducts RollbackCountsResponse `json:"order_products"`
+}
+
+// RollbackResponse lists the jobs a rollback undid, the requested one first, and what it did
+type RollbackResponse struct {
+	Message    string                   `json:"message"`
+	RolledBack []string                 `json:"rolled_back"`
+	Rows       *RollbackSummaryResponse `json:"rows"`
+}
+
+// ReprocessResponse tells what loading again the rejected lines of a job did. The lines rejected
+// again are listed in the result, and the job at StatusURL has the updated totals
+type ReprocessResponse struct {
+	Message   string                       `json:"message"`
+	JobID     string                       `json:"job_id"`
+	StatusURL string                       `json:"status_url"`
+	Result    *user.UserFileResultResponse `json:"result"`
+}
+
+func FromJobToResponse(job *entities.UploadJob) *JobResponse {
+	res := &JobResponse{
+		ID:             job.ID,
+		FileName:       job.FileName,
+		SHA256:         job.SHA256,
+		Size:           job.Size,
+		UploadedBy:     job.UploadedBy,
+		Forced:         job.Forced,
+		Layout:         job.Layout,
+		Format:         job.Format,
+		Encoding:       job.Encoding,
+		Mode:           job.Mode,
+		SnapshotStart:  job.SnapshotStart,
+		SnapshotEnd:    job.SnapshotEnd,
+		Compression:    job.Compression,
+		DryRun:         job.DryRun,
+		State:          string(job.State),
+		ProcessedLines: job.ProcessedLines,
+		AcceptedLines:  job.AcceptedLines,
+		RejectedLines:  job.RejectedLines,
+		SkippedLines:   job.SkippedLines,
+		Rejections:     make([]*user.LineRejectionResponse, 0),
+		Conflicts:      make([]*user.LineConflictResponse, 0),
+		Writes:         user.FromWriteSummaryToResponse(job.Writes),
+		Entries:        make([]*EntryResponse, 0),
+		Error:          job.Error,
+		CreatedAt:      job.CreatedAt,
+	}
+
+	for _, rejection := range job.Rejections {
+		res.Rejections = append(res.Rejections, user.FromLineRejectionToResponse(rejection))
+	}
+
+	for _, conflict := range job.Conflicts {
+		res.Conflicts = append(res.Conflicts, user.FromLineConflictToResponse(conflict))
+	}
+
+	for _, entry := range job.Entries {
+		res.Entries = append(res.Entries, FromEntryToResponse(entry))
+	}
+
+	res.StartedAt, res.FinishedAt = jobTimes(job)
+	if !job.RolledBackAt.IsZero() {
+		rolledBackAt := job.RolledBackAt
+		res.RolledBackAt = &rolledBackAt
+	}
+
+	return res
+}
+
+func FromRollbackToResponse(jobs []*entities.UploadJob, summary entities.RollbackSummary) *RollbackResponse {
+	toResponse := func(counts entities.RollbackCounts) RollbackCountsResponse {
+		return RollbackCountsResponse{
+			Removed:  counts.Removed,
+			Restored: counts.Restored,
+		}
+	}
+
+	res := &RollbackResponse{
+		Message:    "upload rolled back!",
+		RolledBack: make([]string, 0, len(jobs)),
+		Rows: &RollbackSummaryResponse{
+			Users:         toResponse(summary.Users),
+			Products:      toResponse(summary.Products),
+			Orders:        toResponse(summary.Orders),
+			OrderProducts: toResponse(summary.OrderProducts),
+		},
+	}
+
+	for _, job := range jobs {
+		res.RolledBack = append(res.RolledBack, job.ID)
+	}
+
+	return res
+}
+
+func FromReprocessToResponse(job *entities.UploadJob, result *user.UserFileResult) *ReprocessResponse {
+	return &ReprocessResponse{
+		Message:   "rejected lines reprocessed!",
+		JobID:     job.ID,
+		StatusURL: "/uploads/" + job.ID,
+		Result:    user.FromUserFileResultToResponse(result),
+	}
+}
+
+func FromJobToSummaryResponse(job *entities.UploadJob) *JobSummaryResponse {
+	res := &JobSummaryResponse{
+		ID:             job.ID,
+		FileName:       job.FileName,
+		SHA256:         job.SHA256,
+		Size:           job.Size,
+		UploadedBy:     job.UploadedBy,
+		Forced:         job.Forced,
+		DryRun:         job.DryRun,
+		State:          string(job.State),
+		ProcessedLines: job.ProcessedLines,
+		AcceptedLines:  job.AcceptedLines,
+		RejectedLines:  job.RejectedLines,
+		SkippedLines:   job.SkippedLines,
+		Writes:         user.FromWriteSummaryToResponse(job.Writes),
+		Error:          job.Error,
+		CreatedAt:      job.CreatedAt,
+	}
+
+	res.StartedAt, res.FinishedAt = jobTimes(job)
+
+	return res
+}
+
+// jobTimes returns the start and finish times of the job, nil while they did not happen
+func jobTimes(job *entities.UploadJob) (*time.Time, *time.Time) {
+	var startedAt, finishedAt *time.Time
+
+	if !job.StartedAt.IsZero() {
+		t := job.StartedAt
+		startedAt = &t
+	}
+
+	if !job.FinishedAt.IsZero() {
+		t := job.FinishedAt
+		finishedAt = &t
+	}
+
+	return startedAt, finishedAt
+}
+
+func FromEntryToResponse(entry *entities.UploadEntry) *EntryResponse {
+	res := &EntryResponse{
+		Name:           entry.Name,
+		Format:         entry.Format,
+		ProcessedLines: entry.ProcessedLines,
+		AcceptedLines:  entry.AcceptedLines,
+		RejectedLines:  entry.RejectedLines,
+		SkippedLines:   entry.SkippedLines,
+		Rejections:     make([]*user.LineRejectionResponse, 0),
+		Conflicts:      make([]*user.LineConflictResponse, 0),
+		Writes:         user.FromWriteSummaryToResponse(entry.Writes),
+		Header:         user.FromFileHeaderToResponse(entry.Header),
+		OrderTotals:    user.FromOrderTotalsToResponse(entry.OrderTotals),
+		Error:          entry.Error,
+	}
+
+	for _, rejection := range entry.Rejections {
+		res.Rejections = append(res.Rejections, user.FromLineRejectionToResponse(rejection))
+	}
+
+	for _, conflict := range entry.Conflicts {
+		res.Conflicts = append(res.Conflicts, user.FromLineConflictToResponse(conflict))
+	}
+
+	return res
+}
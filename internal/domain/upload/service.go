@@ -0,0 +1,47 @@
+package upload
+
+import (
+	"context"
+	"io"
+
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/user"
+)
+
+// SubmitOptions tells how an uploaded file is loaded and who sent it
+type SubmitOptions struct {
+	user.LoadOptions
+	UploadedBy string
+	// Force loads the file even when the same file was already imported
+	Force bool
+}
+
+type Service interface {
+	// Submit queues the file to be loaded. A file already imported is refused with
+	// ErrUploadAlreadyImported, returning the job that imported it, unless it is forced
+	Submit(fileName string, file io.Reader, opts SubmitOptions) (*entities.UploadJob, error)
+	// SubmitFile queues the file at path, fingerprinted by sha256, moving it instead of copying
+	// it. A refused file is left at path, refused as in Submit
+	SubmitFile(fileName, path, sha256 string, opts SubmitOptions) (*entities.UploadJob, error)
+	// Import loads the file right away, keeping it as a job like the submitted ones and
+	// returning it along with what the load did. Already imported files are refused as in Submit
+	Import(ctx context.Context, fileName string, file io.ReadSeeker, opts SubmitOptions) (*entities.UploadJob, *user.UserFileResult, error)
+	GetJob(id string) (*entities.UploadJob, error)
+	GetJobs(limit, offset int) ([]*entities.UploadJob, error)
+	// Follow subscribes to the progress of the job while its files are loaded. The channel is
+	// closed once the job finishes, right away when it already did, and cancel must always be called
+	Follow(id string) (events <-chan *entities.IngestionProgress, cancel func(), err error)
+	// Rollback undoes what the job imported and returns the rolled back jobs. It is refused with
+	// ErrUploadHasDependents when later jobs depend on its rows, unless cascade rolls them back too
+	Rollback(id string, cascade bool) ([]*entities.UploadJob, entities.RollbackSummary, error)
+	// WriteRejectedLines writes the quarantined lines of the job to w, in the format of their files,
+	// so they can be fixed and sent again. Dry runs keep no lines and are refused with ErrUploadDryRunRejected
+	WriteRejectedLines(job *entities.UploadJob, w io.Writer) error
+	// ReprocessRejectedLines loads again the quarantined lines of the job, once the data they
+	// depend on was fixed, and returns the updated job along with what the reprocess did
+	ReprocessRejectedLines(id string) (*entities.UploadJob, *user.UserFileResult, error)
+	// FailInterruptedJobs fails the jobs a stopped server left queued or running, returning how
+	// many it failed. It must run before the server queues any job, as it fails those as well
+	FailInterruptedJobs() (int, error)
+	Shutdown(ctx context.Context) error
+}
@@ -0,0 +1,52 @@
+package user
+
+import "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
+
+// Format is how the records of a users data file are written
+type Format string
+
+const (
+	// FormatAuto detects the format from the beginning of the file
+	FormatAuto       Format = "auto"
+	FormatFixedWidth Format = "fixed-width"
+	FormatCSV        Format = "csv"
+	FormatNDJSON     Format = "ndjson"
+	FormatJSON       Format = "json"
+)
+
+// Encoding is the character encoding of a users data file. Files are read as UTF-8, so the
+// names of files in other encodings are converted before they are stored
+type Encoding string
+
+const (
+	EncodingUTF8        Encoding = "utf-8"
+	EncodingISO88591    Encoding = "iso-8859-1"
+	EncodingWindows1252 Encoding = "windows-1252"
+)
+
+// RawRecord is a record read from a file before its fields are validated. Line is the line
+// the record starts at, or its index for formats without lines, and Text the record as read,
+// which a decoder of the same format reads back as the same record
+type RawRecord struct {
+	Line   int
+	Text   string
+	Fields map[string]string
+	// Skipped tells the record was blank
+	Skipped bool
+	// Rejection tells the record could not be split into fields
+	Rejection *entities.LineRejection
+	// Header and Trailer are set instead of the fields when the record is the header or the
+	// trailer of the file
+	Header  *entities.FileHeader
+	Trailer *entities.FileTrailer
+}
+
+// Decoder reads the records of a file in a given format, so every format goes through
+// the same validation and writing
+type Decoder interface {
+	// Fields describes how the raw field values must be validated
+	Fields() []*entities.LayoutField
+	// Next returns the next record, or io.EOF when the file is over. Any other error means
+	// the file can not be read further
+	Next() (*RawRecord, error)
+}
@@ -4,5 +4,20 @@ import "github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/
 
 type Repository interface {
 	Get(id uint) (*entities.User, error)
-	Add(user *entities.User) error
+	Upsert(user *entities.User) (entities.UpsertOutcome, error)
+}
+
+// BulkRepository loads parsed lines in a set-based way: the lines are staged first
+// and then merged into the users, products, orders and order products at once.
+// It must be used inside a transaction, which owns the staged lines
+type BulkRepository interface {
+	Stage(usersData []*UserFileData) error
+	// DetectConflicts compares every staged line with the stored rows and the previous staged
+	// lines, the same way row by row upserts would see them under the policies
+	DetectConflicts(policies entities.ConflictPolicies) ([]*entities.LineConflict, error)
+	// Discard removes the staged lines with the given numbers, so they are not merged, and
+	// returns their content by line
+	Discard(lines []int) (map[int]string, error)
+	// Merge keeps the value stored first of the conflict types whose policy says so
+	Merge(policies entities.ConflictPolicies) (entities.WriteSummary, error)
 }
@@ -6,18 +6,165 @@ import (
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
 )
 
+// Operation is what a record of a delta file does to the stored order lines
+type Operation string
+
+const (
+	// OperationInsert stores the line, as the records of full files are stored
+	OperationInsert Operation = "insert"
+	// OperationUpdate sets the value of the lines the order has of the product
+	OperationUpdate Operation = "update"
+	// OperationDelete removes the lines the order has of the product with the value
+	OperationDelete Operation = "delete"
+)
+
 type UserFileData struct {
-	UserID       uint      `json:"user_id"`
-	UserName     string    `json:"user_name"`
-	OrderID      uint      `json:"order_id"`
-	ProductID    uint      `json:"product_id"`
-	ProductValue float64   `json:"product_value"`
-	OrderDate    time.Time `json:"order_date"`
+	Line     int `json:"-"`
+	Position int `json:"-"`
+	// Operation is only set on the records of delta files
+	Operation Operation `json:"-"`
+	// Text is the line as read, kept to quarantine the line when it is rejected
+	Text string `json:"-"`
+	// Rejections are set instead of the values when the line is invalid, so the writer of
+	// the line quarantines it along with the lines it stores
+	Rejections   []*entities.LineRejection `json:"-"`
+	UserID       uint                      `json:"user_id"`
+	UserName     string                    `json:"user_name"`
+	OrderID      uint                      `json:"order_id"`
+	ProductID    uint                      `json:"product_id"`
+	ProductValue entities.Money            `json:"product_value"`
+	OrderDate    time.Time                 `json:"order_date"`
+}
+
+// UserFileResult summarizes the lines read from a users data file.
+// Rejections lists the reasons of the rejected lines, up to MaxReportedRejections entries.
+// In a dry run nothing is written: Writes and AcceptedLines tell what the load would do
+// and Conflicts lists the lines that would change data already stored, up to
+// MaxReportedConflicts entries. Header is set when the layout of the file has a header
+// record. OrderTotals lists the orders a delta or snapshot file changed, by order, up to
+// MaxReportedOrderTotals orders
+type UserFileResult struct {
+	Format         Format
+	DryRun         bool
+	Header         *entities.FileHeader
+	ProcessedLines int
+	AcceptedLines  int
+	RejectedLines  int
+	SkippedLines   int
+	Rejections     []*entities.LineRejection
+	Conflicts      []*entities.LineConflict
+	Writes         entities.WriteSummary
+	OrderTotals    []*entities.OrderTotal
+}
+
+const (
+	// MaxReportedRejections caps how many rejections are kept in a result, so a
+	// completely invalid file does not hold every error in memory
+	MaxReportedRejections = 1000
+	// MaxReportedConflicts caps how many conflicts are kept in a result
+	MaxReportedConflicts = 1000
+	// MaxReportedOrderTotals caps how many order totals are kept in a result
+	MaxReportedOrderTotals = 1000
+)
+
+// Reject counts a rejected line and keeps its reasons while the cap is not reached
+func (r *UserFileResult) Reject(rejections ...*entities.LineRejection) {
+	r.RejectedLines++
+	for _, rejection := range rejections {
+		if len(r.Rejections) >= MaxReportedRejections {
+			return
+		}
+
+		r.Rejections = append(r.Rejections, rejection)
+	}
+}
+
+// Conflict keeps a conflict while the cap is not reached
+func (r *UserFileResult) Conflict(conflict *entities.LineConflict) {
+	if len(r.Conflicts) >= MaxReportedConflicts {
+		return
+	}
+
+	r.Conflicts = append(r.Conflicts, conflict)
+}
+
+type WriteCountsResponse struct {
+	Inserted  int `json:"inserted"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Deleted   int `json:"deleted"`
+}
+
+type WriteSummaryResponse struct {
+	Users         WriteCountsResponse `json:"users"`
+	Products      WriteCountsResponse `json:"products"`
+	Orders        WriteCountsResponse `json:"orders"`
+	OrderProducts WriteCountsResponse `json:"order_products"`
+}
+
+type LineRejectionResponse struct {
+	Entry  string `json:"entry,omitempty"`
+	Line   int    `json:"line"`
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+type LineConflictResponse struct {
+	Entry    string `json:"entry,omitempty"`
+	Line     int    `json:"line"`
+	Type     string `json:"type"`
+	Field    string `json:"field"`
+	Value    string `json:"value"`
+	Existing string `json:"existing"`
+	Incoming string `json:"incoming"`
+	Policy   string `json:"policy"`
+	Reason   string `json:"reason"`
+}
+
+type OrderTotalResponse struct {
+	OrderID  uint           `json:"order_id"`
+	Previous entities.Money `json:"previous"`
+	Current  entities.Money `json:"current"`
+	Removed  bool           `json:"removed"`
+}
+
+type FileHeaderResponse struct {
+	Date          *time.Time `json:"date,omitempty"`
+	Sender        string     `json:"sender,omitempty"`
+	LayoutVersion string     `json:"layout_version,omitempty"`
+}
+
+type UserFileResultResponse struct {
+	Format         string                   `json:"format"`
+	DryRun         bool                     `json:"dry_run"`
+	Header         *FileHeaderResponse      `json:"header,omitempty"`
+	ProcessedLines int                      `json:"processed_lines"`
+	AcceptedLines  int                      `json:"accepted_lines"`
+	RejectedLines  int                      `json:"rejected_lines"`
+	SkippedLines   int                      `json:"skipped_lines"`
+	Rejections     []*LineRejectionResponse `json:"rejections"`
+	Conflicts      []*LineConflictResponse  `json:"conflicts"`
+	Writes         *WriteSummaryResponse    `json:"writes"`
+	OrderTotals    []*OrderTotalResponse    `json:"order_totals,omitempty"`
 }
 
+// UserFileResponse points to the jobs of an upload. JobID and StatusURL are only set
+// when a single file was sent
 type UserFileResponse struct {
-	Message        string `json:"message"`
-	ProcessedLines int    `json:"processed_lines"`
+	Message   string                  `json:"message"`
+	JobID     string                  `json:"job_id,omitempty"`
+	StatusURL string                  `json:"status_url,omitempty"`
+	Files     []*UploadedFileResponse `json:"files"`
+}
+
+// UploadedFileResponse is the job of a file of an upload, the job that already imported it
+// when the file was refused, or none when the file could not be queued
+type UploadedFileResponse struct {
+	FileName  string `json:"file_name"`
+	JobID     string `json:"job_id,omitempty"`
+	StatusURL string `json:"status_url,omitempty"`
+	Error     string `json:"error,omitempty"`
 }
 
 type Response struct {
@@ -31,3 +178,109 @@ func FromUserToResponse(user *entities.User) *Response {
 		Name: user.Name,
 	}
 }
+
+func FromUserFileResultToResponse(result *UserFileResult) *UserFileResultResponse {
+	res := &UserFileResultResponse{
+		Format:         string(result.Format),
+		DryRun:         result.DryRun,
+		Header:         FromFileHeaderToResponse(result.Header),
+		ProcessedLines: result.ProcessedLines,
+		AcceptedLines:  result.AcceptedLines,
+		RejectedLines:  result.RejectedLines,
+		SkippedLines:   result.SkippedLines,
+		Rejections:     make([]*LineRejectionResponse, 0),
+		Conflicts:      make([]*LineConflictResponse, 0),
+		Writes:         FromWriteSummaryToResponse(result.Writes),
+	}
+
+	for _, rejection := range result.Rejections {
+		res.Rejections = append(res.Rejections, FromLineRejectionToResponse(rejection))
+	}
+
+	for _, conflict := range result.Conflicts {
+		res.Conflicts = append(res.Conflicts, FromLineConflictToResponse(conflict))
+	}
+
+	res.OrderTotals = FromOrderTotalsToResponse(result.OrderTotals)
+
+	return res
+}
+
+// FromOrderTotalsToResponse returns nil when no order total was kept, as in full loads
+func FromOrderTotalsToResponse(totals []*entities.OrderTotal) []*OrderTotalResponse {
+	if len(totals) == 0 {
+		return nil
+	}
+
+	res := make([]*OrderTotalResponse, 0, len(totals))
+	for _, total := range totals {
+		res = append(res, &OrderTotalResponse{
+			OrderID:  total.OrderID,
+			Previous: total.Previous,
+			Current:  total.Current,
+			Removed:  total.Removed,
+		})
+	}
+
+	return res
+}
+
+// FromFileHeaderToResponse returns nil when the file had no header
+func FromFileHeaderToResponse(header *entities.FileHeader) *FileHeaderResponse {
+	if header == nil {
+		return nil
+	}
+
+	res := &FileHeaderResponse{
+		Sender:        header.Sender,
+		LayoutVersion: header.LayoutVersion,
+	}
+	if !header.Date.IsZero() {
+		date := header.Date
+		res.Date = &date
+	}
+
+	return res
+}
+
+func FromLineRejectionToResponse(rejection *entities.LineRejection) *LineRejectionResponse {
+	return &LineRejectionResponse{
+		Entry:  rejection.Entry,
+		Line:   rejection.Line,
+		Field:  rejection.Field,
+		Value:  rejection.Value,
+		Reason: rejection.Reason,
+	}
+}
+
+func FromLineConflictToResponse(conflict *entities.LineConflict) *LineConflictResponse {
+	return &LineConflictResponse{
+		Entry:    conflict.Entry,
+		Line:     conflict.Line,
+		Type:     string(conflict.Type),
+		Field:    conflict.Field,
+		Value:    conflict.Value,
+		Existing: conflict.Existing,
+		Incoming: conflict.Incoming,
+		Policy:   string(conflict.Policy),
+		Reason:   conflict.Reason,
+	}
+}
+
+func FromWriteSummaryToResponse(summary entities.WriteSummary) *WriteSummaryResponse {
+	toResponse := func(counts entities.WriteCounts) WriteCountsResponse {
+		return WriteCountsResponse{
+			Inserted:  counts.Inserted,
+			Updated:   counts.Updated,
+			Unchanged: counts.Unchanged,
+			Deleted:   counts.Deleted,
+		}
+	}
+
+	return &WriteSummaryResponse{
+		Users:         toResponse(summary.Users),
+		Products:      toResponse(summary.Products),
+		Orders:        toResponse(summary.Orders),
+		OrderProducts: toResponse(summary.OrderProducts),
+	}
+}
@@ -1,12 +1,77 @@
 package user
 
 import (
-	"mime/multipart"
+	"context"
+	"io"
+	"time"
 
 	"github.com/tulioguaraldob/luizalabs-logistica-challenge/internal/domain/entities"
 )
 
+// LoadConfig tunes how LoadUsersDataFile writes the parsed lines
+type LoadConfig struct {
+	// ChunkSize is how many lines are committed per transaction, zero commits the whole file at once
+	ChunkSize int
+	// BulkThreshold is the amount of valid lines from which the file is bulk loaded, zero disables it
+	BulkThreshold int
+	// MaxLineSize is the longest line, csv record or JSON array element, in bytes. Longer lines
+	// and records are rejected, a longer element fails its file. Zero allows up to 1 MiB
+	MaxLineSize int
+	// DryRunMaxRecords is how many users, products, orders and order lines a dry run remembers
+	// to preview the lines repeating them, a file with more fails. Zero remembers up to 1 million
+	DryRunMaxRecords int
+	// Workers is how many writers store the parsed lines concurrently, lines of the same order
+	// always go to the same writer so they keep their relative order
+	Workers int
+	// ConflictPolicies tells how the lines that disagree with the stored users and orders
+	// are stored, every conflict type without a policy is last-write-wins
+	ConflictPolicies entities.ConflictPolicies
+	// Location is the business timezone of the dates written without one, UTC when nil
+	Location *time.Location
+}
+
+// Mode is how the records of a file are applied to the stored data
+type Mode string
+
+const (
+	// ModeFull upserts every record, so a file only adds to or changes what is stored
+	ModeFull Mode = "full"
+	// ModeDelta applies to the stored order lines the operation every record carries, so a
+	// file may also remove lines and the orders left without any
+	ModeDelta Mode = "delta"
+	// ModeSnapshot takes a file as every order of a range of dates, so the stored orders of
+	// the range missing from the file are removed once the file is upserted
+	ModeSnapshot Mode = "snapshot"
+)
+
+// LoadOptions tells how a single file must be read
+type LoadOptions struct {
+	// Layout is the name of the registered record layout of fixed-width files
+	Layout string
+	// Format is the format of the file, detected from its content when empty or auto
+	Format Format
+	// Encoding is the character encoding of the file, UTF-8 when empty
+	Encoding Encoding
+	// Mode is how the records are applied, full when empty
+	Mode Mode
+	// SnapshotStart and SnapshotEnd are the range of order dates of a snapshot file, each a
+	// day or a RFC 3339 datetime, with the end included
+	SnapshotStart string
+	SnapshotEnd   string
+	// DryRun validates the file and previews its writes against the stored data without writing anything
+	DryRun bool
+	// BatchID and FileName identify where the lines come from in the conflicts they raise
+	BatchID  string
+	FileName string
+	// Size is the size of the file in bytes when known, used to estimate how long its load takes
+	Size int64
+}
+
 type Service interface {
 	GetUserByID(userId uint) (*entities.User, error)
-	LoadUsersDataFile(file multipart.File) int
+	LoadUsersDataFile(ctx context.Context, file io.Reader, opts LoadOptions) (*UserFileResult, error)
+	// ReprocessRejectedLines loads again the quarantined lines of the file of the batch opts
+	// name, read in the format and layout of opts
+	ReprocessRejectedLines(ctx context.Context, opts LoadOptions) (*UserFileResult, error)
+	ValidateLoadOptions(opts LoadOptions) error
 }